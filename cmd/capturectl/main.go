@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/capture"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/modules/replay"
+	capturestore "proxy-system-backend/internal/storage/capture"
+	pluginstore "proxy-system-backend/internal/storage/plugin"
+	"strings"
+	"time"
+)
+
+const usage = `capturectl 录制数据工具
+
+用法:
+  capturectl export [flags]          导出录制会话为 pcapng
+  capturectl import [flags] <file>   导入 pcap / pcapng，经解码插件输出 JSON
+  capturectl replay [flags]          回放录制会话并输出差异报告（有差异时退出码为 1）
+
+执行 capturectl <command> -h 查看参数
+`
+
+// errMismatch 回放响应与录制不一致（退出码 1，便于在 CI 中使用）
+var errMismatch = errors.New("replayed responses differ from the recording")
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	case "replay":
+		err = runReplay(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if errors.Is(err, errMismatch) {
+		log.Print(err)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// openStore 打开与服务端相同的 SQLite 索引和 segment 目录
+func openStore(dbPath, dir string) (*capture.Store, error) {
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	return openStoreDB(db, dir)
+}
+
+func openStoreDB(db *gorm.DB, dir string) (*capture.Store, error) {
+	if err := db.AutoMigrate(&capturestore.SessionModel{}, &capturestore.PacketModel{}); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return capture.NewStore(dir, capturestore.NewSQLiteRepo(db))
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", "data.db", "SQLite 数据库")
+	dir := fs.String("dir", "./data/capture", "segment 目录")
+	conns := fs.String("conn", "", "连接 ID，逗号分隔（为空时按 proxy/dst/domain 选择）")
+	proxyID := fs.String("proxy", "", "代理 ID")
+	dst := fs.String("dst", "", "目标地址 ip:port")
+	domain := fs.String("domain", "", "目标域名")
+	from := fs.String("from", "", "起始时间（RFC3339）")
+	to := fs.String("to", "", "结束时间（RFC3339）")
+	split := fs.String("split", "", "拆分方式：conn / time")
+	window := fs.Duration("window", 10*time.Minute, "split=time 时的窗口长度")
+	out := fs.String("o", "capture.pcapng", "输出文件；拆分时为输出目录")
+	_ = fs.Parse(args)
+
+	opts := capture.ExportOptions{
+		Query: capturestore.SessionQuery{
+			ProxyID: *proxyID,
+			Dst:     *dst,
+			Domain:  *domain,
+		},
+		Split: *split,
+	}
+	if *split == capture.SplitTime {
+		opts.Window = *window
+	}
+	for _, id := range strings.Split(*conns, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			opts.ConnIDs = append(opts.ConnIDs, id)
+		}
+	}
+	var err error
+	if opts.From, err = parseTime(*from); err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	if opts.To, err = parseTime(*to); err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	if !opts.To.IsZero() {
+		opts.Query.To = opts.To.UnixMilli()
+	}
+
+	store, err := openStore(*dbPath, *dir)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	n, err := store.Export(context.Background(), opts, func(name string) (io.Writer, error) {
+		path := *out
+		if opts.Split != capture.SplitNone {
+			if err := os.MkdirAll(*out, 0755); err != nil {
+				return nil, err
+			}
+			path = filepath.Join(*out, name)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		log.Printf("writing %s", path)
+		return f, nil
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no packets matched")
+	}
+	log.Printf("exported %d file(s)", n)
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPath := fs.String("db", "data.db", "SQLite 数据库（插件注册信息与录制索引）")
+	dir := fs.String("dir", "./data/capture", "segment 目录")
+	pluginName := fs.String("plugin", "", "解码插件名称（为空时使用 traffic_hook 配置）")
+	pluginPath := fs.String("plugin-path", "", "插件可执行文件；指定时不需要事先注册")
+	save := fs.Bool("save", false, "写入录制存储")
+	packets := fs.Bool("packets", true, "输出逐包解码结果")
+	out := fs.String("o", "", "JSON 输出文件（默认标准输出）")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: capturectl import [flags] <file.pcap|file.pcapng>")
+	}
+	// 插件系统和解码路径有调试输出，处理期间转到 stderr，保证标准输出只有 JSON
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+
+	appCore, cleanup, err := newApp(db, *pluginName, *pluginPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if *save {
+		store, err := openStoreDB(db, *dir)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		appCore.SetCaptureStore(store)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res, importErr := appCore.ImportPcap(f, app.ImportOptions{
+		Plugin:  *pluginName,
+		Save:    *save,
+		Packets: *packets,
+	})
+	if res == nil {
+		return importErr
+	}
+	if importErr != nil {
+		log.Printf("Warning: %v", importErr)
+	}
+
+	var w io.Writer = stdout
+	if *out != "" {
+		of, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer of.Close()
+		w = of
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		return err
+	}
+
+	log.Printf("imported %d frame(s), %d stream(s), %d skipped", res.Frames, len(res.Streams), res.Skipped)
+	return nil
+}
+
+// newApp 创建 App 并加载插件（数据库注册表中的插件，或 pluginPath 指定的可执行文件）
+func newApp(db *gorm.DB, pluginName, pluginPath string) (*app.App, func(), error) {
+	if pluginPath != "" && pluginName == "" {
+		pluginName = strings.TrimSuffix(filepath.Base(pluginPath), filepath.Ext(pluginPath))
+	}
+
+	appCore := app.New()
+
+	pluginMgr, err := plugin.InitializePluginSystem("")
+	if err != nil {
+		log.Printf("Warning: failed to initialize plugin system: %v", err)
+		pluginMgr = plugin.NewManager(nil)
+	}
+	if err := db.AutoMigrate(pluginstore.PluginModel{}); err != nil {
+		return nil, nil, fmt.Errorf("migrate: %w", err)
+	}
+	pluginSvc := app.NewPluginService(pluginstore.NewPluginRepo(db), pluginMgr)
+	if err := pluginSvc.Bootstrap(); err != nil {
+		return nil, nil, fmt.Errorf("plugin bootstrap: %w", err)
+	}
+	appCore.SetPluginMgr(pluginSvc)
+
+	if pluginName == "" {
+		return appCore, func() {}, nil
+	}
+	if pluginPath != "" {
+		if err := pluginMgr.Register(pluginName, pluginPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := pluginMgr.Load(pluginName); err != nil {
+		return nil, nil, fmt.Errorf("load plugin %s: %w", pluginName, err)
+	}
+	return appCore, func() { _ = pluginMgr.Unload(pluginName) }, nil
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dbPath := fs.String("db", "data.db", "SQLite 数据库")
+	dir := fs.String("dir", "./data/capture", "segment 目录")
+	connID := fs.String("conn", "", "录制会话的连接 ID")
+	target := fs.String("target", "", "目标地址 host:port（默认录制时的目标）")
+	mode := fs.String("mode", string(replay.ModeFast), "发送节奏：original / fast / step")
+	speed := fs.Float64("speed", 1, "original 模式的倍速")
+	pluginName := fs.String("plugin", "", "编解码插件（默认录制时的插件）")
+	pluginPath := fs.String("plugin-path", "", "插件可执行文件；指定时不需要事先注册")
+	cfgPath := fs.String("config", "", "回放配置 JSON（rewrite / ignore 等，命令行参数优先）")
+	out := fs.String("o", "", "报告输出文件（默认标准输出）")
+	_ = fs.Parse(args)
+
+	var cfg replay.Config
+	if *cfgPath != "" {
+		b, err := os.ReadFile(*cfgPath)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return fmt.Errorf("%s: %w", *cfgPath, err)
+		}
+	}
+	if *connID != "" {
+		cfg.ConnID = *connID
+	}
+	if *target != "" {
+		cfg.Target = *target
+	}
+	if *pluginName != "" {
+		cfg.Plugin = *pluginName
+	}
+	cfg.Mode = replay.Mode(*mode)
+	cfg.Speed = *speed
+	if cfg.ConnID == "" {
+		return fmt.Errorf("-conn is required")
+	}
+
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	appCore, cleanup, err := newApp(db, *pluginName, *pluginPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	store, err := openStoreDB(db, *dir)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	appCore.SetCaptureStore(store)
+
+	run, err := appCore.StartReplay(cfg)
+	if err != nil {
+		return err
+	}
+
+	// step 模式：每次回车放行一条请求
+	if cfg.Mode == replay.ModeStep {
+		go func() {
+			in := bufio.NewScanner(os.Stdin)
+			for {
+				if run.Report().Status == replay.StatusWaiting {
+					fmt.Fprint(os.Stderr, "press enter to send the next request ")
+					if !in.Scan() {
+						run.Cancel()
+						return
+					}
+					_ = run.Step()
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+	}
+
+	rep := run.Wait()
+
+	var w io.Writer = stdout
+	if *out != "" {
+		of, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer of.Close()
+		w = of
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		return err
+	}
+
+	log.Printf("replay %s: %d/%d exchange(s) matched", rep.Status, rep.Matched, rep.Total)
+	if rep.Status != replay.StatusDone {
+		return fmt.Errorf("replay %s: %s", rep.Status, rep.Error)
+	}
+	if rep.Mismatched > 0 {
+		return errMismatch
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -9,8 +10,13 @@ import (
 	"log"
 	"proxy-system-backend/internal/app"
 	"proxy-system-backend/internal/handler"
+	"proxy-system-backend/internal/modules/capture"
+	"proxy-system-backend/internal/modules/geoip"
+	"proxy-system-backend/internal/modules/mitm"
 	"proxy-system-backend/internal/modules/plugin"
 	"proxy-system-backend/internal/modules/websocket"
+	capturestore "proxy-system-backend/internal/storage/capture"
+	filterstore "proxy-system-backend/internal/storage/filter"
 	pluginstore "proxy-system-backend/internal/storage/plugin"
 
 	"time"
@@ -70,13 +76,67 @@ func main() {
 		return
 	}
 
+	// ===== 6️⃣ TLS 中间人根证书 =====
+	ca, err := mitm.LoadOrCreateAuthority("./data/mitm")
+	if err != nil {
+		log.Printf("Warning: mitm CA unavailable: %v", err)
+	} else {
+		appCore.SetMITMAuthority(ca)
+	}
+
+	// ===== 7️⃣ 录制存储 =====
+	db.AutoMigrate(&capturestore.SessionModel{}, &capturestore.PacketModel{})
+	captureStore, err := capture.NewStore("./data/capture", capturestore.NewSQLiteRepo(db))
+	if err != nil {
+		log.Printf("Warning: capture store unavailable: %v", err)
+	} else {
+		appCore.SetCaptureStore(captureStore)
+		defer captureStore.Close()
+	}
+
+	// ===== 8️⃣ 过滤规则 =====
+	db.AutoMigrate(&filterstore.RuleModel{}, &filterstore.RuleSetModel{}, &filterstore.RuleSetAssignmentModel{}, &filterstore.SettingsModel{})
+	ruleRepo := filterstore.NewSQLiteRepo(db)
+	filterSvc := app.NewFilterService(ruleRepo, appCore)
+	appCore.SetFilterService(filterSvc)
+	ruleSetSvc := app.NewRuleSetService(filterstore.NewSQLiteRuleSetRepo(db), ruleRepo, appCore)
+	appCore.SetRuleSetService(ruleSetSvc)
+	if err := ruleSetSvc.Reload(context.Background()); err != nil {
+		log.Printf("Warning: filter rule sets not loaded: %v", err)
+	}
+	if err := filterSvc.Reload(context.Background()); err != nil {
+		log.Printf("Warning: filter rules not loaded: %v", err)
+	}
+	defer appCore.StartFilterStats(5 * time.Second)()
+	defer appCore.StartFilterScheduler(time.Second)()
+
+	// ===== 9️⃣ GeoIP（可选）：把 .mmdb 文件放到目录中即可，修改后自动重新加载 =====
+	geoDB := geoip.NewDB("./data/geoip")
+	if s := geoDB.Reload(); len(s.Errors) > 0 {
+		log.Printf("Warning: geoip databases not loaded: %v", s.Errors)
+	}
+	appCore.SetGeoIP(geoDB)
+	defer appCore.StartGeoIPWatcher(30 * time.Second)()
+
 	// API
 	proxyHandler := handler.NewProxyHandler(appCore)
 	pluginHandler := handler.NewPluginHandler(appCore)
+	mitmHandler := handler.NewMITMHandler(appCore)
+	rewriteHandler := handler.NewRewriteHandler(appCore)
+	breakpointHandler := handler.NewBreakpointHandler(appCore)
+	connectionHandler := handler.NewConnectionHandler(appCore)
+	captureHandler := handler.NewCaptureHandler(appCore)
+	replayHandler := handler.NewReplayHandler(appCore)
+	mirrorHandler := handler.NewMirrorHandler(appCore)
+	filterHandler := handler.NewFilterHandler(appCore)
+	ruleSetHandler := handler.NewRuleSetHandler(appCore)
+	geoipHandler := handler.NewGeoIPHandler(appCore)
+	defer appCore.Mirrors().Close()
 
 	api := r.Group("/api")
 	{
 		api.POST("/proxy/start", proxyHandler.StartProxy)
+		api.GET("/proxies", proxyHandler.List)
 	}
 	plugins := api.Group("/plugins")
 	{
@@ -87,6 +147,85 @@ func main() {
 		plugins.POST("/:name/unload", pluginHandler.Unload)
 		plugins.POST("/upload", pluginHandler.Upload)
 	}
+	mitmGroup := api.Group("/mitm")
+	{
+		mitmGroup.GET("/ca", mitmHandler.CAInfo)
+		mitmGroup.GET("/ca.crt", mitmHandler.DownloadCA)
+	}
+	rewriteGroup := api.Group("/rewrite/rules")
+	{
+		rewriteGroup.GET("", rewriteHandler.List)
+		rewriteGroup.POST("", rewriteHandler.Create)
+		rewriteGroup.PUT("/:id", rewriteHandler.Update)
+		rewriteGroup.DELETE("/:id", rewriteHandler.Delete)
+	}
+	breakpoints := api.Group("/breakpoints")
+	{
+		breakpoints.GET("/config", breakpointHandler.GetConfig)
+		breakpoints.PUT("/config", breakpointHandler.SetConfig)
+		breakpoints.GET("", breakpointHandler.ListRules)
+		breakpoints.POST("", breakpointHandler.SaveRule)
+		breakpoints.PUT("/:id", breakpointHandler.SaveRule)
+		breakpoints.DELETE("/:id", breakpointHandler.DeleteRule)
+	}
+	intercept := api.Group("/intercept")
+	{
+		intercept.GET("/pending", breakpointHandler.Pending)
+		intercept.POST("/:id/resolve", breakpointHandler.Resolve)
+	}
+	connections := api.Group("/connections")
+	{
+		connections.GET("", connectionHandler.List)
+		connections.POST("/:connID/inject", connectionHandler.Inject)
+	}
+	captureGroup := api.Group("/capture")
+	{
+		captureGroup.GET("/sessions", captureHandler.ListSessions)
+		captureGroup.GET("/sessions/:connID", captureHandler.GetSession)
+		captureGroup.GET("/sessions/:connID/packets", captureHandler.ListPackets)
+		captureGroup.GET("/export", captureHandler.Export)
+		captureGroup.POST("/import", captureHandler.Import)
+	}
+	replays := api.Group("/replay")
+	{
+		replays.POST("", replayHandler.Start)
+		replays.GET("", replayHandler.List)
+		replays.GET("/:id", replayHandler.Get)
+		replays.POST("/:id/step", replayHandler.Step)
+		replays.POST("/:id/cancel", replayHandler.Cancel)
+	}
+	api.GET("/mirror/stats", mirrorHandler.Stats)
+	filterRules := api.Group("/filter/rules")
+	{
+		filterRules.GET("", filterHandler.ListRules)
+		filterRules.POST("", filterHandler.CreateRule)
+		filterRules.POST("/reorder", filterHandler.Reorder)
+		filterRules.POST("/import", filterHandler.Import)
+		filterRules.GET("/:id", filterHandler.GetRule)
+		filterRules.PUT("/:id", filterHandler.UpdateRule)
+		filterRules.DELETE("/:id", filterHandler.DeleteRule)
+		filterRules.POST("/:id/enable", filterHandler.EnableRule)
+		filterRules.POST("/:id/disable", filterHandler.DisableRule)
+	}
+	ruleSets := api.Group("/filter/sets")
+	{
+		ruleSets.GET("", ruleSetHandler.List)
+		ruleSets.POST("", ruleSetHandler.Create)
+		ruleSets.GET("/:name", ruleSetHandler.Get)
+		ruleSets.PUT("/:name", ruleSetHandler.Update)
+		ruleSets.DELETE("/:name", ruleSetHandler.Delete)
+	}
+	api.GET("/filter/export", filterHandler.Export)
+	api.POST("/filter/import", filterHandler.ImportFile)
+	api.GET("/filter/config", filterHandler.GetConfig)
+	api.PUT("/filter/config", filterHandler.UpdateConfig)
+	api.POST("/filter/migrate", filterHandler.Migrate)
+	api.GET("/filter/stats", filterHandler.Stats)
+	api.POST("/filter/stats/reset", filterHandler.ResetStats)
+	api.POST("/filter/evaluate", filterHandler.Evaluate)
+	api.GET("/geoip", geoipHandler.Status)
+	api.POST("/geoip/reload", geoipHandler.Reload)
+	api.GET("/geoip/lookup", geoipHandler.Lookup)
 	// ===== 5️⃣ Start =====
 	addr := ":8081"
 	log.Println("🚀 server listening on", addr)
@@ -3,14 +3,23 @@ package app
 import (
 	"fmt"
 	"net"
+	"proxy-system-backend/internal/modules/breakpoint"
+	"proxy-system-backend/internal/modules/capture"
 	"proxy-system-backend/internal/modules/filter"
+	"proxy-system-backend/internal/modules/geoip"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/modules/mitm"
 	"proxy-system-backend/internal/modules/plugin"
 	"proxy-system-backend/internal/modules/proxy"
+	"proxy-system-backend/internal/modules/redirect"
+	"proxy-system-backend/internal/modules/replay"
+	"proxy-system-backend/internal/modules/rewrite"
 	"proxy-system-backend/internal/modules/shadowsocks"
 	"proxy-system-backend/internal/modules/shared"
 	"proxy-system-backend/internal/traffic"
 
 	"sync"
+	"sync/atomic"
 )
 
 type App struct {
@@ -18,16 +27,34 @@ type App struct {
 	listeners    []func(Event)
 	proxyMgr     *ProxyManager
 	filterEngine *filter.Engine
+	filterSvc    *FilterService
+	ruleSetSvc   *RuleSetService
+	ruleSets     atomic.Pointer[ruleSetAssignments]
 	pluginMgr    *PluginService
+	mitmCA       *mitm.Authority
+	rewriter     *rewrite.Engine
+	breakpoints  *breakpoint.Manager
+	captureStore *capture.Store
+	replays      *replay.Manager
+	mirrors      *mirror.Manager
+	geoip        *geoip.DB
 }
 
 func New() *App {
-	return &App{
+	a := &App{
 		proxyMgr:     NewProxyManager(),
 		listeners:    make([]func(Event), 0),
 		filterEngine: filter.NewEngine(),
+		rewriter:     rewrite.NewEngine(),
+		breakpoints:  breakpoint.NewManager(),
+		replays:      replay.NewManager(),
+		mirrors:      mirror.NewManager(),
 		//pluginMgr :NewPluginService(),
 	}
+	a.ruleSets.Store(newRuleSetAssignments(nil))
+	a.breakpoints.OnEvent(a.emitBreakpointHit, a.emitBreakpointResolved)
+	a.replays.OnUpdate(a.emitReplay)
+	return a
 }
 
 // Subscribe 订阅事件（websocket / logger / metrics 都可以）
@@ -58,8 +85,12 @@ func (a *App) StartProxy(cfg proxy.Config) error {
 		return err
 	}
 	fmt.Println(cfg.ListenAddr)
-	// 2️⃣ proxy 实例 ID（不是 connID）
-	proxyID := shared.GenerateConnID()
+	// 2️⃣ proxy 实例 ID（不是 connID），未指定时生成
+	proxyID := cfg.ID
+	if proxyID == "" {
+		proxyID = shared.GenerateConnID()
+		cfg.ID = proxyID
+	}
 
 	// 3️⃣ 自动加载配置的插件
 	if a.pluginMgr != nil {
@@ -94,6 +125,33 @@ func (a *App) StartProxy(cfg proxy.Config) error {
 	if err != nil {
 		return err
 	}
+	// 出站：直连或用录制会话 mock，目标域名都按代理的 DNS 配置解析
+	direct, err := a.newDNSDialer(proxyID, cfg.DNS)
+	if err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("proxy %s dns: %w", cfg.ID, err)
+	}
+	var dialer shadowsocks.Dialer = direct
+	switch cfg.Outbound {
+	case "", proxy.OutboundDirect:
+	case proxy.OutboundMock:
+		md, err := a.newMockDialer(proxyID, cfg.Mock, direct.DialContext)
+		if err != nil {
+			_ = ln.Close()
+			return fmt.Errorf("proxy %s mock outbound: %w", cfg.ID, err)
+		}
+		dialer = md
+	default:
+		_ = ln.Close()
+		return fmt.Errorf("proxy %s: unknown outbound %q", cfg.ID, cfg.Outbound)
+	}
+
+	redirects, err := redirect.NewTable(cfg.Redirect)
+	if err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("proxy %s: %w", cfg.ID, err)
+	}
+
 	var sf *SimpleFilter
 	if len(cfg.BlockIPs) > 0 || len(cfg.BlockPorts) > 0 {
 		sf, err = NewSimpleFilter(cfg.BlockIPs, cfg.BlockPorts)
@@ -112,35 +170,101 @@ func (a *App) StartProxy(cfg proxy.Config) error {
 	server := shadowsocks.NewServer(
 		ln,
 		c,
-		DefaultDirectDialer(),
+		dialer,
 		func(connID string) traffic.TrafficHook {
-			hook := a.newTrafficHook(proxyID, connID, sf)
+			hook := a.newTrafficHook(proxyID, connID, sf, cfg)
 
 			return hook
 		},
 	)
 
-	// 6️⃣ 交给 proxyMgr 管理生命周期
-	return a.proxyMgr.StartProxy(proxyID, server)
+	server.SetRedirector(&proxyRedirector{app: a, proxyID: proxyID, cfg: cfg, table: redirects})
+
+	// 6️⃣ TLS 中间人（按代理配置的域名或 filter 规则 action=mitm 选择）
+	if a.mitmCA != nil {
+		server.SetInterceptor(mitm.NewInterceptor(a.mitmCA, cfg.MITM, a.mitmMatcher(cfg)))
+	} else if cfg.MITM.Enabled {
+		_ = ln.Close()
+		return fmt.Errorf("proxy %s enables mitm but no CA is configured", cfg.ID)
+	}
+
+	// 7️⃣ 交给 proxyMgr 管理生命周期
+	return a.proxyMgr.StartProxy(proxyID, cfg, server)
+}
+
+// Proxies 运行中的代理及其适用的规则集
+func (a *App) Proxies() []ProxyInfo {
+	infos, cfgs := a.proxyMgr.Proxies()
+	for i := range infos {
+		infos[i].RuleSets = a.RuleSetsFor(cfgs[i])
+		if infos[i].RuleSets == nil {
+			infos[i].RuleSets = []string{}
+		}
+	}
+	return infos
 }
 
-func (a *App) newTrafficHook(proxyID, connID string, sf *SimpleFilter) traffic.TrafficHook {
+func (a *App) newTrafficHook(proxyID, connID string, sf *SimpleFilter, cfg proxy.Config) traffic.TrafficHook {
 	return &proxyTrafficHook{
 		app:          a,
 		proxyID:      proxyID,
 		connID:       connID,
 		simpleFilter: sf,
+		proxyCfg:     cfg,
+	}
+}
+
+// mitmMatcher 按代理适用的规则集匹配 action=mitm 的规则
+func (a *App) mitmMatcher(cfg proxy.Config) func(ctx *traffic.PacketContext) bool {
+	return func(ctx *traffic.PacketContext) bool {
+		ctx.RuleSets = a.RuleSetsFor(cfg)
+		a.lookupGeo(ctx)
+		return a.filterEngine.Decide(ctx).MITM
 	}
 }
+
 func (a *App) FilterEngine() *filter.Engine {
 	return a.filterEngine
 }
+func (a *App) SetFilterService(s *FilterService) {
+	a.filterSvc = s
+}
+func (a *App) FilterService() *FilterService {
+	return a.filterSvc
+}
+func (a *App) SetRuleSetService(s *RuleSetService) {
+	a.ruleSetSvc = s
+}
+func (a *App) RuleSets() *RuleSetService {
+	return a.ruleSetSvc
+}
+func (a *App) Rewriter() *rewrite.Engine {
+	return a.rewriter
+}
+func (a *App) Breakpoints() *breakpoint.Manager {
+	return a.breakpoints
+}
+func (a *App) SetCaptureStore(s *capture.Store) {
+	a.captureStore = s
+}
+func (a *App) CaptureStore() *capture.Store {
+	return a.captureStore
+}
+func (a *App) Mirrors() *mirror.Manager {
+	return a.mirrors
+}
 func (a *App) SetPluginMgr(p *PluginService) {
 	a.pluginMgr = p
 }
 func (a *App) PluginMgr() *PluginService {
 	return a.pluginMgr
 }
+func (a *App) SetMITMAuthority(ca *mitm.Authority) {
+	a.mitmCA = ca
+}
+func (a *App) MITMAuthority() *mitm.Authority {
+	return a.mitmCA
+}
 func (a *App) GetPluginManager() *plugin.Manager {
 	if a.pluginMgr != nil {
 		return a.pluginMgr.GetPluginManager()
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/modules/breakpoint"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/traffic"
+)
+
+// pause 命中断点时挂起当前 pipe，按操作员的决定放行 / 丢弃 / 修改 ctx.Payload
+// 只阻塞当前连接的当前方向
+func (h *proxyTrafficHook) pause(ctx *traffic.PacketContext, pluginName string, decoded *plugin.DecodeResult) {
+	bps := h.app.Breakpoints()
+	if bps == nil || h.offline {
+		return
+	}
+	rule := bps.Match(ctx)
+	if rule == nil {
+		return
+	}
+
+	held := &breakpoint.Held{
+		ProxyID:   h.proxyID,
+		ConnID:    h.connID,
+		Direction: ctx.Direction,
+		Domain:    ctx.Domain,
+		Src:       addrString(ctx.SrcAddr),
+		Dst:       addrString(ctx.DstAddr),
+		Payload:   bytes.Clone(ctx.Payload),
+		Plugin:    pluginName,
+	}
+	if decoded != nil {
+		held.Decoded = decoded.Data
+	}
+
+	d := bps.Hold(held, rule)
+
+	switch d.Action {
+	case breakpoint.ActionDrop:
+		ctx.Payload = nil
+	case breakpoint.ActionEdit:
+		edited, err := h.editedPayload(pluginName, d)
+		if err != nil {
+			// 修改失败时放行原始数据，避免连接卡死
+			fmt.Printf("[Breakpoint] edit %s failed, forwarding original: %v\n", held.ID, err)
+			return
+		}
+		ctx.Payload = edited
+	}
+}
+
+func (h *proxyTrafficHook) editedPayload(pluginName string, d breakpoint.Decision) ([]byte, error) {
+	if d.Hex != "" {
+		return hex.DecodeString(d.Hex)
+	}
+	return h.encodeWithPlugin(pluginName, d.JSON, nil)
+}
+
+func (a *App) emitBreakpointHit(held *breakpoint.Held) {
+	a.Emit(Event{
+		Type: EventBreakpointHit,
+		Data: map[string]any{
+			"held":        held,
+			"payload_hex": hex.EncodeToString(held.Payload),
+		},
+	})
+}
+
+func (a *App) emitBreakpointResolved(held *breakpoint.Held, d breakpoint.Decision) {
+	a.Emit(Event{
+		Type: EventBreakpointResolved,
+		Data: map[string]any{
+			"id":        held.ID,
+			"conn_id":   held.ConnID,
+			"action":    d.Action,
+			"timed_out": d.TimedOut,
+		},
+	})
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"proxy-system-backend/internal/modules/capture"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/traffic"
+)
+
+// record 录制最终转发的数据包（改包 / 断点之后）
+func (h *proxyTrafficHook) record(ctx *traffic.PacketContext, pluginName string, decoded *plugin.DecodeResult) {
+	if h.tap != nil {
+		h.tap(ctx, pluginName, decoded)
+	}
+	h.mirrorPacket(ctx)
+
+	store := h.app.CaptureStore()
+	if store == nil {
+		return
+	}
+
+	h.syncCapture(ctx, store)
+	if !h.capturing.Load() || len(ctx.Payload) == 0 {
+		return
+	}
+
+	rec := capture.Record{
+		Time:      ctx.Time,
+		ConnID:    h.connID,
+		Direction: ctx.Direction,
+		Injected:  ctx.Injected,
+		Payload:   ctx.Payload,
+	}
+	if decoded != nil {
+		rec.Plugin = pluginName
+		rec.Decoded = decoded.Data
+	}
+	store.Record(h.proxyID, rec)
+}
+
+// syncCapture 按当前的连接级结果开始或结束录制：代理开启录制或命中 action=capture 的规则时录制，
+// 规则修改后不再命中时结束会话、重新命中时继续同一会话；解码结果规则触发的录制持续到连接结束
+func (h *proxyTrafficHook) syncCapture(ctx *traffic.PacketContext, store *capture.Store) {
+	d := h.connDecision(ctx)
+	byConn := h.proxyCfg.Capture || d.Capture
+	if (byConn || h.captureRequested.Load()) == h.capturing.Load() {
+		return
+	}
+
+	h.captureMu.Lock()
+	defer h.captureMu.Unlock()
+	switch {
+	case byConn:
+		h.startCapture(ctx, store, d.Tags)
+	case h.captureRequested.Load():
+		// 解码结果规则命中 capture：从当前数据包开始录制
+		h.startCapture(ctx, store, ctx.Tags)
+	case h.capturing.Load():
+		h.capturing.Store(false)
+		store.EndSession(h.connID)
+	}
+}
+
+// startCapture 开始录制，调用方持有 captureMu
+func (h *proxyTrafficHook) startCapture(ctx *traffic.PacketContext, store *capture.Store, tags []string) {
+	if h.capturing.Load() {
+		return
+	}
+	sess := sessionFromCtx(h.proxyID, ctx)
+	sess.Tags = tags
+	sess.Resume = h.captured
+	if h.offline {
+		sess.Source = "import"
+	}
+	store.StartSession(sess)
+	h.captured = true
+	h.capturing.Store(true)
+}
+
+// OnClose 连接结束，关闭录制会话
+func (h *proxyTrafficHook) OnClose() {
+	h.closeMirror()
+	h.captureMu.Lock()
+	defer h.captureMu.Unlock()
+	if store := h.app.CaptureStore(); store != nil && h.capturing.Load() {
+		store.EndSession(h.connID)
+	}
+}
+
+// sessionFromCtx 以客户端 → 服务器的视角生成会话信息
+func sessionFromCtx(proxyID string, ctx *traffic.PacketContext) capture.Session {
+	client, dst := ctx.SrcAddr, ctx.DstAddr
+	if ctx.Direction == traffic.DirectionIn {
+		client, dst = ctx.DstAddr, ctx.SrcAddr
+	}
+
+	return capture.Session{
+		ConnID:    ctx.ConnID,
+		ProxyID:   proxyID,
+		Protocol:  ctx.Protocol.String(),
+		Client:    addrString(client),
+		Dst:       addrString(dst),
+		Domain:    ctx.Domain,
+		StartedAt: ctx.StartAt,
+	}
+}
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"proxy-system-backend/internal/modules/dns"
+)
+
+// newDNSDialer 直连出站：目标域名按代理的 DNS 配置解析，每次解析推送 EventDNSResolved
+func (a *App) newDNSDialer(proxyID string, cfg dns.Config) (*dns.Dialer, error) {
+	r, err := dns.NewResolver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.OnResolve(func(res dns.Resolution) {
+		a.Emit(Event{
+			Type: EventDNSResolved,
+			Data: map[string]any{
+				"proxy_id":   proxyID,
+				"resolution": res,
+			},
+		})
+	})
+	return dns.NewDialer(r, DefaultDirectDialer().DialContext), nil
+}
@@ -9,6 +9,22 @@ const (
 	EventPluginLoaded EventType = "plugin_loaded"
 	EventTraffic      EventType = "EventTraffic"
 	EventParsed       EventType = "EventParsed"
+	EventRewritten    EventType = "EventRewritten"
+
+	EventBreakpointHit      EventType = "EventBreakpointHit"
+	EventBreakpointResolved EventType = "EventBreakpointResolved"
+	EventReplay             EventType = "EventReplay"
+	EventMockMiss           EventType = "EventMockMiss"
+	EventFilterAlert        EventType = "EventFilterAlert"
+	EventFilterStats        EventType = "EventFilterStats"
+	// 规则集或其分配变化，附带各代理当前适用的规则集
+	EventRuleSetUpdated EventType = "rule_set_updated"
+	// GeoIP 数据库重新加载，附带 geoip.Status
+	EventGeoIPReloaded EventType = "geoip_reloaded"
+	// 出站域名解析（包括失败），附带 proxy_id 与 dns.Resolution
+	EventDNSResolved EventType = "dns_resolved"
+	// 连接被重定向到其它目标
+	EventRedirected EventType = "redirected"
 )
 
 type Event struct {
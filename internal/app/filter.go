@@ -0,0 +1,174 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/filter"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/traffic"
+	"slices"
+)
+
+// connDecision 连接级过滤结果：首个数据包时求值，之后规则（引擎版本）、规则集分配或 GeoIP 数据库
+// 变化时在下一个数据包重新求值，使修改对已建立的连接生效；结果变化时才再次告警
+func (h *proxyTrafficHook) connDecision(ctx *traffic.PacketContext) filter.Decision {
+	gen := h.app.FilterEngine().Generation()
+	geo := h.app.geoGeneration()
+	sets := h.app.ruleSets.Load()
+
+	h.decisionMu.Lock()
+	defer h.decisionMu.Unlock()
+	if h.decided && (h.offline || h.decisionGen == gen && h.decisionGeo == geo && h.decisionSets == sets) {
+		return h.decision
+	}
+
+	// 统计只计入每个连接的首次求值，重新求值不重复计数
+	d := h.evaluateConn(ctx, !h.decided)
+	changed := !h.decided || d.Verdict != h.decision.Verdict || !slices.Equal(d.Rules, h.decision.Rules)
+	h.decided, h.decision, h.decisionGen, h.decisionGeo, h.decisionSets = true, d, gen, geo, sets
+	if d.Alert && changed {
+		h.alert(ctx, "connection", d, "", nil)
+	}
+	return d
+}
+
+// currentRuleSets 连接适用的命名规则集，分配快照变化时重新解析
+func (h *proxyTrafficHook) currentRuleSets() []string {
+	if h.offline {
+		return nil
+	}
+	sets := h.app.ruleSets.Load()
+
+	h.ruleSetsMu.Lock()
+	defer h.ruleSetsMu.Unlock()
+	if h.ruleSetsFrom != sets {
+		h.ruleSets = sets.resolve(h.proxyCfg)
+		h.ruleSetsFrom = sets
+	}
+	return h.ruleSets
+}
+
+// decide 连接级结果叠加限定方向的逐包规则
+func (h *proxyTrafficHook) decide(ctx *traffic.PacketContext) filter.Decision {
+	d := h.connDecision(ctx)
+	if h.offline || !h.proxyCfg.EnableFilter {
+		return d
+	}
+	p := h.app.FilterEngine().EvaluatePacket(ctx)
+	if p.Alert {
+		h.alert(ctx, "packet", p, "", nil)
+	}
+	return d.Override(p)
+}
+
+// decideDecoded 插件解码成功后匹配带解码结果条件的规则，
+// 处理 log / alert / tag / capture，终结动作和触发的改包规则由调用方处理
+func (h *proxyTrafficHook) decideDecoded(ctx *traffic.PacketContext, pluginName string, decoded *plugin.DecodeResult) filter.Decision {
+	engine := h.app.FilterEngine()
+	if h.offline || !engine.HasDecodedRules() {
+		return filter.Decision{}
+	}
+
+	d := engine.EvaluateDecoded(ctx, decoded.Data)
+	if len(d.Rules) == 0 {
+		return d
+	}
+	engine.Account(d, len(ctx.Payload))
+	if !h.proxyCfg.EnableFilter {
+		// 未开启过滤时只用于告警和录制
+		d = filter.Decision{Rules: d.Rules, Alert: d.Alert, Capture: d.Capture}
+	}
+
+	if d.Log {
+		h.logDecision(ctx, d)
+	}
+	if d.Alert {
+		h.alert(ctx, "decoded", d, pluginName, decoded)
+	}
+	if d.Capture {
+		h.captureRequested.Store(true)
+	}
+	if len(d.Tags) > 0 {
+		tags := slices.Clone(ctx.Tags)
+		for _, t := range d.Tags {
+			if !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		ctx.Tags = tags
+	}
+	return d
+}
+
+func (h *proxyTrafficHook) evaluateConn(ctx *traffic.PacketContext, count bool) filter.Decision {
+	if h.offline {
+		return filter.Decision{Verdict: filter.ActionAllow}
+	}
+	// block_ips / block_ports 优先于规则
+	if h.simpleFilter != nil && !h.simpleFilter.Match(ctx) {
+		return filter.Decision{Verdict: filter.ActionDeny}
+	}
+
+	engine := h.app.FilterEngine()
+	d := engine.Decide(ctx)
+	if count {
+		engine.Count(d)
+	}
+	if !h.proxyCfg.EnableFilter {
+		// 未开启过滤时，规则只用于选择录制 / 中间人 / 镜像 / 告警的连接
+		return filter.Decision{
+			Verdict: filter.ActionAllow,
+			Rules:   d.Rules,
+			Capture: d.Capture,
+			MITM:    d.MITM,
+			Mirror:  d.Mirror,
+			Alert:   d.Alert,
+		}
+	}
+	return d
+}
+
+// logDecision action=log 命中时输出
+func (h *proxyTrafficHook) logDecision(ctx *traffic.PacketContext, d filter.Decision) {
+	view := ctx.ClientView()
+	fmt.Printf("[Filter] proxy=%s conn=%s %s %s -> %s len=%d verdict=%s rules=%v tags=%v\n",
+		h.proxyID, h.connID, ctx.Direction, addrString(view.SrcAddr), addrString(view.DstAddr),
+		len(ctx.Payload), d.Verdict, d.Rules, d.Tags)
+}
+
+// alert action=alert 命中时推送告警事件，stage 为 connection / packet / decoded
+func (h *proxyTrafficHook) alert(ctx *traffic.PacketContext, stage string, d filter.Decision, pluginName string, decoded *plugin.DecodeResult) {
+	view := ctx.ClientView()
+	data := map[string]any{
+		"proxy_id":   h.proxyID,
+		"conn_id":    h.connID,
+		"stage":      stage,
+		"direction":  ctx.Direction.String(),
+		"client":     addrString(view.SrcAddr),
+		"dst":        addrString(view.DstAddr),
+		"client_geo": view.SrcGeo,
+		"dst_geo":    view.DstGeo,
+		"domain":     ctx.Domain,
+		"rules":      d.Rules,
+		"tags":       d.Tags,
+		"time":       ctx.Time,
+	}
+	if decoded != nil {
+		data["plugin"] = pluginName
+		data["decoded"] = decoded.Data
+	}
+	if ctx.FilterTrace != nil {
+		data["filter_trace"] = ctx.FilterTrace
+	}
+	h.app.Emit(Event{Type: EventFilterAlert, Data: data})
+}
+
+// traceFilter 代理开启 trace_filter 时，把规则求值过程附在数据包上，
+// 随 EventTraffic / EventFilterAlert 推送；不受 enable_filter 影响，也不计入统计
+func (h *proxyTrafficHook) traceFilter(ctx *traffic.PacketContext, decoded json.RawMessage) {
+	if h.offline || !h.proxyCfg.TraceFilter {
+		return
+	}
+	t := h.app.FilterEngine().Trace(ctx, decoded)
+	ctx.FilterTrace = &t
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	"proxy-system-backend/internal/modules/filter"
@@ -29,6 +30,9 @@ func (l *FilterLoader) Load(ctx context.Context) error {
 	var compiled []*filter.CompiledRule
 
 	for _, m := range models {
+		if !m.Enabled || m.Expired {
+			continue
+		}
 		rule, err := ModelToRule(m)
 		if err != nil {
 			log.Println("rule parse failed:", err)
@@ -48,3 +52,23 @@ func (l *FilterLoader) Load(ctx context.Context) error {
 	log.Printf("✅ filter rules loaded: %d\n", len(compiled))
 	return nil
 }
+
+// compileModels 编译启用且未过期的规则，任何一条失败都返回错误
+func compileModels(models []filterstore.RuleModel) ([]*filter.CompiledRule, error) {
+	var compiled []*filter.CompiledRule
+	for _, m := range models {
+		if !m.Enabled || m.Expired {
+			continue
+		}
+		rule, err := ModelToRule(m)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", m.ID, err)
+		}
+		cr, err := filter.CompileRule(*rule)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, cr)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/filter"
+	filterstore "proxy-system-backend/internal/storage/filter"
+	"proxy-system-backend/internal/types"
+	"strconv"
+	"strings"
+)
+
+// LegacyMigration 旧版 packet.PacketFilter 规则迁移结果
+type LegacyMigration struct {
+	DryRun bool `json:"dry_run"`
+
+	Found           int            `json:"found"`
+	Migrated        []MigratedRule `json:"migrated"`
+	Skipped         []SkippedRule  `json:"skipped"`
+	AlreadyMigrated []int          `json:"already_migrated"` // 已有 legacy:<id> 标签的旧规则
+	LegacyEnabled   bool           `json:"legacy_enabled"`
+	DefaultAction   filter.Action  `json:"default_action"`
+	Notes           []string       `json:"notes,omitempty"`
+}
+
+type MigratedRule struct {
+	LegacyID int      `json:"legacy_id"`
+	RuleID   int64    `json:"rule_id,omitempty"` // dry_run 时为 0
+	Name     string   `json:"name"`
+	Notes    []string `json:"notes,omitempty"`
+}
+
+type SkippedRule struct {
+	LegacyID int    `json:"legacy_id"`
+	Name     string `json:"name"`
+	Reason   string `json:"reason"`
+}
+
+// MigrateLegacy 把旧版 filter_rules 表和 filter_config.rules 中的规则转换后追加到规则库，
+// 同 ID 的规则以表中的为准；已迁移过的（带 legacy:<id> 标签）跳过，无法转换的列入 Skipped。
+// 旧版过滤未启用时迁移的规则默认停用。规则和默认动作在同一事务中写入
+func (s *FilterService) MigrateLegacy(ctx context.Context, src *filterstore.LegacyRepo, dryRun bool) (LegacyMigration, error) {
+	report := LegacyMigration{DryRun: dryRun, Migrated: []MigratedRule{}, Skipped: []SkippedRule{}, AlreadyMigrated: []int{}}
+
+	cfg, err := src.Config(ctx)
+	if err != nil {
+		return report, err
+	}
+	legacy, err := readLegacyRules(ctx, src, cfg, &report)
+	if err != nil {
+		return report, err
+	}
+	current, err := s.Config(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.DefaultAction = current.DefaultAction
+	report.LegacyEnabled = true
+	if cfg != nil {
+		report.LegacyEnabled = cfg.Enabled
+		switch a := filter.Action(cfg.DefaultAction); {
+		case !cfg.Enabled:
+			// 旧版过滤未启用时所有连接都被允许
+			report.DefaultAction = filter.ActionAllow
+			report.Notes = append(report.Notes, "legacy filter was disabled: default action set to allow and migrated rules are disabled")
+		case a == filter.ActionAllow || a == filter.ActionDeny:
+			report.DefaultAction = a
+		default:
+			report.Notes = append(report.Notes, fmt.Sprintf("legacy default action %q is not supported, keeping %s", cfg.DefaultAction, current.DefaultAction))
+		}
+	}
+
+	migrated, err := s.migratedLegacyIDs(ctx)
+	if err != nil {
+		return report, err
+	}
+	var rules []filter.Rule
+	for _, l := range legacy {
+		if migrated[l.ID] {
+			report.AlreadyMigrated = append(report.AlreadyMigrated, l.ID)
+			continue
+		}
+		r, notes, err := filter.ConvertLegacyRule(l)
+		if err == nil {
+			_, err = filter.CompileRule(r)
+		}
+		if err != nil {
+			report.Skipped = append(report.Skipped, SkippedRule{LegacyID: l.ID, Name: l.Name, Reason: err.Error()})
+			continue
+		}
+		if !report.LegacyEnabled && r.Enabled {
+			r.Enabled = false
+			notes = append(notes, "disabled because the legacy filter was disabled")
+		}
+		rules = append(rules, r)
+		report.Migrated = append(report.Migrated, MigratedRule{LegacyID: l.ID, Name: r.Name, Notes: notes})
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	err = s.apply(ctx, "migrate", func(repo filterstore.RuleRepository) ([]int64, error) {
+		ids := make([]int64, 0, len(rules))
+		for i, r := range rules {
+			m := RuleToModel(r)
+			if err := repo.Save(ctx, &m); err != nil {
+				return nil, err
+			}
+			report.Migrated[i].RuleID = m.ID
+			ids = append(ids, m.ID)
+		}
+		settings, err := repo.Settings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		settings.DefaultAction = string(report.DefaultAction)
+		return ids, repo.SaveSettings(ctx, settings)
+	})
+	if err != nil {
+		return report, err
+	}
+	return report, s.app.FilterEngine().SetDefaultAction(report.DefaultAction)
+}
+
+// readLegacyRules 合并 filter_rules 表和 filter_config.rules，按旧规则 ID 去重（表中的优先）；
+// JSON 无法解析的行直接列入 Skipped
+func readLegacyRules(ctx context.Context, src *filterstore.LegacyRepo, cfg *filterstore.LegacyConfigModel, report *LegacyMigration) ([]filter.LegacyRule, error) {
+	rows, err := src.Rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []filter.LegacyRule
+	seen := map[int]bool{}
+	for _, m := range rows {
+		l := filter.LegacyRule{
+			ID:          int(m.ID),
+			Name:        m.Name,
+			Action:      types.FilterAction(m.Action),
+			Direction:   types.FilterDirection(m.Direction),
+			Enabled:     m.Enabled,
+			Priority:    m.Priority,
+			Description: m.Description,
+		}
+		seen[l.ID] = true
+		report.Found++
+		if err := unmarshalLegacyColumns(m, &l); err != nil {
+			report.Skipped = append(report.Skipped, SkippedRule{LegacyID: l.ID, Name: l.Name, Reason: err.Error()})
+			continue
+		}
+		out = append(out, l)
+	}
+
+	if cfg != nil && strings.TrimSpace(cfg.Rules) != "" {
+		var rules []filter.LegacyRule
+		if err := json.Unmarshal([]byte(cfg.Rules), &rules); err != nil {
+			return nil, fmt.Errorf("filter_config.rules: %w", err)
+		}
+		for _, l := range rules {
+			if seen[l.ID] {
+				continue
+			}
+			seen[l.ID] = true
+			report.Found++
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func unmarshalLegacyColumns(m filterstore.LegacyRuleModel, l *filter.LegacyRule) error {
+	columns := []struct {
+		name string
+		data string
+		v    any
+	}{
+		{"source_ips", m.SourceIPs, &l.SourceIPs},
+		{"dest_ips", m.DestIPs, &l.DestIPs},
+		{"source_ports", m.SourcePorts, &l.SourcePorts},
+		{"dest_ports", m.DestPorts, &l.DestPorts},
+	}
+	for _, c := range columns {
+		if strings.TrimSpace(c.data) == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(c.data), c.v); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// migratedLegacyIDs 规则库中已迁移的旧规则 ID
+func (s *FilterService) migratedLegacyIDs(ctx context.Context) (map[int]bool, error) {
+	models, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[int]bool{}
+	for _, m := range models {
+		var tags []string
+		_ = json.Unmarshal([]byte(m.Tags), &tags)
+		for _, t := range tags {
+			if rest, ok := strings.CutPrefix(t, filter.LegacyTagPrefix); ok {
+				if id, err := strconv.Atoi(rest); err == nil {
+					ids[id] = true
+				}
+			}
+		}
+	}
+	return ids, nil
+}
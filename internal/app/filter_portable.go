@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"proxy-system-backend/internal/modules/filter"
+	filterstore "proxy-system-backend/internal/storage/filter"
+	"time"
+)
+
+// RuleDiff 导入规则文件与规则集现有规则的差异，规则在规则集内按名称对应
+type RuleDiff struct {
+	RuleSet string `json:"rule_set"`
+	DryRun  bool   `json:"dry_run"`
+	Sync    bool   `json:"sync"`
+
+	Added     []filter.RuleDTO `json:"added"`
+	Changed   []RuleChange     `json:"changed"`
+	Removed   []filter.RuleDTO `json:"removed"` // 只在 sync 时删除文件中没有的规则
+	Unchanged int              `json:"unchanged"`
+
+	// 转换时跳过的内容（iptables）
+	Notes []string `json:"notes,omitempty"`
+}
+
+type RuleChange struct {
+	Name   string         `json:"name"`
+	Fields []string       `json:"fields"`
+	Before filter.RuleDTO `json:"before"`
+	After  filter.RuleDTO `json:"after"`
+}
+
+// ExportRuleSet 导出规则集（空为默认规则集）中的全部规则，按优先级从高到低
+func (s *FilterService) ExportRuleSet(ctx context.Context, set string) (filter.RuleFile, error) {
+	if err := s.app.checkRuleSets(ctx, []filter.Rule{{Set: set}}, ruleSetField); err != nil {
+		return filter.RuleFile{}, err
+	}
+	rules, err := ruleSetRules(ctx, s.repo, set)
+	if err != nil {
+		return filter.RuleFile{}, err
+	}
+	out := make([]filter.Rule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, r.rule)
+	}
+	return filter.NewRuleFile(set, out), nil
+}
+
+// ImportRuleFile 把规则文件导入 f.RuleSet：文件中新增的规则加入，同名规则有差异时更新（保留 ID），
+// sync=true 时删除规则集中文件里没有的规则。dry_run 只返回差异；否则在同一事务中应用
+func (s *FilterService) ImportRuleFile(ctx context.Context, f filter.RuleFile, sync, dryRun bool) (RuleDiff, error) {
+	rules, err := f.ToRules()
+	if err != nil {
+		return RuleDiff{}, err
+	}
+	if err := s.app.checkRuleSets(ctx, []filter.Rule{{Set: f.RuleSet}}, ruleSetField); err != nil {
+		return RuleDiff{}, err
+	}
+
+	var diff RuleDiff
+	plan := func(repo filterstore.RuleRepository) (filter.ImportPlan, map[int64]storedRule, error) {
+		existing, err := ruleSetRules(ctx, repo, f.RuleSet)
+		if err != nil {
+			return filter.ImportPlan{}, nil, err
+		}
+		stored := make([]filter.Rule, 0, len(existing))
+		byID := make(map[int64]storedRule, len(existing))
+		for _, r := range existing {
+			stored = append(stored, r.rule)
+			byID[r.rule.ID] = r
+		}
+		p := filter.PlanImport(stored, rules, sync)
+		diff = importDiff(p, byID, f.RuleSet, sync, dryRun)
+		return p, byID, nil
+	}
+	if dryRun {
+		_, _, err := plan(s.repo)
+		return diff, err
+	}
+
+	op := "import"
+	if sync {
+		op = "sync"
+	}
+	err = s.apply(ctx, op, func(repo filterstore.RuleRepository) ([]int64, error) {
+		// 在事务内重新比较，避免与并发修改冲突
+		p, byID, err := plan(repo)
+		if err != nil {
+			return nil, err
+		}
+		var ids []int64
+		for i, r := range p.Added {
+			m := RuleToModel(r)
+			if err := repo.Save(ctx, &m); err != nil {
+				return nil, err
+			}
+			diff.Added[i].ID = m.ID
+			ids = append(ids, m.ID)
+		}
+		for _, c := range p.Changed {
+			c.After.ID = c.Before.ID
+			m := RuleToModel(c.After)
+			m.CreatedAt = byID[c.Before.ID].createdAt
+			if err := repo.Save(ctx, &m); err != nil {
+				return nil, err
+			}
+			ids = append(ids, m.ID)
+		}
+		for _, r := range p.Removed {
+			if err := repo.Delete(ctx, r.ID); err != nil {
+				return nil, err
+			}
+			ids = append(ids, r.ID)
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return RuleDiff{}, err
+	}
+	return diff, nil
+}
+
+// storedRule 规则库中的规则及其接口格式（差异中返回）
+type storedRule struct {
+	rule      filter.Rule
+	dto       filter.RuleDTO
+	createdAt time.Time
+}
+
+// importDiff 导入计划转换为接口格式，byID 为规则集中的规则
+func importDiff(p filter.ImportPlan, byID map[int64]storedRule, set string, sync, dryRun bool) RuleDiff {
+	d := RuleDiff{
+		RuleSet:   set,
+		DryRun:    dryRun,
+		Sync:      sync,
+		Added:     make([]filter.RuleDTO, 0, len(p.Added)),
+		Changed:   make([]RuleChange, 0, len(p.Changed)),
+		Removed:   make([]filter.RuleDTO, 0, len(p.Removed)),
+		Unchanged: p.Unchanged,
+	}
+	for _, r := range p.Added {
+		d.Added = append(d.Added, filter.RuleToDTO(r, time.Time{}, time.Time{}))
+	}
+	for _, c := range p.Changed {
+		before := byID[c.Before.ID]
+		after := c.After
+		after.ID = c.Before.ID
+		d.Changed = append(d.Changed, RuleChange{
+			Name:   c.After.Name,
+			Fields: c.Fields,
+			Before: before.dto,
+			After:  filter.RuleToDTO(after, before.createdAt, time.Time{}),
+		})
+	}
+	for _, r := range p.Removed {
+		d.Removed = append(d.Removed, byID[r.ID].dto)
+	}
+	return d
+}
+
+// ruleSetRules 规则集中的规则，按优先级从高到低
+func ruleSetRules(ctx context.Context, repo filterstore.RuleRepository, set string) ([]storedRule, error) {
+	models, err := repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []storedRule
+	for _, m := range models {
+		if m.RuleSet != set {
+			continue
+		}
+		r, err := ModelToRule(m)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", m.ID, err)
+		}
+		d, err := ModelToDTO(m)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", m.ID, err)
+		}
+		out = append(out, storedRule{rule: *r, dto: d, createdAt: m.CreatedAt})
+	}
+	return out, nil
+}
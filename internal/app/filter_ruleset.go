@@ -0,0 +1,353 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"proxy-system-backend/internal/modules/filter"
+	"proxy-system-backend/internal/modules/proxy"
+	filterstore "proxy-system-backend/internal/storage/filter"
+	"slices"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 规则集：默认规则集（规则的 rule_set 为空）对所有连接生效；命名规则集按分配生效：
+//   - global：所有代理
+//   - user：proxy.Config.User 相同的代理
+//   - proxy：ID 或名称相同的代理
+//
+// 连接适用的规则集 = 默认 + 全局 + 所属用户 + 所在代理（代理继承用户和全局的规则集），
+// 这些规则集的规则合并后仍按优先级统一求值。分配变化后，已建立连接的连接级结果
+// 在下一个数据包时重新求值
+
+// RuleSetDTO 规则集及其分配
+type RuleSetDTO struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	Global  bool     `json:"global"`
+	Proxies []string `json:"proxies,omitempty"` // 代理 ID 或名称
+	Users   []string `json:"users,omitempty"`
+
+	// 只读：规则集中的规则数
+	Rules     int   `json:"rules"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// ruleSetAssignments 规则集分配的快照，修改时整体替换；
+// 指针变化即表示分配变化（proxyTrafficHook 据此重新求值）
+type ruleSetAssignments struct {
+	global  []string
+	proxies map[string][]string
+	users   map[string][]string
+}
+
+func newRuleSetAssignments(models []filterstore.RuleSetAssignmentModel) *ruleSetAssignments {
+	s := &ruleSetAssignments{proxies: map[string][]string{}, users: map[string][]string{}}
+	for _, m := range models {
+		switch m.Scope {
+		case filterstore.ScopeGlobal:
+			s.global = appendUnique(s.global, m.RuleSet)
+		case filterstore.ScopeProxy:
+			s.proxies[m.Target] = appendUnique(s.proxies[m.Target], m.RuleSet)
+		case filterstore.ScopeUser:
+			s.users[m.Target] = appendUnique(s.users[m.Target], m.RuleSet)
+		}
+	}
+	return s
+}
+
+// resolve 代理适用的命名规则集：全局、用户、代理依次合并
+func (s *ruleSetAssignments) resolve(cfg proxy.Config) []string {
+	var out []string
+	for _, name := range s.global {
+		out = appendUnique(out, name)
+	}
+	if cfg.User != "" {
+		for _, name := range s.users[cfg.User] {
+			out = appendUnique(out, name)
+		}
+	}
+	for _, target := range []string{cfg.ID, cfg.Name} {
+		if target == "" {
+			continue
+		}
+		for _, name := range s.proxies[target] {
+			out = appendUnique(out, name)
+		}
+	}
+	return out
+}
+
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// RuleSetsFor 代理适用的命名规则集（不含默认规则集）
+func (a *App) RuleSetsFor(cfg proxy.Config) []string {
+	return a.ruleSets.Load().resolve(cfg)
+}
+
+// RuleSetService 规则集与分配的持久化，修改后替换 App 的分配快照并推送 EventRuleSetUpdated
+type RuleSetService struct {
+	mu    sync.Mutex
+	repo  filterstore.RuleSetRepository
+	rules filterstore.RuleRepository
+	app   *App
+}
+
+func NewRuleSetService(repo filterstore.RuleSetRepository, rules filterstore.RuleRepository, app *App) *RuleSetService {
+	return &RuleSetService{repo: repo, rules: rules, app: app}
+}
+
+// Reload 从数据库加载分配（启动时使用）
+func (s *RuleSetService) Reload(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload(ctx)
+}
+
+func (s *RuleSetService) reload(ctx context.Context) error {
+	models, err := s.repo.Assignments(ctx)
+	if err != nil {
+		return err
+	}
+	s.app.ruleSets.Store(newRuleSetAssignments(models))
+	return nil
+}
+
+func (s *RuleSetService) List(ctx context.Context) ([]RuleSetDTO, error) {
+	sets, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := s.repo.Assignments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.ruleCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]RuleSetDTO, 0, len(sets))
+	for _, m := range sets {
+		out = append(out, ruleSetToDTO(m, assignments, counts[m.Name]))
+	}
+	return out, nil
+}
+
+func (s *RuleSetService) Get(ctx context.Context, name string) (RuleSetDTO, error) {
+	m, err := s.repo.Get(ctx, name)
+	if err != nil {
+		return RuleSetDTO{}, err
+	}
+	assignments, err := s.repo.Assignments(ctx)
+	if err != nil {
+		return RuleSetDTO{}, err
+	}
+	counts, err := s.ruleCounts(ctx)
+	if err != nil {
+		return RuleSetDTO{}, err
+	}
+	return ruleSetToDTO(*m, assignments, counts[m.Name]), nil
+}
+
+// Exists 规则集是否存在（保存规则时校验 rule_set）
+func (s *RuleSetService) Exists(ctx context.Context, name string) (bool, error) {
+	_, err := s.repo.Get(ctx, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *RuleSetService) Create(ctx context.Context, dto RuleSetDTO) (RuleSetDTO, error) {
+	if err := validateRuleSet(&dto); err != nil {
+		return RuleSetDTO{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok, err := s.Exists(ctx, dto.Name); err != nil {
+		return RuleSetDTO{}, err
+	} else if ok {
+		return RuleSetDTO{}, &filter.ValidationError{Fields: []filter.FieldError{{Field: "name", Message: fmt.Sprintf("rule set %q already exists", dto.Name)}}}
+	}
+	m := filterstore.RuleSetModel{Name: dto.Name, Description: dto.Description}
+	return s.save(ctx, "create", &m, dto)
+}
+
+// Update 修改描述并替换分配，名称不可修改
+func (s *RuleSetService) Update(ctx context.Context, name string, dto RuleSetDTO) (RuleSetDTO, error) {
+	dto.Name = name
+	if err := validateRuleSet(&dto); err != nil {
+		return RuleSetDTO{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.repo.Get(ctx, name)
+	if err != nil {
+		return RuleSetDTO{}, err
+	}
+	m.Description = dto.Description
+	return s.save(ctx, "update", m, dto)
+}
+
+// Delete 删除规则集及其分配，规则集中还有规则时拒绝
+func (s *RuleSetService) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.repo.Get(ctx, name); err != nil {
+		return err
+	}
+	counts, err := s.ruleCounts(ctx)
+	if err != nil {
+		return err
+	}
+	if n := counts[name]; n > 0 {
+		return &filter.ValidationError{Fields: []filter.FieldError{{Field: "name", Message: fmt.Sprintf("rule set %q still has %d rules", name, n)}}}
+	}
+	if err := s.repo.Delete(ctx, name); err != nil {
+		return err
+	}
+	if err := s.reload(ctx); err != nil {
+		return err
+	}
+	s.emit("delete", name)
+	return nil
+}
+
+func (s *RuleSetService) save(ctx context.Context, op string, m *filterstore.RuleSetModel, dto RuleSetDTO) (RuleSetDTO, error) {
+	var assignments []filterstore.RuleSetAssignmentModel
+	if dto.Global {
+		assignments = append(assignments, filterstore.RuleSetAssignmentModel{Scope: filterstore.ScopeGlobal})
+	}
+	for _, p := range dto.Proxies {
+		assignments = append(assignments, filterstore.RuleSetAssignmentModel{Scope: filterstore.ScopeProxy, Target: p})
+	}
+	for _, u := range dto.Users {
+		assignments = append(assignments, filterstore.RuleSetAssignmentModel{Scope: filterstore.ScopeUser, Target: u})
+	}
+	if err := s.repo.Save(ctx, m, assignments); err != nil {
+		return RuleSetDTO{}, err
+	}
+	if err := s.reload(ctx); err != nil {
+		return RuleSetDTO{}, err
+	}
+	s.emit(op, m.Name)
+
+	counts, err := s.ruleCounts(ctx)
+	if err != nil {
+		return RuleSetDTO{}, err
+	}
+	return ruleSetToDTO(*m, assignments, counts[m.Name]), nil
+}
+
+func (s *RuleSetService) emit(op, name string) {
+	s.app.Emit(Event{
+		Type: EventRuleSetUpdated,
+		Data: map[string]any{
+			"op":       op,
+			"rule_set": name,
+			"proxies":  s.app.Proxies(),
+		},
+	})
+}
+
+func (s *RuleSetService) ruleCounts(ctx context.Context) (map[string]int, error) {
+	models, err := s.rules.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, m := range models {
+		if m.RuleSet != "" {
+			counts[m.RuleSet]++
+		}
+	}
+	return counts, nil
+}
+
+// validateRuleSet 校验名称并去掉空白 / 重复的分配目标
+func validateRuleSet(dto *RuleSetDTO) error {
+	verr := &filter.ValidationError{}
+	dto.Name = strings.TrimSpace(dto.Name)
+	if err := filter.ValidateSetName(dto.Name); err != nil {
+		verr.Fields = append(verr.Fields, filter.FieldError{Field: "name", Message: err.Error()})
+	}
+	dto.Proxies = cleanTargets(dto.Proxies)
+	dto.Users = cleanTargets(dto.Users)
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func cleanTargets(in []string) []string {
+	var out []string
+	for _, t := range in {
+		if t = strings.TrimSpace(t); t != "" {
+			out = appendUnique(out, t)
+		}
+	}
+	return out
+}
+
+func ruleSetToDTO(m filterstore.RuleSetModel, assignments []filterstore.RuleSetAssignmentModel, rules int) RuleSetDTO {
+	d := RuleSetDTO{
+		Name:        m.Name,
+		Description: m.Description,
+		Rules:       rules,
+		CreatedAt:   m.CreatedAt.Unix(),
+		UpdatedAt:   m.UpdatedAt.Unix(),
+	}
+	for _, a := range assignments {
+		if a.RuleSet != m.Name {
+			continue
+		}
+		switch a.Scope {
+		case filterstore.ScopeGlobal:
+			d.Global = true
+		case filterstore.ScopeProxy:
+			d.Proxies = append(d.Proxies, a.Target)
+		case filterstore.ScopeUser:
+			d.Users = append(d.Users, a.Target)
+		}
+	}
+	return d
+}
+
+// checkRuleSets 规则引用的命名规则集必须存在；未配置 RuleSetService 时不允许使用命名规则集
+func (a *App) checkRuleSets(ctx context.Context, rules []filter.Rule, field func(i int) string) error {
+	verr := &filter.ValidationError{}
+	for i, r := range rules {
+		if r.Set == "" {
+			continue
+		}
+		svc := a.RuleSets()
+		if svc == nil {
+			verr.Fields = append(verr.Fields, filter.FieldError{Field: field(i), Message: "rule sets storage is not configured"})
+			continue
+		}
+		ok, err := svc.Exists(ctx, r.Set)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			verr.Fields = append(verr.Fields, filter.FieldError{Field: field(i), Message: fmt.Sprintf("rule set %q not found", r.Set)})
+		}
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// StartFilterScheduler 按 interval 检查规则的生效时间：到点启用 / 停用规则（只重建索引），
+// 有变化时推送 EventRuleUpdated（op=schedule）；结束时间或 TTL 已过的规则在存储中标记为过期（op=expire）。
+// 返回的函数停止检查
+func (a *App) StartFilterScheduler(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				a.tickFilterSchedule(now)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (a *App) tickFilterSchedule(now time.Time) {
+	engine := a.FilterEngine()
+	changed, expired := engine.Tick(now)
+	if len(changed) > 0 {
+		a.Emit(Event{
+			Type: EventRuleUpdated,
+			Data: map[string]any{
+				"op":     "schedule",
+				"ids":    changed,
+				"active": engine.ActiveCount(),
+			},
+		})
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	if svc := a.FilterService(); svc != nil {
+		if err := svc.MarkExpired(context.Background(), expired); err != nil {
+			log.Printf("filter: mark expired rules %v: %v", expired, err)
+		}
+		return
+	}
+	a.Emit(Event{
+		Type: EventRuleUpdated,
+		Data: map[string]any{
+			"op":     "expire",
+			"ids":    expired,
+			"active": engine.ActiveCount(),
+		},
+	})
+}
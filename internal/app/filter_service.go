@@ -0,0 +1,360 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"proxy-system-backend/internal/modules/filter"
+	filterstore "proxy-system-backend/internal/storage/filter"
+	"slices"
+	"sync"
+)
+
+// 重新排序时相邻规则的优先级间隔，便于之后插入
+const reorderStep = 10
+
+// FilterService 过滤规则的持久化与热更新：
+// 每次修改都在事务中完成，并用事务内的完整规则集重新编译，编译失败则回滚；
+// 提交后原子替换 filter.Engine 的规则并推送 EventRuleUpdated
+type FilterService struct {
+	mu   sync.Mutex // 串行化写操作，保证引擎规则与数据库一致
+	repo filterstore.RuleRepository
+	app  *App
+}
+
+func NewFilterService(repo filterstore.RuleRepository, app *App) *FilterService {
+	return &FilterService{repo: repo, app: app}
+}
+
+// Reload 从数据库重新加载规则（启动时使用，跳过无法编译的规则）
+func (s *FilterService) Reload(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg, err := s.Config(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.app.FilterEngine().SetDefaultAction(cfg.DefaultAction); err != nil {
+		return err
+	}
+	return NewFilterLoader(s.repo, s.app.FilterEngine()).Load(ctx)
+}
+
+// FilterConfig 过滤全局设置
+type FilterConfig struct {
+	DefaultAction filter.Action `json:"default_action"` // 没有终结规则命中时的结果：allow / deny / reset
+}
+
+func (s *FilterService) Config(ctx context.Context) (FilterConfig, error) {
+	m, err := s.repo.Settings(ctx)
+	if err != nil {
+		return FilterConfig{}, err
+	}
+	return FilterConfig{DefaultAction: cmp.Or(filter.Action(m.DefaultAction), filter.ActionAllow)}, nil
+}
+
+// UpdateConfig 保存全局设置并立即生效（已建立的连接在下一个数据包时重新求值）
+func (s *FilterService) UpdateConfig(ctx context.Context, cfg FilterConfig) (FilterConfig, error) {
+	if !cfg.DefaultAction.Terminal() {
+		return FilterConfig{}, &filter.ValidationError{Fields: []filter.FieldError{{Field: "default_action", Message: "must be allow, deny or reset"}}}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.repo.Settings(ctx)
+	if err != nil {
+		return FilterConfig{}, err
+	}
+	m.DefaultAction = string(cfg.DefaultAction)
+	if err := s.repo.SaveSettings(ctx, m); err != nil {
+		return FilterConfig{}, err
+	}
+	if err := s.app.FilterEngine().SetDefaultAction(cfg.DefaultAction); err != nil {
+		return FilterConfig{}, err
+	}
+	s.app.Emit(Event{
+		Type: EventRuleUpdated,
+		Data: map[string]any{
+			"op":             "config",
+			"ids":            []int64{},
+			"active":         s.app.FilterEngine().ActiveCount(),
+			"default_action": cfg.DefaultAction,
+		},
+	})
+	return cfg, nil
+}
+
+func (s *FilterService) List(ctx context.Context) ([]filter.RuleDTO, error) {
+	models, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]filter.RuleDTO, 0, len(models))
+	for _, m := range models {
+		d, err := ModelToDTO(m)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", m.ID, err)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (s *FilterService) Get(ctx context.Context, id int64) (filter.RuleDTO, error) {
+	m, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return filter.RuleDTO{}, err
+	}
+	return ModelToDTO(*m)
+}
+
+func (s *FilterService) Create(ctx context.Context, dto filter.RuleDTO) (filter.RuleDTO, error) {
+	rule, err := dto.ToRule()
+	if err != nil {
+		return filter.RuleDTO{}, err
+	}
+	rule.ID = 0
+	if err := s.app.checkRuleSets(ctx, []filter.Rule{rule}, ruleSetField); err != nil {
+		return filter.RuleDTO{}, err
+	}
+
+	var out filter.RuleDTO
+	err = s.apply(ctx, "create", func(repo filterstore.RuleRepository) ([]int64, error) {
+		m := RuleToModel(rule)
+		if err := repo.Save(ctx, &m); err != nil {
+			return nil, err
+		}
+		out, err = ModelToDTO(m)
+		return []int64{m.ID}, err
+	})
+	return out, err
+}
+
+func (s *FilterService) Update(ctx context.Context, id int64, dto filter.RuleDTO) (filter.RuleDTO, error) {
+	rule, err := dto.ToRule()
+	if err != nil {
+		return filter.RuleDTO{}, err
+	}
+	rule.ID = id
+	if err := s.app.checkRuleSets(ctx, []filter.Rule{rule}, ruleSetField); err != nil {
+		return filter.RuleDTO{}, err
+	}
+
+	var out filter.RuleDTO
+	err = s.apply(ctx, "update", func(repo filterstore.RuleRepository) ([]int64, error) {
+		old, err := repo.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		m := RuleToModel(rule)
+		m.CreatedAt = old.CreatedAt
+		if err := repo.Save(ctx, &m); err != nil {
+			return nil, err
+		}
+		out, err = ModelToDTO(m)
+		return []int64{id}, err
+	})
+	return out, err
+}
+
+func (s *FilterService) Delete(ctx context.Context, id int64) error {
+	return s.apply(ctx, "delete", func(repo filterstore.RuleRepository) ([]int64, error) {
+		if _, err := repo.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return []int64{id}, repo.Delete(ctx, id)
+	})
+}
+
+// SetEnabled 启用 / 停用规则
+func (s *FilterService) SetEnabled(ctx context.Context, id int64, enabled bool) (filter.RuleDTO, error) {
+	op := "disable"
+	if enabled {
+		op = "enable"
+	}
+
+	var out filter.RuleDTO
+	err := s.apply(ctx, op, func(repo filterstore.RuleRepository) ([]int64, error) {
+		m, err := repo.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		m.Enabled = enabled
+		if err := repo.Save(ctx, m); err != nil {
+			return nil, err
+		}
+		out, err = ModelToDTO(*m)
+		return []int64{id}, err
+	})
+	return out, err
+}
+
+// Reorder 按 ids 的顺序（优先级从高到低）重新分配优先级，ids 必须包含全部规则
+func (s *FilterService) Reorder(ctx context.Context, ids []int64) ([]filter.RuleDTO, error) {
+	err := s.apply(ctx, "reorder", func(repo filterstore.RuleRepository) ([]int64, error) {
+		models, err := repo.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int64]*filterstore.RuleModel, len(models))
+		for i := range models {
+			byID[models[i].ID] = &models[i]
+		}
+
+		verr := &filter.ValidationError{}
+		seen := make(map[int64]bool, len(ids))
+		for i, id := range ids {
+			switch {
+			case seen[id]:
+				verr.Fields = append(verr.Fields, filter.FieldError{Field: fmt.Sprintf("ids[%d]", i), Message: fmt.Sprintf("duplicate rule %d", id)})
+			case byID[id] == nil:
+				verr.Fields = append(verr.Fields, filter.FieldError{Field: fmt.Sprintf("ids[%d]", i), Message: fmt.Sprintf("rule %d not found", id)})
+			}
+			seen[id] = true
+		}
+		for _, m := range models {
+			if !seen[m.ID] {
+				verr.Fields = append(verr.Fields, filter.FieldError{Field: "ids", Message: fmt.Sprintf("missing rule %d", m.ID)})
+			}
+		}
+		if len(verr.Fields) > 0 {
+			return nil, verr
+		}
+
+		for i, id := range ids {
+			m := byID[id]
+			m.Priority = (len(ids) - i) * reorderStep
+			if err := repo.Save(ctx, m); err != nil {
+				return nil, err
+			}
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ctx)
+}
+
+// Import 批量导入：全部校验通过并在同一事务中写入，任何一条失败都不生效。
+// replace=true 时先删除现有规则；带 id 的规则覆盖同 id 的规则
+func (s *FilterService) Import(ctx context.Context, dtos []filter.RuleDTO, replace bool) ([]filter.RuleDTO, error) {
+	rules := make([]filter.Rule, 0, len(dtos))
+	verr := &filter.ValidationError{}
+	for i, d := range dtos {
+		r, err := d.ToRule()
+		if err != nil {
+			ve, ok := err.(*filter.ValidationError)
+			if !ok {
+				return nil, err
+			}
+			verr.Fields = append(verr.Fields, ve.Prefix(fmt.Sprintf("rules[%d]", i)).Fields...)
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if len(verr.Fields) > 0 {
+		return nil, verr
+	}
+	err := s.app.checkRuleSets(ctx, rules, func(i int) string {
+		return fmt.Sprintf("rules[%d].rule_set", i)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	op := "import"
+	if replace {
+		op = "replace"
+	}
+	err = s.apply(ctx, op, func(repo filterstore.RuleRepository) ([]int64, error) {
+		if replace {
+			if err := repo.DeleteAll(ctx); err != nil {
+				return nil, err
+			}
+		}
+		ids := make([]int64, 0, len(rules))
+		for _, r := range rules {
+			m := RuleToModel(r)
+			if !replace && m.ID != 0 {
+				if old, err := repo.Get(ctx, m.ID); err == nil {
+					m.CreatedAt = old.CreatedAt
+				}
+			}
+			if err := repo.Save(ctx, &m); err != nil {
+				return nil, err
+			}
+			ids = append(ids, m.ID)
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ctx)
+}
+
+// MarkExpired 标记结束时间或 TTL 已过的规则（由 StartFilterScheduler 调用），
+// 已删除的规则跳过
+func (s *FilterService) MarkExpired(ctx context.Context, ids []int64) error {
+	return s.apply(ctx, "expire", func(repo filterstore.RuleRepository) ([]int64, error) {
+		models, err := repo.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var marked []int64
+		for i := range models {
+			m := &models[i]
+			if m.Expired || !slices.Contains(ids, m.ID) {
+				continue
+			}
+			m.Expired = true
+			if err := repo.Save(ctx, m); err != nil {
+				return nil, err
+			}
+			marked = append(marked, m.ID)
+		}
+		return marked, nil
+	})
+}
+
+// apply 在事务中执行修改并重新编译全部规则，提交后替换引擎规则并推送事件
+func (s *FilterService) apply(ctx context.Context, op string, fn func(repo filterstore.RuleRepository) ([]int64, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		ids      []int64
+		compiled []*filter.CompiledRule
+	)
+	err := s.repo.Transaction(ctx, func(repo filterstore.RuleRepository) error {
+		var err error
+		if ids, err = fn(repo); err != nil {
+			return err
+		}
+		models, err := repo.List(ctx)
+		if err != nil {
+			return err
+		}
+		compiled, err = compileModels(models)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.app.FilterEngine().Replace(compiled)
+	s.app.Emit(Event{
+		Type: EventRuleUpdated,
+		Data: map[string]any{
+			"op":     op,
+			"ids":    ids,
+			"active": s.app.FilterEngine().ActiveCount(),
+		},
+	})
+	return nil
+}
+
+func ruleSetField(int) string {
+	return "rule_set"
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"proxy-system-backend/internal/modules/filter"
+	"sync"
+	"time"
+)
+
+// StartFilterStats 按 interval 推送过滤统计（EventFilterStats），统计没有变化时跳过；
+// 返回的函数停止推送
+func (a *App) StartFilterStats(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last uint64
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s := a.FilterEngine().Stats()
+				if total := s.Total(); total != last {
+					last = total
+					a.Emit(Event{Type: EventFilterStats, Data: s})
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// ResetFilterStats 清零过滤统计并立即推送
+func (a *App) ResetFilterStats() filter.Stats {
+	a.FilterEngine().ResetStats()
+	s := a.FilterEngine().Stats()
+	a.Emit(Event{Type: EventFilterStats, Data: s})
+	return s
+}
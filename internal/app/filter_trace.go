@@ -0,0 +1,134 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"proxy-system-backend/internal/modules/filter"
+	"proxy-system-backend/internal/modules/proxy"
+	"proxy-system-backend/internal/traffic"
+)
+
+// FilterProbe 规则调试用的数据包描述；PacketID 非 0 时使用录制的数据包，
+// 其余字段中 Decoded 非空时覆盖录制的解码结果
+type FilterProbe struct {
+	PacketID int64
+
+	// 适用的命名规则集；为 nil 时按 ProxyID 所在代理的分配解析（ProxyID 为空时只有全局规则集）
+	RuleSets []string
+	ProxyID  string
+
+	// 客户端、服务器地址（ip:port），Direction 决定哪一端是源
+	Client    string
+	Server    string
+	Direction traffic.Direction
+	Protocol  traffic.Protocol
+	Domain    string
+	Payload   []byte
+
+	// 插件解码结果，为空时不求值解码结果条件
+	Decoded json.RawMessage
+}
+
+// EvaluateFilter 用当前规则求值并返回完整过程，不影响统计；
+// 描述有误时返回 *filter.ValidationError，录制的数据包不存在时返回 gorm.ErrRecordNotFound
+func (a *App) EvaluateFilter(ctx context.Context, p FilterProbe) (filter.Trace, error) {
+	if p.PacketID != 0 {
+		var err error
+		if p, err = a.probeFromCapture(ctx, p); err != nil {
+			return filter.Trace{}, err
+		}
+	}
+
+	verr := &filter.ValidationError{}
+	client, err := probeAddr(p.Client)
+	if err != nil {
+		verr.Fields = append(verr.Fields, filter.FieldError{Field: "client", Message: err.Error()})
+	}
+	server, err := probeAddr(p.Server)
+	if err != nil {
+		verr.Fields = append(verr.Fields, filter.FieldError{Field: "server", Message: err.Error()})
+	}
+	if len(p.Decoded) > 0 && !json.Valid(p.Decoded) {
+		verr.Fields = append(verr.Fields, filter.FieldError{Field: "decoded", Message: "invalid JSON"})
+	}
+	if len(verr.Fields) > 0 {
+		return filter.Trace{}, verr
+	}
+
+	if p.Direction == traffic.DirectionUnknown {
+		p.Direction = traffic.DirectionOut
+	}
+	if p.Protocol == traffic.ProtocolUnknown {
+		p.Protocol = traffic.ProtocolTCP
+	}
+	src, dst := client, server
+	if p.Direction == traffic.DirectionIn {
+		src, dst = server, client
+	}
+
+	pc := traffic.NewCtx("probe", p.Direction, p.Protocol, src, dst)
+	pc.Domain = p.Domain
+	pc.Payload = p.Payload
+	pc.RuleSets = p.RuleSets
+	if pc.RuleSets == nil {
+		cfg, ok := a.proxyMgr.Config(p.ProxyID)
+		if !ok {
+			cfg = proxy.Config{ID: p.ProxyID}
+		}
+		pc.RuleSets = a.RuleSetsFor(cfg)
+	}
+	a.lookupGeo(pc)
+	return a.FilterEngine().Trace(pc, p.Decoded), nil
+}
+
+// probeFromCapture 从录制的数据包和会话补全地址、方向、负载与解码结果
+func (a *App) probeFromCapture(ctx context.Context, p FilterProbe) (FilterProbe, error) {
+	store := a.CaptureStore()
+	if store == nil {
+		return p, fmt.Errorf("capture is not configured")
+	}
+	pkt, err := store.Packet(ctx, p.PacketID)
+	if err != nil {
+		return p, err
+	}
+	sess, err := store.Session(ctx, pkt.ConnID)
+	if err != nil {
+		return p, fmt.Errorf("session %s: %w", pkt.ConnID, err)
+	}
+
+	out := FilterProbe{
+		PacketID:  p.PacketID,
+		RuleSets:  p.RuleSets,
+		ProxyID:   cmp.Or(p.ProxyID, pkt.ProxyID),
+		Client:    sess.Client,
+		Server:    sess.Dst,
+		Direction: pkt.Direction,
+		Protocol:  traffic.ProtocolTCP,
+		Domain:    sess.Domain,
+		Payload:   pkt.Payload,
+		Decoded:   pkt.Decoded,
+	}
+	if sess.Protocol == traffic.ProtocolUDP.String() {
+		out.Protocol = traffic.ProtocolUDP
+	}
+	if len(p.Decoded) > 0 {
+		out.Decoded = p.Decoded
+	}
+	return out, nil
+}
+
+// probeAddr 解析 ip:port，空字符串表示未知地址
+func probeAddr(s string) (net.Addr, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return nil, err
+	}
+	return net.TCPAddrFromAddrPort(ap), nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"proxy-system-backend/internal/modules/geoip"
+	"proxy-system-backend/internal/traffic"
+	"sync"
+	"time"
+)
+
+func (a *App) SetGeoIP(db *geoip.DB) {
+	a.geoip = db
+}
+
+// GeoIP 未配置时为 nil
+func (a *App) GeoIP() *geoip.DB {
+	return a.geoip
+}
+
+// ReloadGeoIP 重新加载 GeoIP 数据库并推送 EventGeoIPReloaded；
+// 已建立的连接在下一个数据包时重新查询地址并重新匹配规则
+func (a *App) ReloadGeoIP() geoip.Status {
+	s := a.geoip.Reload()
+	a.Emit(Event{Type: EventGeoIPReloaded, Data: s})
+	return s
+}
+
+// StartGeoIPWatcher 按 interval 检查数据库目录，文件增删或修改后自动重新加载；
+// 返回的函数停止检查
+func (a *App) StartGeoIPWatcher(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if a.geoip.Changed() {
+					a.ReloadGeoIP()
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// geoGeneration GeoIP 数据库的加载次数，未配置时为 0
+func (a *App) geoGeneration() uint64 {
+	if a.geoip == nil {
+		return 0
+	}
+	return a.geoip.Generation()
+}
+
+// lookupGeo 查询数据包两端地址的 GeoIP 信息
+func (a *App) lookupGeo(ctx *traffic.PacketContext) {
+	if a.geoip == nil {
+		return
+	}
+	ctx.SrcGeo, ctx.DstGeo = a.geoip.Lookup(ctx.SrcIP), a.geoip.Lookup(ctx.DstIP)
+}
+
+// enrichGeo 填充数据包两端的 GeoIP 信息；每个连接只查询一次，数据库重新加载后重新查询
+func (h *proxyTrafficHook) enrichGeo(ctx *traffic.PacketContext) {
+	db := h.app.GeoIP()
+	if db == nil {
+		return
+	}
+	gen := db.Generation()
+
+	h.geoMu.Lock()
+	if !h.geoDone || h.geoGen != gen {
+		view := ctx.ClientView()
+		h.clientGeo, h.serverGeo = db.Lookup(view.SrcIP), db.Lookup(view.DstIP)
+		h.geoDone, h.geoGen = true, gen
+	}
+	client, server := h.clientGeo, h.serverGeo
+	h.geoMu.Unlock()
+
+	if ctx.Direction == traffic.DirectionIn {
+		ctx.SrcGeo, ctx.DstGeo = server, client
+	} else {
+		ctx.SrcGeo, ctx.DstGeo = client, server
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/traffic"
+	"time"
+)
+
+// InjectRequest 注入数据包；Payload 与 JSON 二选一
+type InjectRequest struct {
+	ConnID    string
+	Direction traffic.Direction
+
+	// 原始字节
+	Payload []byte
+
+	// 解码后的 JSON，通过插件 Encode 得到原始字节
+	JSON   json.RawMessage
+	Plugin string
+}
+
+// Inject 向活跃连接写入构造的数据包，返回实际写入的字节
+func (a *App) Inject(req InjectRequest) ([]byte, error) {
+	if req.Direction != traffic.DirectionOut && req.Direction != traffic.DirectionIn {
+		return nil, fmt.Errorf("direction must be out or in")
+	}
+
+	payload := req.Payload
+	if len(req.JSON) > 0 {
+		if a.pluginMgr == nil {
+			return nil, fmt.Errorf("plugin service not configured")
+		}
+		name := req.Plugin
+		if name == "" {
+			name = plugin.GetTrafficHookDecoderPlugin()
+		}
+		if name == "" {
+			return nil, fmt.Errorf("no plugin specified for json payload")
+		}
+
+		invoker := plugin.NewPluginInvoker(a.pluginMgr.GetPluginManager())
+		call := plugin.NewEncodeCallContext(name, req.JSON)
+		call.SetMetadata("conn_id", req.ConnID)
+		call.Context.SetTimeout(time.Duration(plugin.GetTrafficHookTimeout()) * time.Millisecond)
+
+		encoded, err := invoker.InvokeEncode(call)
+		if err != nil {
+			return nil, fmt.Errorf("encode with plugin '%s': %w", name, err)
+		}
+		payload = encoded
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+
+	if err := a.proxyMgr.Inject(req.ConnID, req.Direction, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Connections 返回所有活跃连接
+func (a *App) Connections() []ConnInfo {
+	return a.proxyMgr.Connections()
+}
+
+// recordInjected 注入的数据包只做解码展示，不经过过滤 / 改包 / 断点
+func (h *proxyTrafficHook) recordInjected(ctx *traffic.PacketContext) {
+	data := map[string]any{
+		"proxy_id": h.proxyID,
+		"conn_id":  h.connID,
+		"payload":  ctx,
+		"injected": true,
+	}
+
+	var (
+		decoded    *plugin.DecodeResult
+		pluginName string
+	)
+	if h.app.PluginMgr() != nil && plugin.IsTrafficHookEnabled() {
+		if name := h.getDecoderPlugin(); name != "" {
+			if res, err := h.decodeWithPlugin(name, ctx); err == nil {
+				decoded, pluginName = res, name
+				data["decoded"] = decoded
+				data["decoder_plugin"] = name
+			}
+		}
+	}
+	h.record(ctx, pluginName, decoded)
+
+	h.app.Emit(Event{Type: EventTraffic, Data: data})
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"fmt"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/traffic"
+)
+
+// mirrorPacket 将最终转发的数据包复制到镜像目标（命中 action=mirror 的连接）；
+// 规则修改后镜像配置变化时关闭原来的镜像，按新配置重新开启
+func (h *proxyTrafficHook) mirrorPacket(ctx *traffic.PacketContext) {
+	if h.offline {
+		return
+	}
+	mc := h.connDecision(ctx).Mirror
+
+	h.mirrorMu.Lock()
+	defer h.mirrorMu.Unlock()
+	if !sameMirror(h.mirrorCfg, mc) {
+		h.closeMirrorLocked()
+		// 打开失败时同样记下配置，配置不变就不再重试
+		h.mirrorCfg = mc
+		if mc != nil {
+			tee, err := h.app.Mirrors().Open(h.connID, *mc)
+			if err != nil {
+				fmt.Printf("[Mirror] %s: %v\n", mc.Target, err)
+			} else {
+				h.mirror = tee
+			}
+		}
+	}
+	if h.mirror != nil {
+		h.mirror.Write(ctx.Direction, ctx.Payload, ctx.Time)
+	}
+}
+
+func (h *proxyTrafficHook) closeMirror() {
+	h.mirrorMu.Lock()
+	defer h.mirrorMu.Unlock()
+	h.closeMirrorLocked()
+}
+
+func (h *proxyTrafficHook) closeMirrorLocked() {
+	if h.mirror != nil {
+		h.mirror.Close()
+		h.mirror = nil
+	}
+}
+
+func sameMirror(a, b *mirror.Config) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
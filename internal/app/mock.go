@@ -0,0 +1,51 @@
+package app
+
+import (
+	"proxy-system-backend/internal/modules/mock"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/modules/replay"
+)
+
+// newMockDialer 用录制会话构建 mock 出站，未匹配的请求推送 EventMockMiss
+func (a *App) newMockDialer(proxyID string, cfg mock.Config, passthrough mock.DialFunc) (*mock.Dialer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	_, msgs, recorded, err := a.loadRecording(cfg.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	pluginName := cfg.Plugin
+	if pluginName == "" {
+		pluginName = recorded
+	} else {
+		// 指定了其它插件时，录制的解码结果不能用来计算匹配键
+		for i := range msgs {
+			msgs[i].Decoded = nil
+		}
+	}
+
+	var codec replay.Codec
+	if mgr := a.GetPluginManager(); pluginName != "" && mgr != nil {
+		codec = &pluginCodec{invoker: plugin.NewPluginInvoker(mgr), name: pluginName, connID: cfg.Session}
+	}
+
+	lib, err := mock.NewLibrary(cfg, msgs, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	d := mock.NewDialer(lib, passthrough)
+	d.OnMiss(func(m mock.Miss) {
+		a.Emit(Event{
+			Type: EventMockMiss,
+			Data: map[string]any{
+				"proxy_id": proxyID,
+				"session":  cfg.Session,
+				"miss":     m,
+			},
+		})
+	})
+	return d, nil
+}
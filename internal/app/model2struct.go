@@ -3,22 +3,53 @@ package app
 import (
 	"encoding/json"
 	"proxy-system-backend/internal/modules/filter"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/modules/redirect"
 	filterstore "proxy-system-backend/internal/storage/filter"
 	"proxy-system-backend/internal/traffic"
+	"time"
 )
 
 func ModelToRule(m filterstore.RuleModel) (*filter.Rule, error) {
 	var srcCIDR, dstCIDR []string
 	var srcPort, dstPort []filter.PortRange
-	var tags []string
+	var tags, decoded, windows, geo []string
+	var asn []uint32
 
 	_ = json.Unmarshal([]byte(m.SrcCIDR), &srcCIDR)
 	_ = json.Unmarshal([]byte(m.DstCIDR), &dstCIDR)
 	_ = json.Unmarshal([]byte(m.SrcPort), &srcPort)
 	_ = json.Unmarshal([]byte(m.DstPort), &dstPort)
+	_ = json.Unmarshal([]byte(m.Geo), &geo)
+	_ = json.Unmarshal([]byte(m.ASN), &asn)
 	_ = json.Unmarshal([]byte(m.Tags), &tags)
+	_ = json.Unmarshal([]byte(m.Decoded), &decoded)
+	_ = json.Unmarshal([]byte(m.Windows), &windows)
 
-	return &filter.Rule{
+	var payload []filter.PayloadMatch
+	if m.Payload != "" {
+		if err := json.Unmarshal([]byte(m.Payload), &payload); err != nil {
+			return nil, err
+		}
+	}
+
+	var mc *mirror.Config
+	if m.Mirror != "" {
+		mc = &mirror.Config{}
+		if err := json.Unmarshal([]byte(m.Mirror), mc); err != nil {
+			return nil, err
+		}
+	}
+
+	var rt *redirect.Target
+	if m.Redirect != "" {
+		rt = &redirect.Target{}
+		if err := json.Unmarshal([]byte(m.Redirect), rt); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &filter.Rule{
 		ID:          m.ID,
 		Name:        m.Name,
 		Description: m.Description,
@@ -28,10 +59,98 @@ func ModelToRule(m filterstore.RuleModel) (*filter.Rule, error) {
 		Priority:  m.Priority,
 		Enabled:   m.Enabled,
 
-		SrcCIDR: srcCIDR,
-		DstCIDR: dstCIDR,
-		SrcPort: srcPort,
-		DstPort: dstPort,
-		Tags:    tags,
-	}, nil
+		SrcCIDR:  srcCIDR,
+		DstCIDR:  dstCIDR,
+		SrcPort:  srcPort,
+		DstPort:  dstPort,
+		Geo:      geo,
+		ASN:      asn,
+		Payload:  payload,
+		Decoded:  decoded,
+		Expr:     m.Expr,
+		Tags:     tags,
+		Set:      m.RuleSet,
+		Mirror:   mc,
+		Redirect: rt,
+		Windows:  windows,
+		TTL:      time.Duration(m.TTL) * time.Second,
+		Expired:  m.Expired,
+	}
+	if m.StartAt > 0 {
+		r.StartAt = time.Unix(m.StartAt, 0)
+	}
+	if m.EndAt > 0 {
+		r.EndAt = time.Unix(m.EndAt, 0)
+	}
+	if r.TTL > 0 && !m.CreatedAt.IsZero() {
+		r.ExpiresAt = m.CreatedAt.Add(r.TTL)
+	}
+	return r, nil
+}
+
+// RuleToModel 规则转换为存储模型（列表字段以 JSON 保存）
+func RuleToModel(r filter.Rule) filterstore.RuleModel {
+	m := filterstore.RuleModel{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+
+		Action:    string(r.Action),
+		Direction: uint8(r.Direction),
+		Priority:  r.Priority,
+		Enabled:   r.Enabled,
+
+		SrcCIDR: jsonString(r.SrcCIDR),
+		DstCIDR: jsonString(r.DstCIDR),
+		SrcPort: jsonString(r.SrcPort),
+		DstPort: jsonString(r.DstPort),
+		Tags:    jsonString(r.Tags),
+		Expr:    r.Expr,
+		RuleSet: r.Set,
+
+		TTL:     int64(r.TTL / time.Second),
+		Expired: r.Expired,
+	}
+	if !r.StartAt.IsZero() {
+		m.StartAt = r.StartAt.Unix()
+	}
+	if !r.EndAt.IsZero() {
+		m.EndAt = r.EndAt.Unix()
+	}
+	if len(r.Windows) > 0 {
+		m.Windows = jsonString(r.Windows)
+	}
+	if len(r.Geo) > 0 {
+		m.Geo = jsonString(r.Geo)
+	}
+	if len(r.ASN) > 0 {
+		m.ASN = jsonString(r.ASN)
+	}
+	if len(r.Payload) > 0 {
+		m.Payload = jsonString(r.Payload)
+	}
+	if len(r.Decoded) > 0 {
+		m.Decoded = jsonString(r.Decoded)
+	}
+	if r.Mirror != nil {
+		m.Mirror = jsonString(r.Mirror)
+	}
+	if r.Redirect != nil {
+		m.Redirect = jsonString(r.Redirect)
+	}
+	return m
+}
+
+// ModelToDTO 存储模型转换为接口格式
+func ModelToDTO(m filterstore.RuleModel) (filter.RuleDTO, error) {
+	r, err := ModelToRule(m)
+	if err != nil {
+		return filter.RuleDTO{}, err
+	}
+	return filter.RuleToDTO(*r, m.CreatedAt, m.UpdatedAt), nil
+}
+
+func jsonString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
 }
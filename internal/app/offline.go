@@ -0,0 +1,201 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"proxy-system-backend/internal/modules/pcap"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/modules/proxy"
+	"proxy-system-backend/internal/modules/shared"
+	"proxy-system-backend/internal/traffic"
+	"time"
+)
+
+// ImportOptions 离线导入 pcap 的选项
+type ImportOptions struct {
+	// 解码插件，为空时使用 traffic_hook 配置的插件
+	Plugin string
+	// 写入录制存储（Source=import）
+	Save bool
+	// 结果中包含逐包解码数据
+	Packets bool
+}
+
+// ImportedStream 一条重组后的连接
+type ImportedStream struct {
+	ConnID      string    `json:"conn_id"`
+	Protocol    string    `json:"protocol"`
+	Client      string    `json:"client"`
+	Server      string    `json:"server"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Packets     int       `json:"packets"`
+	Bytes       int64     `json:"bytes"`
+	Retransmits int       `json:"retransmits"`
+	GapBytes    int64     `json:"gap_bytes"`
+	Closed      string    `json:"closed"`
+}
+
+// ImportedPacket 重组后交给解码器的一块数据
+type ImportedPacket struct {
+	ConnID    string            `json:"conn_id"`
+	Time      time.Time         `json:"time"`
+	Direction traffic.Direction `json:"direction"`
+	Payload   []byte            `json:"payload"`
+	Plugin    string            `json:"plugin,omitempty"`
+	Decoded   json.RawMessage   `json:"decoded,omitempty"`
+}
+
+type ImportResult struct {
+	ProxyID string           `json:"proxy_id"`
+	Frames  int              `json:"frames"`
+	Skipped int              `json:"skipped"` // 非 TCP / UDP、IP 分片或无法解析的帧
+	Streams []ImportedStream `json:"streams"`
+	Packets []ImportedPacket `json:"packets,omitempty"`
+}
+
+type importConn struct {
+	connID string
+	hook   *proxyTrafficHook
+	out    traffic.PacketContext
+	in     traffic.PacketContext
+}
+
+// ImportPcap 读取 pcap / pcapng，重组 TCP 流后按实时流量的解码路径处理
+// 不经过改包和断点；Save 时结果写入录制存储
+func (a *App) ImportPcap(r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Plugin != "" {
+		mgr := a.GetPluginManager()
+		if mgr == nil {
+			return nil, fmt.Errorf("plugin service not configured")
+		}
+		if info, ok := mgr.Get(opts.Plugin); !ok || info.Status != plugin.PluginStatusRunning {
+			return nil, fmt.Errorf("plugin %s is not loaded", opts.Plugin)
+		}
+	}
+	if opts.Save && a.captureStore == nil {
+		return nil, fmt.Errorf("capture is not configured")
+	}
+
+	rd, err := pcap.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ImportResult{ProxyID: "import-" + shared.GenerateConnID()}
+	cfg := proxy.Config{Capture: opts.Save}
+	conns := make(map[int]*importConn)
+
+	asm := pcap.NewAssembler(
+		func(s *pcap.Stream, fromClient bool, ts time.Time, data []byte) {
+			c := conns[s.ID]
+			if c == nil {
+				c = a.newImportConn(res, s, cfg, opts)
+				conns[s.ID] = c
+			}
+
+			ctx := c.in
+			if fromClient {
+				ctx = c.out
+			}
+			ctx.Time = ts
+			ctx.Payload = data
+			c.hook.OnPacket(&ctx)
+		},
+		func(s *pcap.Stream) {
+			c := conns[s.ID]
+			if c == nil {
+				return
+			}
+			delete(conns, s.ID)
+			c.hook.OnClose()
+
+			proto := traffic.ProtocolTCP
+			if s.UDP {
+				proto = traffic.ProtocolUDP
+			}
+			res.Streams = append(res.Streams, ImportedStream{
+				ConnID:      c.connID,
+				Protocol:    proto.String(),
+				Client:      s.Client.String(),
+				Server:      s.Server.String(),
+				Start:       s.Start,
+				End:         s.End,
+				Packets:     s.Packets,
+				Bytes:       s.Bytes,
+				Retransmits: s.Retransmits,
+				GapBytes:    s.GapBytes,
+				Closed:      s.Closed,
+			})
+		},
+	)
+
+	var readErr error
+	for {
+		fr, err := rd.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+		res.Frames++
+
+		seg, err := pcap.Decode(fr.LinkType, fr.Data)
+		if err != nil {
+			res.Skipped++
+			continue
+		}
+		asm.Add(fr.Time, seg)
+	}
+	asm.Flush()
+
+	if readErr != nil {
+		return res, fmt.Errorf("read capture after %d frames: %w", res.Frames, readErr)
+	}
+	return res, nil
+}
+
+func (a *App) newImportConn(res *ImportResult, s *pcap.Stream, cfg proxy.Config, opts ImportOptions) *importConn {
+	connID := shared.GenerateConnID()
+
+	proto := traffic.ProtocolTCP
+	client, server := net.Addr(net.TCPAddrFromAddrPort(s.Client)), net.Addr(net.TCPAddrFromAddrPort(s.Server))
+	if s.UDP {
+		proto = traffic.ProtocolUDP
+		client, server = net.UDPAddrFromAddrPort(s.Client), net.UDPAddrFromAddrPort(s.Server)
+	}
+
+	out := traffic.NewCtx(connID, traffic.DirectionOut, proto, client, server)
+	in := traffic.NewCtx(connID, traffic.DirectionIn, proto, server, client)
+	out.StartAt, in.StartAt = s.Start, s.Start
+
+	hook := &proxyTrafficHook{
+		app:      a,
+		proxyID:  res.ProxyID,
+		connID:   connID,
+		proxyCfg: cfg,
+		offline:  true,
+		decoder:  opts.Plugin,
+	}
+	if opts.Packets {
+		hook.tap = func(ctx *traffic.PacketContext, pluginName string, decoded *plugin.DecodeResult) {
+			p := ImportedPacket{
+				ConnID:    connID,
+				Time:      ctx.Time,
+				Direction: ctx.Direction,
+				Payload:   append([]byte(nil), ctx.Payload...),
+			}
+			if decoded != nil {
+				p.Plugin = pluginName
+				p.Decoded = append(json.RawMessage(nil), decoded.Data...)
+			}
+			res.Packets = append(res.Packets, p)
+		}
+	}
+
+	return &importConn{connID: connID, hook: hook, out: *out, in: *in}
+}
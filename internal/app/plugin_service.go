@@ -133,6 +133,10 @@ func (s *PluginService) Decode(name string, isClient bool, payload []byte) (*plu
 
 }
 
+func (s *PluginService) Encode(name string, data []byte) ([]byte, error) {
+	return s.mgr.Encode(name, data)
+}
+
 // GetPluginManager 获取内部的 PluginManager
 func (s *PluginService) GetPluginManager() *plugin.Manager {
 	return s.mgr
@@ -2,23 +2,29 @@ package app
 
 import (
 	"fmt"
+	"proxy-system-backend/internal/modules/proxy"
 	"proxy-system-backend/internal/modules/shadowsocks"
+	"proxy-system-backend/internal/traffic"
+	"slices"
 	"sync"
 )
 
 type ProxyManager struct {
 	mu      sync.Mutex
 	servers map[string]*shadowsocks.Server
+	configs map[string]proxy.Config
 }
 
 func NewProxyManager() *ProxyManager {
 	return &ProxyManager{
 		servers: make(map[string]*shadowsocks.Server),
+		configs: make(map[string]proxy.Config),
 	}
 }
 
 func (pm *ProxyManager) StartProxy(
 	id string,
+	cfg proxy.Config,
 	server *shadowsocks.Server,
 ) error {
 	pm.mu.Lock()
@@ -29,6 +35,7 @@ func (pm *ProxyManager) StartProxy(
 	}
 
 	pm.servers[id] = server
+	pm.configs[id] = cfg
 
 	go func() {
 		if err := server.Serve(); err != nil {
@@ -50,5 +57,101 @@ func (pm *ProxyManager) StopProxy(id string) error {
 
 	_ = srv.Close() // 你可以实现 Close
 	delete(pm.servers, id)
+	delete(pm.configs, id)
 	return nil
 }
+
+// ConnInfo 带代理 ID 的活跃连接信息
+type ConnInfo struct {
+	ProxyID string `json:"proxy_id"`
+	shadowsocks.ConnInfo
+}
+
+// Connections 返回所有代理的活跃连接
+func (pm *ProxyManager) Connections() []ConnInfo {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	var out []ConnInfo
+	for id, srv := range pm.servers {
+		for _, c := range srv.Conns() {
+			out = append(out, ConnInfo{ProxyID: id, ConnInfo: c})
+		}
+	}
+	return out
+}
+
+// Config 运行中代理的配置
+func (pm *ProxyManager) Config(id string) (proxy.Config, bool) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	cfg, ok := pm.configs[id]
+	return cfg, ok
+}
+
+// ProxyInfo 运行中的代理
+type ProxyInfo struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	User         string `json:"user,omitempty"`
+	ListenAddr   string `json:"listen_addr"`
+	Method       string `json:"method"`
+	EnableFilter bool   `json:"enable_filter"`
+	Capture      bool   `json:"capture"`
+	Outbound     string `json:"outbound,omitempty"`
+	CreatedAt    int64  `json:"created_at"`
+	Connections  int    `json:"connections"`
+
+	// 适用的命名规则集（默认规则集总是适用，不列出）
+	RuleSets []string `json:"rule_sets"`
+}
+
+// Proxies 返回运行中的代理（按 ID 排序），RuleSets 由调用方填写
+func (pm *ProxyManager) Proxies() ([]ProxyInfo, []proxy.Config) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	ids := make([]string, 0, len(pm.configs))
+	for id := range pm.configs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	infos := make([]ProxyInfo, 0, len(ids))
+	cfgs := make([]proxy.Config, 0, len(ids))
+	for _, id := range ids {
+		cfg := pm.configs[id]
+		infos = append(infos, ProxyInfo{
+			ID:           id,
+			Name:         cfg.Name,
+			User:         cfg.User,
+			ListenAddr:   cfg.ListenAddr,
+			Method:       cfg.Method,
+			EnableFilter: cfg.EnableFilter,
+			Capture:      cfg.Capture,
+			Outbound:     cfg.Outbound,
+			CreatedAt:    cfg.CreatedAt,
+			Connections:  len(pm.servers[id].Conns()),
+		})
+		cfgs = append(cfgs, cfg)
+	}
+	return infos, cfgs
+}
+
+// Inject 找到连接所属的代理并注入数据包
+func (pm *ProxyManager) Inject(connID string, dir traffic.Direction, payload []byte) error {
+	pm.mu.Lock()
+	var srv *shadowsocks.Server
+	for _, s := range pm.servers {
+		if s.HasConn(connID) {
+			srv = s
+			break
+		}
+	}
+	pm.mu.Unlock()
+
+	if srv == nil {
+		return shadowsocks.ErrConnNotFound
+	}
+	return srv.Inject(connID, dir, payload)
+}
@@ -3,8 +3,13 @@ package app
 import (
 	"fmt"
 	"proxy-system-backend/internal/modules/filter"
+	"proxy-system-backend/internal/modules/mirror"
 	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/modules/proxy"
 	"proxy-system-backend/internal/traffic"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,43 +17,100 @@ type proxyTrafficHook struct {
 	proxyID string
 	connID  string
 	app     *App
-	//engine  *filter.Engine todo 后续补充
+	// 代理的 block_ips / block_ports
 	simpleFilter *SimpleFilter
-	// 插件调用器
+	// 插件调用器，首次使用时创建；注入（HTTP 处理协程）与双向 pipe 会并发使用
+	invokerOnce   sync.Once
 	pluginInvoker *plugin.PluginInvoker
+
+	// 代理配置（启动时的快照）
+	proxyCfg proxy.Config
+
+	// 过滤：连接级结果在首个数据包时求值，规则或规则集分配变化后重新求值
+	decisionMu   sync.Mutex
+	decided      bool
+	decision     filter.Decision
+	decisionGen  uint64
+	decisionGeo  uint64
+	decisionSets *ruleSetAssignments
+
+	// 连接两端的 GeoIP 信息，数据库重新加载后重新查询
+	geoMu     sync.Mutex
+	geoDone   bool
+	geoGen    uint64
+	clientGeo *traffic.GeoInfo
+	serverGeo *traffic.GeoInfo
+
+	// 适用的规则集，随分配快照更新
+	ruleSetsMu   sync.Mutex
+	ruleSetsFrom *ruleSetAssignments
+	ruleSets     []string
+
+	// 录制：随连接级结果开始 / 结束，也可由解码结果规则开启；captured 表示曾经录制过
+	captureMu        sync.Mutex
+	captured         bool
+	capturing        atomic.Bool
+	captureRequested atomic.Bool
+
+	// 镜像：随连接级结果中 action=mirror 的配置开启、切换或关闭
+	mirrorMu  sync.Mutex
+	mirrorCfg *mirror.Config
+	mirror    *mirror.Tee
+
+	// 离线导入：不改包、不挂起，指定解码插件，结果交给 tap
+	offline bool
+	decoder string
+	tap     func(ctx *traffic.PacketContext, pluginName string, decoded *plugin.DecodeResult)
 }
 
-func (h *proxyTrafficHook) initPluginInvoker() {
-	if h.pluginInvoker == nil {
+// invoker 返回插件调用器，插件服务未配置时为 nil
+func (h *proxyTrafficHook) invoker() *plugin.PluginInvoker {
+	h.invokerOnce.Do(func() {
 		// 从 PluginService 获取 Manager
 		if mgr := h.app.GetPluginManager(); mgr != nil {
 			h.pluginInvoker = plugin.NewPluginInvoker(mgr)
 		}
-	}
+	})
+	return h.pluginInvoker
 }
 
 func (h *proxyTrafficHook) OnPacket(ctx *traffic.PacketContext) bool {
-	//engine := h.app.FilterEngine()
-	//if engine == nil {
-	//	return true
-	//}
-	//if engine.Match(ctx) {
-	//
-	//}
-	if h.simpleFilter != nil {
-		if h.simpleFilter.Match(ctx) {
-			fmt.Println("跳过i", ctx.SrcPort, ctx.DstPort)
-			return true
-		}
+	// 注入的数据包已经写出，只做记录
+	h.enrichGeo(ctx)
+	if ctx.Injected {
+		h.recordInjected(ctx)
+		return true
 	}
 
-	// 使用配置的插件进行解码
-	if h.app.PluginMgr() != nil {
-		h.initPluginInvoker()
+	ctx.RuleSets = h.currentRuleSets()
+	h.traceFilter(ctx, nil)
+	d := h.decide(ctx)
+	h.app.FilterEngine().Account(d, len(ctx.Payload))
+	if d.Log {
+		h.logDecision(ctx, d)
+	}
+	switch d.Verdict {
+	case filter.ActionDeny:
+		// 连接级 deny 关闭连接；逐包规则的 deny 只丢弃当前数据包
+		if h.connDecision(ctx).Verdict == filter.ActionDeny {
+			return false
+		}
+		ctx.Payload = nil
+		return true
+	case filter.ActionReset:
+		ctx.Reset = true
+		return false
+	}
+	ctx.Tags = d.Tags
 
-		if plugin.IsTrafficHookEnabled() {
+	// 使用配置的插件进行解码（skip_decode 跳过，decode:<plugin> 指定插件）
+	if h.app.PluginMgr() != nil && !d.SkipDecode {
+		if plugin.IsTrafficHookEnabled() || h.decoder != "" || d.Decode != "" {
 			// 获取配置的解码插件名称
-			decoderPlugin := h.getDecoderPlugin()
+			decoderPlugin := d.Decode
+			if decoderPlugin == "" {
+				decoderPlugin = h.getDecoderPlugin()
+			}
 
 			if decoderPlugin != "" {
 				// 尝试使用配置的插件解码
@@ -60,16 +122,40 @@ func (h *proxyTrafficHook) OnPacket(ctx *traffic.PacketContext) bool {
 						Type: EventParsed,
 						Data: data,
 					})
+					// 解码结果规则：告警 / 标签 / 录制 / 丢包 / 触发改包
+					if h.app.FilterEngine().HasDecodedRules() {
+						h.traceFilter(ctx, data.Data)
+					}
+					dd := h.decideDecoded(ctx, decoderPlugin, data)
+					switch dd.Verdict {
+					case filter.ActionDeny:
+						ctx.Payload = nil
+						return true
+					case filter.ActionReset:
+						ctx.Reset = true
+						return false
+					}
+					// 改包：decode → 规则 → encode
+					h.rewrite(ctx, decoderPlugin, data, slices.Concat(d.Rewrite, dd.Rewrite))
+					// 断点：挂起等待操作员处理
+					h.pause(ctx, decoderPlugin, data)
+					h.record(ctx, decoderPlugin, data)
 					return true
 				} else {
 					// 解码失败，根据回退行为处理
-					return h.handleDecodeError(ctx, err, decoderPlugin)
+					ok := h.handleDecodeError(ctx, err, decoderPlugin)
+					if ok {
+						h.record(ctx, "", nil)
+					}
+					return ok
 				}
 			}
 		}
 	}
 
 	// 没有配置插件或插件未启用，发送原始流量事件
+	h.pause(ctx, "", nil)
+	h.record(ctx, "", nil)
 	h.app.Emit(Event{
 		Type: EventTraffic,
 		Data: map[string]any{
@@ -83,6 +169,10 @@ func (h *proxyTrafficHook) OnPacket(ctx *traffic.PacketContext) bool {
 
 // getDecoderPlugin 获取解码插件名称
 func (h *proxyTrafficHook) getDecoderPlugin() string {
+	if h.decoder != "" {
+		return h.decoder
+	}
+
 	// 优先使用配置的插件
 	decoderPlugin := plugin.GetTrafficHookDecoderPlugin()
 	if decoderPlugin != "" {
@@ -102,12 +192,8 @@ func (h *proxyTrafficHook) getDecoderPlugin() string {
 
 // decodeWithPlugin 使用插件解码流量数据（使用新的调用器）
 func (h *proxyTrafficHook) decodeWithPlugin(pluginName string, ctx *traffic.PacketContext) (*plugin.DecodeResult, error) {
-	// 检查调用器是否已初始化
-	if h.pluginInvoker == nil {
-		h.initPluginInvoker()
-	}
-
-	if h.pluginInvoker == nil {
+	invoker := h.invoker()
+	if invoker == nil {
 		return nil, fmt.Errorf("plugin invoker not initialized")
 	}
 
@@ -124,7 +210,6 @@ func (h *proxyTrafficHook) decodeWithPlugin(pluginName string, ctx *traffic.Pack
 	req.SetMetadata("conn_id", h.connID)
 	req.SetMetadata("timestamp", time.Now().Unix())
 	req.SetMetadata("direction", ctx.Direction.String())
-	fmt.Println(req.IsClient)
 	// 设置超时
 	timeout := time.Duration(plugin.GetTrafficHookTimeout()) * time.Millisecond
 	req.Context.SetTimeout(timeout)
@@ -133,7 +218,7 @@ func (h *proxyTrafficHook) decodeWithPlugin(pluginName string, ctx *traffic.Pack
 	// req.Context.EnableRetryWithConfig(3, 100*time.Millisecond)
 
 	// 调用插件
-	return h.pluginInvoker.InvokeDecode(req)
+	return invoker.InvokeDecode(req)
 }
 
 // handleDecodeError 处理解码错误
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"proxy-system-backend/internal/modules/proxy"
+	"proxy-system-backend/internal/modules/redirect"
+	"proxy-system-backend/internal/traffic"
+)
+
+// 重定向的来源
+const (
+	redirectByRule  = "rule"  // 过滤规则 action=redirect
+	redirectByProxy = "proxy" // 代理的 redirect 配置
+)
+
+// proxyRedirector 在连接目标之前按过滤规则和代理配置改写目标地址
+type proxyRedirector struct {
+	app     *App
+	proxyID string
+	cfg     proxy.Config
+	table   *redirect.Table
+}
+
+// Redirect 过滤规则 action=redirect 优先，其次按顺序匹配代理的 redirect 配置；
+// 重定向时推送 EventRedirected。
+// 与录制 / 中间人一样，redirect 规则不受 enable_filter 影响；
+// 这里只查询规则不计入统计，连接的命中统计由 connDecision 记录
+func (r *proxyRedirector) Redirect(ctx *traffic.PacketContext) string {
+	ctx.RuleSets = r.app.RuleSetsFor(r.cfg)
+	r.app.lookupGeo(ctx)
+
+	source := redirectByRule
+	target := r.app.filterEngine.Decide(ctx).Redirect
+	if target == nil {
+		host := ctx.Domain
+		if host == "" && ctx.DstIP != nil {
+			host = ctx.DstIP.String()
+		}
+		t, ok := r.table.Lookup(host, ctx.DstPort)
+		if !ok {
+			return ""
+		}
+		source, target = redirectByProxy, &t
+	}
+
+	addr := target.Apply(ctx.OrigDst)
+	if target.RewriteHost {
+		ctx.UpstreamHost = redirect.HostHeader(addr)
+	}
+	r.app.Emit(Event{
+		Type: EventRedirected,
+		Data: map[string]any{
+			"proxy_id":      r.proxyID,
+			"conn_id":       ctx.ConnID,
+			"client":        addrString(ctx.SrcAddr),
+			"orig_dst":      ctx.OrigDst,
+			"effective_dst": addr,
+			"upstream_host": ctx.UpstreamHost,
+			"source":        source,
+		},
+	})
+	return addr
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/modules/replay"
+	capturestore "proxy-system-backend/internal/storage/capture"
+	"time"
+)
+
+const replayDialTimeout = 10 * time.Second
+
+// pluginCodec 基于插件的回放编解码
+type pluginCodec struct {
+	invoker *plugin.PluginInvoker
+	name    string
+	connID  string
+}
+
+func (c *pluginCodec) Decode(payload []byte, isClient bool) (json.RawMessage, error) {
+	req := plugin.NewDecodeCallContext(c.name, isClient, payload)
+	req.SetMetadata("conn_id", c.connID)
+	req.SetMetadata("replay", true)
+	req.Context.SetTimeout(time.Duration(plugin.GetTrafficHookTimeout()) * time.Millisecond)
+
+	res, err := c.invoker.InvokeDecode(req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+func (c *pluginCodec) Encode(data json.RawMessage) ([]byte, error) {
+	req := plugin.NewEncodeCallContext(c.name, data)
+	req.SetMetadata("conn_id", c.connID)
+	req.SetMetadata("replay", true)
+	req.Context.SetTimeout(time.Duration(plugin.GetTrafficHookTimeout()) * time.Millisecond)
+	return c.invoker.InvokeEncode(req)
+}
+
+// StartReplay 将录制会话的客户端数据回放到目标服务器（默认录制时的目标）
+func (a *App) StartReplay(cfg replay.Config) (*replay.Run, error) {
+	sess, msgs, recorded, err := a.loadRecording(cfg.ConnID)
+	if err != nil {
+		return nil, err
+	}
+	target := cfg.Target
+	if target == "" {
+		target = sess.Dst
+	}
+	pluginName := cfg.Plugin
+	if pluginName == "" {
+		pluginName = recorded
+	}
+
+	// 指定了其它插件时，录制的解码结果不能直接比较
+	if cfg.Plugin != "" {
+		for i := range msgs {
+			msgs[i].Decoded = nil
+		}
+	}
+
+	var codec replay.Codec
+	if mgr := a.GetPluginManager(); pluginName != "" && mgr != nil {
+		codec = &pluginCodec{invoker: plugin.NewPluginInvoker(mgr), name: pluginName, connID: cfg.ConnID}
+	}
+
+	dial := func(ctx context.Context, addr string) (net.Conn, error) {
+		d := net.Dialer{Timeout: replayDialTimeout}
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	return a.replays.Start(cfg, target, pluginName, msgs, codec, dial)
+}
+
+// loadRecording 读取录制会话及其全部数据，并返回录制时使用的解码插件
+func (a *App) loadRecording(connID string) (*capturestore.SessionModel, []replay.Message, string, error) {
+	store := a.CaptureStore()
+	if store == nil {
+		return nil, nil, "", fmt.Errorf("capture is not configured")
+	}
+
+	ctx := context.Background()
+	sess, err := store.Session(ctx, connID)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("session %s: %w", connID, err)
+	}
+
+	var msgs []replay.Message
+	var pluginName string
+	for offset := 0; ; {
+		list, total, err := store.Packets(ctx, connID, offset, 500)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		for _, p := range list {
+			msgs = append(msgs, replay.Message{
+				Time:      p.Time,
+				Direction: p.Direction,
+				Payload:   p.Payload,
+				Decoded:   p.Decoded,
+			})
+			if pluginName == "" {
+				pluginName = p.Plugin
+			}
+		}
+		offset += len(list)
+		if len(list) == 0 || int64(offset) >= total {
+			break
+		}
+	}
+	return sess, msgs, pluginName, nil
+}
+
+func (a *App) Replays() *replay.Manager {
+	return a.replays
+}
+
+// emitReplay 推送回放进度（每轮结束和状态变化）
+func (a *App) emitReplay(rep replay.Report, ex *replay.Exchange) {
+	rep.Exchanges = nil
+	a.Emit(Event{
+		Type: EventReplay,
+		Data: map[string]any{
+			"replay":   rep,
+			"exchange": ex,
+		},
+	})
+}
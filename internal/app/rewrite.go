@@ -0,0 +1,79 @@
+package app
+
+import (
+	"encoding/hex"
+	"fmt"
+	"proxy-system-backend/internal/modules/plugin"
+	"proxy-system-backend/internal/modules/rewrite"
+	"proxy-system-backend/internal/traffic"
+	"time"
+)
+
+// rewrite 对解码结果应用改包规则（以及过滤规则 rewrite:<id> 触发的规则），
+// 改动后通过插件 Encode 重新编码并替换 ctx.Payload；任何一步失败都保持原始 payload 不变
+func (h *proxyTrafficHook) rewrite(ctx *traffic.PacketContext, pluginName string, decoded *plugin.DecodeResult, trigger []int64) {
+	rw := h.app.Rewriter()
+	if rw == nil || (rw.Empty() && len(trigger) == 0) || h.offline {
+		return
+	}
+
+	res, err := rw.ApplyWith(ctx.Direction, pluginName, decoded.Data, trigger)
+	if err != nil {
+		fmt.Printf("[Rewrite] apply failed: %v\n", err)
+		return
+	}
+	if !res.Changed() {
+		return
+	}
+
+	encoded, err := h.encodeWithPlugin(pluginName, res.After, res.Length)
+	if err != nil {
+		fmt.Printf("[Rewrite] encode with plugin '%s' failed: %v\n", pluginName, err)
+		return
+	}
+
+	before := ctx.Payload
+	ctx.Payload = encoded
+
+	h.app.Emit(Event{
+		Type: EventRewritten,
+		Data: map[string]any{
+			"proxy_id":   h.proxyID,
+			"conn_id":    h.connID,
+			"direction":  ctx.Direction.String(),
+			"plugin":     pluginName,
+			"rules":      res.Rules,
+			"changes":    res.Changes,
+			"before":     res.Before,
+			"after":      res.After,
+			"before_hex": hex.EncodeToString(before),
+			"after_hex":  hex.EncodeToString(encoded),
+		},
+	})
+}
+
+// encodeWithPlugin 调用插件 Encode，并按需修正长度字段
+func (h *proxyTrafficHook) encodeWithPlugin(pluginName string, data []byte, length *rewrite.LengthField) ([]byte, error) {
+	invoker := h.invoker()
+	if invoker == nil {
+		return nil, fmt.Errorf("plugin invoker not initialized")
+	}
+
+	req := plugin.NewEncodeCallContext(pluginName, data)
+	req.SetMetadata("proxy_id", h.proxyID)
+	req.SetMetadata("conn_id", h.connID)
+	req.Context.SetTimeout(time.Duration(plugin.GetTrafficHookTimeout()) * time.Millisecond)
+
+	encoded, err := invoker.InvokeEncode(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("plugin returned empty payload")
+	}
+
+	if length != nil {
+		return length.Apply(encoded)
+	}
+	return encoded, nil
+}
@@ -22,7 +22,7 @@ func NewSimpleFilter(blockIPs []string, blockPorts []string) (*SimpleFilter, err
 	f := &SimpleFilter{}
 
 	for _, ip := range blockIPs {
-		_, netw, err := net.ParseCIDR(ip)
+		netw, err := parseIPNet(ip)
 		if err != nil {
 			return nil, err
 		}
@@ -39,7 +39,11 @@ func NewSimpleFilter(blockIPs []string, blockPorts []string) (*SimpleFilter, err
 
 	return f, nil
 }
+
+// Match 返回 true 表示放行，目标命中 block_ips / block_ports 时返回 false。
+// 按客户端视角匹配，两个方向的数据包结果一致
 func (f *SimpleFilter) Match(ctx *traffic.PacketContext) bool {
+	ctx = ctx.ClientView()
 	ip := ctx.DstIP
 	if ip != nil {
 		for _, n := range f.BlockIPs {
@@ -59,6 +63,23 @@ func (f *SimpleFilter) Match(ctx *traffic.PacketContext) bool {
 	return true
 }
 
+// parseIPNet 支持 CIDR 和单个 IP
+func parseIPNet(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip: %s", s)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
 func parsePortRange(s string) (PortRange, error) {
 	s = strings.TrimSpace(s)
 	if s == "" {
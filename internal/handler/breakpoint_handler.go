@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/breakpoint"
+	"strconv"
+)
+
+type BreakpointHandler struct {
+	app *app.App
+}
+
+func NewBreakpointHandler(a *app.App) *BreakpointHandler {
+	return &BreakpointHandler{app: a}
+}
+
+func (h *BreakpointHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.app.Breakpoints().Config()})
+}
+
+func (h *BreakpointHandler) SetConfig(c *gin.Context) {
+	var req breakpoint.Config
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err := h.app.Breakpoints().SetConfig(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": req})
+}
+
+func (h *BreakpointHandler) ListRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.app.Breakpoints().Rules()})
+}
+
+func (h *BreakpointHandler) SaveRule(c *gin.Context) {
+	var req breakpoint.Rule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	req.ID = 0
+	if idStr := c.Param("id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid rule id"})
+			return
+		}
+		req.ID = id
+	}
+
+	rule, err := h.app.Breakpoints().Upsert(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+func (h *BreakpointHandler) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid rule id"})
+		return
+	}
+	if !h.app.Breakpoints().Delete(id) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "rule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Pending 当前挂起的数据包
+func (h *BreakpointHandler) Pending(c *gin.Context) {
+	list := h.app.Breakpoints().Pending()
+
+	data := make([]gin.H, 0, len(list))
+	for _, held := range list {
+		data = append(data, gin.H{
+			"held":        held,
+			"payload_hex": hex.EncodeToString(held.Payload),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// Resolve 放行 / 丢弃 / 修改后放行
+func (h *BreakpointHandler) Resolve(c *gin.Context) {
+	var req breakpoint.Decision
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	req.TimedOut = false
+
+	if err := h.app.Breakpoints().Resolve(c.Param("id"), req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"net/http"
+	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/capture"
+	capturestore "proxy-system-backend/internal/storage/capture"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxPageSize = 500
+
+type CaptureHandler struct {
+	app *app.App
+}
+
+func NewCaptureHandler(a *app.App) *CaptureHandler {
+	return &CaptureHandler{app: a}
+}
+
+// ListSessions GET /capture/sessions?proxy_id=&dst=&domain=&from=&to=&page=&page_size=
+func (h *CaptureHandler) ListSessions(c *gin.Context) {
+	store := h.app.CaptureStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "capture is not configured"})
+		return
+	}
+
+	page, size := pageParams(c)
+	q := capturestore.SessionQuery{
+		ProxyID: c.Query("proxy_id"),
+		Dst:     c.Query("dst"),
+		Domain:  c.Query("domain"),
+		From:    queryInt64(c, "from", 0),
+		To:      queryInt64(c, "to", 0),
+		Offset:  (page - 1) * size,
+		Limit:   size,
+	}
+
+	list, total, err := store.Sessions(c.Request.Context(), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      list,
+		"total":     total,
+		"page":      page,
+		"page_size": size,
+	})
+}
+
+func (h *CaptureHandler) GetSession(c *gin.Context) {
+	store := h.app.CaptureStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "capture is not configured"})
+		return
+	}
+
+	s, err := store.Session(c.Request.Context(), c.Param("connID"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": s})
+}
+
+// ListPackets GET /capture/sessions/:connID/packets?page=&page_size=
+func (h *CaptureHandler) ListPackets(c *gin.Context) {
+	store := h.app.CaptureStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "capture is not configured"})
+		return
+	}
+
+	page, size := pageParams(c)
+	list, total, err := store.Packets(c.Request.Context(), c.Param("connID"), (page-1)*size, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      list,
+		"total":     total,
+		"page":      page,
+		"page_size": size,
+	})
+}
+
+func pageParams(c *gin.Context) (page, size int) {
+	page = int(queryInt64(c, "page", 1))
+	size = int(queryInt64(c, "page_size", 100))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 100
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	return page, size
+}
+
+func queryInt64(c *gin.Context, key string, def int64) int64 {
+	v, err := strconv.ParseInt(c.Query(key), 10, 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// Export GET /capture/export?conn_id=a,b&proxy_id=&dst=&domain=&from=&to=&split=conn|time&window=10m
+// 单个文件直接返回 pcapng，按连接 / 时间拆分时返回 zip
+func (h *CaptureHandler) Export(c *gin.Context) {
+	store := h.app.CaptureStore()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "capture is not configured"})
+		return
+	}
+
+	opts, err := exportOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if opts.Split == capture.SplitNone {
+		files, err := store.Export(c.Request.Context(), opts, func(name string) (io.Writer, error) {
+			c.Header("Content-Type", "application/vnd.tcpdump.pcap")
+			c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+			c.Status(http.StatusOK)
+			return c.Writer, nil
+		})
+		exportDone(c, files, err)
+		return
+	}
+
+	zw := zip.NewWriter(c.Writer)
+	files, err := store.Export(c.Request.Context(), opts, func(name string) (io.Writer, error) {
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "application/zip")
+			c.Header("Content-Disposition", `attachment; filename="capture.zip"`)
+			c.Status(http.StatusOK)
+		}
+		return zw.Create(name)
+	})
+	if files > 0 {
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	exportDone(c, files, err)
+}
+
+// exportDone 尚未写出任何内容时以 JSON 返回错误，否则只能记录日志
+func exportDone(c *gin.Context, files int, err error) {
+	if files == 0 && err == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "no packets matched"})
+		return
+	}
+	if err == nil {
+		return
+	}
+	if !c.Writer.Written() {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	log.Printf("[Capture] export aborted: %v", err)
+}
+
+func exportOptions(c *gin.Context) (capture.ExportOptions, error) {
+	opts := capture.ExportOptions{
+		Query: capturestore.SessionQuery{
+			ProxyID: c.Query("proxy_id"),
+			Dst:     c.Query("dst"),
+			Domain:  c.Query("domain"),
+		},
+		Split: c.Query("split"),
+	}
+	for _, id := range strings.Split(c.Query("conn_id"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			opts.ConnIDs = append(opts.ConnIDs, id)
+		}
+	}
+
+	// from / to（unix 毫秒）限定数据包时间；会话在 to 之后开始的不需要读取
+	if from := queryInt64(c, "from", 0); from > 0 {
+		opts.From = time.UnixMilli(from)
+	}
+	if to := queryInt64(c, "to", 0); to > 0 {
+		opts.To = time.UnixMilli(to)
+		opts.Query.To = to
+	}
+
+	if w := c.Query("window"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil {
+			return opts, fmt.Errorf("invalid window: %w", err)
+		}
+		opts.Window = d
+	}
+	return opts, nil
+}
+
+// Import POST /capture/import（multipart：file，可选 plugin / save / packets）
+// 离线分析 pcap / pcapng：重组 TCP 流并交给解码插件，save=true 时写入录制存储
+func (h *CaptureHandler) Import(c *gin.Context) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "file is required"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	res, err := h.app.ImportPcap(f, app.ImportOptions{
+		Plugin:  c.PostForm("plugin"),
+		Save:    c.PostForm("save") == "true",
+		Packets: c.DefaultPostForm("packets", "true") == "true",
+	})
+	if err != nil && res == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	resp := gin.H{"success": true, "data": res}
+	if err != nil {
+		// 文件中途损坏：返回已处理的部分
+		resp["warning"] = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
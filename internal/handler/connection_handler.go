@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/shadowsocks"
+	"proxy-system-backend/internal/traffic"
+)
+
+type ConnectionHandler struct {
+	app *app.App
+}
+
+func NewConnectionHandler(a *app.App) *ConnectionHandler {
+	return &ConnectionHandler{app: a}
+}
+
+// InjectRequest out：发往服务器，in：发往客户端；hex / base64 / json 三选一
+type InjectRequest struct {
+	Direction string          `json:"direction" binding:"required"`
+	Hex       string          `json:"hex,omitempty"`
+	Base64    string          `json:"base64,omitempty"`
+	JSON      json.RawMessage `json:"json,omitempty"`
+	Plugin    string          `json:"plugin,omitempty"`
+}
+
+func (h *ConnectionHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.app.Connections(),
+	})
+}
+
+func (h *ConnectionHandler) Inject(c *gin.Context) {
+	var req InjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	dir, err := traffic.ParseDirection(req.Direction)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	in := app.InjectRequest{
+		ConnID:    c.Param("connID"),
+		Direction: dir,
+		JSON:      req.JSON,
+		Plugin:    req.Plugin,
+	}
+
+	set := 0
+	for _, ok := range []bool{req.Hex != "", req.Base64 != "", len(req.JSON) > 0} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		err = errors.New("only one of hex, base64 or json may be set")
+	case req.Hex != "":
+		in.Payload, err = hex.DecodeString(req.Hex)
+	case req.Base64 != "":
+		in.Payload, err = base64.StdEncoding.DecodeString(req.Base64)
+	case len(req.JSON) == 0:
+		err = errors.New("one of hex, base64 or json is required")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	written, err := h.app.Inject(in)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, shadowsocks.ErrConnNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"bytes": len(written),
+			"hex":   hex.EncodeToString(written),
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"proxy-system-backend/internal/app"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInjectStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/connections/:connID/inject", NewConnectionHandler(app.New()).Inject)
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{"unknown connection", `{"direction":"out","hex":"0102"}`, http.StatusNotFound},
+		{"bad hex", `{"direction":"out","hex":"zz"}`, http.StatusBadRequest},
+		{"bad base64", `{"direction":"in","base64":"!!"}`, http.StatusBadRequest},
+		{"no payload", `{"direction":"out"}`, http.StatusBadRequest},
+		{"hex and base64", `{"direction":"out","hex":"0102","base64":"AQI="}`, http.StatusBadRequest},
+		{"hex and json", `{"direction":"out","hex":"0102","json":{"a":1}}`, http.StatusBadRequest},
+		{"bad direction", `{"direction":"sideways","hex":"0102"}`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/connections/missing/inject", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tc.name, rr.Code, tc.want, rr.Body)
+		}
+	}
+}
@@ -1,22 +1,517 @@
 package handler
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"path/filepath"
 	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/filter"
+	"proxy-system-backend/internal/modules/plugin"
+	filterstore "proxy-system-backend/internal/storage/filter"
+	"proxy-system-backend/internal/traffic"
+	"strconv"
+	"strings"
 )
 
 type FilterHandler struct {
 	app *app.App
 }
 
-//func (h *FilterHandler) ListRules(c *gin.Context) {
-//	//rules, err := h.app.FilterEngine().
-//	if err != nil {
-//		c.JSON(500, gin.H{"success": false, "error": err.Error()})
-//		return
-//	}
-//
-//	c.JSON(200, gin.H{
-//		"success": true,
-//		"data":    rules,
-//	})
-//}
+func NewFilterHandler(a *app.App) *FilterHandler {
+	return &FilterHandler{app: a}
+}
+
+type reorderRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// evaluateRequest 合成数据包描述，或 packet_id 引用录制的数据包；payload_hex / payload 二选一
+type evaluateRequest struct {
+	PacketID int64 `json:"packet_id,omitempty"`
+
+	// 适用的规则集；不填时按 proxy_id（或录制数据包所属代理）的分配解析
+	RuleSets []string `json:"rule_sets,omitempty"`
+	ProxyID  string   `json:"proxy_id,omitempty"`
+
+	Client     string          `json:"client,omitempty"` // ip:port
+	Server     string          `json:"server,omitempty"` // ip:port
+	Direction  string          `json:"direction,omitempty"`
+	Protocol   string          `json:"protocol,omitempty"` // tcp / udp
+	Domain     string          `json:"domain,omitempty"`
+	PayloadHex string          `json:"payload_hex,omitempty"`
+	Payload    string          `json:"payload,omitempty"`
+	Decoded    json.RawMessage `json:"decoded,omitempty"`
+}
+
+// migrateRequest legacy_db 为旧版 plugins.db 的路径，不填时使用插件目录下的 plugins.db
+type migrateRequest struct {
+	LegacyDB string `json:"legacy_db,omitempty"`
+	DryRun   bool   `json:"dry_run,omitempty"`
+}
+
+// 旧版数据库只能从数据目录或插件目录中读取
+const legacyDataDir = "./data"
+
+// 导入的规则文件大小上限
+const maxRuleFileSize = 16 << 20
+
+type importRulesRequest struct {
+	Rules []filter.RuleDTO `json:"rules"`
+	// 先删除现有规则
+	Replace bool `json:"replace,omitempty"`
+}
+
+func (h *FilterHandler) ListRules(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	rules, err := svc.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rules,
+	})
+}
+
+func (h *FilterHandler) GetRule(c *gin.Context) {
+	svc, id, ok := h.serviceAndID(c)
+	if !ok {
+		return
+	}
+	rule, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+func (h *FilterHandler) CreateRule(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	var req filter.RuleDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	rule, err := svc.Create(c.Request.Context(), req)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+func (h *FilterHandler) UpdateRule(c *gin.Context) {
+	svc, id, ok := h.serviceAndID(c)
+	if !ok {
+		return
+	}
+	var req filter.RuleDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	rule, err := svc.Update(c.Request.Context(), id, req)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+func (h *FilterHandler) DeleteRule(c *gin.Context) {
+	svc, id, ok := h.serviceAndID(c)
+	if !ok {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), id); err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// EnableRule POST /filter/rules/:id/enable
+func (h *FilterHandler) EnableRule(c *gin.Context) {
+	h.setEnabled(c, true)
+}
+
+// DisableRule POST /filter/rules/:id/disable
+func (h *FilterHandler) DisableRule(c *gin.Context) {
+	h.setEnabled(c, false)
+}
+
+func (h *FilterHandler) setEnabled(c *gin.Context, enabled bool) {
+	svc, id, ok := h.serviceAndID(c)
+	if !ok {
+		return
+	}
+	rule, err := svc.SetEnabled(c.Request.Context(), id, enabled)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+// Reorder POST /filter/rules/reorder 按 ids 顺序（优先级从高到低）重排全部规则
+func (h *FilterHandler) Reorder(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	var req reorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	rules, err := svc.Reorder(c.Request.Context(), req.IDs)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rules})
+}
+
+// Import POST /filter/rules/import 批量导入，全部成功或全部不生效
+func (h *FilterHandler) Import(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	var req importRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	rules, err := svc.Import(c.Request.Context(), req.Rules, req.Replace)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rules})
+}
+
+// Export GET /filter/export?rule_set=&format=json|yaml 导出规则集为规则文件
+func (h *FilterHandler) Export(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	set := c.Query("rule_set")
+	format := c.DefaultQuery("format", filter.FormatJSON)
+
+	f, err := svc.ExportRuleSet(c.Request.Context(), set)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	data, err := filter.MarshalRuleFile(f, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	name := set
+	if name == "" {
+		name = "default"
+	}
+	contentType := "application/json"
+	if format == filter.FormatYAML {
+		contentType = "application/yaml"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.rules.%s"`, name, format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ImportFile POST /filter/import?format=json|yaml|blocklist|iptables 导入请求体中的规则文件，
+// 返回与规则集现有规则的差异；dry_run=true 时不应用
+func (h *FilterHandler) ImportFile(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRuleFileSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if len(data) > maxRuleFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": "rule file too large"})
+		return
+	}
+
+	verr := &filter.ValidationError{}
+	flag := func(name string) bool {
+		v, err := strconv.ParseBool(c.DefaultQuery(name, "false"))
+		if err != nil {
+			verr.Fields = append(verr.Fields, filter.FieldError{Field: name, Message: "must be true or false"})
+		}
+		return v
+	}
+	sync, dryRun := flag("sync"), flag("dry_run")
+	priority, err := strconv.Atoi(c.DefaultQuery("priority", "0"))
+	if err != nil {
+		verr.Fields = append(verr.Fields, filter.FieldError{Field: "priority", Message: "must be an integer"})
+	}
+	if len(verr.Fields) > 0 {
+		ruleError(c, verr)
+		return
+	}
+
+	var (
+		f     filter.RuleFile
+		notes []string
+	)
+	switch format := c.DefaultQuery("format", filter.FormatJSON); format {
+	case filter.FormatJSON, filter.FormatYAML:
+		f, err = filter.ParseRuleFile(data, format)
+	case filter.FormatBlocklist:
+		opts := filter.BlocklistOptions{
+			Action:   c.Query("action"),
+			Priority: priority,
+			Source:   c.Query("match") == "src",
+		}
+		for _, tag := range strings.Split(c.Query("tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				opts.Tags = append(opts.Tags, tag)
+			}
+		}
+		f, err = filter.ParseBlocklist(data, opts)
+	case filter.FormatIPTables:
+		f, notes, err = filter.ParseIPTables(data, priority)
+	default:
+		err = &filter.ValidationError{Fields: []filter.FieldError{{Field: "format", Message: "must be json, yaml, blocklist or iptables"}}}
+	}
+	if err != nil {
+		var ve *filter.ValidationError
+		if !errors.As(err, &ve) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		ruleError(c, err)
+		return
+	}
+	if set, ok := c.GetQuery("rule_set"); ok {
+		// 参数优先于文件中的 rule_set，可把别的团队的文件导入自己的规则集
+		f.RuleSet = set
+	}
+
+	diff, err := svc.ImportRuleFile(c.Request.Context(), f, sync, dryRun)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	diff.Notes = notes
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": diff})
+}
+
+// GetConfig GET /filter/config 过滤全局设置
+func (h *FilterHandler) GetConfig(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	cfg, err := svc.Config(c.Request.Context())
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
+
+// UpdateConfig PUT /filter/config 修改默认动作
+func (h *FilterHandler) UpdateConfig(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	var req app.FilterConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	cfg, err := svc.UpdateConfig(c.Request.Context(), req)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
+
+// Migrate POST /filter/migrate 迁移旧版 packet.PacketFilter 的规则和配置
+func (h *FilterHandler) Migrate(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	var req migrateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	path, err := legacyDBPath(req.LegacyDB)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "legacy_db: " + err.Error()})
+		return
+	}
+	src, closeDB, err := filterstore.OpenLegacy(path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "open legacy_db: " + err.Error()})
+		return
+	}
+	defer closeDB()
+
+	report, err := svc.MigrateLegacy(c.Request.Context(), src, req.DryRun)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// Stats GET /filter/stats 规则命中统计
+func (h *FilterHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.app.FilterEngine().Stats()})
+}
+
+// ResetStats POST /filter/stats/reset
+func (h *FilterHandler) ResetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.app.ResetFilterStats()})
+}
+
+// Evaluate POST /filter/evaluate 用当前规则试算一个数据包，返回逐条规则的求值过程
+func (h *FilterHandler) Evaluate(c *gin.Context) {
+	var req evaluateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	probe := app.FilterProbe{
+		PacketID: req.PacketID,
+		RuleSets: req.RuleSets,
+		ProxyID:  req.ProxyID,
+		Client:   req.Client,
+		Server:   req.Server,
+		Domain:   req.Domain,
+		Payload:  []byte(req.Payload),
+		Decoded:  req.Decoded,
+	}
+	verr := &filter.ValidationError{}
+	var err error
+	if probe.Direction, err = traffic.ParseDirection(req.Direction); err != nil {
+		verr.Fields = append(verr.Fields, filter.FieldError{Field: "direction", Message: err.Error()})
+	}
+	switch req.Protocol {
+	case "", traffic.ProtocolTCP.String():
+		probe.Protocol = traffic.ProtocolTCP
+	case traffic.ProtocolUDP.String():
+		probe.Protocol = traffic.ProtocolUDP
+	default:
+		verr.Fields = append(verr.Fields, filter.FieldError{Field: "protocol", Message: "must be tcp or udp"})
+	}
+	if req.PayloadHex != "" {
+		if probe.Payload, err = hex.DecodeString(strings.ReplaceAll(req.PayloadHex, " ", "")); err != nil {
+			verr.Fields = append(verr.Fields, filter.FieldError{Field: "payload_hex", Message: err.Error()})
+		}
+	}
+	if len(verr.Fields) > 0 {
+		ruleError(c, verr)
+		return
+	}
+
+	trace, err := h.app.EvaluateFilter(c.Request.Context(), probe)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "packet not found"})
+			return
+		}
+		ruleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": trace})
+}
+
+func (h *FilterHandler) service(c *gin.Context) *app.FilterService {
+	svc := h.app.FilterService()
+	if svc == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "filter rules storage is not configured"})
+	}
+	return svc
+}
+
+func (h *FilterHandler) serviceAndID(c *gin.Context) (*app.FilterService, int64, bool) {
+	svc := h.service(c)
+	if svc == nil {
+		return nil, 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid rule id"})
+		return nil, 0, false
+	}
+	return svc, id, true
+}
+
+// ruleError 校验错误逐字段返回，规则不存在返回 404
+func ruleError(c *gin.Context, err error) {
+	var verr *filter.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "validation failed", "fields": verr.Fields})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "rule not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	}
+}
+
+// legacyDBPath 解析 legacy_db：不填时为配置的插件目录下的 plugins.db，
+// 否则（解析符号链接后）必须位于数据目录或插件目录中
+func legacyDBPath(p string) (string, error) {
+	pluginDir := plugin.GetConfig().PluginDir
+	if p == "" {
+		p = filepath.Join(pluginDir, "plugins.db")
+	}
+	path, err := filepath.Abs(p)
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range []string{legacyDataDir, pluginDir} {
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if r, err := filepath.EvalSymlinks(root); err == nil {
+			root = r
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside the data directory (%s) and plugin directory (%s)", p, legacyDataDir, pluginDir)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"proxy-system-backend/internal/app"
+)
+
+type GeoIPHandler struct {
+	app *app.App
+}
+
+func NewGeoIPHandler(a *app.App) *GeoIPHandler {
+	return &GeoIPHandler{app: a}
+}
+
+// Status GET /geoip 已加载的数据库文件
+func (h *GeoIPHandler) Status(c *gin.Context) {
+	db := h.app.GeoIP()
+	if db == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "geoip is not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": db.Status()})
+}
+
+// Reload POST /geoip/reload 替换数据库文件后立即重新加载（目录也会定期检查）
+func (h *GeoIPHandler) Reload(c *gin.Context) {
+	if h.app.GeoIP() == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "geoip is not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.app.ReloadGeoIP()})
+}
+
+// Lookup GET /geoip/lookup?ip= 查询地址的国家 / ASN，未收录时 data 为 null
+func (h *GeoIPHandler) Lookup(c *gin.Context) {
+	db := h.app.GeoIP()
+	if db == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "geoip is not configured"})
+		return
+	}
+	ip := net.ParseIP(c.Query("ip"))
+	if ip == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid ip"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": db.Lookup(ip)})
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-system-backend/internal/app"
+)
+
+type MirrorHandler struct {
+	app *app.App
+}
+
+func NewMirrorHandler(a *app.App) *MirrorHandler {
+	return &MirrorHandler{app: a}
+}
+
+// Stats GET /mirror/stats 各镜像目标的发送 / 丢弃 / 错误计数
+func (h *MirrorHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.app.Mirrors().Stats()})
+}
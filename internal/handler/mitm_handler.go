@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-system-backend/internal/app"
+)
+
+type MITMHandler struct {
+	app *app.App
+}
+
+func NewMITMHandler(a *app.App) *MITMHandler {
+	return &MITMHandler{app: a}
+}
+
+// DownloadCA 下载根证书，安装到设备后即可解密 TLS 流量
+func (h *MITMHandler) DownloadCA(c *gin.Context) {
+	ca := h.app.MITMAuthority()
+	if ca == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "mitm CA is not configured",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="proxy-system-ca.crt"`)
+	c.Data(http.StatusOK, "application/x-x509-ca-cert", ca.CertPEM())
+}
+
+// CAInfo 返回根证书的基本信息
+func (h *MITMHandler) CAInfo(c *gin.Context) {
+	ca := h.app.MITMAuthority()
+	if ca == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "mitm CA is not configured",
+		})
+		return
+	}
+
+	cert := ca.Certificate()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"subject":     cert.Subject.CommonName,
+			"not_before":  cert.NotBefore.Unix(),
+			"not_after":   cert.NotAfter.Unix(),
+			"fingerprint": ca.Fingerprint(),
+		},
+	})
+}
@@ -40,11 +40,29 @@ func (h *ProxyHandler) StartProxy(c *gin.Context) {
 	cfg.ID = shared.GenerateConnID()
 	cfg.Method = "aes-256-gcm"
 	cfg.Name = "tmp"
+	if req.Name != "" {
+		cfg.Name = req.Name
+	}
+	cfg.User = req.User
 	cfg.Enabled = true
 	cfg.CreatedAt = now
 	cfg.UpdatedAt = now
 	cfg.BlockIPs = req.BlockIPs
 	cfg.BlockPorts = req.BlockPorts
+	cfg.Capture = req.Capture
+	cfg.EnableFilter = req.EnableFilter
+	cfg.TraceFilter = req.TraceFilter
+	if req.MITM != nil {
+		cfg.MITM = *req.MITM
+	}
+	cfg.Outbound = req.Outbound
+	if req.Mock != nil {
+		cfg.Mock = *req.Mock
+	}
+	if req.DNS != nil {
+		cfg.DNS = *req.DNS
+	}
+	cfg.Redirect = req.Redirect
 	cfg.ListenAddr = fmt.Sprintf("%s:%v", ip, n)
 	fmt.Println(fmt.Sprintf("%+v", cfg))
 	if err := h.app.StartProxy(cfg); err != nil {
@@ -60,7 +78,16 @@ func (h *ProxyHandler) StartProxy(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		//"result": req.ID,
-		"qr_code": dat,
+		"proxy_id": cfg.ID,
+		"qr_code":  dat,
+	})
+}
+
+// List GET /proxies 运行中的代理及其适用的过滤规则集
+func (h *ProxyHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.app.Proxies(),
 	})
 }
 
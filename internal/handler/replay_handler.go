@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/replay"
+)
+
+type ReplayHandler struct {
+	app *app.App
+}
+
+func NewReplayHandler(a *app.App) *ReplayHandler {
+	return &ReplayHandler{app: a}
+}
+
+// Start POST /replay
+func (h *ReplayHandler) Start(c *gin.Context) {
+	var req replay.Config
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if req.ConnID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "conn_id is required"})
+		return
+	}
+
+	run, err := h.app.StartReplay(req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": run.Report()})
+}
+
+func (h *ReplayHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.app.Replays().List()})
+}
+
+// Get GET /replay/:id 回放报告（含逐轮差异）
+func (h *ReplayHandler) Get(c *gin.Context) {
+	run, ok := h.app.Replays().Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "replay not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": run.Report()})
+}
+
+// Step POST /replay/:id/step step 模式下放行下一条请求
+func (h *ReplayHandler) Step(c *gin.Context) {
+	run, ok := h.app.Replays().Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "replay not found"})
+		return
+	}
+	if err := run.Step(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *ReplayHandler) Cancel(c *gin.Context) {
+	run, ok := h.app.Replays().Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "replay not found"})
+		return
+	}
+	run.Cancel()
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
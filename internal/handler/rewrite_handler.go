@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/rewrite"
+	"strconv"
+)
+
+type RewriteHandler struct {
+	app *app.App
+}
+
+func NewRewriteHandler(a *app.App) *RewriteHandler {
+	return &RewriteHandler{app: a}
+}
+
+func (h *RewriteHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.app.Rewriter().List(),
+	})
+}
+
+func (h *RewriteHandler) Create(c *gin.Context) {
+	var req rewrite.Rule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	req.ID = 0
+
+	rule, err := h.app.Rewriter().Upsert(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+func (h *RewriteHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid rule id"})
+		return
+	}
+	if _, ok := h.app.Rewriter().Get(id); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "rule not found"})
+		return
+	}
+
+	var req rewrite.Rule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	req.ID = id
+
+	rule, err := h.app.Rewriter().Upsert(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+func (h *RewriteHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid rule id"})
+		return
+	}
+	if !h.app.Rewriter().Delete(id) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"proxy-system-backend/internal/app"
+	"proxy-system-backend/internal/modules/filter"
+)
+
+type RuleSetHandler struct {
+	app *app.App
+}
+
+func NewRuleSetHandler(a *app.App) *RuleSetHandler {
+	return &RuleSetHandler{app: a}
+}
+
+func (h *RuleSetHandler) List(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	sets, err := svc.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": sets})
+}
+
+func (h *RuleSetHandler) Get(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	set, err := svc.Get(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		ruleSetError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": set})
+}
+
+func (h *RuleSetHandler) Create(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	var req app.RuleSetDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	set, err := svc.Create(c.Request.Context(), req)
+	if err != nil {
+		ruleSetError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": set})
+}
+
+// Update PUT /filter/sets/:name 修改描述并替换全部分配
+func (h *RuleSetHandler) Update(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	var req app.RuleSetDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	set, err := svc.Update(c.Request.Context(), c.Param("name"), req)
+	if err != nil {
+		ruleSetError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": set})
+}
+
+func (h *RuleSetHandler) Delete(c *gin.Context) {
+	svc := h.service(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), c.Param("name")); err != nil {
+		ruleSetError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *RuleSetHandler) service(c *gin.Context) *app.RuleSetService {
+	svc := h.app.RuleSets()
+	if svc == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "rule sets storage is not configured"})
+	}
+	return svc
+}
+
+// ruleSetError 校验错误逐字段返回，规则集不存在返回 404
+func ruleSetError(c *gin.Context, err error) {
+	var verr *filter.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "validation failed", "fields": verr.Fields})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "rule set not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	}
+}
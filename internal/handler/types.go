@@ -1,10 +1,42 @@
 package handler
 
+import (
+	"proxy-system-backend/internal/modules/dns"
+	"proxy-system-backend/internal/modules/mitm"
+	"proxy-system-backend/internal/modules/mock"
+	"proxy-system-backend/internal/modules/redirect"
+)
+
 type StartProxyRequest struct {
+	// 显示名称；user 为 Shadowsocks 用户，两者都可用于分配过滤规则集
+	Name string `json:"name,omitempty"`
+	User string `json:"user,omitempty"`
+
 	BlockIPs   []string `json:"block_ips,omitempty"`
 	BlockPorts []string `json:"block_ports,omitempty"`
 
 	PluginName string `json:"plugin_name,omitempty"`
+
+	MITM *mitm.Config `json:"mitm,omitempty"`
+
+	// 录制该代理的所有连接
+	Capture bool `json:"capture,omitempty"`
+
+	// 按过滤规则决定放行 / 拒绝并执行 log、decode 等动作
+	EnableFilter bool `json:"enable_filter,omitempty"`
+
+	// 流量事件附带规则求值过程（filter_trace），调试用
+	TraceFilter bool `json:"trace_filter,omitempty"`
+
+	// 出站方式：direct（默认）/ mock
+	Outbound string       `json:"outbound,omitempty"`
+	Mock     *mock.Config `json:"mock,omitempty"`
+
+	// 出站域名解析：上游 DNS、静态解析、地址族偏好
+	DNS *dns.Config `json:"dns,omitempty"`
+
+	// 目标重定向：把请求 match 的连接转发到 to（如本地开发服务器）
+	Redirect []redirect.Rule `json:"redirect,omitempty"`
 }
 
 type StartProxyResult struct {
@@ -0,0 +1,284 @@
+package breakpoint
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/modules/shared"
+	"proxy-system-backend/internal/traffic"
+	"sort"
+	"sync"
+	"time"
+)
+
+type compiledRule struct {
+	rule   Rule
+	dstNet *net.IPNet
+	prefix []byte
+}
+
+// Manager 管理断点规则和挂起的数据包
+// Hold 只阻塞当前连接当前方向的 pipe，其它连接不受影响
+type Manager struct {
+	mu      sync.RWMutex
+	cfg     Config
+	rules   []*compiledRule
+	nextID  int64
+	pending map[string]*Held
+
+	// 挂起 / 处理完成时回调（推送到 WebSocket）
+	onHold    func(h *Held)
+	onResolve func(h *Held, d Decision)
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		cfg:     DefaultConfig(),
+		nextID:  1,
+		pending: make(map[string]*Held),
+	}
+}
+
+// OnEvent 设置挂起 / 处理完成回调
+func (m *Manager) OnEvent(onHold func(h *Held), onResolve func(h *Held, d Decision)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onHold = onHold
+	m.onResolve = onResolve
+}
+
+func (m *Manager) Config() Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
+}
+
+func (m *Manager) SetConfig(cfg Config) error {
+	if cfg.TimeoutSec <= 0 {
+		return fmt.Errorf("timeout_sec must be > 0")
+	}
+	switch cfg.TimeoutAction {
+	case ActionForward, ActionDrop:
+	default:
+		return fmt.Errorf("timeout_action must be forward or drop")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	return nil
+}
+
+func compileRule(r Rule) (*compiledRule, error) {
+	cr := &compiledRule{rule: r}
+	if r.DstCIDR != "" {
+		_, n, err := net.ParseCIDR(r.DstCIDR)
+		if err != nil {
+			return nil, fmt.Errorf("dst_cidr: %w", err)
+		}
+		cr.dstNet = n
+	}
+	if r.PayloadPrefix != "" {
+		b, err := hex.DecodeString(r.PayloadPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("payload_prefix: %w", err)
+		}
+		cr.prefix = b
+	}
+	if r.DstPort < 0 || r.DstPort > 65535 {
+		return nil, fmt.Errorf("dst_port out of range: %d", r.DstPort)
+	}
+	return cr, nil
+}
+
+// Upsert 新增或更新断点规则（ID 为 0 时新增）
+func (m *Manager) Upsert(r Rule) (Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r.ID == 0 {
+		r.ID = m.nextID
+	}
+	cr, err := compileRule(r)
+	if err != nil {
+		return Rule{}, err
+	}
+	if r.ID >= m.nextID {
+		m.nextID = r.ID + 1
+	}
+
+	for i, old := range m.rules {
+		if old.rule.ID == r.ID {
+			m.rules[i] = cr
+			return r, nil
+		}
+	}
+	m.rules = append(m.rules, cr)
+	sort.Slice(m.rules, func(i, j int) bool { return m.rules[i].rule.ID < m.rules[j].rule.ID })
+	return r, nil
+}
+
+func (m *Manager) Delete(id int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, cr := range m.rules {
+		if cr.rule.ID == id {
+			m.rules = append(m.rules[:i], m.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) Rules() []Rule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Rule, 0, len(m.rules))
+	for _, cr := range m.rules {
+		out = append(out, cr.rule)
+	}
+	return out
+}
+
+// Match 返回第一个命中的断点规则；未启用断点时总是 nil
+func (m *Manager) Match(ctx *traffic.PacketContext) *Rule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.cfg.Enabled {
+		return nil
+	}
+	for _, cr := range m.rules {
+		if cr.matches(ctx) {
+			r := cr.rule
+			return &r
+		}
+	}
+	return nil
+}
+
+func (cr *compiledRule) matches(ctx *traffic.PacketContext) bool {
+	r := cr.rule
+	if !r.Enabled {
+		return false
+	}
+	if r.Direction != traffic.DirectionUnknown && r.Direction != ctx.Direction {
+		return false
+	}
+
+	// 入方向的目标是客户端，规则中的目标统一指远端服务器
+	remoteIP, remotePort := ctx.DstIP, ctx.DstPort
+	if ctx.Direction == traffic.DirectionIn {
+		remoteIP, remotePort = ctx.SrcIP, ctx.SrcPort
+	}
+
+	if r.Domain != "" && !shared.MatchDomain(r.Domain, ctx.Domain) {
+		return false
+	}
+	if cr.dstNet != nil && (remoteIP == nil || !cr.dstNet.Contains(remoteIP)) {
+		return false
+	}
+	if r.DstPort != 0 && r.DstPort != remotePort {
+		return false
+	}
+	if len(cr.prefix) > 0 && !bytes.HasPrefix(ctx.Payload, cr.prefix) {
+		return false
+	}
+	return true
+}
+
+// Hold 挂起数据包直到操作员处理或超时，返回最终决定
+func (m *Manager) Hold(h *Held, rule *Rule) Decision {
+	m.mu.Lock()
+	cfg := m.cfg
+	timeout := time.Duration(cfg.TimeoutSec) * time.Second
+	if rule != nil && rule.TimeoutSec > 0 {
+		timeout = time.Duration(rule.TimeoutSec) * time.Second
+	}
+
+	h.ID = shared.GenerateConnID()
+	h.HeldAt = time.Now()
+	h.Deadline = h.HeldAt.Add(timeout)
+	h.ch = make(chan Decision, 1)
+	if rule != nil {
+		h.RuleID = rule.ID
+	}
+	m.pending[h.ID] = h
+	onHold, onResolve := m.onHold, m.onResolve
+	m.mu.Unlock()
+
+	if onHold != nil {
+		onHold(h)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var d Decision
+	select {
+	case d = <-h.ch:
+	case <-timer.C:
+		m.mu.Lock()
+		// 与 Resolve 竞争：已被处理则以处理结果为准
+		if _, ok := m.pending[h.ID]; ok {
+			delete(m.pending, h.ID)
+			d = Decision{Action: cfg.TimeoutAction, TimedOut: true}
+		} else {
+			d = <-h.ch
+		}
+		m.mu.Unlock()
+	}
+
+	if onResolve != nil {
+		onResolve(h, d)
+	}
+	return d
+}
+
+// Resolve 操作员处理挂起的数据包
+func (m *Manager) Resolve(id string, d Decision) error {
+	switch d.Action {
+	case ActionForward, ActionDrop:
+	case ActionEdit:
+		if d.Hex == "" && len(d.JSON) == 0 {
+			return fmt.Errorf("edit requires hex or json")
+		}
+		if d.Hex != "" {
+			if _, err := hex.DecodeString(d.Hex); err != nil {
+				return fmt.Errorf("invalid hex: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown action %q", d.Action)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.pending[id]
+	if !ok {
+		return fmt.Errorf("held packet %s not found (already resolved or timed out)", id)
+	}
+	if d.Action == ActionEdit && d.Hex == "" && h.Plugin == "" {
+		return fmt.Errorf("json edit requires a decoder plugin")
+	}
+	delete(m.pending, id)
+	h.ch <- d
+	return nil
+}
+
+// Pending 返回当前挂起的数据包（按挂起时间排序）
+func (m *Manager) Pending() []*Held {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]*Held, 0, len(m.pending))
+	for _, h := range m.pending {
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].HeldAt.Before(out[j].HeldAt) })
+	return out
+}
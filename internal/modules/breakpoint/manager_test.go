@@ -0,0 +1,66 @@
+package breakpoint
+
+import (
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+	"time"
+)
+
+func TestHoldResolveEdit(t *testing.T) {
+	m := NewManager()
+	_ = m.SetConfig(Config{Enabled: true, TimeoutSec: 5, TimeoutAction: ActionForward})
+	if _, err := m.Upsert(Rule{Enabled: true, Direction: traffic.DirectionOut, DstPort: 7000, PayloadPrefix: "cafe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &traffic.PacketContext{
+		Direction: traffic.DirectionOut,
+		DstIP:     net.ParseIP("10.0.0.1"),
+		DstPort:   7000,
+		Payload:   []byte{0xca, 0xfe, 0x01},
+	}
+	rule := m.Match(ctx)
+	if rule == nil {
+		t.Fatal("breakpoint did not match")
+	}
+
+	held := make(chan *Held, 1)
+	m.OnEvent(func(h *Held) { held <- h }, nil)
+
+	done := make(chan Decision, 1)
+	go func() { done <- m.Hold(&Held{Payload: ctx.Payload}, rule) }()
+
+	h := <-held
+	if len(m.Pending()) != 1 {
+		t.Fatalf("pending = %d", len(m.Pending()))
+	}
+	if err := m.Resolve(h.ID, Decision{Action: ActionEdit, Hex: "beef"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := <-done
+	if d.Action != ActionEdit || d.Hex != "beef" {
+		t.Fatalf("decision = %+v", d)
+	}
+	if err := m.Resolve(h.ID, Decision{Action: ActionForward}); err == nil {
+		t.Fatal("resolving twice should fail")
+	}
+}
+
+func TestHoldTimeout(t *testing.T) {
+	m := NewManager()
+	_ = m.SetConfig(Config{Enabled: true, TimeoutSec: 1, TimeoutAction: ActionDrop})
+
+	start := time.Now()
+	d := m.Hold(&Held{}, &Rule{ID: 1})
+	if d.Action != ActionDrop || !d.TimedOut {
+		t.Fatalf("decision = %+v", d)
+	}
+	if time.Since(start) < time.Second {
+		t.Fatal("released before timeout")
+	}
+	if len(m.Pending()) != 0 {
+		t.Fatal("timed-out packet still pending")
+	}
+}
@@ -0,0 +1,80 @@
+package breakpoint
+
+import (
+	"encoding/json"
+	"proxy-system-backend/internal/traffic"
+	"time"
+)
+
+// Rule 断点规则：命中的数据包会被挂起，等待操作员处理
+type Rule struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+
+	Direction traffic.Direction `json:"direction"`          // unknown 表示双向
+	Domain    string            `json:"domain,omitempty"`   // 支持 "*.example.com"
+	DstCIDR   string            `json:"dst_cidr,omitempty"` // "10.0.0.0/8"
+	DstPort   int               `json:"dst_port,omitempty"` // 0 表示任意端口
+
+	// payload 前缀（hex），为空表示任意内容
+	PayloadPrefix string `json:"payload_prefix,omitempty"`
+
+	// 覆盖全局超时（秒），0 使用全局配置
+	TimeoutSec int `json:"timeout_sec,omitempty"`
+}
+
+// Action 操作员（或超时）对挂起数据包的处理方式
+type Action string
+
+const (
+	ActionForward Action = "forward" // 原样放行
+	ActionDrop    Action = "drop"    // 丢弃该数据包（连接保持）
+	ActionEdit    Action = "edit"    // 修改后放行
+)
+
+// Decision 处理结果；edit 时 Hex / JSON 二选一
+type Decision struct {
+	Action Action          `json:"action"`
+	Hex    string          `json:"hex,omitempty"`
+	JSON   json.RawMessage `json:"json,omitempty"`
+
+	// 超时自动处理
+	TimedOut bool `json:"timed_out,omitempty"`
+}
+
+// Config 全局断点配置
+type Config struct {
+	Enabled       bool   `json:"enabled"`
+	TimeoutSec    int    `json:"timeout_sec"`
+	TimeoutAction Action `json:"timeout_action"` // forward / drop
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled:       false,
+		TimeoutSec:    60,
+		TimeoutAction: ActionForward,
+	}
+}
+
+// Held 被挂起的数据包
+type Held struct {
+	ID        string            `json:"id"`
+	RuleID    int64             `json:"rule_id"`
+	ProxyID   string            `json:"proxy_id"`
+	ConnID    string            `json:"conn_id"`
+	Direction traffic.Direction `json:"direction"`
+	Domain    string            `json:"domain,omitempty"`
+	Src       string            `json:"src"`
+	Dst       string            `json:"dst"`
+
+	Payload []byte          `json:"payload"`
+	Decoded json.RawMessage `json:"decoded,omitempty"`
+	Plugin  string          `json:"plugin,omitempty"`
+
+	HeldAt   time.Time `json:"held_at"`
+	Deadline time.Time `json:"deadline"`
+
+	ch chan Decision
+}
@@ -0,0 +1,260 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"proxy-system-backend/internal/modules/pcap"
+	capturestore "proxy-system-backend/internal/storage/capture"
+	"proxy-system-backend/internal/traffic"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 拆分方式
+const (
+	SplitNone = ""     // 全部写入一个文件
+	SplitConn = "conn" // 每个连接一个文件
+	SplitTime = "time" // 按时间窗口拆分
+)
+
+const exportPageSize = 1000
+
+// 地址无法解析时使用的文档保留地址（RFC 5737）
+var (
+	placeholderClient = netip.MustParseAddrPort("192.0.2.1:40000")
+	placeholderServer = netip.MustParseAddrPort("198.51.100.1:0")
+)
+
+// ExportOptions 导出条件；ConnIDs 为空时按 Query 选择会话
+type ExportOptions struct {
+	ConnIDs []string
+	Query   capturestore.SessionQuery
+
+	// 数据包时间范围，零值不限
+	From time.Time
+	To   time.Time
+
+	Split  string
+	Window time.Duration // SplitTime 时的窗口长度
+}
+
+func (o ExportOptions) validate() error {
+	switch o.Split {
+	case SplitNone, SplitConn:
+	case SplitTime:
+		if o.Window <= 0 {
+			return fmt.Errorf("split=time requires a positive window")
+		}
+	default:
+		return fmt.Errorf("unknown split %q", o.Split)
+	}
+	return nil
+}
+
+// FileOpener 为每个输出文件返回写入目标
+type FileOpener func(name string) (io.Writer, error)
+
+type exportPacket struct {
+	index capturestore.PacketModel
+	flow  *exportFlow
+	order int  // 会话序号，按连接拆分时先按会话分组
+	last  bool // 该会话的最后一个数据包
+}
+
+type exportFlow struct {
+	session capturestore.SessionModel
+	flow    *pcap.Flow
+	opened  bool
+}
+
+// Export 把录制会话导出为 pcapng，返回写出的文件数
+func (s *Store) Export(ctx context.Context, opts ExportOptions, open FileOpener) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+
+	sessions, err := s.exportSessions(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var packets []exportPacket
+	for n, sess := range sessions {
+		ef := &exportFlow{session: sess, flow: newExportFlow(sess)}
+		list, err := s.exportPackets(ctx, sess.ConnID, opts)
+		if err != nil {
+			return 0, err
+		}
+		for i, pm := range list {
+			packets = append(packets, exportPacket{index: pm, flow: ef, order: n, last: i == len(list)-1})
+		}
+	}
+	sort.SliceStable(packets, func(i, j int) bool {
+		a, b := packets[i], packets[j]
+		if opts.Split == SplitConn && a.order != b.order {
+			return a.order < b.order
+		}
+		return a.index.Time < b.index.Time
+	})
+
+	var (
+		files int
+		w     *pcap.NGWriter
+		key   string
+	)
+	for _, p := range packets {
+		name := exportFileName(opts, p)
+		if w == nil || name != key {
+			out, err := open(name)
+			if err != nil {
+				return files, err
+			}
+			if w, err = pcap.NewNGWriter(out, pcap.LinkTypeEthernet); err != nil {
+				return files, err
+			}
+			key = name
+			files++
+		}
+
+		if err := s.writePacket(w, p); err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+func (s *Store) exportSessions(ctx context.Context, opts ExportOptions) ([]capturestore.SessionModel, error) {
+	if len(opts.ConnIDs) > 0 {
+		out := make([]capturestore.SessionModel, 0, len(opts.ConnIDs))
+		for _, id := range opts.ConnIDs {
+			sess, err := s.repo.GetSession(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("session %s: %w", id, err)
+			}
+			out = append(out, *sess)
+		}
+		return out, nil
+	}
+
+	var out []capturestore.SessionModel
+	q := opts.Query
+	q.Offset, q.Limit = 0, exportPageSize
+	for {
+		list, total, err := s.repo.ListSessions(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, list...)
+		q.Offset += len(list)
+		if len(list) == 0 || int64(q.Offset) >= total {
+			return out, nil
+		}
+	}
+}
+
+// exportPackets 读取会话内落在时间范围内的数据包索引
+func (s *Store) exportPackets(ctx context.Context, connID string, opts ExportOptions) ([]capturestore.PacketModel, error) {
+	var out []capturestore.PacketModel
+	offset := 0
+	for {
+		list, total, err := s.repo.ListPackets(ctx, connID, offset, exportPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, pm := range list {
+			t := time.UnixMicro(pm.Time)
+			if !opts.From.IsZero() && t.Before(opts.From) {
+				continue
+			}
+			if !opts.To.IsZero() && t.After(opts.To) {
+				continue
+			}
+			out = append(out, pm)
+		}
+		offset += len(list)
+		if len(list) == 0 || int64(offset) >= total {
+			return out, nil
+		}
+	}
+}
+
+// writePacket 写入数据帧；会话首包前补握手，已结束会话的末包后补挥手
+func (s *Store) writePacket(w *pcap.NGWriter, p exportPacket) error {
+	r, err := readRecord(s.dir, p.index.Segment, p.index.Offset, p.index.Size)
+	if err != nil {
+		return fmt.Errorf("read packet %d: %w", p.index.ID, err)
+	}
+
+	ef := p.flow
+	if !ef.opened {
+		ef.opened = true
+		ts := r.Time
+		if ef.session.StartedAt > 0 {
+			ts = time.UnixMilli(ef.session.StartedAt)
+		}
+		for _, frame := range ef.flow.Handshake() {
+			if err := w.WritePacket(ts, frame, ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	comment := packetComment(r)
+	for _, frame := range ef.flow.Data(r.Direction != traffic.DirectionIn, r.Payload) {
+		if err := w.WritePacket(r.Time, frame, comment); err != nil {
+			return err
+		}
+	}
+
+	if p.last && ef.session.EndedAt > 0 {
+		ts := time.UnixMilli(ef.session.EndedAt)
+		if ts.Before(r.Time) {
+			ts = r.Time
+		}
+		for _, frame := range ef.flow.Close() {
+			if err := w.WritePacket(ts, frame, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newExportFlow(sess capturestore.SessionModel) *pcap.Flow {
+	client, err := netip.ParseAddrPort(sess.Client)
+	if err != nil {
+		client = placeholderClient
+	}
+	server, err := netip.ParseAddrPort(sess.Dst)
+	if err != nil {
+		server = placeholderServer
+	}
+	return pcap.NewFlow(client, server, sess.Protocol == traffic.ProtocolUDP.String())
+}
+
+// packetComment 数据包注释：插件解码结果与注入标记
+func packetComment(r *Record) string {
+	var parts []string
+	if r.Injected {
+		parts = append(parts, "injected")
+	}
+	if r.Plugin != "" && len(r.Decoded) > 0 {
+		parts = append(parts, fmt.Sprintf("%s: %s", r.Plugin, r.Decoded))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func exportFileName(opts ExportOptions, p exportPacket) string {
+	switch opts.Split {
+	case SplitConn:
+		return p.flow.session.ConnID + ".pcapng"
+	case SplitTime:
+		t := time.UnixMicro(p.index.Time).Truncate(opts.Window)
+		return "capture-" + t.UTC().Format("20060102T150405Z") + ".pcapng"
+	default:
+		return "capture.pcapng"
+	}
+}
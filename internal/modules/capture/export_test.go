@@ -0,0 +1,145 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"proxy-system-backend/internal/traffic"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ngPacket struct {
+	frame   []byte
+	comment string
+}
+
+// readEPBs 解析导出的 pcapng，返回所有 Enhanced Packet Block
+func readEPBs(t *testing.T, b []byte) []ngPacket {
+	t.Helper()
+	var out []ngPacket
+	for len(b) > 0 {
+		typ := binary.LittleEndian.Uint32(b)
+		total := binary.LittleEndian.Uint32(b[4:])
+		if int(total) > len(b) || binary.LittleEndian.Uint32(b[total-4:]) != total {
+			t.Fatalf("corrupt block length %d", total)
+		}
+		if typ == 6 {
+			body := b[8 : total-4]
+			capLen := binary.LittleEndian.Uint32(body[12:])
+			p := ngPacket{frame: body[20 : 20+capLen]}
+			opts := body[20+(capLen+3)/4*4:]
+			if len(opts) >= 4 && binary.LittleEndian.Uint16(opts) == 1 {
+				n := binary.LittleEndian.Uint16(opts[2:])
+				p.comment = string(opts[4 : 4+n])
+			}
+			out = append(out, p)
+		}
+		b = b[total:]
+	}
+	return out
+}
+
+func recordExportSessions(t *testing.T, s *Store, start time.Time) {
+	t.Helper()
+	s.StartSession(Session{ConnID: "c1", ProxyID: "p1", Client: "127.0.0.1:50000", Dst: "10.0.0.1:7000", StartedAt: start})
+	s.StartSession(Session{ConnID: "c2", ProxyID: "p1", Client: "127.0.0.1:50001", Dst: "[2001:db8::1]:443", StartedAt: start})
+
+	s.Record("p1", Record{Time: start.Add(1 * time.Millisecond), ConnID: "c1", Direction: traffic.DirectionOut,
+		Payload: []byte("hello"), Plugin: "demo", Decoded: json.RawMessage(`{"cmd":"hello"}`)})
+	s.Record("p1", Record{Time: start.Add(2 * time.Millisecond), ConnID: "c2", Direction: traffic.DirectionOut,
+		Payload: []byte("v6")})
+	s.Record("p1", Record{Time: start.Add(3 * time.Millisecond), ConnID: "c1", Direction: traffic.DirectionIn,
+		Payload: []byte("world!")})
+
+	s.EndSession("c1")
+	s.EndSession("c2")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportSingleFile(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Now()
+	recordExportSessions(t, s, start)
+
+	var buf bytes.Buffer
+	files, err := s.Export(context.Background(), ExportOptions{ConnIDs: []string{"c1"}}, func(string) (io.Writer, error) {
+		return &buf, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != 1 {
+		t.Fatalf("files = %d, want 1", files)
+	}
+
+	pkts := readEPBs(t, buf.Bytes())
+	// 3 握手 + 2 数据 + 3 挥手
+	if len(pkts) != 8 {
+		t.Fatalf("packets = %d, want 8", len(pkts))
+	}
+
+	out, in := pkts[3], pkts[4]
+	if !strings.Contains(out.comment, `demo: {"cmd":"hello"}`) {
+		t.Fatalf("comment = %q", out.comment)
+	}
+
+	// 以太网 14 + IPv4 20，TCP 序列号连续
+	tcpOut, tcpIn := out.frame[34:], in.frame[34:]
+	if seq := binary.BigEndian.Uint32(tcpOut[4:]); seq != 1001 {
+		t.Fatalf("client seq = %d", seq)
+	}
+	if ack := binary.BigEndian.Uint32(tcpIn[8:]); ack != 1001+5 {
+		t.Fatalf("server ack = %d", ack)
+	}
+	if !bytes.Equal(in.frame[54:], []byte("world!")) {
+		t.Fatalf("payload = %q", in.frame[54:])
+	}
+	if checksum(out.frame[14:34]) != 0 {
+		t.Fatal("bad IPv4 header checksum")
+	}
+}
+
+func TestExportSplitByConn(t *testing.T) {
+	s := newTestStore(t)
+	recordExportSessions(t, s, time.Now())
+
+	bufs := make(map[string]*bytes.Buffer)
+	files, err := s.Export(context.Background(), ExportOptions{Split: SplitConn}, func(name string) (io.Writer, error) {
+		if _, ok := bufs[name]; ok {
+			t.Fatalf("file %s opened twice", name)
+		}
+		bufs[name] = &bytes.Buffer{}
+		return bufs[name], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != 2 || bufs["c1.pcapng"] == nil || bufs["c2.pcapng"] == nil {
+		t.Fatalf("files = %d %v", files, bufs)
+	}
+
+	pkts := readEPBs(t, bufs["c2.pcapng"].Bytes())
+	if len(pkts) != 7 {
+		t.Fatalf("packets = %d, want 7", len(pkts))
+	}
+	if et := binary.BigEndian.Uint16(pkts[0].frame[12:]); et != 0x86DD {
+		t.Fatalf("ethertype = %#x, want IPv6", et)
+	}
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,240 @@
+package capture
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"proxy-system-backend/internal/traffic"
+	"sync"
+	"time"
+)
+
+// segment 文件格式：连续的记录
+//
+//	u32 记录长度（不含自身）
+//	u8  版本
+//	i64 时间（unix 纳秒）
+//	u8  方向
+//	u8  标志位（bit0: injected）
+//	u16 + connID
+//	u16 + plugin
+//	u32 + payload
+//	u32 + decoded JSON
+const (
+	recordVersion  = 1
+	flagInjected   = 1 << 0
+	segmentPrefix  = "seg-"
+	segmentSuffix  = ".cap"
+	defaultSegSize = 64 << 20
+)
+
+// Record 一个录制的数据包
+type Record struct {
+	Time      time.Time         `json:"time"`
+	ConnID    string            `json:"conn_id"`
+	Direction traffic.Direction `json:"direction"`
+	Injected  bool              `json:"injected,omitempty"`
+	Plugin    string            `json:"plugin,omitempty"`
+	Payload   []byte            `json:"payload"`
+	Decoded   json.RawMessage   `json:"decoded,omitempty"`
+}
+
+// segmentWriter 追加写 segment 文件，超过 maxSize 后滚动
+type segmentWriter struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+
+	name string
+	f    *os.File
+	size int64
+}
+
+func newSegmentWriter(dir string, maxSize int64) (*segmentWriter, error) {
+	if maxSize <= 0 {
+		maxSize = defaultSegSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create capture dir: %w", err)
+	}
+	return &segmentWriter{dir: dir, maxSize: maxSize}, nil
+}
+
+// Append 写入记录，返回所在 segment、偏移和长度
+func (w *segmentWriter) Append(r *Record) (string, int64, int, error) {
+	buf := encodeRecord(r)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil || w.size+int64(len(buf)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	off := w.size
+	if _, err := w.f.Write(buf); err != nil {
+		return "", 0, 0, err
+	}
+	w.size += int64(len(buf))
+	return w.name, off, len(buf), nil
+}
+
+func (w *segmentWriter) rotate() error {
+	if w.f != nil {
+		_ = w.f.Close()
+	}
+
+	name := fmt.Sprintf("%s%d%s", segmentPrefix, time.Now().UnixNano(), segmentSuffix)
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+
+	w.name = name
+	w.f = f
+	w.size = 0
+	return nil
+}
+
+func (w *segmentWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Sync()
+}
+
+func (w *segmentWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// readRecord 从 segment 中读取一条记录
+func readRecord(dir, segment string, offset int64, size int) (*Record, error) {
+	if filepath.Base(segment) != segment {
+		return nil, fmt.Errorf("invalid segment name %q", segment)
+	}
+
+	f, err := os.Open(filepath.Join(dir, segment))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, size)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return decodeRecord(buf)
+}
+
+func encodeRecord(r *Record) []byte {
+	n := 1 + 8 + 1 + 1 + 2 + len(r.ConnID) + 2 + len(r.Plugin) + 4 + len(r.Payload) + 4 + len(r.Decoded)
+	buf := make([]byte, 4+n)
+
+	binary.BigEndian.PutUint32(buf, uint32(n))
+	p := buf[4:]
+	p[0] = recordVersion
+	binary.BigEndian.PutUint64(p[1:], uint64(r.Time.UnixNano()))
+	p[9] = byte(r.Direction)
+	if r.Injected {
+		p[10] |= flagInjected
+	}
+	p = p[11:]
+
+	p = putBytes16(p, []byte(r.ConnID))
+	p = putBytes16(p, []byte(r.Plugin))
+	p = putBytes32(p, r.Payload)
+	putBytes32(p, r.Decoded)
+	return buf
+}
+
+func decodeRecord(buf []byte) (*Record, error) {
+	if len(buf) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	n := int(binary.BigEndian.Uint32(buf))
+	if len(buf) < 4+n || n < 11 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p := buf[4 : 4+n]
+	if p[0] != recordVersion {
+		return nil, fmt.Errorf("unsupported record version %d", p[0])
+	}
+
+	r := &Record{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(p[1:]))),
+		Direction: traffic.Direction(p[9]),
+		Injected:  p[10]&flagInjected != 0,
+	}
+	p = p[11:]
+
+	var (
+		b   []byte
+		err error
+	)
+	if b, p, err = getBytes16(p); err != nil {
+		return nil, err
+	}
+	r.ConnID = string(b)
+	if b, p, err = getBytes16(p); err != nil {
+		return nil, err
+	}
+	r.Plugin = string(b)
+	if r.Payload, p, err = getBytes32(p); err != nil {
+		return nil, err
+	}
+	if b, _, err = getBytes32(p); err != nil {
+		return nil, err
+	}
+	if len(b) > 0 {
+		r.Decoded = b
+	}
+	return r, nil
+}
+
+func putBytes16(p, b []byte) []byte {
+	binary.BigEndian.PutUint16(p, uint16(len(b)))
+	copy(p[2:], b)
+	return p[2+len(b):]
+}
+
+func putBytes32(p, b []byte) []byte {
+	binary.BigEndian.PutUint32(p, uint32(len(b)))
+	copy(p[4:], b)
+	return p[4+len(b):]
+}
+
+func getBytes16(p []byte) ([]byte, []byte, error) {
+	if len(p) < 2 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	n := int(binary.BigEndian.Uint16(p))
+	if len(p) < 2+n {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	return p[2 : 2+n], p[2+n:], nil
+}
+
+func getBytes32(p []byte) ([]byte, []byte, error) {
+	if len(p) < 4 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	n := int(binary.BigEndian.Uint32(p))
+	if len(p) < 4+n {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	return p[4 : 4+n], p[4+n:], nil
+}
@@ -0,0 +1,321 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"log"
+	capturestore "proxy-system-backend/internal/storage/capture"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	queueSize     = 4096
+	flushBatch    = 256
+	flushInterval = 200 * time.Millisecond
+)
+
+// Session 录制会话的元信息
+type Session struct {
+	ConnID    string
+	ProxyID   string
+	Source    string // live / import
+	Protocol  string // tcp / udp
+	Client    string
+	Dst       string
+	Domain    string
+	Tags      []string // 过滤规则 tag:<label> 打上的标签
+	StartedAt time.Time
+
+	// 同一连接结束录制后再次开始：沿用已保存会话的开始时间和统计
+	Resume bool
+}
+
+// Packet 带索引 ID 的录制数据包
+type Packet struct {
+	ID      int64  `json:"id"`
+	ProxyID string `json:"proxy_id"`
+	Record
+}
+
+type entryKind uint8
+
+const (
+	entryStart entryKind = iota
+	entryPacket
+	entryEnd
+)
+
+type entry struct {
+	kind    entryKind
+	session Session
+	proxyID string
+	record  *Record
+	connID  string
+	endedAt time.Time
+}
+
+// Store 录制存储：数据写入 segment 文件，索引写入 SQLite
+// 写入全部异步进行，队列满时丢弃并计数，不阻塞转发
+type Store struct {
+	dir  string
+	repo capturestore.Repository
+	seg  *segmentWriter
+
+	queue   chan entry
+	dropped atomic.Int64
+
+	// 进行中的会话（connID -> 会话统计）
+	live map[string]*capturestore.SessionModel
+
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+func NewStore(dir string, repo capturestore.Repository) (*Store, error) {
+	seg, err := newSegmentWriter(dir, defaultSegSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:     dir,
+		repo:    repo,
+		seg:     seg,
+		queue:   make(chan entry, queueSize),
+		live:    make(map[string]*capturestore.SessionModel),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// StartSession 开始录制一个连接
+func (s *Store) StartSession(sess Session) {
+	if sess.StartedAt.IsZero() {
+		sess.StartedAt = time.Now()
+	}
+	if sess.Source == "" {
+		sess.Source = "live"
+	}
+	if sess.Protocol == "" {
+		sess.Protocol = "tcp"
+	}
+	s.enqueue(entry{kind: entryStart, session: sess})
+}
+
+// Record 录制一个数据包（payload / decoded 会被复制）
+func (s *Store) Record(proxyID string, r Record) {
+	r.Payload = bytes.Clone(r.Payload)
+	r.Decoded = bytes.Clone(r.Decoded)
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	s.enqueue(entry{kind: entryPacket, proxyID: proxyID, record: &r})
+}
+
+// EndSession 结束录制
+func (s *Store) EndSession(connID string) {
+	s.enqueue(entry{kind: entryEnd, connID: connID, endedAt: time.Now()})
+}
+
+// Dropped 因队列满被丢弃的条目数
+func (s *Store) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+func (s *Store) enqueue(e entry) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	select {
+	case s.queue <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *Store) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []capturestore.PacketModel
+	dirty := make(map[string]struct{})
+
+	flush := func() {
+		ctx := context.Background()
+		if err := s.repo.AddPackets(ctx, batch); err != nil {
+			log.Printf("[Capture] write packet index failed: %v", err)
+		}
+		batch = batch[:0]
+		for id := range dirty {
+			if m, ok := s.live[id]; ok {
+				if err := s.repo.SaveSession(ctx, m); err != nil {
+					log.Printf("[Capture] save session %s failed: %v", id, err)
+				}
+			}
+			delete(dirty, id)
+		}
+	}
+
+	handle := func(e entry) {
+		switch e.kind {
+		case entryStart:
+			m := &capturestore.SessionModel{
+				ConnID:    e.session.ConnID,
+				ProxyID:   e.session.ProxyID,
+				Source:    e.session.Source,
+				Protocol:  e.session.Protocol,
+				Client:    e.session.Client,
+				Dst:       e.session.Dst,
+				Domain:    e.session.Domain,
+				Tags:      strings.Join(e.session.Tags, ","),
+				StartedAt: e.session.StartedAt.UnixMilli(),
+			}
+			if e.session.Resume {
+				if prev, err := s.repo.GetSession(context.Background(), m.ConnID); err == nil {
+					m.StartedAt, m.Packets, m.Bytes = prev.StartedAt, prev.Packets, prev.Bytes
+				}
+			}
+			s.live[m.ConnID] = m
+			dirty[m.ConnID] = struct{}{}
+
+		case entryPacket:
+			pm, ok := s.appendPacket(e.proxyID, e.record)
+			if !ok {
+				return
+			}
+			batch = append(batch, pm)
+			if m, ok := s.live[pm.ConnID]; ok {
+				m.Packets++
+				m.Bytes += int64(pm.Length)
+				dirty[pm.ConnID] = struct{}{}
+			}
+			if len(batch) >= flushBatch {
+				flush()
+			}
+
+		case entryEnd:
+			if m, ok := s.live[e.connID]; ok {
+				m.EndedAt = e.endedAt.UnixMilli()
+				dirty[e.connID] = struct{}{}
+				flush()
+				delete(s.live, e.connID)
+			}
+		}
+	}
+
+	for {
+		select {
+		case e := <-s.queue:
+			handle(e)
+
+		case <-ticker.C:
+			if len(batch) > 0 || len(dirty) > 0 {
+				flush()
+			}
+
+		case <-s.done:
+			// 写完剩余队列再退出
+			for {
+				select {
+				case e := <-s.queue:
+					handle(e)
+				default:
+					flush()
+					_ = s.seg.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Store) appendPacket(proxyID string, r *Record) (capturestore.PacketModel, bool) {
+	segment, off, size, err := s.seg.Append(r)
+	if err != nil {
+		log.Printf("[Capture] append segment failed: %v", err)
+		return capturestore.PacketModel{}, false
+	}
+
+	dst := ""
+	if m, ok := s.live[r.ConnID]; ok {
+		dst = m.Dst
+	}
+
+	return capturestore.PacketModel{
+		ProxyID:   proxyID,
+		ConnID:    r.ConnID,
+		Time:      r.Time.UnixMicro(),
+		Dst:       dst,
+		Direction: uint8(r.Direction),
+		Length:    len(r.Payload),
+		Plugin:    r.Plugin,
+		Injected:  r.Injected,
+		Segment:   segment,
+		Offset:    off,
+		Size:      size,
+	}, true
+}
+
+// Close 刷新剩余数据并关闭
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	<-s.stopped
+	return nil
+}
+
+// Sessions 按条件分页查询会话
+func (s *Store) Sessions(ctx context.Context, q capturestore.SessionQuery) ([]capturestore.SessionModel, int64, error) {
+	return s.repo.ListSessions(ctx, q)
+}
+
+func (s *Store) Session(ctx context.Context, connID string) (*capturestore.SessionModel, error) {
+	return s.repo.GetSession(ctx, connID)
+}
+
+// Packets 分页读取一个会话的数据包
+func (s *Store) Packets(ctx context.Context, connID string, offset, limit int) ([]Packet, int64, error) {
+	index, total, err := s.repo.ListPackets(ctx, connID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	out := make([]Packet, 0, len(index))
+	for _, pm := range index {
+		p, err := s.load(pm)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, *p)
+	}
+	return out, total, nil
+}
+
+// Packet 按索引 ID 读取单个数据包
+func (s *Store) Packet(ctx context.Context, id int64) (*Packet, error) {
+	pm, err := s.repo.GetPacket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(*pm)
+}
+
+func (s *Store) load(pm capturestore.PacketModel) (*Packet, error) {
+	r, err := readRecord(s.dir, pm.Segment, pm.Offset, pm.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &Packet{ID: pm.ID, ProxyID: pm.ProxyID, Record: *r}, nil
+}
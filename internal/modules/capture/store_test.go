@@ -0,0 +1,103 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	capturestore "proxy-system-backend/internal/storage/capture"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "capture.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&capturestore.SessionModel{}, &capturestore.PacketModel{}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(filepath.Join(dir, "segments"), capturestore.NewSQLiteRepo(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStoreRecordAndPage(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	start := time.Now()
+	s.StartSession(Session{ConnID: "c1", ProxyID: "p1", Dst: "10.0.0.1:7000", StartedAt: start})
+	for i := 0; i < 5; i++ {
+		s.Record("p1", Record{
+			Time:      start.Add(time.Duration(i) * time.Millisecond),
+			ConnID:    "c1",
+			Direction: traffic.DirectionOut,
+			Payload:   []byte{byte(i), 0xff},
+			Plugin:    "demo",
+			Decoded:   json.RawMessage(`{"seq":1}`),
+			Injected:  i == 4,
+		})
+	}
+	s.EndSession("c1")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, total, err := s.Sessions(ctx, capturestore.SessionQuery{ProxyID: "p1", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || sessions[0].Packets != 5 || sessions[0].Bytes != 10 || sessions[0].EndedAt == 0 {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+
+	page, total, err := s.Packets(ctx, "c1", 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(page) != 2 {
+		t.Fatalf("total=%d len=%d", total, len(page))
+	}
+	if page[0].Payload[0] != 2 || page[0].Plugin != "demo" || string(page[0].Decoded) != `{"seq":1}` {
+		t.Fatalf("packet = %+v", page[0])
+	}
+
+	last, _, _ := s.Packets(ctx, "c1", 4, 1)
+	if !last[0].Injected || last[0].Direction != traffic.DirectionOut {
+		t.Fatalf("last packet = %+v", last[0])
+	}
+}
+
+func TestStoreResumeSession(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Now()
+
+	// 规则不再命中时结束会话，重新命中时继续累计
+	s.StartSession(Session{ConnID: "c1", ProxyID: "p1", StartedAt: start})
+	s.Record("p1", Record{ConnID: "c1", Payload: []byte{1, 2}})
+	s.EndSession("c1")
+	s.StartSession(Session{ConnID: "c1", ProxyID: "p1", StartedAt: start.Add(time.Second), Resume: true})
+	s.Record("p1", Record{ConnID: "c1", Payload: []byte{3}})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := s.Session(context.Background(), "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Packets != 2 || sess.Bytes != 3 || sess.StartedAt != start.UnixMilli() || sess.EndedAt != 0 {
+		t.Fatalf("session = %+v", sess)
+	}
+}
@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 地址族偏好
+const (
+	PreferIPv4     = "ipv4"      // 同时查询 A / AAAA，IPv4 地址优先（默认）
+	PreferIPv6     = "ipv6"      // 同时查询，IPv6 地址优先
+	PreferIPv4Only = "ipv4_only" // 只查询 A
+	PreferIPv6Only = "ipv6_only" // 只查询 AAAA
+)
+
+// 上游协议
+const (
+	ProtoUDP = "udp" // 响应被截断时改用 TCP 重试
+	ProtoTCP = "tcp"
+	ProtoTLS = "tls" // DNS over TLS（RFC 7858）
+)
+
+const (
+	defaultTimeout = 3 * time.Second
+	defaultMaxTTL  = time.Hour
+	// 系统解析器不返回 TTL，结果按此缓存
+	systemTTL = 30 * time.Second
+)
+
+// Config 代理出站的域名解析：静态解析 → 缓存 → 上游；未配置上游时使用系统解析器
+type Config struct {
+	// 上游 DNS，按顺序尝试，失败时换下一个：8.8.8.8（默认 udp）、tcp://1.1.1.1:53、
+	// tls://1.1.1.1#cloudflare-dns.com（DoT，# 后为证书名称，省略时为主机名）
+	Upstreams []string `json:"upstreams,omitempty"`
+
+	// 静态解析：域名 → 逗号分隔的 IP，"*.example.com" 匹配子域名；优先于上游，结果不缓存
+	Hosts map[string]string `json:"hosts,omitempty"`
+
+	// ipv4（默认）/ ipv6 / ipv4_only / ipv6_only
+	Prefer string `json:"prefer,omitempty"`
+
+	// 单个上游的超时，默认 3s
+	Timeout string `json:"timeout,omitempty"`
+
+	// 缓存时间的上下限，默认按记录的 TTL、最长 1h；max_ttl=0s 时不缓存
+	MinTTL string `json:"min_ttl,omitempty"`
+	MaxTTL string `json:"max_ttl,omitempty"`
+}
+
+// upstream 解析后的上游地址
+type upstream struct {
+	proto      string
+	addr       string // host:port
+	serverName string // DoT 校验证书的名称
+}
+
+func (u upstream) String() string {
+	return u.proto + "://" + u.addr
+}
+
+// Validate 检查配置并补全默认值
+func (c *Config) Validate() error {
+	switch c.Prefer {
+	case "":
+		c.Prefer = PreferIPv4
+	case PreferIPv4, PreferIPv6, PreferIPv4Only, PreferIPv6Only:
+	default:
+		return fmt.Errorf("unknown dns prefer %q", c.Prefer)
+	}
+	for _, s := range c.Upstreams {
+		if _, err := parseUpstream(s); err != nil {
+			return err
+		}
+	}
+	if _, err := parseHosts(c.Hosts); err != nil {
+		return err
+	}
+	for _, d := range []struct{ name, v string }{{"timeout", c.Timeout}, {"min_ttl", c.MinTTL}, {"max_ttl", c.MaxTTL}} {
+		if d.v == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.v); err != nil || v < 0 {
+			return fmt.Errorf("dns %s: invalid duration %q", d.name, d.v)
+		}
+	}
+	return nil
+}
+
+// durations 超时和缓存时间上下限（已通过 Validate）
+func (c *Config) durations() (timeout, minTTL, maxTTL time.Duration) {
+	timeout, maxTTL = defaultTimeout, defaultMaxTTL
+	if v, _ := time.ParseDuration(c.Timeout); v > 0 {
+		timeout = v
+	}
+	if c.MinTTL != "" {
+		minTTL, _ = time.ParseDuration(c.MinTTL)
+	}
+	if c.MaxTTL != "" {
+		maxTTL, _ = time.ParseDuration(c.MaxTTL)
+	}
+	return timeout, minTTL, maxTTL
+}
+
+func parseUpstream(s string) (upstream, error) {
+	raw := s
+	if !strings.Contains(s, "://") {
+		s = ProtoUDP + "://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.Path != "" && u.Path != "/" {
+		return upstream{}, fmt.Errorf("invalid dns upstream %q", raw)
+	}
+
+	up := upstream{proto: u.Scheme, serverName: u.Fragment}
+	port := "53"
+	switch u.Scheme {
+	case ProtoUDP, ProtoTCP:
+	case ProtoTLS:
+		port = "853"
+		if up.serverName == "" {
+			up.serverName = u.Hostname()
+		}
+	default:
+		return upstream{}, fmt.Errorf("invalid dns upstream %q: unknown protocol %q, expected udp, tcp or tls", raw, u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	up.addr = net.JoinHostPort(u.Hostname(), port)
+	return up, nil
+}
+
+// parseHosts 域名统一为小写、去掉末尾的点
+func parseHosts(hosts map[string]string) (map[string][]net.IP, error) {
+	out := make(map[string][]net.IP, len(hosts))
+	for name, list := range hosts {
+		key := normalizeName(name)
+		if key == "" || key == "*" || key == "*." {
+			return nil, fmt.Errorf("dns hosts: invalid name %q", name)
+		}
+		var ips []net.IP
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("dns hosts %s: invalid ip %q", name, item)
+			}
+			ips = append(ips, ip)
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("dns hosts %s: no ip", name)
+		}
+		out[key] = ips
+	}
+	return out, nil
+}
+
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
@@ -0,0 +1,400 @@
+package dns
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// 解析结果的来源
+const (
+	SourceHosts    = "hosts"
+	SourceCache    = "cache"
+	SourceUpstream = "upstream"
+	SourceSystem   = "system"
+)
+
+// 缓存条目上限，超过时先清理过期条目，仍超过则清空
+const maxCacheEntries = 4096
+
+// ErrNotFound 域名不存在或没有所需地址族的记录
+var ErrNotFound = errors.New("no such host")
+
+// Resolution 一次域名解析的记录
+type Resolution struct {
+	Host      string   `json:"host"`
+	IPs       []string `json:"ips,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	Upstream  string   `json:"upstream,omitempty"` // 实际应答的上游
+	TTL       int64    `json:"ttl"`                // 秒；缓存命中时为剩余时间
+	Error     string   `json:"error,omitempty"`
+	ElapsedMs int64    `json:"elapsed_ms"`
+	Time      int64    `json:"time"`
+}
+
+type cacheKey struct {
+	name  string
+	qtype dnsmessage.Type
+}
+
+type cacheEntry struct {
+	ips      []net.IP
+	upstream string
+	expires  time.Time
+}
+
+// Resolver 按 Config 解析出站连接的域名，并发安全
+type Resolver struct {
+	prefer    string
+	upstreams []upstream
+	hosts     map[string][]net.IP
+	timeout   time.Duration
+	minTTL    time.Duration
+	maxTTL    time.Duration
+
+	// RootCAs 校验 DoT 证书，nil 时使用系统根证书
+	RootCAs *x509.CertPool
+
+	dialer    net.Dialer
+	lookupIP  func(ctx context.Context, network, host string) ([]net.IP, error)
+	now       func() time.Time
+	onResolve func(Resolution)
+
+	mu    sync.Mutex
+	cache map[cacheKey]cacheEntry
+}
+
+func NewResolver(cfg Config) (*Resolver, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Resolver{
+		prefer:   cfg.Prefer,
+		lookupIP: net.DefaultResolver.LookupIP,
+		now:      time.Now,
+		cache:    make(map[cacheKey]cacheEntry),
+	}
+	r.timeout, r.minTTL, r.maxTTL = cfg.durations()
+	r.hosts, _ = parseHosts(cfg.Hosts)
+	for _, s := range cfg.Upstreams {
+		up, _ := parseUpstream(s)
+		r.upstreams = append(r.upstreams, up)
+	}
+	return r, nil
+}
+
+// OnResolve 设置每次解析（包括失败）的回调
+func (r *Resolver) OnResolve(fn func(Resolution)) {
+	r.onResolve = fn
+}
+
+// Resolve 返回 host 的地址，按偏好的地址族排序；IP 字面量直接返回且不记录
+func (r *Resolver) Resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	start := r.now()
+	name := normalizeName(host)
+
+	res := Resolution{Host: name, Time: start.Unix()}
+	ips, err := r.resolve(ctx, name, &res)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	for _, ip := range ips {
+		res.IPs = append(res.IPs, ip.String())
+	}
+	res.ElapsedMs = r.now().Sub(start).Milliseconds()
+	if r.onResolve != nil {
+		r.onResolve(res)
+	}
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host, IsNotFound: errors.Is(err, ErrNotFound)}
+	}
+	return ips, nil
+}
+
+func (r *Resolver) resolve(ctx context.Context, name string, res *Resolution) ([]net.IP, error) {
+	if ips, ok := r.lookupHosts(name); ok {
+		res.Source = SourceHosts
+		if ips = r.order(ips); len(ips) == 0 {
+			return nil, ErrNotFound
+		}
+		return ips, nil
+	}
+
+	var qtypes []dnsmessage.Type
+	switch r.prefer {
+	case PreferIPv4Only:
+		qtypes = []dnsmessage.Type{dnsmessage.TypeA}
+	case PreferIPv6Only:
+		qtypes = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		qtypes = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	}
+
+	// 所有类型都命中缓存时不查询上游
+	var ips []net.IP
+	hit := true
+	ttl := time.Duration(-1)
+	for _, qt := range qtypes {
+		e, ok := r.cached(cacheKey{name, qt})
+		if !ok {
+			hit = false
+			break
+		}
+		ips = append(ips, e.ips...)
+		if left := e.expires.Sub(r.now()); ttl < 0 || left < ttl {
+			ttl = left
+		}
+		res.Upstream = e.upstream
+	}
+	if hit {
+		res.Source, res.TTL = SourceCache, int64(ttl.Round(time.Second)/time.Second)
+		if ips = r.order(ips); len(ips) == 0 {
+			return nil, ErrNotFound
+		}
+		return ips, nil
+	}
+	res.Upstream = ""
+
+	if len(r.upstreams) == 0 {
+		res.Source = SourceSystem
+		return r.resolveSystem(ctx, name, qtypes, res)
+	}
+	res.Source = SourceUpstream
+	return r.resolveUpstream(ctx, name, qtypes, res)
+}
+
+// lookupHosts 静态解析：先精确匹配，再从最长的父域名开始匹配通配符
+func (r *Resolver) lookupHosts(name string) ([]net.IP, bool) {
+	if ips, ok := r.hosts[name]; ok {
+		return ips, true
+	}
+	for s := name; ; {
+		i := strings.IndexByte(s, '.')
+		if i < 0 {
+			return nil, false
+		}
+		s = s[i+1:]
+		if ips, ok := r.hosts["*."+s]; ok {
+			return ips, true
+		}
+	}
+}
+
+type queryResult struct {
+	qtype dnsmessage.Type
+	ans   answer
+	up    upstream
+	err   error
+}
+
+func (r *Resolver) resolveUpstream(ctx context.Context, name string, qtypes []dnsmessage.Type, res *Resolution) ([]net.IP, error) {
+	results := make(chan queryResult, len(qtypes))
+	for _, qt := range qtypes {
+		go func() {
+			ans, up, err := r.queryUpstreams(ctx, name, qt)
+			results <- queryResult{qtype: qt, ans: ans, up: up, err: err}
+		}()
+	}
+
+	var (
+		ips      []net.IP
+		errs     []error
+		notFound = true
+		ttl      = time.Duration(-1)
+	)
+	for range qtypes {
+		q := <-results
+		if q.err != nil {
+			errs = append(errs, q.err)
+			notFound = false
+			continue
+		}
+		if !q.ans.notFound {
+			notFound = false
+		}
+		d := r.store(cacheKey{name, q.qtype}, q.ans, q.up.String())
+		if ttl < 0 || d < ttl {
+			ttl = d
+		}
+		if res.Upstream == "" || q.qtype == qtypes[0] {
+			res.Upstream = q.up.String()
+		}
+		ips = append(ips, q.ans.ips...)
+	}
+	res.TTL = int64(max(ttl, 0) / time.Second)
+
+	if ips = r.order(ips); len(ips) > 0 {
+		return ips, nil
+	}
+	// 部分查询失败且没有得到地址时报告失败原因
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if notFound {
+		return nil, fmt.Errorf("%w (NXDOMAIN)", ErrNotFound)
+	}
+	return nil, ErrNotFound
+}
+
+// queryUpstreams 按顺序尝试上游，返回第一个成功的应答
+func (r *Resolver) queryUpstreams(ctx context.Context, name string, qtype dnsmessage.Type) (answer, upstream, error) {
+	var lastErr error
+	for _, up := range r.upstreams {
+		if err := ctx.Err(); err != nil {
+			return answer{}, up, err
+		}
+		ans, err := r.query(ctx, up, name, qtype)
+		if err == nil {
+			return ans, up, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", up, err)
+	}
+	return answer{}, upstream{}, lastErr
+}
+
+// resolveSystem 未配置上游时使用系统解析器，结果缓存 systemTTL
+func (r *Resolver) resolveSystem(ctx context.Context, name string, qtypes []dnsmessage.Type, res *Resolution) ([]net.IP, error) {
+	network := "ip"
+	if len(qtypes) == 1 {
+		network = map[dnsmessage.Type]string{dnsmessage.TypeA: "ip4", dnsmessage.TypeAAAA: "ip6"}[qtypes[0]]
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	ips, err := r.lookupIP(ctx, network, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var ttl time.Duration
+	for _, qt := range qtypes {
+		var ans answer
+		for _, ip := range ips {
+			if (ip.To4() != nil) == (qt == dnsmessage.TypeA) {
+				ans.ips = append(ans.ips, ip)
+			}
+		}
+		ans.ttl = systemTTL
+		ttl = r.store(cacheKey{name, qt}, ans, "")
+	}
+	res.TTL = int64(ttl / time.Second)
+
+	if ips = r.order(ips); len(ips) == 0 {
+		return nil, ErrNotFound
+	}
+	return ips, nil
+}
+
+func (r *Resolver) cached(key cacheKey) (cacheEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.cache[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	if !r.now().Before(e.expires) {
+		delete(r.cache, key)
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+// store 按 min_ttl / max_ttl 修正 TTL 后缓存应答，返回实际的缓存时间
+func (r *Resolver) store(key cacheKey, ans answer, up string) time.Duration {
+	ttl := min(max(ans.ttl, r.minTTL), r.maxTTL)
+	if ttl <= 0 {
+		return 0
+	}
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cache) >= maxCacheEntries {
+		for k, e := range r.cache {
+			if !now.Before(e.expires) {
+				delete(r.cache, k)
+			}
+		}
+		if len(r.cache) >= maxCacheEntries {
+			clear(r.cache)
+		}
+	}
+	r.cache[key] = cacheEntry{ips: ans.ips, upstream: up, expires: now.Add(ttl)}
+	return ttl
+}
+
+// order 按偏好排序（稳定），*_only 时去掉另一地址族
+func (r *Resolver) order(ips []net.IP) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch r.prefer {
+	case PreferIPv4Only:
+		return v4
+	case PreferIPv6Only:
+		return v6
+	case PreferIPv6:
+		return append(v6, v4...)
+	default:
+		return append(v4, v6...)
+	}
+}
+
+// DialFunc 实际建立连接的函数
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dialer 先用 Resolver 解析目标域名，再按顺序连接各个地址
+type Dialer struct {
+	resolver *Resolver
+	next     DialFunc
+}
+
+func NewDialer(r *Resolver, next DialFunc) *Dialer {
+	return &Dialer{resolver: r, next: next}
+}
+
+// DialContext 所有地址都连接失败时返回最后一个错误
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return d.next(ctx, network, addr)
+	}
+	ips, err := d.resolver.Resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := d.next(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,436 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"proxy-system-backend/internal/modules/mitm"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubServer 本地 DNS 服务器，UDP 与 TCP 监听同一端口
+type stubServer struct {
+	records  map[string][]net.IP // 域名 → 地址，不在表中的返回 NXDOMAIN
+	ttl      uint32
+	truncate bool // UDP 响应只返回 TC 位
+	rcode    dnsmessage.RCode
+
+	udp     net.PacketConn
+	tcp     net.Listener
+	queries atomic.Int32
+}
+
+func newStub(t *testing.T, records map[string][]net.IP, opts ...func(*stubServer)) *stubServer {
+	t.Helper()
+	s := &stubServer{records: records, ttl: 60}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for {
+		tcp, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		udp, err := net.ListenPacket("udp", tcp.Addr().String())
+		if err != nil {
+			tcp.Close()
+			continue
+		}
+		s.tcp, s.udp = tcp, udp
+		break
+	}
+	t.Cleanup(func() { s.udp.Close(); s.tcp.Close() })
+
+	go s.serveUDP()
+	go s.serveStream(s.tcp)
+	return s
+}
+
+func (s *stubServer) addr() string {
+	return s.tcp.Addr().String()
+}
+
+func (s *stubServer) serveUDP() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.handle(buf[:n], true); resp != nil {
+			_, _ = s.udp.WriteTo(resp, from)
+		}
+	}
+}
+
+func (s *stubServer) serveStream(ln net.Listener) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			var l [2]byte
+			if _, err := io.ReadFull(c, l[:]); err != nil {
+				return
+			}
+			q := make([]byte, binary.BigEndian.Uint16(l[:]))
+			if _, err := io.ReadFull(c, q); err != nil {
+				return
+			}
+			resp := s.handle(q, false)
+			_, _ = c.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp))))
+			_, _ = c.Write(resp)
+		}()
+	}
+}
+
+func (s *stubServer) handle(q []byte, udp bool) []byte {
+	s.queries.Add(1)
+	var p dnsmessage.Parser
+	h, err := p.Start(q)
+	if err != nil {
+		return nil
+	}
+	question, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	rh := dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true, RCode: s.rcode}
+	name := strings.TrimSuffix(question.Name.String(), ".")
+	ips, ok := s.records[name]
+	if !ok && s.rcode == dnsmessage.RCodeSuccess {
+		rh.RCode = dnsmessage.RCodeNameError
+	}
+	if udp && s.truncate {
+		rh.Truncated = true
+		ips = nil
+	}
+
+	b := dnsmessage.NewBuilder(nil, rh)
+	_ = b.StartQuestions()
+	_ = b.Question(question)
+	_ = b.StartAnswers()
+	for _, ip := range ips {
+		hdr := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: s.ttl}
+		if v4 := ip.To4(); v4 != nil && question.Type == dnsmessage.TypeA {
+			_ = b.AResource(hdr, dnsmessage.AResource{A: [4]byte(v4)})
+		} else if v4 == nil && question.Type == dnsmessage.TypeAAAA {
+			_ = b.AAAAResource(hdr, dnsmessage.AAAAResource{AAAA: [16]byte(ip.To16())})
+		}
+	}
+	_ = b.StartAuthorities()
+	soaName := dnsmessage.MustNewName("test.")
+	_ = b.SOAResource(
+		dnsmessage.ResourceHeader{Name: soaName, Class: dnsmessage.ClassINET, TTL: 300},
+		dnsmessage.SOAResource{NS: soaName, MBox: soaName, MinTTL: 5},
+	)
+	resp, _ := b.Finish()
+	return resp
+}
+
+func newTestResolver(t *testing.T, cfg Config) (*Resolver, *[]Resolution, *time.Time) {
+	t.Helper()
+	r, err := NewResolver(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+	var (
+		mu  sync.Mutex
+		log []Resolution
+	)
+	r.OnResolve(func(res Resolution) {
+		mu.Lock()
+		log = append(log, res)
+		mu.Unlock()
+	})
+	return r, &log, &now
+}
+
+func ipStrings(ips []net.IP) []string {
+	var out []string
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, cfg := range []Config{
+		{Prefer: "ipv5"},
+		{Upstreams: []string{"quic://1.1.1.1"}},
+		{Upstreams: []string{"udp://"}},
+		{Hosts: map[string]string{"a.test": "1.2.3"}},
+		{Hosts: map[string]string{"*.": "1.2.3.4"}},
+		{MaxTTL: "-1s"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%+v: expected error", cfg)
+		}
+	}
+
+	for in, want := range map[string]upstream{
+		"8.8.8.8":                          {proto: ProtoUDP, addr: "8.8.8.8:53"},
+		"tcp://[2001:db8::1]":              {proto: ProtoTCP, addr: "[2001:db8::1]:53"},
+		"tls://1.1.1.1#cloudflare-dns.com": {proto: ProtoTLS, addr: "1.1.1.1:853", serverName: "cloudflare-dns.com"},
+		"tls://dns.google:8853":            {proto: ProtoTLS, addr: "dns.google:8853", serverName: "dns.google"},
+	} {
+		up, err := parseUpstream(in)
+		if err != nil || up != want {
+			t.Errorf("parseUpstream(%q) = %+v, %v", in, up, err)
+		}
+	}
+}
+
+func TestResolveHosts(t *testing.T) {
+	r, log, _ := newTestResolver(t, Config{
+		Prefer: PreferIPv6,
+		Hosts: map[string]string{
+			"Game.Test.":     "10.0.0.1, 2001:db8::1",
+			"*.cdn.test":     "10.0.0.2",
+			"only6.cdn.test": "2001:db8::2",
+		},
+	})
+	ctx := context.Background()
+
+	for host, want := range map[string][]string{
+		"game.test":      {"2001:db8::1", "10.0.0.1"},
+		"a.b.cdn.test":   {"10.0.0.2"},
+		"only6.cdn.test": {"2001:db8::2"},
+		"192.168.1.1":    {"192.168.1.1"},
+	} {
+		ips, err := r.Resolve(ctx, host)
+		if err != nil || !slices.Equal(ipStrings(ips), want) {
+			t.Errorf("Resolve(%q) = %v, %v; want %v", host, ips, err, want)
+		}
+	}
+	// IP 字面量不记录
+	if len(*log) != 3 || (*log)[0].Source != SourceHosts {
+		t.Fatalf("log = %+v", *log)
+	}
+
+	r.prefer = PreferIPv4Only
+	if _, err := r.Resolve(ctx, "only6.cdn.test"); !isNotFound(err) {
+		t.Fatalf("ipv4_only on v6 host: err = %v", err)
+	}
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func TestResolveUpstreamCache(t *testing.T) {
+	stub := newStub(t, map[string][]net.IP{
+		"game.test": {net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+	})
+	r, log, now := newTestResolver(t, Config{Upstreams: []string{stub.addr()}, MinTTL: "10s", MaxTTL: "30s"})
+	ctx := context.Background()
+
+	ips, err := r.Resolve(ctx, "GAME.test")
+	if err != nil || !slices.Equal(ipStrings(ips), []string{"10.0.0.1", "2001:db8::1"}) {
+		t.Fatalf("Resolve = %v, %v", ips, err)
+	}
+	if q := stub.queries.Load(); q != 2 {
+		t.Fatalf("queries = %d, want A + AAAA", q)
+	}
+	first := (*log)[0]
+	if first.Source != SourceUpstream || first.Upstream != "udp://"+stub.addr() || first.TTL != 30 {
+		t.Fatalf("resolution = %+v", first)
+	}
+
+	// TTL 60s 被 max_ttl 截为 30s
+	*now = now.Add(20 * time.Second)
+	if _, err := r.Resolve(ctx, "game.test"); err != nil {
+		t.Fatal(err)
+	}
+	if q := stub.queries.Load(); q != 2 || (*log)[1].Source != SourceCache || (*log)[1].TTL != 10 {
+		t.Fatalf("queries = %d, resolution = %+v", q, (*log)[1])
+	}
+
+	*now = now.Add(15 * time.Second)
+	if _, err := r.Resolve(ctx, "game.test"); err != nil {
+		t.Fatal(err)
+	}
+	if q := stub.queries.Load(); q != 4 {
+		t.Fatalf("expired entry not refreshed, queries = %d", q)
+	}
+
+	// NXDOMAIN 按 SOA minimum（5s）、min_ttl（10s）缓存
+	if _, err := r.Resolve(ctx, "missing.test"); !isNotFound(err) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := r.Resolve(ctx, "missing.test"); !isNotFound(err) {
+		t.Fatalf("err = %v", err)
+	}
+	if q := stub.queries.Load(); q != 6 {
+		t.Fatalf("negative answer not cached, queries = %d", q)
+	}
+	if last := (*log)[len(*log)-1]; last.Source != SourceCache || last.Error == "" {
+		t.Fatalf("resolution = %+v", last)
+	}
+}
+
+func TestResolveFailoverAndTruncation(t *testing.T) {
+	// 第一个上游返回 SERVFAIL，第二个上游的 UDP 响应被截断
+	bad := newStub(t, nil, func(s *stubServer) { s.rcode = dnsmessage.RCodeServerFailure })
+	good := newStub(t, map[string][]net.IP{"game.test": {net.ParseIP("10.0.0.1")}}, func(s *stubServer) { s.truncate = true })
+
+	r, log, _ := newTestResolver(t, Config{
+		Upstreams: []string{bad.addr(), "udp://" + good.addr()},
+		Prefer:    PreferIPv4Only,
+	})
+	ips, err := r.Resolve(context.Background(), "game.test")
+	if err != nil || !slices.Equal(ipStrings(ips), []string{"10.0.0.1"}) {
+		t.Fatalf("Resolve = %v, %v", ips, err)
+	}
+	if bad.queries.Load() != 1 || good.queries.Load() != 2 {
+		t.Fatalf("queries bad=%d good=%d", bad.queries.Load(), good.queries.Load())
+	}
+	if (*log)[0].Upstream != "udp://"+good.addr() {
+		t.Fatalf("upstream = %s", (*log)[0].Upstream)
+	}
+
+	// 全部失败
+	r2, log2, _ := newTestResolver(t, Config{Upstreams: []string{bad.addr()}, Prefer: PreferIPv4Only})
+	if _, err := r2.Resolve(context.Background(), "game.test"); err == nil || isNotFound(err) {
+		t.Fatalf("err = %v", err)
+	}
+	if !strings.Contains((*log2)[0].Error, "SERVFAIL") {
+		t.Fatalf("error = %q", (*log2)[0].Error)
+	}
+}
+
+func TestResolveDoT(t *testing.T) {
+	ca, err := mitm.LoadOrCreateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := newStub(t, map[string][]net.IP{"game.test": {net.ParseIP("10.0.0.1")}})
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(h *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return ca.Issue(h.ServerName)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go stub.serveStream(ln)
+
+	r, log, _ := newTestResolver(t, Config{Upstreams: []string{"tls://" + ln.Addr().String() + "#dns.test"}, Prefer: PreferIPv4Only})
+	r.RootCAs = x509.NewCertPool()
+	r.RootCAs.AddCert(ca.Certificate())
+
+	ips, err := r.Resolve(context.Background(), "game.test")
+	if err != nil || !slices.Equal(ipStrings(ips), []string{"10.0.0.1"}) {
+		t.Fatalf("Resolve = %v, %v", ips, err)
+	}
+	if (*log)[0].Upstream != "tls://"+ln.Addr().String() {
+		t.Fatalf("resolution = %+v", (*log)[0])
+	}
+
+	// 证书不受信任
+	r2, _, _ := newTestResolver(t, Config{Upstreams: []string{"tls://" + ln.Addr().String() + "#dns.test"}, Prefer: PreferIPv4Only})
+	r2.RootCAs = x509.NewCertPool()
+	if _, err := r2.Resolve(context.Background(), "game.test"); err == nil {
+		t.Fatal("untrusted DoT certificate accepted")
+	}
+}
+
+func TestResolveSystem(t *testing.T) {
+	r, log, _ := newTestResolver(t, Config{})
+	calls := 0
+	r.lookupIP = func(_ context.Context, network, host string) ([]net.IP, error) {
+		calls++
+		if host != "game.test" || network != "ip" {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("10.0.0.1")}, nil
+	}
+	for range 2 {
+		ips, err := r.Resolve(context.Background(), "game.test")
+		if err != nil || !slices.Equal(ipStrings(ips), []string{"10.0.0.1", "2001:db8::1"}) {
+			t.Fatalf("Resolve = %v, %v", ips, err)
+		}
+	}
+	if calls != 1 || (*log)[0].Source != SourceSystem || (*log)[1].Source != SourceCache {
+		t.Fatalf("calls = %d, log = %+v", calls, *log)
+	}
+	if _, err := r.Resolve(context.Background(), "missing.test"); !isNotFound(err) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestDialerUsesResolver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			_, _ = c.Write([]byte("ok"))
+			c.Close()
+		}
+	}()
+
+	r, log, _ := newTestResolver(t, Config{Hosts: map[string]string{"game.test": "127.0.0.2, 127.0.0.1"}})
+	var dialed []string
+	var nd net.Dialer
+	d := NewDialer(r, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if strings.HasPrefix(addr, "127.0.0.2:") {
+			return nil, errors.New("unreachable")
+		}
+		return nd.DialContext(ctx, network, addr)
+	})
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	c, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("game.test", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	buf, _ := io.ReadAll(c)
+	if string(buf) != "ok" || len(dialed) != 2 || len(*log) != 1 {
+		t.Fatalf("read %q, dialed %v, log %+v", buf, dialed, *log)
+	}
+}
+
+func TestParseAnswerZeroTTL(t *testing.T) {
+	name := dnsmessage.MustNewName("www.test.")
+	target := dnsmessage.MustNewName("edge.test.")
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 7, Response: true})
+	_ = b.StartAnswers()
+	// TTL 为 0 的 CNAME 不缓存，即使后面的 A 记录 TTL 较长
+	_ = b.CNAMEResource(dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: 0}, dnsmessage.CNAMEResource{CNAME: target})
+	_ = b.AResource(dnsmessage.ResourceHeader{Name: target, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}})
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := parseAnswer(msg, 7, dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.ips) != 1 || a.ttl != 0 {
+		t.Fatalf("answer = %+v", a)
+	}
+}
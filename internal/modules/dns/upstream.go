@@ -0,0 +1,247 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer 一次查询的结果；ips 为空且 notFound=false 表示域名存在但没有该类型的记录
+type answer struct {
+	ips      []net.IP
+	ttl      time.Duration // 记录（或否定应答 SOA）的最小 TTL，0 表示不缓存
+	notFound bool          // NXDOMAIN
+}
+
+// query 向上游查询 name 的 A / AAAA 记录
+func (r *Resolver) query(ctx context.Context, up upstream, name string, qtype dnsmessage.Type) (answer, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	id := uint16(rand.Uint32())
+	q, err := buildQuery(id, name, qtype)
+	if err != nil {
+		return answer{}, err
+	}
+
+	var resp []byte
+	switch up.proto {
+	case ProtoUDP:
+		resp, err = r.exchangeUDP(ctx, up, id, q)
+		if err == nil && truncated(resp) {
+			// 响应超过 UDP 报文大小，改用 TCP
+			resp, err = r.exchangeStream(ctx, upstream{proto: ProtoTCP, addr: up.addr}, q)
+		}
+	default:
+		resp, err = r.exchangeStream(ctx, up, q)
+	}
+	if err != nil {
+		return answer{}, err
+	}
+	return parseAnswer(resp, id, qtype)
+}
+
+func buildQuery(id uint16, name string, qtype dnsmessage.Type) ([]byte, error) {
+	n, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %w", name, err)
+	}
+	b := dnsmessage.NewBuilder(make([]byte, 2, 514), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: n, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	// 声明 EDNS0 以接收较大的 UDP 响应
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	// 前两个字节预留给 TCP 的长度前缀
+	binary.BigEndian.PutUint16(msg, uint16(len(msg)-2))
+	return msg, nil
+}
+
+func (r *Resolver) exchangeUDP(ctx context.Context, up upstream, id uint16, q []byte) ([]byte, error) {
+	conn, err := r.dialer.DialContext(ctx, "udp", up.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(q[2:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// 忽略 ID 不符的报文（迟到的响应或伪造）
+		if n >= 12 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+// exchangeStream TCP / DoT：报文前加两字节长度
+func (r *Resolver) exchangeStream(ctx context.Context, up upstream, q []byte) ([]byte, error) {
+	conn, err := r.dialer.DialContext(ctx, "tcp", up.addr)
+	if err != nil {
+		return nil, err
+	}
+	if up.proto == ProtoTLS {
+		tc := tls.Client(conn, &tls.Config{ServerName: up.serverName, RootCAs: r.RootCAs, MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(q); err != nil {
+		return nil, err
+	}
+	var l [2]byte
+	if _, err := io.ReadFull(conn, l[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// truncated 响应头的 TC 位
+func truncated(msg []byte) bool {
+	return len(msg) > 2 && msg[2]&0x02 != 0
+}
+
+func parseAnswer(msg []byte, id uint16, qtype dnsmessage.Type) (answer, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return answer{}, fmt.Errorf("invalid dns response: %w", err)
+	}
+	if h.ID != id || !h.Response {
+		return answer{}, fmt.Errorf("invalid dns response: id mismatch")
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+	default:
+		return answer{}, fmt.Errorf("dns server returned %s", rcodeName(h.RCode))
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return answer{}, fmt.Errorf("invalid dns response: %w", err)
+	}
+
+	a := answer{notFound: h.RCode == dnsmessage.RCodeNameError}
+	var (
+		ttl  uint32
+		seen bool // TTL 为 0 的记录同样参与取最小值
+	)
+	minTTL := func(v uint32) {
+		if !seen || v < ttl {
+			ttl, seen = v, true
+		}
+	}
+	// 递归服务器返回完整的 CNAME 链，直接取其中的 A / AAAA 记录
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return answer{}, fmt.Errorf("invalid dns response: %w", err)
+		}
+		switch {
+		case rh.Type == qtype && qtype == dnsmessage.TypeA:
+			res, err := p.AResource()
+			if err != nil {
+				return answer{}, err
+			}
+			a.ips = append(a.ips, net.IP(res.A[:]))
+			minTTL(rh.TTL)
+		case rh.Type == qtype && qtype == dnsmessage.TypeAAAA:
+			res, err := p.AAAAResource()
+			if err != nil {
+				return answer{}, err
+			}
+			a.ips = append(a.ips, net.IP(res.AAAA[:]))
+			minTTL(rh.TTL)
+		default:
+			if rh.Type == dnsmessage.TypeCNAME {
+				minTTL(rh.TTL)
+			}
+			if err := p.SkipAnswer(); err != nil {
+				return answer{}, err
+			}
+		}
+	}
+
+	if len(a.ips) == 0 {
+		// 否定应答按 SOA 的 minimum 缓存（RFC 2308）
+		ttl = 0
+		for {
+			rh, err := p.AuthorityHeader()
+			if err != nil {
+				break
+			}
+			if rh.Type != dnsmessage.TypeSOA {
+				_ = p.SkipAuthority()
+				continue
+			}
+			soa, err := p.SOAResource()
+			if err != nil {
+				break
+			}
+			ttl = min(rh.TTL, soa.MinTTL)
+			break
+		}
+	}
+	a.ttl = time.Duration(ttl) * time.Second
+	return a, nil
+}
+
+func rcodeName(c dnsmessage.RCode) string {
+	switch c {
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	}
+	return fmt.Sprintf("rcode %d", c)
+}
@@ -0,0 +1,72 @@
+package filter
+
+// acMatcher Aho-Corasick 多模式匹配，构建后只读，可并发使用
+type acMatcher struct {
+	next [][256]int32 // 完整转移表（已合并失败指针）
+	out  [][]int32    // 每个状态结束的模式
+	lens []int        // 模式长度
+}
+
+// newACMatcher 以 patterns 的下标作为模式 ID
+func newACMatcher(patterns [][]byte) *acMatcher {
+	m := &acMatcher{
+		next: make([][256]int32, 1),
+		out:  make([][]int32, 1),
+		lens: make([]int, len(patterns)),
+	}
+
+	// 1️⃣ trie
+	for id, p := range patterns {
+		m.lens[id] = len(p)
+		state := int32(0)
+		for _, b := range p {
+			if m.next[state][b] == 0 {
+				m.next = append(m.next, [256]int32{})
+				m.out = append(m.out, nil)
+				m.next[state][b] = int32(len(m.next) - 1)
+			}
+			state = m.next[state][b]
+		}
+		m.out[state] = append(m.out[state], int32(id))
+	}
+
+	// 2️⃣ BFS 计算失败指针，并把缺失的转移补成失败指针的转移
+	fail := make([]int32, len(m.next))
+	queue := make([]int32, 0, len(m.next))
+	for b := 0; b < 256; b++ {
+		if s := m.next[0][b]; s != 0 {
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		m.out[s] = append(m.out[s], m.out[fail[s]]...)
+		for b := 0; b < 256; b++ {
+			t := m.next[s][b]
+			if t == 0 {
+				m.next[s][b] = m.next[fail[s]][b]
+				continue
+			}
+			fail[t] = m.next[fail[s]][b]
+			queue = append(queue, t)
+		}
+	}
+	return m
+}
+
+// scan 返回每个模式出现的起始位置
+func (m *acMatcher) scan(p []byte) map[int32][]int {
+	var hits map[int32][]int
+	state := int32(0)
+	for i, b := range p {
+		state = m.next[state][b]
+		for _, id := range m.out[state] {
+			if hits == nil {
+				hits = make(map[int32][]int)
+			}
+			hits[id] = append(hits[id], i-m.lens[id]+1)
+		}
+	}
+	return hits
+}
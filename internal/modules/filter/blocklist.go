@@ -0,0 +1,311 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// BlocklistOptions 纯文本黑名单的转换选项
+type BlocklistOptions struct {
+	Action   string // 默认 deny
+	Priority int
+	Tags     []string
+	Source   bool // IP / CIDR 匹配客户端地址（src_ip），默认匹配目标地址（dst_ip）
+}
+
+// ParseBlocklist 每行一个 IP、CIDR 或域名（# 开始注释），也接受 hosts 文件格式（"0.0.0.0 ads.example.com"，
+// 其中不带点的主机名如 localhost 忽略）。每个条目生成一条以条目命名的规则，重复条目只保留一条；
+// 域名同时匹配其子域名，"*.example.com" 只匹配子域名
+func ParseBlocklist(data []byte, opts BlocklistOptions) (RuleFile, error) {
+	action := opts.Action
+	if action == "" {
+		action = string(ActionDeny)
+	}
+	f := RuleFile{Version: RuleFileVersion}
+	seen := map[string]bool{}
+	add := func(name string, p PortableRule) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		p.Name, p.Action, p.Priority, p.Tags = name, action, opts.Priority, slices.Clone(opts.Tags)
+		f.Rules = append(f.Rules, p)
+	}
+
+	verr := &ValidationError{}
+	lines, numbers := splitLines(data, true)
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		hosts := len(fields) > 1 && net.ParseIP(fields[0]) != nil
+		if !hosts && len(fields) > 1 {
+			verr.add(fmt.Sprintf("line %d", numbers[i]), fmt.Errorf("expected one entry per line, got %q", line))
+			continue
+		}
+		if hosts {
+			// hosts 文件格式：第一列为指向的地址，后面是要拦截的域名
+			fields = fields[1:]
+		}
+
+		for _, entry := range fields {
+			entry = strings.ToLower(strings.TrimSuffix(entry, "."))
+			if cidrs, err := ParseCIDRList(entry); err == nil && !hosts {
+				p := PortableRule{DstIP: cidrs[0]}
+				if opts.Source {
+					p = PortableRule{SrcIP: cidrs[0]}
+				}
+				add(cidrs[0], p)
+				continue
+			}
+			if hosts && !strings.Contains(entry, ".") {
+				continue
+			}
+			expr, err := domainExpr(entry)
+			if err != nil {
+				verr.add(fmt.Sprintf("line %d", numbers[i]), err)
+				continue
+			}
+			add(entry, PortableRule{Expr: expr})
+		}
+	}
+	if len(verr.Fields) > 0 {
+		return RuleFile{}, verr
+	}
+	return f, nil
+}
+
+// domainExpr 域名条目转换为过滤表达式
+func domainExpr(entry string) (string, error) {
+	name, wildcard := strings.CutPrefix(entry, "*.")
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("invalid ip, cidr or domain %q", entry)
+	}
+	for _, l := range labels {
+		if l == "" || len(l) > 63 || strings.HasPrefix(l, "-") || strings.HasSuffix(l, "-") ||
+			strings.IndexFunc(l, func(r rune) bool { return !isDomainRune(r) }) >= 0 {
+			return "", fmt.Errorf("invalid ip, cidr or domain %q", entry)
+		}
+	}
+	if wildcard {
+		return fmt.Sprintf(`domain ~ "*.%s"`, name), nil
+	}
+	return fmt.Sprintf(`domain == "%s" || domain ~ "*.%s"`, name, name), nil
+}
+
+func isDomainRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
+
+// iptables 规则之间的优先级间隔
+const iptablesPriorityStep = 10
+
+// ParseIPTables 解析 iptables-save 输出或 iptables 命令中的 -A 规则（子集）：
+// -p tcp|udp|all、-s / -d（可逗号分隔）、--sport / --dport、-m multiport --sports / --dports、
+// -m tcp / udp / comment、--comment、-j DROP|REJECT|ACCEPT|LOG（REJECT --reject-with tcp-reset 转换为 reset）。
+// -s 匹配客户端地址，-d 匹配目标地址，与链无关。规则按出现顺序赋予递减的优先级（最后一条为 priority），
+// 与 iptables 一样首个命中的终结规则生效。*filter、:INPUT ACCEPT、-P、-N、COMMIT 等行忽略；
+// 条件无法表达的规则（网卡、连接状态、取反、跳转到自定义链等）跳过，原因在 notes 中列出
+func ParseIPTables(data []byte, priority int) (f RuleFile, notes []string, err error) {
+	f = RuleFile{Version: RuleFileVersion}
+	verr := &ValidationError{}
+	names := map[string]bool{}
+
+	lines, numbers := splitLines(data, false)
+	for i, line := range lines {
+		args, err := shellFields(line)
+		if err != nil {
+			verr.add(fmt.Sprintf("line %d", numbers[i]), err)
+			continue
+		}
+		if len(args) > 0 && (args[0] == "iptables" || args[0] == "ip6tables") {
+			args = args[1:]
+		}
+		if len(args) == 0 || args[0] != "-A" && args[0] != "--append" {
+			continue
+		}
+
+		p, skip, err := parseIPTablesRule(args)
+		switch {
+		case err != nil:
+			verr.add(fmt.Sprintf("line %d", numbers[i]), err)
+			continue
+		case skip != "":
+			notes = append(notes, fmt.Sprintf("line %d: skipped, %s", numbers[i], skip))
+			continue
+		}
+		if names[p.Name] {
+			p.Name = fmt.Sprintf("%s (line %d)", p.Name, numbers[i])
+		}
+		names[p.Name] = true
+		f.Rules = append(f.Rules, p)
+	}
+	if len(verr.Fields) > 0 {
+		return RuleFile{}, nil, verr
+	}
+	for i := range f.Rules {
+		f.Rules[i].Priority = priority + (len(f.Rules)-1-i)*iptablesPriorityStep
+	}
+	return f, notes, nil
+}
+
+// parseIPTablesRule args 以 -A <chain> 开头；skip 非空时表示规则无法表达
+func parseIPTablesRule(args []string) (p PortableRule, skip string, err error) {
+	if len(args) < 2 {
+		return p, "", fmt.Errorf("-A requires a chain")
+	}
+	var (
+		proto, target, rejectWith, comment string
+		spec                               []string // 规范化的规则，无注释时用作规则名
+	)
+	next := func(i *int, opt string) (string, error) {
+		if *i+1 >= len(args) {
+			return "", fmt.Errorf("%s requires an argument", opt)
+		}
+		*i++
+		return args[*i], nil
+	}
+
+	for i := 2; i < len(args); i++ {
+		opt := args[i]
+		switch opt {
+		case "!":
+			return p, "negated matches are not supported", nil
+		case "-p", "--protocol":
+			v, err := next(&i, opt)
+			if err != nil {
+				return p, "", err
+			}
+			switch proto = strings.ToLower(v); proto {
+			case "tcp", "udp":
+				spec = append(spec, "-p "+proto)
+			case "all":
+				proto = ""
+			default:
+				return p, fmt.Sprintf("protocol %q is not supported", v), nil
+			}
+		case "-s", "--source", "-d", "--destination":
+			v, err := next(&i, opt)
+			if err != nil {
+				return p, "", err
+			}
+			cidrs, err := ParseCIDRList(v)
+			if err != nil {
+				return p, "", fmt.Errorf("%s: %w", opt, err)
+			}
+			list := strings.Join(cidrs, ",")
+			if opt == "-s" || opt == "--source" {
+				p.SrcIP = list
+				spec = append(spec, "-s "+list)
+			} else {
+				p.DstIP = list
+				spec = append(spec, "-d "+list)
+			}
+		case "--sport", "--source-port", "--sports", "--source-ports", "--dport", "--destination-port", "--dports", "--destination-ports":
+			v, err := next(&i, opt)
+			if err != nil {
+				return p, "", err
+			}
+			ports := strings.ReplaceAll(v, ":", "-") // iptables 的端口范围写作 lo:hi
+			if _, err := ParsePortList(ports); err != nil {
+				return p, "", fmt.Errorf("%s: %w", opt, err)
+			}
+			if strings.HasPrefix(opt, "--s") {
+				p.SrcPort = ports
+				spec = append(spec, "--sport "+ports)
+			} else {
+				p.DstPort = ports
+				spec = append(spec, "--dport "+ports)
+			}
+		case "-m", "--match":
+			v, err := next(&i, opt)
+			if err != nil {
+				return p, "", err
+			}
+			switch v {
+			case "tcp", "udp", "multiport", "comment":
+			default:
+				return p, fmt.Sprintf("match module %q is not supported", v), nil
+			}
+		case "--comment":
+			if comment, err = next(&i, opt); err != nil {
+				return p, "", err
+			}
+		case "-j", "--jump":
+			if target, err = next(&i, opt); err != nil {
+				return p, "", err
+			}
+		case "--reject-with":
+			if rejectWith, err = next(&i, opt); err != nil {
+				return p, "", err
+			}
+		default:
+			return p, fmt.Sprintf("option %s is not supported", opt), nil
+		}
+	}
+
+	switch target {
+	case "DROP":
+		p.Action = string(ActionDeny)
+	case "REJECT":
+		p.Action = string(ActionDeny)
+		if rejectWith == "tcp-reset" {
+			p.Action = string(ActionReset)
+		}
+	case "ACCEPT":
+		p.Action = string(ActionAllow)
+	case "LOG":
+		p.Action = string(ActionLog)
+	case "":
+		return p, "rule has no target", nil
+	default:
+		return p, fmt.Sprintf("target %q is not supported", target), nil
+	}
+	spec = append(spec, "-j "+target)
+
+	if proto != "" {
+		p.Expr = "proto == " + proto
+	}
+	p.Name = strings.Join(spec, " ")
+	if comment != "" {
+		p.Name, p.Description = comment, strings.Join(spec, " ")
+	}
+	return p, "", nil
+}
+
+// shellFields 按空白拆分，支持单引号 / 双引号（iptables-save 的 --comment 带引号）
+func shellFields(line string) ([]string, error) {
+	var (
+		out   []string
+		cur   strings.Builder
+		quote rune
+		inArg bool
+	)
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				out = append(out, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		out = append(out, cur.String())
+	}
+	return out, nil
+}
@@ -0,0 +1,164 @@
+package filter
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net"
+)
+
+// ipKey 128 位地址，IPv4 按 IPv4-mapped（::ffff:a.b.c.d）存放
+type ipKey struct {
+	hi, lo uint64
+}
+
+func keyOf(ip net.IP) (ipKey, bool) {
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ipKey{}, false
+	}
+	return ipKey{binary.BigEndian.Uint64(ip16[:8]), binary.BigEndian.Uint64(ip16[8:])}, true
+}
+
+// family 与 net.IPNet.Contains 一致：IPv4 地址只匹配 IPv4 网段
+func family(ip net.IP) int {
+	if ip.To4() != nil {
+		return 0
+	}
+	return 1
+}
+
+// prefixOf CIDR 的网络地址和前缀长度（IPv4 前缀加 96）
+func prefixOf(n *net.IPNet) (ipKey, int, bool) {
+	ones, size := n.Mask.Size()
+	if size == 32 {
+		ones += 96
+	}
+	k, ok := keyOf(n.IP)
+	if !ok || (size != 32 && size != 128) {
+		return ipKey{}, 0, false
+	}
+	return k.mask(ones), ones, true
+}
+
+// bit 第 i 位（从最高位开始）
+func (k ipKey) bit(i int) int {
+	if i < 64 {
+		return int(k.hi>>(63-i)) & 1
+	}
+	return int(k.lo>>(127-i)) & 1
+}
+
+func (k ipKey) mask(n int) ipKey {
+	switch {
+	case n <= 0:
+		return ipKey{}
+	case n < 64:
+		return ipKey{hi: k.hi &^ (^uint64(0) >> n)}
+	case n < 128:
+		return ipKey{hi: k.hi, lo: k.lo &^ (^uint64(0) >> (n - 64))}
+	}
+	return k
+}
+
+// commonBits 两个地址相同的前缀位数
+func (k ipKey) commonBits(o ipKey) int {
+	if x := k.hi ^ o.hi; x != 0 {
+		return bits.LeadingZeros64(x)
+	}
+	return 64 + bits.LeadingZeros64(k.lo^o.lo)
+}
+
+// cidrTrie 压缩前缀树（radix trie），每个节点是一个前缀，
+// 查询时沿路径访问所有包含该地址的前缀，复杂度与规则数量无关
+type cidrTrie struct {
+	roots [2]*trieNode // IPv4 / IPv6
+}
+
+type trieNode struct {
+	key   ipKey
+	bits  int
+	child [2]*trieNode
+	// 以该前缀结尾的 CIDR 对应的值；为空的节点只是分叉点
+	vals []int32
+}
+
+func (t *cidrTrie) insert(n *net.IPNet, v int32) bool {
+	key, plen, ok := prefixOf(n)
+	if !ok {
+		return false
+	}
+	p := &t.roots[family(n.IP)]
+	for {
+		node := *p
+		if node == nil {
+			*p = &trieNode{key: key, bits: plen, vals: []int32{v}}
+			return true
+		}
+
+		common := min(key.commonBits(node.key), plen, node.bits)
+		if common == node.bits {
+			if plen == node.bits {
+				node.vals = append(node.vals, v)
+				return true
+			}
+			p = &node.child[key.bit(node.bits)]
+			continue
+		}
+
+		// 在分歧位置插入分叉节点
+		split := &trieNode{key: key.mask(common), bits: common}
+		split.child[node.key.bit(common)] = node
+		if plen == common {
+			split.vals = []int32{v}
+		} else {
+			split.child[key.bit(common)] = &trieNode{key: key, bits: plen, vals: []int32{v}}
+		}
+		*p = split
+		return true
+	}
+}
+
+// lookup 按前缀从短到长访问包含 ip 的 CIDR 的值，fn 返回 false 时停止
+func (t *cidrTrie) lookup(ip net.IP, fn func(vals []int32) bool) {
+	key, ok := keyOf(ip)
+	if !ok {
+		return
+	}
+	for node := t.roots[family(ip)]; node != nil; {
+		if key.commonBits(node.key) < node.bits {
+			return
+		}
+		if len(node.vals) > 0 && !fn(node.vals) {
+			return
+		}
+		if node.bits == 128 {
+			return
+		}
+		node = node.child[key.bit(node.bits)]
+	}
+}
+
+// cidrSet 一组 CIDR，用于单条规则的地址条件
+type cidrSet struct {
+	trie cidrTrie
+}
+
+func newCIDRSet(nets []*net.IPNet) *cidrSet {
+	if len(nets) == 0 {
+		return nil
+	}
+	s := &cidrSet{}
+	for _, n := range nets {
+		s.trie.insert(n, 0)
+	}
+	return s
+}
+
+func (s *cidrSet) contains(ip net.IP) bool {
+	found := false
+	s.trie.lookup(ip, func([]int32) bool {
+		found = true
+		return false
+	})
+	return found
+}
@@ -1,14 +1,21 @@
 package filter
 
 import (
+	"fmt"
 	"net"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/modules/redirect"
 	"proxy-system-backend/internal/traffic"
+	"slices"
+	"strings"
 )
 
 type CompiledRule struct {
 	ID       int64
+	Name     string
 	Action   Action
 	Priority int
+	Set      string
 
 	Direction traffic.Direction
 
@@ -16,9 +23,35 @@ type CompiledRule struct {
 	DstIPNets []*net.IPNet
 	SrcPorts  []PortRange
 	DstPorts  []PortRange
+
+	// 目标地址的国家代码 / ASN
+	Geo []string
+	ASN []uint32
+
+	Mirror   *mirror.Config
+	Redirect *redirect.Target
+
+	// 地址、端口条件的编译形式（前缀树 / 端口位图），由 CompileRule 生成
+	srcIPs, dstIPs     *cidrSet
+	srcPorts, dstPorts *portSet
+
+	payload []payloadMatcher
+	decoded []*Predicate
+	expr    *Expr
+
+	// 限定方向或匹配负载的规则逐包匹配，其余规则每个连接只匹配一次
+	perPacket bool
+
+	// 生效时间，为空时总是生效
+	schedule *schedule
 }
 
 func (r *CompiledRule) Match(ctx *traffic.PacketContext) bool {
+	return r.match(ctx, nil)
+}
+
+// match scan 为空时单独扫描负载
+func (r *CompiledRule) match(ctx *traffic.PacketContext, scan *payloadScan) bool {
 
 	// 1️⃣ 方向匹配
 	if r.Direction != traffic.DirectionUnknown &&
@@ -27,35 +60,72 @@ func (r *CompiledRule) Match(ctx *traffic.PacketContext) bool {
 	}
 
 	// 2️⃣ 源 IP
-	if len(r.SrcIPNets) > 0 {
-		if ctx.SrcIP == nil || !matchIPNet(ctx.SrcIP, r.SrcIPNets) {
-			return false
-		}
+	if !r.matchSrcIP(ctx.SrcIP) {
+		return false
 	}
 
 	// 3️⃣ 目标 IP
-	if len(r.DstIPNets) > 0 {
-		if ctx.DstIP == nil || !matchIPNet(ctx.DstIP, r.DstIPNets) {
-			return false
-		}
+	if !r.matchDstIP(ctx.DstIP) {
+		return false
 	}
 
 	// 4️⃣ 源端口
-	if len(r.SrcPorts) > 0 {
-		if ctx.SrcPort == 0 || !matchPort(ctx.SrcPort, r.SrcPorts) {
-			return false
-		}
+	if !r.matchSrcPort(ctx.SrcPort) {
+		return false
 	}
 
 	// 5️⃣ 目标端口
-	if len(r.DstPorts) > 0 {
-		if ctx.DstPort == 0 || !matchPort(ctx.DstPort, r.DstPorts) {
+	if !r.matchDstPort(ctx.DstPort) {
+		return false
+	}
+
+	// 6️⃣ 目标国家 / ASN
+	if !r.matchGeo(ctx.DstGeo) || !r.matchASN(ctx.DstGeo) {
+		return false
+	}
+
+	if scan == nil && (len(r.payload) > 0 || r.expr != nil) {
+		scan = newPayloadScan(ctx.Payload, nil)
+	}
+
+	// 7️⃣ 负载
+	for _, m := range r.payload {
+		if !m.match(scan) {
 			return false
 		}
 	}
 
+	// 8️⃣ 表达式
+	if r.expr != nil && !r.expr.fn(ctx, scan) {
+		return false
+	}
+
 	return true
 }
+func (r *CompiledRule) matchSrcIP(ip net.IP) bool {
+	return r.srcIPs == nil || (ip != nil && r.srcIPs.contains(ip))
+}
+
+func (r *CompiledRule) matchDstIP(ip net.IP) bool {
+	return r.dstIPs == nil || (ip != nil && r.dstIPs.contains(ip))
+}
+
+func (r *CompiledRule) matchSrcPort(port int) bool {
+	return r.srcPorts == nil || (port != 0 && r.srcPorts.contains(port))
+}
+
+func (r *CompiledRule) matchDstPort(port int) bool {
+	return r.dstPorts == nil || (port != 0 && r.dstPorts.contains(port))
+}
+
+func (r *CompiledRule) matchGeo(geo *traffic.GeoInfo) bool {
+	return len(r.Geo) == 0 || (geo != nil && slices.Contains(r.Geo, geo.Country))
+}
+
+func (r *CompiledRule) matchASN(geo *traffic.GeoInfo) bool {
+	return len(r.ASN) == 0 || (geo != nil && slices.Contains(r.ASN, geo.ASN))
+}
+
 func matchIP(addr net.Addr, nets []*net.IPNet) bool {
 	tcp, ok := addr.(*net.TCPAddr)
 	if !ok {
@@ -82,11 +152,87 @@ func matchPort(port int, ranges []PortRange) bool {
 func CompileRule(r Rule) (*CompiledRule, error) {
 	cr := &CompiledRule{
 		ID:        r.ID,
+		Name:      r.Name,
 		Action:    r.Action,
 		Priority:  r.Priority,
+		Set:       r.Set,
 		Direction: r.Direction,
 		SrcPorts:  r.SrcPort,
 		DstPorts:  r.DstPort,
+		Geo:       r.Geo,
+		ASN:       r.ASN,
+		perPacket: r.Direction != traffic.DirectionUnknown || len(r.Payload) > 0 || len(r.Decoded) > 0,
+	}
+
+	if err := r.Action.Validate(); err != nil {
+		return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+	}
+	if r.Set != "" {
+		if err := ValidateSetName(r.Set); err != nil {
+			return nil, fmt.Errorf("rule %d: rule set %w", r.ID, err)
+		}
+	}
+
+	if r.Action == ActionMirror {
+		if r.Mirror == nil {
+			return nil, fmt.Errorf("rule %d: mirror action requires a mirror target", r.ID)
+		}
+		m := *r.Mirror
+		if err := m.Validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+		}
+		cr.Mirror = &m
+	}
+	if r.Action == ActionRedirect {
+		if r.Redirect == nil {
+			return nil, fmt.Errorf("rule %d: redirect action requires a redirect target", r.ID)
+		}
+		t := *r.Redirect
+		if err := t.Validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+		}
+		cr.Redirect = &t
+	}
+
+	for i, pm := range r.Payload {
+		m, err := pm.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: payload[%d]: %w", r.ID, i, err)
+		}
+		cr.payload = append(cr.payload, m)
+	}
+
+	for i, expr := range r.Decoded {
+		p, err := ParsePredicate(expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: decoded[%d]: %w", r.ID, i, err)
+		}
+		cr.decoded = append(cr.decoded, p)
+	}
+	if len(cr.decoded) > 0 {
+		if err := checkDecodedAction(r.Action); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+		}
+	}
+
+	sched, err := compileSchedule(r)
+	if err != nil {
+		return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+	}
+	cr.schedule = sched
+
+	if strings.TrimSpace(r.Expr) != "" {
+		e, err := CompileExpr(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: expr %w", r.ID, err)
+		}
+		cr.expr = e
+		cr.perPacket = cr.perPacket || e.PerPacket()
+	}
+	if cr.perPacket {
+		if err := checkPacketAction(r.Action); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+		}
 	}
 
 	for _, cidr := range r.SrcCIDR {
@@ -105,5 +251,37 @@ func CompileRule(r Rule) (*CompiledRule, error) {
 		cr.DstIPNets = append(cr.DstIPNets, n)
 	}
 
+	cr.srcIPs = newCIDRSet(cr.SrcIPNets)
+	cr.dstIPs = newCIDRSet(cr.DstIPNets)
+	cr.srcPorts = newPortSet(cr.SrcPorts)
+	cr.dstPorts = newPortSet(cr.DstPorts)
+
 	return cr, nil
 }
+
+// checkDecodedAction 解码后才匹配的规则不能再影响解码本身和连接级功能
+func checkDecodedAction(a Action) error {
+	switch a.Kind() {
+	case ActionMITM, ActionMirror, ActionSkipDecode, ActionDecode, ActionRedirect:
+		return fmt.Errorf("action %q cannot be used with decoded conditions", a.Kind())
+	}
+	return nil
+}
+
+// checkPacketAction 重定向在连接目标之前决定，不能使用方向、负载等逐包条件
+func checkPacketAction(a Action) error {
+	if a.Kind() == ActionRedirect {
+		return fmt.Errorf("action %q cannot be used with direction, payload or per-packet expr conditions", a.Kind())
+	}
+	return nil
+}
+
+// matchDecoded 解码结果条件全部满足
+func (r *CompiledRule) matchDecoded(doc any) bool {
+	for _, p := range r.decoded {
+		if !p.Eval(doc) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/modules/redirect"
+	"slices"
+	"strconv"
+)
+
+// Decision 规则匹配结果：一个终结动作加上沿途命中的附加动作
+type Decision struct {
+	// allow / deny / reset；逐包匹配未命中终结动作时为空
+	Verdict Action `json:"verdict,omitempty"`
+	// 决定 Verdict 的规则，0 表示默认动作（Default）或 block_ips / block_ports
+	RuleID  int64 `json:"rule_id,omitempty"`
+	Default bool  `json:"default,omitempty"`
+	// 按匹配顺序命中的所有规则
+	Rules []int64 `json:"rules,omitempty"`
+
+	Log        bool           `json:"log,omitempty"`
+	Capture    bool           `json:"capture,omitempty"`
+	MITM       bool           `json:"mitm,omitempty"`
+	SkipDecode bool           `json:"skip_decode,omitempty"`
+	Decode     string         `json:"decode,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	Mirror     *mirror.Config `json:"mirror,omitempty"`
+	// 连接目标之前求值时有效（见 action=redirect）
+	Redirect *redirect.Target `json:"redirect,omitempty"`
+	// 只针对本阶段（连接 / 数据包 / 解码结果），不随 Override 合并
+	Alert bool `json:"alert,omitempty"`
+	// 对当前数据包触发的改包规则
+	Rewrite []int64 `json:"rewrite,omitempty"`
+}
+
+// apply 记录命中的规则，返回是否终结
+func (d *Decision) apply(r *CompiledRule) bool {
+	d.Rules = append(d.Rules, r.ID)
+
+	switch r.Action.Kind() {
+	case ActionAllow, ActionDeny, ActionReset:
+		d.Verdict = r.Action
+		d.RuleID = r.ID
+		return true
+	case ActionLog:
+		d.Log = true
+	case ActionCapture:
+		d.Capture = true
+	case ActionMITM:
+		d.MITM = true
+	case ActionSkipDecode:
+		d.SkipDecode = true
+	case ActionDecode:
+		// 优先级高的规则先命中，先到先得
+		if d.Decode == "" {
+			d.Decode = r.Action.Arg()
+		}
+	case ActionTag:
+		if tag := r.Action.Arg(); !slices.Contains(d.Tags, tag) {
+			d.Tags = append(d.Tags, tag)
+		}
+	case ActionMirror:
+		if d.Mirror == nil {
+			d.Mirror = r.Mirror
+		}
+	case ActionRedirect:
+		if d.Redirect == nil {
+			d.Redirect = r.Redirect
+		}
+	case ActionAlert:
+		d.Alert = true
+	case ActionRewrite:
+		id, _ := strconv.ParseInt(r.Action.Arg(), 10, 64)
+		if !slices.Contains(d.Rewrite, id) {
+			d.Rewrite = append(d.Rewrite, id)
+		}
+	}
+	return false
+}
+
+// Override 用逐包规则的结果覆盖连接级结果（只影响当前数据包）
+func (d Decision) Override(p Decision) Decision {
+	if len(p.Rules) == 0 {
+		return d
+	}
+	out := d
+	out.Rules = append(slices.Clone(d.Rules), p.Rules...)
+	if p.Verdict != "" {
+		out.Verdict = p.Verdict
+		out.RuleID = p.RuleID
+		out.Default = false
+	}
+	out.Log = d.Log || p.Log
+	if p.Decode != "" {
+		out.Decode = p.Decode
+		out.SkipDecode = false
+	}
+	if p.SkipDecode {
+		out.SkipDecode = true
+	}
+	out.Tags = slices.Clone(d.Tags)
+	for _, t := range p.Tags {
+		if !slices.Contains(out.Tags, t) {
+			out.Tags = append(out.Tags, t)
+		}
+	}
+	out.Rewrite = slices.Clone(d.Rewrite)
+	for _, id := range p.Rewrite {
+		if !slices.Contains(out.Rewrite, id) {
+			out.Rewrite = append(out.Rewrite, id)
+		}
+	}
+	return out
+}
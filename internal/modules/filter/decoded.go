@@ -0,0 +1,173 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/jsonpath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// 解码结果条件的比较运算符（长的在前，便于最长匹配）
+var predicateOps = []string{"==", "!=", ">=", "<=", "=~", "!~", ">", "<"}
+
+// Predicate 解码结果（插件 Decode 输出的 JSON）上的条件：
+//
+//	msg.type == "Login"
+//	player.gold > 1000000
+//	player.name =~ "^GM_"
+//	msg.session          （只写路径表示字段存在）
+//
+// 右侧为 JSON 值；=~ / !~ 的右侧为正则字符串
+type Predicate struct {
+	Path  jsonpath.Path
+	Op    string
+	Value any
+
+	re *regexp.Regexp
+}
+
+// ParsePredicate 解析 "path op value"
+func ParsePredicate(s string) (*Predicate, error) {
+	s = strings.TrimSpace(s)
+	end := pathEnd(s)
+	if end == 0 {
+		return nil, fmt.Errorf("missing path in %q", s)
+	}
+	path, err := jsonpath.Parse(s[:end])
+	if err != nil {
+		return nil, err
+	}
+	p := &Predicate{Path: path}
+
+	rest := strings.TrimSpace(s[end:])
+	if rest == "" {
+		return p, nil
+	}
+	for _, op := range predicateOps {
+		if strings.HasPrefix(rest, op) {
+			p.Op = op
+			break
+		}
+	}
+	if p.Op == "" {
+		return nil, fmt.Errorf("unknown operator at %q", rest)
+	}
+
+	raw := strings.TrimSpace(rest[len(p.Op):])
+	if raw == "" {
+		return nil, fmt.Errorf("missing value after %s", p.Op)
+	}
+	if err := json.Unmarshal([]byte(raw), &p.Value); err != nil {
+		return nil, fmt.Errorf("invalid value %s: must be JSON (quote strings)", raw)
+	}
+
+	switch p.Op {
+	case "=~", "!~":
+		pattern, ok := p.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a regex string", p.Op)
+		}
+		if p.re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+	case ">", ">=", "<", "<=":
+		switch p.Value.(type) {
+		case float64, string:
+		default:
+			return nil, fmt.Errorf("%s requires a number or string", p.Op)
+		}
+	}
+	return p, nil
+}
+
+// pathEnd 路径在第一个空白或运算符处结束（[...] 内除外）
+func pathEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '[':
+			j := strings.IndexByte(s[i:], ']')
+			if j < 0 {
+				return len(s)
+			}
+			i += j
+		case ' ', '\t', '=', '!', '<', '>':
+			return i
+		}
+	}
+	return len(s)
+}
+
+func (p *Predicate) String() string {
+	if p.Op == "" {
+		return p.Path.String()
+	}
+	v, _ := json.Marshal(p.Value)
+	return fmt.Sprintf("%s %s %s", p.Path, p.Op, v)
+}
+
+// Eval 在 json.Unmarshal 得到的文档上求值
+func (p *Predicate) Eval(doc any) bool {
+	v, ok := p.Path.Get(doc)
+	switch p.Op {
+	case "":
+		return ok
+	case "==":
+		return ok && reflect.DeepEqual(v, p.Value)
+	case "!=":
+		return !ok || !reflect.DeepEqual(v, p.Value)
+	case "=~":
+		return ok && p.re.MatchString(scalarString(v))
+	case "!~":
+		return !ok || !p.re.MatchString(scalarString(v))
+	}
+	if !ok {
+		return false
+	}
+
+	var c int
+	switch want := p.Value.(type) {
+	case float64:
+		got, isNum := v.(float64)
+		if !isNum {
+			return false
+		}
+		c = compare(got, want)
+	case string:
+		got, isStr := v.(string)
+		if !isStr {
+			return false
+		}
+		c = strings.Compare(got, want)
+	}
+	switch p.Op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+func compare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// scalarString 正则匹配时数字、布尔值按 JSON 文本匹配
+func scalarString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,82 @@
+package filter
+
+import (
+	"encoding/json"
+	"proxy-system-backend/internal/traffic"
+	"slices"
+	"testing"
+)
+
+func TestPredicateEval(t *testing.T) {
+	var doc any
+	_ = json.Unmarshal([]byte(`{"msg":{"type":"Login","seq":3},"player":{"name":"GM_bob","gold":2000000,"items":[{"id":7}]}}`), &doc)
+
+	cases := map[string]bool{
+		`msg.type == "Login"`:        true,
+		`msg.type=="Logout"`:         false,
+		`msg.type != "Logout"`:       true,
+		`player.gold > 1000000`:      true,
+		`player.gold <= 1000000`:     false,
+		`player.name =~ "^GM_"`:      true,
+		`player.name !~ "^GM_"`:      false,
+		`msg.seq =~ "^[0-9]$"`:       true,
+		`player.items[0].id == 7`:    true,
+		`player.items[1]`:            false,
+		`msg.session`:                false,
+		`msg.session != "x"`:         true,
+		`msg.type > "A"`:             true,
+		`player.name > 10`:           false, // 类型不同
+		`$.msg["type"] == "Login"`:   true,
+		`player.gold >= 2000000.0`:   true,
+		`player.items == [{"id":7}]`: true,
+	}
+	for expr, want := range cases {
+		p, err := ParsePredicate(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got := p.Eval(doc); got != want {
+			t.Errorf("%s = %v, want %v", expr, got, want)
+		}
+	}
+
+	for _, bad := range []string{``, `== 1`, `a.b = 1`, `a.b == Login`, `a.b =~ 1`, `a.b =~ "("`, `a.b > true`, `a.b ==`} {
+		if _, err := ParsePredicate(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestEvaluateDecoded(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 30, Action: ActionAlert, Decoded: []string{`msg.type == "Login"`}},
+		Rule{ID: 2, Priority: 20, Action: "rewrite:5", Decoded: []string{`player.gold > 1000000`}, Direction: traffic.DirectionIn},
+		Rule{ID: 3, Priority: 10, Action: ActionDeny, Decoded: []string{`player.gold > 1000000`}},
+		Rule{ID: 4, Priority: 5, Action: "tag:never"},
+	)
+	data := json.RawMessage(`{"msg":{"type":"Login"},"player":{"gold":5000000}}`)
+
+	d := e.EvaluateDecoded(ctxFor(traffic.DirectionOut), data)
+	if !d.Alert || d.Verdict != ActionDeny || !slices.Equal(d.Rules, []int64{1, 3}) || len(d.Rewrite) != 0 {
+		t.Fatalf("out decision = %+v", d)
+	}
+	d = e.EvaluateDecoded(ctxFor(traffic.DirectionIn), data)
+	if !slices.Equal(d.Rewrite, []int64{5}) || !slices.Equal(d.Rules, []int64{1, 2, 3}) {
+		t.Fatalf("in decision = %+v", d)
+	}
+
+	// 解码结果规则不参与逐包匹配
+	if p := e.EvaluatePacket(ctxFor(traffic.DirectionOut)); len(p.Rules) != 0 {
+		t.Fatalf("packet decision = %+v", p)
+	}
+	if d := e.EvaluateDecoded(ctxFor(traffic.DirectionOut), json.RawMessage(`not json`)); len(d.Rules) != 0 {
+		t.Fatalf("invalid json decision = %+v", d)
+	}
+
+	if _, err := CompileRule(Rule{ID: 9, Action: "decode:x", Decoded: []string{`a`}}); err == nil {
+		t.Fatal("decode action with decoded conditions should be rejected")
+	}
+	if err := Action("rewrite:abc").Validate(); err == nil {
+		t.Fatal("rewrite without numeric id should be rejected")
+	}
+}
@@ -1,21 +1,398 @@
 package filter
 
+import (
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/modules/redirect"
+	"proxy-system-backend/internal/traffic"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // internal/modules/filter/dto.go
 type RuleDTO struct {
-	ID        int64  `json:"id"`
-	Name      string `json:"name"`
-	Priority  int    `json:"priority"`
-	Action    string `json:"action"` // allow | deny
-	Direction string `json:"direction,omitempty"`
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Priority    int    `json:"priority"`
+	Action      string `json:"action"` // allow | deny | reset | log | alert | capture | mitm | mirror | redirect | skip_decode | decode:<plugin> | tag:<label> | rewrite:<id>
+	Direction   string `json:"direction,omitempty"`
 
+	// 逗号分隔：IP / CIDR，端口 / 端口范围（"80,8000-9000"）
 	SrcIP   string `json:"src_ip,omitempty"`
 	DstIP   string `json:"dst_ip,omitempty"`
 	SrcPort string `json:"src_port,omitempty"`
 	DstPort string `json:"dst_port,omitempty"`
 
+	// 逗号分隔：目标地址的国家代码（"CN,JP"）/ ASN（"13335,AS15169"），需要 GeoIP 数据库
+	Geo string `json:"geo,omitempty"`
+	ASN string `json:"asn,omitempty"`
+
+	// 负载匹配条件，需全部满足
+	Payload []PayloadMatch `json:"payload,omitempty"`
+	// 解码结果条件，需全部满足，如 `msg.type == "Login"`
+	Decoded []string `json:"decoded,omitempty"`
+	// 过滤表达式，与上面的条件同时满足，如 `dst.domain ~ "*.game.com" && port in 7000..7100`
+	Expr string `json:"expr,omitempty"`
+
 	Tags []string `json:"tags,omitempty"`
 
+	// 所属规则集，空为默认规则集（对所有连接生效）
+	RuleSet string `json:"rule_set,omitempty"`
+
+	// action=mirror 时的镜像目标
+	Mirror *mirror.Config `json:"mirror,omitempty"`
+	// action=redirect 时的新目标
+	Redirect *redirect.Target `json:"redirect,omitempty"`
+
+	// 生效时间（Unix 秒），0 表示不限
+	StartAt int64 `json:"start_at,omitempty"`
+	EndAt   int64 `json:"end_at,omitempty"`
+	// 周期生效窗口，如 "0 9 * * 1-5 8h"（见 Window）
+	Windows []string `json:"windows,omitempty"`
+	// 创建后的有效期，如 "30m"，精确到秒
+	TTL string `json:"ttl,omitempty"`
+	// 只读：TTL 到期时间（Unix 秒）、是否已过期
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	Expired   bool  `json:"expired,omitempty"`
+
 	Enabled   bool  `json:"enabled"`
 	CreatedAt int64 `json:"created_at"`
 	UpdatedAt int64 `json:"updated_at"`
 }
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError 规则校验失败，逐字段列出原因
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return "invalid rule: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(field string, err error) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: err.Error()})
+}
+
+// Prefix 给字段名加前缀（批量导入时标明是第几条规则）
+func (e *ValidationError) Prefix(p string) *ValidationError {
+	out := &ValidationError{Fields: make([]FieldError, len(e.Fields))}
+	for i, f := range e.Fields {
+		out.Fields[i] = FieldError{Field: p + "." + f.Field, Message: f.Message}
+	}
+	return out
+}
+
+// ToRule 校验并转换为规则，失败时返回 *ValidationError
+func (d RuleDTO) ToRule() (Rule, error) {
+	verr := &ValidationError{}
+	r := Rule{
+		ID:          d.ID,
+		Name:        strings.TrimSpace(d.Name),
+		Description: d.Description,
+		Action:      Action(strings.TrimSpace(d.Action)),
+		Priority:    d.Priority,
+		Enabled:     d.Enabled,
+		Payload:     d.Payload,
+		Decoded:     d.Decoded,
+		Expr:        strings.TrimSpace(d.Expr),
+		Tags:        d.Tags,
+		Set:         strings.TrimSpace(d.RuleSet),
+		Mirror:      d.Mirror,
+		Redirect:    d.Redirect,
+	}
+
+	if r.Name == "" {
+		verr.add("name", fmt.Errorf("is required"))
+	}
+	if err := r.Action.Validate(); err != nil {
+		verr.add("action", err)
+	}
+	if r.Set != "" {
+		if err := ValidateSetName(r.Set); err != nil {
+			verr.add("rule_set", err)
+		}
+	}
+	if r.Action == ActionMirror {
+		if d.Mirror == nil {
+			verr.add("mirror", fmt.Errorf("is required for action mirror"))
+		} else {
+			m := *d.Mirror
+			if err := m.Validate(); err != nil {
+				verr.add("mirror", err)
+			}
+		}
+	}
+	if r.Action == ActionRedirect {
+		if d.Redirect == nil {
+			verr.add("redirect", fmt.Errorf("is required for action redirect"))
+		} else {
+			t := *d.Redirect
+			if err := t.Validate(); err != nil {
+				verr.add("redirect", err)
+			}
+		}
+	}
+
+	dir, err := traffic.ParseDirection(d.Direction)
+	if err != nil {
+		verr.add("direction", err)
+	}
+	r.Direction = dir
+
+	if r.SrcCIDR, err = ParseCIDRList(d.SrcIP); err != nil {
+		verr.add("src_ip", err)
+	}
+	if r.DstCIDR, err = ParseCIDRList(d.DstIP); err != nil {
+		verr.add("dst_ip", err)
+	}
+	if r.SrcPort, err = ParsePortList(d.SrcPort); err != nil {
+		verr.add("src_port", err)
+	}
+	if r.DstPort, err = ParsePortList(d.DstPort); err != nil {
+		verr.add("dst_port", err)
+	}
+	if r.Geo, err = ParseCountryList(d.Geo); err != nil {
+		verr.add("geo", err)
+	}
+	if r.ASN, err = ParseASNList(d.ASN); err != nil {
+		verr.add("asn", err)
+	}
+	for i, m := range d.Payload {
+		if err := m.Validate(); err != nil {
+			verr.add(fmt.Sprintf("payload[%d]", i), err)
+		}
+	}
+	for i, expr := range d.Decoded {
+		if _, err := ParsePredicate(expr); err != nil {
+			verr.add(fmt.Sprintf("decoded[%d]", i), err)
+		}
+	}
+	if len(d.Decoded) > 0 {
+		if err := checkDecodedAction(r.Action); err != nil {
+			verr.add("action", err)
+		}
+	}
+	perPacket := r.Direction != traffic.DirectionUnknown || len(d.Payload) > 0 || len(d.Decoded) > 0
+	if r.Expr != "" {
+		if e, err := CompileExpr(r.Expr); err != nil {
+			verr.add("expr", err)
+		} else if e.PerPacket() {
+			perPacket = true
+		}
+	}
+	if perPacket {
+		if err := checkPacketAction(r.Action); err != nil {
+			verr.add("action", err)
+		}
+	}
+
+	if d.StartAt > 0 {
+		r.StartAt = time.Unix(d.StartAt, 0)
+	}
+	if d.EndAt > 0 {
+		r.EndAt = time.Unix(d.EndAt, 0)
+		if d.StartAt > 0 && d.EndAt <= d.StartAt {
+			verr.add("end_at", fmt.Errorf("must be after start_at"))
+		}
+	}
+	for i, w := range d.Windows {
+		if _, err := ParseWindow(w); err != nil {
+			verr.add(fmt.Sprintf("windows[%d]", i), err)
+		}
+	}
+	r.Windows = d.Windows
+	if d.TTL != "" {
+		// 存储按秒保存，不足一秒或带小数秒的值无法原样保存
+		if r.TTL, err = time.ParseDuration(d.TTL); err != nil || r.TTL <= 0 {
+			verr.add("ttl", fmt.Errorf("invalid duration %q", d.TTL))
+		} else if r.TTL%time.Second != 0 {
+			verr.add("ttl", fmt.Errorf("%q: must be a whole number of seconds", d.TTL))
+		}
+	}
+
+	if len(verr.Fields) > 0 {
+		return Rule{}, verr
+	}
+	return r, nil
+}
+
+// RuleToDTO 规则转换为接口格式
+func RuleToDTO(r Rule, createdAt, updatedAt time.Time) RuleDTO {
+	d := RuleDTO{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Priority:    r.Priority,
+		Action:      string(r.Action),
+		SrcIP:       strings.Join(r.SrcCIDR, ","),
+		DstIP:       strings.Join(r.DstCIDR, ","),
+		SrcPort:     formatPorts(r.SrcPort),
+		DstPort:     formatPorts(r.DstPort),
+		Geo:         strings.Join(r.Geo, ","),
+		ASN:         formatASNs(r.ASN),
+		Payload:     r.Payload,
+		Decoded:     r.Decoded,
+		Expr:        r.Expr,
+		Tags:        r.Tags,
+		RuleSet:     r.Set,
+		Mirror:      r.Mirror,
+		Redirect:    r.Redirect,
+		Windows:     r.Windows,
+		Expired:     r.Expired,
+		Enabled:     r.Enabled,
+	}
+	if r.Direction != traffic.DirectionUnknown {
+		d.Direction = r.Direction.String()
+	}
+	if !r.StartAt.IsZero() {
+		d.StartAt = r.StartAt.Unix()
+	}
+	if !r.EndAt.IsZero() {
+		d.EndAt = r.EndAt.Unix()
+	}
+	if r.TTL > 0 {
+		d.TTL = r.TTL.String()
+	}
+	if !r.ExpiresAt.IsZero() {
+		d.ExpiresAt = r.ExpiresAt.Unix()
+	}
+	if !createdAt.IsZero() {
+		d.CreatedAt = createdAt.Unix()
+	}
+	if !updatedAt.IsZero() {
+		d.UpdatedAt = updatedAt.Unix()
+	}
+	return d
+}
+
+// ParseCIDRList 解析逗号分隔的 IP / CIDR，单个 IP 转换为 /32 或 /128
+func ParseCIDRList(s string) ([]string, error) {
+	var out []string
+	for _, item := range splitList(s) {
+		if strings.Contains(item, "/") {
+			_, n, err := net.ParseCIDR(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cidr %q", item)
+			}
+			out = append(out, n.String())
+			continue
+		}
+		ip := net.ParseIP(item)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %q", item)
+		}
+		if ip.To4() != nil {
+			out = append(out, ip.String()+"/32")
+		} else {
+			out = append(out, ip.String()+"/128")
+		}
+	}
+	return out, nil
+}
+
+// ParsePortList 解析逗号分隔的端口 / 端口范围
+func ParsePortList(s string) ([]PortRange, error) {
+	var out []PortRange
+	for _, item := range splitList(s) {
+		lo, hi, isRange := strings.Cut(item, "-")
+		from, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+		}
+		if from > to {
+			return nil, fmt.Errorf("invalid port range %q", item)
+		}
+		out = append(out, PortRange{Min: from, Max: to})
+	}
+	return out, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
+
+// ParseCountryList 解析逗号分隔的两位国家代码，转换为大写
+func ParseCountryList(s string) ([]string, error) {
+	var out []string
+	for _, item := range splitList(s) {
+		if len(item) != 2 || !isASCIILetter(item[0]) || !isASCIILetter(item[1]) {
+			return nil, fmt.Errorf("invalid country code %q, expected ISO 3166-1 alpha-2 such as CN", item)
+		}
+		out = append(out, strings.ToUpper(item))
+	}
+	return out, nil
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ParseASNList 解析逗号分隔的 ASN，可带 AS 前缀
+func ParseASNList(s string) ([]uint32, error) {
+	var out []uint32
+	for _, item := range splitList(s) {
+		num := item
+		if len(num) > 2 && strings.EqualFold(num[:2], "as") {
+			num = num[2:]
+		}
+		n, err := strconv.ParseUint(num, 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid asn %q", item)
+		}
+		out = append(out, uint32(n))
+	}
+	return out, nil
+}
+
+func formatASNs(asns []uint32) string {
+	parts := make([]string, 0, len(asns))
+	for _, n := range asns {
+		parts = append(parts, strconv.FormatUint(uint64(n), 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatPorts(ports []PortRange) string {
+	parts := make([]string, 0, len(ports))
+	for _, p := range ports {
+		if p.Min == p.Max {
+			parts = append(parts, strconv.Itoa(p.Min))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", p.Min, p.Max))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package filter
+
+import (
+	"errors"
+	"proxy-system-backend/internal/modules/redirect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRuleDTOValidation(t *testing.T) {
+	_, err := RuleDTO{Action: "decode", DstIP: "10.0.0.0/33", SrcPort: "90-80", Direction: "up", Geo: "CHN", ASN: "AS-1"}.ToRule()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v", err)
+	}
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field)
+	}
+	want := []string{"name", "action", "direction", "dst_ip", "src_port", "geo", "asn"}
+	if !slices.Equal(fields, want) {
+		t.Fatalf("fields = %v, want %v", fields, want)
+	}
+	if p := verr.Prefix("rules[2]"); p.Fields[0].Field != "rules[2].name" {
+		t.Fatalf("prefixed = %v", p.Fields)
+	}
+}
+
+func TestRuleDTORedirect(t *testing.T) {
+	_, err := RuleDTO{Name: "dev", Action: "redirect", Direction: "out"}.ToRule()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 || verr.Fields[0].Field != "redirect" || verr.Fields[1].Field != "action" {
+		t.Fatalf("err = %v", err)
+	}
+
+	r, err := RuleDTO{Name: "dev", Action: "redirect", DstPort: "7000", Redirect: &redirect.Target{To: "127.0.0.1:7001", RewriteHost: true}, Enabled: true}.ToRule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := RuleToDTO(r, time.Time{}, time.Time{}); out.Redirect == nil || *out.Redirect != *r.Redirect {
+		t.Fatalf("dto = %+v", out)
+	}
+}
+
+func TestRuleDTORoundTrip(t *testing.T) {
+	in := RuleDTO{
+		Name:      "game",
+		Action:    "tag:game",
+		Direction: "in",
+		DstIP:     "10.0.0.1, 2001:db8::/32",
+		DstPort:   "443,8000-9000",
+		Geo:       "jp, us",
+		ASN:       "AS13335,15169",
+		Enabled:   true,
+	}
+	r, err := in.ToRule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(r.DstCIDR, []string{"10.0.0.1/32", "2001:db8::/32"}) {
+		t.Fatalf("cidr = %v", r.DstCIDR)
+	}
+	if len(r.DstPort) != 2 || r.DstPort[1] != (PortRange{Min: 8000, Max: 9000}) {
+		t.Fatalf("ports = %v", r.DstPort)
+	}
+
+	out := RuleToDTO(r, time.Unix(100, 0), time.Time{})
+	if out.DstIP != "10.0.0.1/32,2001:db8::/32" || out.DstPort != "443,8000-9000" || out.Direction != "in" ||
+		out.Geo != "JP,US" || out.ASN != "13335,15169" {
+		t.Fatalf("dto = %+v", out)
+	}
+	if out.CreatedAt != 100 || out.UpdatedAt != 0 {
+		t.Fatalf("timestamps = %d %d", out.CreatedAt, out.UpdatedAt)
+	}
+}
@@ -1,29 +1,51 @@
 package filter
 
 import (
+	"encoding/json"
+	"fmt"
 	"proxy-system-backend/internal/traffic"
+	"slices"
 	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Engine struct {
 	enabled       atomic.Bool
-	defaultAction atomic.Value // Action
-	rules         atomic.Value // []*CompiledRule
+	defaultAction atomic.Value              // Action
+	index         atomic.Pointer[ruleIndex] // 按优先级排序的规则及其索引
+	packetRules   atomic.Bool               // 是否存在逐包匹配的规则
+	scanner       atomic.Pointer[payloadScanner]
+	decodedRules  atomic.Bool   // 是否存在解码结果条件的规则
+	generation    atomic.Uint64 // 每次替换索引加一
+
+	// 已由 Tick 报告过的过期规则（replaceMu 保护）
+	expiredSent map[int64]bool
+
+	// 统计：计数器按规则 ID 保存，热更新后同 ID 规则继续累计
+	replaceMu   sync.Mutex
+	counters    atomic.Pointer[counterSet]
+	defaultHits ruleCounter
+	connEvals   atomic.Uint64
+	packetEvals atomic.Uint64
+	statsSince  atomic.Int64
 }
 
 func NewEngine() *Engine {
 	e := &Engine{}
 	e.enabled.Store(false)
 	e.defaultAction.Store(ActionAllow)
-	e.rules.Store([]*CompiledRule{})
+	e.index.Store(newRuleIndex(nil))
+	e.counters.Store(&counterSet{})
+	e.statsSince.Store(time.Now().Unix())
 	return e
 }
 func (e *Engine) Load(cfg Config, rules []Rule) error {
 	var compiled []*CompiledRule
 
 	for _, r := range rules {
-		if !r.Enabled {
+		if !r.Enabled || r.Expired {
 			continue
 		}
 		cr, err := CompileRule(r)
@@ -33,13 +55,9 @@ func (e *Engine) Load(cfg Config, rules []Rule) error {
 		compiled = append(compiled, cr)
 	}
 
-	sort.Slice(compiled, func(i, j int) bool {
-		return compiled[i].Priority > compiled[j].Priority
-	})
-
 	e.enabled.Store(cfg.Enabled)
 	e.defaultAction.Store(cfg.DefaultAction)
-	e.rules.Store(compiled)
+	e.Replace(compiled)
 
 	return nil
 }
@@ -49,15 +67,242 @@ func (e *Engine) Match(ctx *traffic.PacketContext) bool {
 		return true
 	}
 
-	rules := e.rules.Load().([]*CompiledRule)
-	for _, r := range rules {
-		if r.Match(ctx) {
-			return r.Action == ActionAllow
-		}
+	if r := e.MatchRule(ctx); r != nil {
+		return r.Action == ActionAllow
 	}
 
 	return e.defaultAction.Load().(Action) == ActionAllow
 }
+
+// MatchRule 按优先级返回第一个命中的规则，未命中返回 nil
+// 与 Match 不同，不受 enabled 开关影响，供 mitm 等按规则选择的功能使用
+func (e *Engine) MatchRule(ctx *traffic.PacketContext) *CompiledRule {
+	x := e.index.Load()
+	c := x.candidates(ctx, x.every)
+	defer x.release(c)
+
+	for i := c.set.next(0); i >= 0; i = c.set.next(i + 1) {
+		if r := x.rules[i]; r.Match(ctx) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Evaluate 连接级匹配并计入统计（连接数、规则命中），每个连接只应调用一次
+func (e *Engine) Evaluate(ctx *traffic.PacketContext) Decision {
+	d := e.Decide(ctx)
+	e.Count(d)
+	return d
+}
+
+// Decide 连接级匹配，不计入统计：以客户端视角依次匹配不限方向的规则，
+// 收集附加动作直到命中终结动作，未命中时使用默认动作。
+// 用于连接之外的查询（中间人、重定向）和规则变化后的重新求值
+func (e *Engine) Decide(ctx *traffic.PacketContext) Decision {
+	view := ctx.ClientView()
+	x := e.index.Load()
+	c := x.candidates(view, x.conn)
+	defer x.release(c)
+
+	var d Decision
+	for i := c.set.next(0); i >= 0; i = c.set.next(i + 1) {
+		r := x.rules[i]
+		if !r.Match(view) {
+			continue
+		}
+		if d.apply(r) {
+			return d
+		}
+	}
+
+	d.Verdict = e.defaultAction.Load().(Action)
+	if !d.Verdict.Terminal() {
+		d.Verdict = ActionAllow
+	}
+	d.Default = true
+	return d
+}
+
+// EvaluatePacket 逐包匹配限定方向或匹配负载的规则，用于覆盖连接级结果；
+// 未命中终结动作时 Verdict 为空
+func (e *Engine) EvaluatePacket(ctx *traffic.PacketContext) Decision {
+	var d Decision
+	e.packetEvals.Add(1)
+	if !e.packetRules.Load() {
+		return d
+	}
+	x := e.index.Load()
+	c := x.candidates(ctx, x.packet)
+	defer x.release(c)
+
+	scan := newPayloadScan(ctx.Payload, e.scanner.Load())
+	for i := c.set.next(0); i >= 0; i = c.set.next(i + 1) {
+		r := x.rules[i]
+		if !r.match(ctx, scan) {
+			continue
+		}
+		e.count(r)
+		if d.apply(r) {
+			break
+		}
+	}
+	return d
+}
+
+// HasDecodedRules 是否需要对解码结果调用 EvaluateDecoded
+func (e *Engine) HasDecodedRules() bool {
+	return e.decodedRules.Load()
+}
+
+// EvaluateDecoded 插件解码成功后，匹配带解码结果条件的规则（同时检查规则的其他条件）；
+// 未命中终结动作时 Verdict 为空
+func (e *Engine) EvaluateDecoded(ctx *traffic.PacketContext, data json.RawMessage) Decision {
+	var d Decision
+	if !e.decodedRules.Load() {
+		return d
+	}
+
+	var (
+		doc    any
+		parsed bool
+	)
+	x := e.index.Load()
+	c := x.candidates(ctx, x.decoded)
+	defer x.release(c)
+
+	scan := newPayloadScan(ctx.Payload, e.scanner.Load())
+	for i := c.set.next(0); i >= 0; i = c.set.next(i + 1) {
+		r := x.rules[i]
+		if !r.match(ctx, scan) {
+			continue
+		}
+		if !parsed {
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return d
+			}
+			parsed = true
+		}
+		if !r.matchDecoded(doc) {
+			continue
+		}
+		e.count(r)
+		if d.apply(r) {
+			break
+		}
+	}
+	return d
+}
+
+// Replace 替换规则（按优先级从高到低排序），索引在替换前建好，匹配中的数据包继续使用旧索引；
+// 未到生效时间的规则只编译不索引，由 Tick 按时启用
 func (e *Engine) Replace(rules []*CompiledRule) {
-	e.rules.Store(rules)
+	e.replaceMu.Lock()
+	defer e.replaceMu.Unlock()
+
+	rules = slices.Clone(rules)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+
+	e.replaceCounters(rules)
+	e.expiredSent = make(map[int64]bool)
+	e.publish(rules, time.Now())
+}
+
+// Tick 按时间启用 / 停用规则（只重建索引，不重新编译），
+// 返回生效状态变化的规则，以及本次新发现已过期的规则
+func (e *Engine) Tick(now time.Time) (changed, expired []int64) {
+	e.replaceMu.Lock()
+	defer e.replaceMu.Unlock()
+
+	x := e.index.Load()
+	if x.next.IsZero() || now.Before(x.next) {
+		return nil, nil
+	}
+	for _, r := range x.all {
+		if r.schedule == nil {
+			continue
+		}
+		if r.Active(now) == x.inactive[r.ID] {
+			changed = append(changed, r.ID)
+		}
+		if r.Expired(now) && !e.expiredSent[r.ID] {
+			e.expiredSent[r.ID] = true
+			expired = append(expired, r.ID)
+		}
+	}
+	e.publish(x.all, now)
+	return changed, expired
+}
+
+// SetDefaultAction 设置没有终结规则命中时的结果（只能是终结动作），
+// 同时增加规则版本，使已建立的连接重新求值
+func (e *Engine) SetDefaultAction(a Action) error {
+	if !a.Terminal() {
+		return fmt.Errorf("default action must be allow, deny or reset, got %q", a)
+	}
+	e.replaceMu.Lock()
+	defer e.replaceMu.Unlock()
+	e.defaultAction.Store(a)
+	e.generation.Add(1)
+	return nil
+}
+
+// DefaultAction 没有终结规则命中时的结果
+func (e *Engine) DefaultAction() Action {
+	return e.defaultAction.Load().(Action)
+}
+
+// ActiveCount 当前生效的规则数
+func (e *Engine) ActiveCount() int {
+	return len(e.index.Load().rules)
+}
+
+// Generation 规则版本，规则替换或按时启用 / 停用后变化，
+// 用于判断已建立连接的连接级结果是否需要重新求值
+func (e *Engine) Generation() uint64 {
+	return e.generation.Load()
+}
+
+// publish 用 now 时生效的规则建立索引并原子替换
+func (e *Engine) publish(all []*CompiledRule, now time.Time) {
+	active := all
+	inactive := map[int64]bool{}
+	var next time.Time
+	for i, r := range all {
+		if r.schedule == nil {
+			continue
+		}
+		n := r.schedule.next(now)
+		if r.Expired(now) && !e.expiredSent[r.ID] {
+			// 加载时已过期，下一次 Tick 立即报告
+			n = now
+		}
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+		if !r.Active(now) {
+			if len(inactive) == 0 {
+				active = slices.Clone(all[:i])
+			}
+			inactive[r.ID] = true
+		} else if len(inactive) > 0 {
+			active = append(active, r)
+		}
+	}
+
+	packet, decoded := false, false
+	for _, r := range active {
+		packet = packet || r.perPacket
+		decoded = decoded || len(r.decoded) > 0
+	}
+
+	x := newRuleIndex(active)
+	x.all, x.inactive, x.next = all, inactive, next
+	e.index.Store(x)
+	e.scanner.Store(newPayloadScanner(active))
+	e.packetRules.Store(packet)
+	e.decodedRules.Store(decoded)
+	e.generation.Add(1)
 }
@@ -0,0 +1,155 @@
+package filter
+
+import (
+	"net"
+	"proxy-system-backend/internal/modules/redirect"
+	"proxy-system-backend/internal/traffic"
+	"slices"
+	"testing"
+)
+
+func ctxFor(dir traffic.Direction) *traffic.PacketContext {
+	client := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 50000}
+	server := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 443}
+	if dir == traffic.DirectionIn {
+		return traffic.NewCtx("c1", dir, traffic.ProtocolTCP, server, client)
+	}
+	return traffic.NewCtx("c1", dir, traffic.ProtocolTCP, client, server)
+}
+
+func load(t *testing.T, def Action, rules ...Rule) *Engine {
+	t.Helper()
+	for i := range rules {
+		rules[i].Enabled = true
+	}
+	e := NewEngine()
+	if err := e.Load(Config{DefaultAction: def}, rules); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEvaluateCollectsUntilTerminal(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 30, Action: "tag:game", DstPort: []PortRange{{Min: 443, Max: 443}}},
+		Rule{ID: 2, Priority: 20, Action: ActionLog},
+		Rule{ID: 3, Priority: 15, Action: "decode:proto-a", DstCIDR: []string{"10.0.0.0/8"}},
+		Rule{ID: 4, Priority: 10, Action: ActionReset, DstCIDR: []string{"10.0.0.0/8"}},
+		Rule{ID: 5, Priority: 5, Action: ActionCapture},
+	)
+
+	// 两个方向得到相同的连接级结果
+	for _, dir := range []traffic.Direction{traffic.DirectionOut, traffic.DirectionIn} {
+		d := e.Evaluate(ctxFor(dir))
+		if d.Verdict != ActionReset || d.RuleID != 4 {
+			t.Fatalf("%s: verdict = %s rule %d", dir, d.Verdict, d.RuleID)
+		}
+		if !d.Log || d.Decode != "proto-a" || !slices.Equal(d.Tags, []string{"game"}) {
+			t.Fatalf("%s: decision = %+v", dir, d)
+		}
+		if d.Capture || !slices.Equal(d.Rules, []int64{1, 2, 3, 4}) {
+			t.Fatalf("%s: rules after terminal applied: %+v", dir, d)
+		}
+	}
+
+	d := load(t, ActionDeny, Rule{ID: 1, Action: ActionSkipDecode}).Evaluate(ctxFor(traffic.DirectionOut))
+	if d.Verdict != ActionDeny || d.RuleID != 0 || !d.SkipDecode {
+		t.Fatalf("default decision = %+v", d)
+	}
+}
+
+func TestEvaluatePacketOverrides(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 10, Action: "tag:conn"},
+		Rule{ID: 2, Priority: 9, Action: ActionDeny, Direction: traffic.DirectionIn},
+		Rule{ID: 3, Priority: 8, Action: ActionSkipDecode, Direction: traffic.DirectionOut},
+	)
+
+	out := ctxFor(traffic.DirectionOut)
+	conn := e.Evaluate(out)
+	if conn.Verdict != ActionAllow || !slices.Equal(conn.Rules, []int64{1}) {
+		t.Fatalf("connection decision = %+v", conn)
+	}
+
+	d := conn.Override(e.EvaluatePacket(out))
+	if d.Verdict != ActionAllow || !d.SkipDecode || !slices.Equal(d.Tags, []string{"conn"}) {
+		t.Fatalf("out packet = %+v", d)
+	}
+	d = conn.Override(e.EvaluatePacket(ctxFor(traffic.DirectionIn)))
+	if d.Verdict != ActionDeny || d.RuleID != 2 || d.SkipDecode {
+		t.Fatalf("in packet = %+v", d)
+	}
+	if len(conn.Rules) != 1 {
+		t.Fatal("override modified the connection decision")
+	}
+}
+
+func TestCompileRuleValidatesAction(t *testing.T) {
+	for _, a := range []Action{"decode:", "tag", "allow:x", "drop"} {
+		if _, err := CompileRule(Rule{ID: 1, Action: a}); err == nil {
+			t.Errorf("action %q should be rejected", a)
+		}
+	}
+	if _, err := CompileRule(Rule{ID: 1, Action: ActionMirror}); err == nil {
+		t.Error("mirror without target should be rejected")
+	}
+	if _, err := CompileRule(Rule{ID: 1, Action: ActionRedirect}); err == nil {
+		t.Error("redirect without target should be rejected")
+	}
+	to := &redirect.Target{To: "127.0.0.1:7001"}
+	if _, err := CompileRule(Rule{ID: 1, Action: ActionRedirect, Redirect: to, Payload: []PayloadMatch{{Type: PayloadPrefix, Pattern: "16 03"}}}); err == nil {
+		t.Error("redirect with payload conditions should be rejected")
+	}
+}
+
+func TestEvaluateRedirect(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 20, Action: ActionRedirect, Expr: `dst.domain == "gameserver" && port == 7000`,
+			Redirect: &redirect.Target{To: "127.0.0.1:7001"}},
+		Rule{ID: 2, Priority: 10, Action: ActionRedirect, Expr: `dst.domain ~ "*.test"`,
+			Redirect: &redirect.Target{To: "127.0.0.2"}},
+	)
+
+	// 连接目标之前的上下文：域名目标只有端口，没有 DstIP
+	ctx := traffic.NewCtx("c1", traffic.DirectionOut, traffic.ProtocolTCP, &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 50000}, nil)
+	ctx.Domain, ctx.DstPort = "gameserver", 7000
+	if d := e.Evaluate(ctx); d.Redirect == nil || d.Redirect.To != "127.0.0.1:7001" || d.Verdict != ActionAllow {
+		t.Fatalf("decision = %+v", d)
+	}
+	ctx.Domain = "api.test"
+	if d := e.Evaluate(ctx); d.Redirect == nil || d.Redirect.To != "127.0.0.2" {
+		t.Fatalf("decision = %+v", d)
+	}
+	ctx.Domain = "other.org"
+	if d := e.Evaluate(ctx); d.Redirect != nil {
+		t.Fatalf("decision = %+v", d)
+	}
+}
+
+func TestEvaluateGeo(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 30, Action: "tag:cdn", ASN: []uint32{13335}},
+		Rule{ID: 2, Priority: 20, Action: ActionDeny, Geo: []string{"KP", "IR"}},
+		Rule{ID: 3, Priority: 10, Action: "tag:cn-client", Expr: `src.country == "cn" && dst.asn in [13335, 15169]`},
+	)
+
+	cases := []struct {
+		client, server *traffic.GeoInfo
+		verdict        Action
+		tags           []string
+	}{
+		{nil, nil, ActionAllow, nil},
+		{nil, &traffic.GeoInfo{Country: "KP"}, ActionDeny, nil},
+		{&traffic.GeoInfo{Country: "CN"}, &traffic.GeoInfo{Country: "US", ASN: 13335}, ActionAllow, []string{"cdn", "cn-client"}},
+		{&traffic.GeoInfo{Country: "JP"}, &traffic.GeoInfo{Country: "US", ASN: 15169}, ActionAllow, nil},
+	}
+	for i, c := range cases {
+		// 入站数据包按客户端视角匹配：目标为服务器一端
+		ctx := ctxFor(traffic.DirectionIn)
+		ctx.SrcGeo, ctx.DstGeo = c.server, c.client
+		d := e.Evaluate(ctx)
+		if d.Verdict != c.verdict || !slices.Equal(d.Tags, c.tags) {
+			t.Errorf("case %d: decision = %+v, want %s %v", i, d, c.verdict, c.tags)
+		}
+	}
+}
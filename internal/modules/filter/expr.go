@@ -0,0 +1,688 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"path"
+	"proxy-system-backend/internal/traffic"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Expr 编译后的过滤表达式，例如：
+//
+//	dst.domain ~ "*.game.com" && dir == out && port in 7000..7100 && payload[0:2] == 0xCAFE
+//
+// 字段与运算符见 exprFields；ip / port 表示任意一端
+type Expr struct {
+	src       string
+	fn        exprFunc
+	perPacket bool
+}
+
+type exprFunc func(ctx *traffic.PacketContext, s *payloadScan) bool
+
+// CompileExpr 解析、类型检查并编译表达式，错误为 *ExprError（带行列号）
+func CompileExpr(src string) (*Expr, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{src: src, toks: toks}
+	fn, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t.pos, "unexpected %s", t)
+	}
+	return &Expr{src: src, fn: fn, perPacket: p.perPacket}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// PerPacket 是否引用了 dir / len / payload 等逐包字段
+func (e *Expr) PerPacket() bool {
+	return e.perPacket
+}
+
+func (e *Expr) Match(ctx *traffic.PacketContext) bool {
+	return e.fn(ctx, newPayloadScan(ctx.Payload, nil))
+}
+
+type exprType int
+
+const (
+	typeInt exprType = iota
+	typeString
+	typeIP
+	typeBytes
+	typeBool
+	typeEnum
+)
+
+func (t exprType) String() string {
+	return [...]string{"int", "string", "ip", "bytes", "bool", "enum"}[t]
+}
+
+type (
+	intAccessor   func(ctx *traffic.PacketContext, s *payloadScan) (int, bool)
+	ipAccessor    func(ctx *traffic.PacketContext) net.IP
+	bytesAccessor func(ctx *traffic.PacketContext, s *payloadScan) []byte
+	strAccessor   func(ctx *traffic.PacketContext) string
+)
+
+// exprField 字段定义；ints / ips 有两个访问器时任意一个满足即可
+type exprField struct {
+	name      string
+	typ       exprType
+	perPacket bool
+
+	ints  []intAccessor
+	ips   []ipAccessor
+	bytes bytesAccessor
+	str   strAccessor // string / enum / bool（"true"）
+	enum  []string
+}
+
+func srcPort(ctx *traffic.PacketContext, _ *payloadScan) (int, bool) {
+	return ctx.SrcPort, ctx.SrcPort != 0
+}
+func dstPort(ctx *traffic.PacketContext, _ *payloadScan) (int, bool) {
+	return ctx.DstPort, ctx.DstPort != 0
+}
+func srcIP(ctx *traffic.PacketContext) net.IP  { return ctx.SrcIP }
+func dstIP(ctx *traffic.PacketContext) net.IP  { return ctx.DstIP }
+func domain(ctx *traffic.PacketContext) string { return strings.ToLower(ctx.Domain) }
+
+// geoString / geoASN 地址的 GeoIP 信息，未知时字符串为空、ASN 不满足任何比较
+func geoString(geo func(*traffic.PacketContext) *traffic.GeoInfo, field func(*traffic.GeoInfo) string) strAccessor {
+	return func(ctx *traffic.PacketContext) string {
+		if g := geo(ctx); g != nil {
+			return strings.ToLower(field(g))
+		}
+		return ""
+	}
+}
+
+func geoASN(geo func(*traffic.PacketContext) *traffic.GeoInfo) intAccessor {
+	return func(ctx *traffic.PacketContext, _ *payloadScan) (int, bool) {
+		if g := geo(ctx); g != nil && g.ASN != 0 {
+			return int(g.ASN), true
+		}
+		return 0, false
+	}
+}
+
+func srcGeo(ctx *traffic.PacketContext) *traffic.GeoInfo { return ctx.SrcGeo }
+func dstGeo(ctx *traffic.PacketContext) *traffic.GeoInfo { return ctx.DstGeo }
+func geoCountry(g *traffic.GeoInfo) string               { return g.Country }
+func geoOrg(g *traffic.GeoInfo) string                   { return g.Org }
+
+var exprFields = map[string]exprField{
+	"src.ip":     {typ: typeIP, ips: []ipAccessor{srcIP}},
+	"dst.ip":     {typ: typeIP, ips: []ipAccessor{dstIP}},
+	"ip":         {typ: typeIP, ips: []ipAccessor{srcIP, dstIP}},
+	"src.port":   {typ: typeInt, ints: []intAccessor{srcPort}},
+	"dst.port":   {typ: typeInt, ints: []intAccessor{dstPort}},
+	"port":       {typ: typeInt, ints: []intAccessor{srcPort, dstPort}},
+	"domain":     {typ: typeString, str: domain},
+	"dst.domain": {typ: typeString, str: domain},
+	// GeoIP：国家代码（不区分大小写）、ASN、ASN 所属组织
+	"src.country": {typ: typeString, str: geoString(srcGeo, geoCountry)},
+	"dst.country": {typ: typeString, str: geoString(dstGeo, geoCountry)},
+	"src.asn":     {typ: typeInt, ints: []intAccessor{geoASN(srcGeo)}},
+	"dst.asn":     {typ: typeInt, ints: []intAccessor{geoASN(dstGeo)}},
+	"src.org":     {typ: typeString, str: geoString(srcGeo, geoOrg)},
+	"dst.org":     {typ: typeString, str: geoString(dstGeo, geoOrg)},
+	"proto": {typ: typeEnum, enum: []string{"tcp", "udp"},
+		str: func(ctx *traffic.PacketContext) string { return ctx.Protocol.String() }},
+	"tls": {typ: typeBool,
+		str: func(ctx *traffic.PacketContext) string { return strconv.FormatBool(ctx.TLSIntercepted) }},
+	"dir": {typ: typeEnum, perPacket: true, enum: []string{"out", "in"},
+		str: func(ctx *traffic.PacketContext) string { return ctx.Direction.String() }},
+	"len": {typ: typeInt, perPacket: true,
+		ints: []intAccessor{func(ctx *traffic.PacketContext, _ *payloadScan) (int, bool) { return len(ctx.Payload), true }}},
+	"payload": {typ: typeBytes, perPacket: true,
+		bytes: func(ctx *traffic.PacketContext, _ *payloadScan) []byte { return ctx.Payload }},
+}
+
+type exprParser struct {
+	src       string
+	toks      []token
+	i         int
+	perPacket bool
+}
+
+func (p *exprParser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *exprParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// is 当前 token 是否为指定运算符或关键字
+func (p *exprParser) is(texts ...string) bool {
+	t := p.peek()
+	return (t.kind == tokOp || t.kind == tokIdent) && slices.Contains(texts, t.text)
+}
+
+func (p *exprParser) expect(op string) (token, error) {
+	t := p.next()
+	if t.kind != tokOp || t.text != op {
+		return t, p.errorf(t.pos, "expected %q, got %s", op, t)
+	}
+	return t, nil
+}
+
+func (p *exprParser) errorf(pos int, format string, args ...any) error {
+	return exprErrorf(p.src, pos, format, args...)
+}
+
+func (p *exprParser) parseOr() (exprFunc, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("||", "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx *traffic.PacketContext, s *payloadScan) bool { return l(ctx, s) || right(ctx, s) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprFunc, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("&&", "and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx *traffic.PacketContext, s *payloadScan) bool { return l(ctx, s) && right(ctx, s) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprFunc, error) {
+	if p.is("!", "not") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *traffic.PacketContext, s *payloadScan) bool { return !x(ctx, s) }, nil
+	}
+	if p.is("(") {
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	return p.parseComparison()
+}
+
+// parseField 字段名（点号连接），payload 可跟 [i] 或 [a:b]
+func (p *exprParser) parseField() (exprField, int, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return exprField{}, t.pos, p.errorf(t.pos, "expected field, got %s", t)
+	}
+	name := t.text
+	for p.is(".") {
+		p.next()
+		part := p.next()
+		if part.kind != tokIdent {
+			return exprField{}, t.pos, p.errorf(part.pos, "expected field name after '.', got %s", part)
+		}
+		name += "." + part.text
+	}
+
+	f, ok := exprFields[name]
+	if !ok {
+		return exprField{}, t.pos, p.errorf(t.pos, "unknown field %q", name)
+	}
+	f.name = name
+	p.perPacket = p.perPacket || f.perPacket
+
+	if f.typ == typeBytes && p.is("[") {
+		p.next()
+		return p.parseSlice(f, t.pos)
+	}
+	return f, t.pos, nil
+}
+
+func (p *exprParser) parseSlice(f exprField, pos int) (exprField, int, error) {
+	lo, hi, hasHi := 0, 0, false
+	if !p.is(":") {
+		v, err := p.intToken(p.next())
+		if err != nil {
+			return f, pos, err
+		}
+		lo = v
+		if p.is("]") {
+			// payload[i]：单个字节
+			p.next()
+			f.name = fmt.Sprintf("%s[%d]", f.name, lo)
+			f.typ = typeInt
+			f.ints = []intAccessor{func(ctx *traffic.PacketContext, _ *payloadScan) (int, bool) {
+				if lo >= len(ctx.Payload) {
+					return 0, false
+				}
+				return int(ctx.Payload[lo]), true
+			}}
+			return f, pos, nil
+		}
+	}
+	if _, err := p.expect(":"); err != nil {
+		return f, pos, err
+	}
+	if !p.is("]") {
+		t := p.next()
+		v, err := p.intToken(t)
+		if err != nil {
+			return f, pos, err
+		}
+		if v < lo {
+			return f, pos, p.errorf(t.pos, "slice end %d is before start %d", v, lo)
+		}
+		hi, hasHi = v, true
+	}
+	if _, err := p.expect("]"); err != nil {
+		return f, pos, err
+	}
+
+	f.name += "[" + strconv.Itoa(lo) + ":"
+	if hasHi {
+		f.name += strconv.Itoa(hi)
+	}
+	f.name += "]"
+	f.bytes = func(ctx *traffic.PacketContext, _ *payloadScan) []byte {
+		b := ctx.Payload
+		if lo >= len(b) {
+			return nil
+		}
+		if hasHi && hi < len(b) {
+			return b[lo:hi]
+		}
+		return b[lo:]
+	}
+	return f, pos, nil
+}
+
+func (p *exprParser) intToken(t token) (int, error) {
+	var (
+		v   int64
+		err error
+	)
+	switch t.kind {
+	case tokInt:
+		v, err = strconv.ParseInt(t.text, 10, 32)
+	case tokHex:
+		v, err = strconv.ParseInt(t.text, 16, 32)
+	default:
+		return 0, p.errorf(t.pos, "expected integer, got %s", t)
+	}
+	if err != nil || v < 0 {
+		return 0, p.errorf(t.pos, "integer %s out of range", t)
+	}
+	return int(v), nil
+}
+
+// exprValue 运算符右侧：单个值、范围 a..b 或列表 [...]
+type exprValue struct {
+	tok    token
+	hi     *token // 范围上界
+	items  []exprValue
+	isList bool
+}
+
+func (p *exprParser) parseValue() (exprValue, error) {
+	if p.is("[") {
+		open := p.next()
+		v := exprValue{tok: open, isList: true}
+		for {
+			item, err := p.parseScalar()
+			if err != nil {
+				return v, err
+			}
+			v.items = append(v.items, item)
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+		_, err := p.expect("]")
+		return v, err
+	}
+	return p.parseScalar()
+}
+
+func (p *exprParser) parseScalar() (exprValue, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInt, tokHex, tokString, tokAddr, tokIdent:
+	default:
+		return exprValue{}, p.errorf(t.pos, "expected value, got %s", t)
+	}
+	v := exprValue{tok: t}
+	if p.is("..") {
+		p.next()
+		hi := p.next()
+		v.hi = &hi
+	}
+	return v, nil
+}
+
+// 各类型支持的运算符
+var exprTypeOps = map[exprType][]string{
+	typeInt:    {"==", "!=", "<", "<=", ">", ">=", "in"},
+	typeString: {"==", "!=", "~", "=~", "contains", "in"},
+	typeIP:     {"==", "!=", "in"},
+	typeBytes:  {"==", "!=", "contains", "=~"},
+	typeBool:   {"==", "!="},
+	typeEnum:   {"==", "!=", "in"},
+}
+
+func (p *exprParser) parseComparison() (exprFunc, error) {
+	f, pos, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.peek()
+	isOp := (opTok.kind == tokOp && slices.Contains([]string{"==", "!=", "<", "<=", ">", ">=", "~", "=~"}, opTok.text)) ||
+		(opTok.kind == tokIdent && (opTok.text == "in" || opTok.text == "contains"))
+	if !isOp {
+		if f.typ != typeBool {
+			return nil, p.errorf(pos, "%s field %q needs a comparison", f.typ, f.name)
+		}
+		return func(ctx *traffic.PacketContext, _ *payloadScan) bool { return f.str(ctx) == "true" }, nil
+	}
+	p.next()
+	op := opTok.text
+	if !slices.Contains(exprTypeOps[f.typ], op) {
+		return nil, p.errorf(opTok.pos, "operator %q is not supported for %s field %q", op, f.typ, f.name)
+	}
+
+	val, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if (val.isList || val.hi != nil) && op != "in" {
+		return nil, p.errorf(val.tok.pos, "lists and ranges are only allowed with 'in'")
+	}
+
+	negate := op == "!="
+	if negate {
+		op = "=="
+	}
+	var fn exprFunc
+	switch f.typ {
+	case typeInt:
+		fn, err = p.compileInt(f, op, val)
+	case typeString:
+		fn, err = p.compileString(f, op, val)
+	case typeIP:
+		fn, err = p.compileIP(f, op, val)
+	case typeBytes:
+		fn, err = p.compileBytes(f, op, val)
+	case typeBool, typeEnum:
+		fn, err = p.compileEnum(f, val)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		inner := fn
+		fn = func(ctx *traffic.PacketContext, s *payloadScan) bool { return !inner(ctx, s) }
+	}
+	return fn, nil
+}
+
+// items 列表的元素；单个值视为只有一个元素的列表
+func (v exprValue) list() []exprValue {
+	if v.isList {
+		return v.items
+	}
+	return []exprValue{v}
+}
+
+func (p *exprParser) compileInt(f exprField, op string, val exprValue) (exprFunc, error) {
+	var test func(int) bool
+	if op == "in" {
+		var ranges []PortRange
+		for _, item := range val.list() {
+			lo, err := p.intToken(item.tok)
+			if err != nil {
+				return nil, err
+			}
+			hi := lo
+			if item.hi != nil {
+				if hi, err = p.intToken(*item.hi); err != nil {
+					return nil, err
+				}
+				if hi < lo {
+					return nil, p.errorf(item.tok.pos, "invalid range %d..%d", lo, hi)
+				}
+			}
+			ranges = append(ranges, PortRange{Min: lo, Max: hi})
+		}
+		test = func(v int) bool { return matchPort(v, ranges) }
+	} else {
+		want, err := p.intToken(val.tok)
+		if err != nil {
+			return nil, err
+		}
+		test = map[string]func(int) bool{
+			"==": func(v int) bool { return v == want },
+			"<":  func(v int) bool { return v < want },
+			"<=": func(v int) bool { return v <= want },
+			">":  func(v int) bool { return v > want },
+			">=": func(v int) bool { return v >= want },
+		}[op]
+	}
+
+	accs := f.ints
+	return func(ctx *traffic.PacketContext, s *payloadScan) bool {
+		for _, acc := range accs {
+			if v, ok := acc(ctx, s); ok && test(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (p *exprParser) stringToken(t token) (string, error) {
+	if t.kind != tokString {
+		return "", p.errorf(t.pos, "expected string, got %s", t)
+	}
+	return t.text, nil
+}
+
+func (p *exprParser) compileString(f exprField, op string, val exprValue) (exprFunc, error) {
+	get := f.str
+	switch op {
+	case "==", "contains", "~":
+		s, err := p.stringToken(val.tok)
+		if err != nil {
+			return nil, err
+		}
+		s = strings.ToLower(s)
+		switch op {
+		case "==":
+			return func(ctx *traffic.PacketContext, _ *payloadScan) bool { return get(ctx) == s }, nil
+		case "contains":
+			return func(ctx *traffic.PacketContext, _ *payloadScan) bool { return strings.Contains(get(ctx), s) }, nil
+		}
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, p.errorf(val.tok.pos, "invalid glob %q", s)
+		}
+		return func(ctx *traffic.PacketContext, _ *payloadScan) bool {
+			ok, _ := path.Match(s, get(ctx))
+			return ok
+		}, nil
+
+	case "=~":
+		re, err := p.regex(val.tok)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *traffic.PacketContext, _ *payloadScan) bool { return re.MatchString(get(ctx)) }, nil
+
+	default: // in
+		var set []string
+		for _, item := range val.list() {
+			s, err := p.stringToken(item.tok)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, strings.ToLower(s))
+		}
+		return func(ctx *traffic.PacketContext, _ *payloadScan) bool { return slices.Contains(set, get(ctx)) }, nil
+	}
+}
+
+func (p *exprParser) regex(t token) (*regexp.Regexp, error) {
+	s, err := p.stringToken(t)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, p.errorf(t.pos, "invalid regex: %v", err)
+	}
+	return re, nil
+}
+
+// ipNet IPv4 可直接书写，IPv6 需写成字符串；单个 IP 视为 /32 或 /128
+func (p *exprParser) ipNet(t token, allowCIDR bool) (*net.IPNet, error) {
+	if t.kind != tokAddr && t.kind != tokString {
+		return nil, p.errorf(t.pos, "expected ip address, got %s", t)
+	}
+	if strings.Contains(t.text, "/") {
+		if !allowCIDR {
+			return nil, p.errorf(t.pos, "use 'in' to match a cidr")
+		}
+		_, n, err := net.ParseCIDR(t.text)
+		if err != nil {
+			return nil, p.errorf(t.pos, "invalid cidr %q", t.text)
+		}
+		return n, nil
+	}
+	ip := net.ParseIP(t.text)
+	if ip == nil {
+		return nil, p.errorf(t.pos, "invalid ip %q", t.text)
+	}
+	bits := 128
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits = v4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func (p *exprParser) compileIP(f exprField, op string, val exprValue) (exprFunc, error) {
+	var nets []*net.IPNet
+	for _, item := range val.list() {
+		n, err := p.ipNet(item.tok, op == "in")
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+
+	set := newCIDRSet(nets)
+	accs := f.ips
+	return func(ctx *traffic.PacketContext, _ *payloadScan) bool {
+		for _, acc := range accs {
+			if ip := acc(ctx); ip != nil && set.contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (p *exprParser) compileBytes(f exprField, op string, val exprValue) (exprFunc, error) {
+	get := f.bytes
+	if op == "=~" {
+		// 字符串中的 \xNN 是原始字节，同样按 Latin-1 转换后再编译
+		t := val.tok
+		if t.kind == tokString {
+			t.text = newPayloadScan([]byte(t.text), nil).latin1()
+		}
+		re, err := p.regex(t)
+		if err != nil {
+			return nil, err
+		}
+		// 与 type=regex 的负载条件相同：按字节匹配
+		return func(ctx *traffic.PacketContext, s *payloadScan) bool {
+			b := get(ctx, s)
+			return re.MatchString(newPayloadScan(b, nil).latin1())
+		}, nil
+	}
+
+	var want []byte
+	switch t := val.tok; t.kind {
+	case tokHex:
+		if len(t.text)%2 != 0 {
+			return nil, p.errorf(t.pos, "hex literal 0x%s must have an even number of digits to compare with bytes", t.text)
+		}
+		want, _ = hex.DecodeString(t.text)
+	case tokString:
+		want = []byte(t.text)
+	default:
+		return nil, p.errorf(t.pos, "expected hex literal or string, got %s", t)
+	}
+
+	if op == "contains" {
+		return func(ctx *traffic.PacketContext, s *payloadScan) bool { return bytes.Contains(get(ctx, s), want) }, nil
+	}
+	return func(ctx *traffic.PacketContext, s *payloadScan) bool { return bytes.Equal(get(ctx, s), want) }, nil
+}
+
+// compileEnum dir / proto / tls 与标识符比较
+func (p *exprParser) compileEnum(f exprField, val exprValue) (exprFunc, error) {
+	allowed := f.enum
+	if f.typ == typeBool {
+		allowed = []string{"true", "false"}
+	}
+
+	var set []string
+	for _, item := range val.list() {
+		t := item.tok
+		if (t.kind != tokIdent && t.kind != tokString) || !slices.Contains(allowed, t.text) {
+			return nil, p.errorf(t.pos, "%q expects one of %s, got %s", f.name, strings.Join(allowed, " / "), t)
+		}
+		set = append(set, t.text)
+	}
+
+	get := f.str
+	return func(ctx *traffic.PacketContext, _ *payloadScan) bool { return slices.Contains(set, get(ctx)) }, nil
+}
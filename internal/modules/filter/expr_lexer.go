@@ -0,0 +1,158 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt    // 123
+	tokHex    // 0xCAFE
+	tokString // "..."
+	tokAddr   // 10.0.0.1、10.0.0.0/8
+	tokOp     // 运算符与标点
+)
+
+type token struct {
+	kind tokenKind
+	text string // tokString 为去掉引号后的内容
+	pos  int    // 在表达式中的字节偏移
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// 多字符运算符在前，保证最长匹配
+var exprOps = []string{"&&", "||", "==", "!=", "=~", "<=", ">=", "..", "!", "~", "<", ">", "(", ")", "[", "]", ",", ":", "."}
+
+// ExprError 表达式错误，Line / Col 从 1 开始
+type ExprError struct {
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+	Msg  string `json:"message"`
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+func exprErrorf(src string, pos int, format string, args ...any) *ExprError {
+	if pos > len(src) {
+		pos = len(src)
+	}
+	line := 1 + strings.Count(src[:pos], "\n")
+	col := pos - strings.LastIndexByte(src[:pos], '\n')
+	return &ExprError{Line: line, Col: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func lexExpr(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case isIdentStart(c):
+			j := i
+			for j < len(src) && (isIdentStart(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+
+		case isDigit(c):
+			tok, n, err := lexNumber(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i += n
+
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, exprErrorf(src, i, "unterminated string")
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, exprErrorf(src, i, "invalid string %s", src[i:j+1])
+			}
+			toks = append(toks, token{kind: tokString, text: s, pos: i})
+			i = j + 1
+
+		default:
+			op := ""
+			for _, o := range exprOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, exprErrorf(src, i, "unexpected character %q", c)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexNumber 整数、0x 十六进制，或 IPv4 地址 / CIDR（IPv6 请写成字符串）
+func lexNumber(src string, i int) (token, int, error) {
+	if strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X") {
+		j := i + 2
+		for j < len(src) && isHexDigit(src[j]) {
+			j++
+		}
+		if j == i+2 {
+			return token{}, 0, exprErrorf(src, i, "invalid hex literal")
+		}
+		return token{kind: tokHex, text: src[i+2 : j], pos: i}, j - i, nil
+	}
+
+	j := i
+	for j < len(src) && isDigit(src[j]) {
+		j++
+	}
+	// "7000..7100" 是范围，"10.0.0.1" 是地址
+	if j+1 < len(src) && src[j] == '.' && isDigit(src[j+1]) {
+		for j < len(src) && (isDigit(src[j]) || src[j] == '.' || src[j] == '/') {
+			j++
+		}
+		return token{kind: tokAddr, text: src[i:j], pos: i}, j - i, nil
+	}
+	return token{kind: tokInt, text: src[i:j], pos: i}, j - i, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
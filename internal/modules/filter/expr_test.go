@@ -0,0 +1,95 @@
+package filter
+
+import (
+	"errors"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+)
+
+func TestExprMatch(t *testing.T) {
+	out := ctxFor(traffic.DirectionOut) // 192.168.1.10:50000 -> 10.0.0.5:443
+	out.Domain = "Login.Game.com"
+	out.Payload = []byte{0xca, 0xfe, 0x01, 'h', 'i'}
+	in := ctxFor(traffic.DirectionIn)
+
+	cases := []struct {
+		expr string
+		ctx  *traffic.PacketContext
+		want bool
+	}{
+		{`dst.domain ~ "*.game.com" && dir == out && port in 400..500 && payload[0:2] == 0xCAFE`, out, true},
+		{`dst.domain ~ "*.game.com" && dir == in`, out, false},
+		{`dir == in`, in, true},
+		{`port == 443 and src.port in [50000, 60000..60010]`, out, true},
+		{`port != 443`, out, false},
+		{`dst.ip in 10.0.0.0/8 && src.ip == 192.168.1.10`, out, true},
+		{`ip in ["2001:db8::/32", 10.0.0.5]`, in, true},
+		{`!(dst.port < 1024) || len >= 5`, out, true},
+		{`payload[2] == 0x01 && payload[9] == 1`, out, false},
+		{`payload contains "hi" && payload[3:] == "hi"`, out, true},
+		{`payload =~ "^\xca\xfe"`, out, true},
+		{`domain == "login.game.com" && domain in ["a.com", "LOGIN.game.com"]`, out, true},
+		{`domain =~ "(?i)^login\\." && not tls && proto == tcp`, out, true},
+	}
+	for _, c := range cases {
+		e, err := CompileExpr(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := e.Match(c.ctx); got != c.want {
+			t.Errorf("%s = %v, want %v", c.expr, got, c.want)
+		}
+	}
+
+	if e, _ := CompileExpr(`dst.ip in 10.0.0.0/8`); e.PerPacket() {
+		t.Error("address-only expression should match per connection")
+	}
+	if e, _ := CompileExpr(`port == 1 || len > 3`); !e.PerPacket() {
+		t.Error("len should make the expression per packet")
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	cases := map[string]string{
+		`dts.ip == 1.2.3.4`:                    `1:1: unknown field "dts.ip"`,
+		`port ~ "80"`:                          `1:6: operator "~" is not supported for int field "port"`,
+		`dir == up`:                            `1:8: "dir" expects one of out / in, got "up"`,
+		"port == 1 &&\n  dst.ip == 10.0.0.0/8": `2:13: use 'in' to match a cidr`,
+		`payload[0:2] == 0xCAF`:                `1:17: hex literal 0xCAF must have an even number of digits to compare with bytes`,
+		`port == 80 )`:                         `1:12: unexpected ")"`,
+		`(port == 80`:                          `1:12: expected ")", got end of expression`,
+		`domain`:                               `1:1: string field "domain" needs a comparison`,
+		`port == 80..90`:                       `1:9: lists and ranges are only allowed with 'in'`,
+		`domain == "a`:                         `1:11: unterminated string`,
+	}
+	for expr, want := range cases {
+		_, err := CompileExpr(expr)
+		var ee *ExprError
+		if !errors.As(err, &ee) || err.Error() != want {
+			t.Errorf("%q: err = %v, want %s", expr, err, want)
+		}
+	}
+}
+
+func TestRuleExpr(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 20, Action: ActionDeny, Expr: `dst.port == 443 && payload[0] == 0x16`},
+		Rule{ID: 2, Priority: 10, Action: "tag:lan", Expr: `src.ip in 192.168.0.0/16`, DstPort: []PortRange{{Min: 443, Max: 443}}},
+	)
+
+	ctx := ctxFor(traffic.DirectionOut)
+	ctx.Payload = []byte{0x16, 0x03}
+	if d := e.EvaluatePacket(ctx); d.Verdict != ActionDeny {
+		t.Fatalf("packet decision = %+v", d)
+	}
+	// 不引用逐包字段的表达式按连接匹配（客户端视角）
+	if d := e.Evaluate(ctxFor(traffic.DirectionIn)); len(d.Tags) != 1 || d.Tags[0] != "lan" {
+		t.Fatalf("connection decision = %+v", d)
+	}
+
+	_, err := RuleDTO{Name: "x", Action: "deny", Expr: `port in 1..`}.ToRule()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Fields[0].Field != "expr" {
+		t.Fatalf("err = %v", err)
+	}
+}
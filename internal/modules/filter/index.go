@@ -0,0 +1,193 @@
+package filter
+
+import (
+	"math/bits"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"sync"
+	"time"
+)
+
+// bitset 规则位图，第 i 位对应优先级排序后的第 i 条规则
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i int) {
+	b[i>>6] |= 1 << (i & 63)
+}
+
+func (b bitset) and(o bitset) {
+	for i := range b {
+		b[i] &= o[i]
+	}
+}
+
+func (b bitset) or(o bitset) {
+	for i := range o {
+		b[i] |= o[i]
+	}
+}
+
+// next 从 i 开始的第一个置位，没有时返回 -1
+func (b bitset) next(i int) int {
+	w := i >> 6
+	if w >= len(b) {
+		return -1
+	}
+	if x := b[w] >> (i & 63); x != 0 {
+		return i + bits.TrailingZeros64(x)
+	}
+	for w++; w < len(b); w++ {
+		if b[w] != 0 {
+			return w<<6 + bits.TrailingZeros64(b[w])
+		}
+	}
+	return -1
+}
+
+// ruleIndex 按阶段、方向、源 / 目标地址索引规则，Replace 时整体重建并原子替换。
+// 查询得到候选规则位图后按位序（即优先级）逐条完整匹配，优先级语义不变
+type ruleIndex struct {
+	rules []*CompiledRule // 当前生效的规则
+
+	// 全部规则（含未到生效时间的），inactive 为其中未生效的规则，
+	// next 为下一次可能有规则启用 / 停用的时间
+	all      []*CompiledRule
+	inactive map[int64]bool
+	next     time.Time
+
+	every   bitset // 全部生效规则
+	conn    bitset // 不限方向的规则，每个连接匹配一次
+	packet  bitset // 逐包匹配（不含解码结果条件）
+	decoded bitset // 带解码结果条件
+
+	// dir[d]：不限方向或方向为 d 的规则
+	dir [3]bitset
+
+	// 没有地址条件的规则，加上地址所在 CIDR 的规则即为候选
+	src, dst       cidrTrie
+	srcAny, dstAny bitset
+	hasSrc, hasDst bool
+
+	// 默认规则集的规则、各命名规则集的规则，selections 缓存规则集组合的位图
+	unnamed    bitset
+	sets       map[string]bitset
+	selections sync.Map
+
+	scratch sync.Pool // *candidates
+}
+
+type candidates struct {
+	set, tmp bitset
+}
+
+func newRuleIndex(rules []*CompiledRule) *ruleIndex {
+	n := len(rules)
+	x := &ruleIndex{
+		rules:   rules,
+		every:   newBitset(n),
+		conn:    newBitset(n),
+		packet:  newBitset(n),
+		decoded: newBitset(n),
+		srcAny:  newBitset(n),
+		dstAny:  newBitset(n),
+		unnamed: newBitset(n),
+		sets:    map[string]bitset{},
+	}
+	for d := range x.dir {
+		x.dir[d] = newBitset(n)
+	}
+
+	for i, r := range rules {
+		x.every.set(i)
+		if r.Set == "" {
+			x.unnamed.set(i)
+		} else {
+			if x.sets[r.Set] == nil {
+				x.sets[r.Set] = newBitset(n)
+			}
+			x.sets[r.Set].set(i)
+		}
+		switch {
+		case len(r.decoded) > 0:
+			x.decoded.set(i)
+		case r.perPacket:
+			x.packet.set(i)
+		default:
+			x.conn.set(i)
+		}
+
+		if r.Direction == traffic.DirectionUnknown {
+			for d := range x.dir {
+				x.dir[d].set(i)
+			}
+		} else if int(r.Direction) < len(x.dir) {
+			x.dir[r.Direction].set(i)
+		}
+
+		x.hasSrc = indexNets(&x.src, x.srcAny, r.SrcIPNets, i) || x.hasSrc
+		x.hasDst = indexNets(&x.dst, x.dstAny, r.DstIPNets, i) || x.hasDst
+	}
+
+	words := len(x.every)
+	x.scratch.New = func() any {
+		return &candidates{set: make(bitset, words), tmp: make(bitset, words)}
+	}
+	return x
+}
+
+// indexNets 把规则的 CIDR 加入前缀树，没有地址条件的规则记入 wild
+func indexNets(t *cidrTrie, wild bitset, nets []*net.IPNet, i int) bool {
+	if len(nets) == 0 {
+		wild.set(i)
+		return false
+	}
+	for _, n := range nets {
+		t.insert(n, int32(i))
+	}
+	return true
+}
+
+// candidates 阶段 stage 中可能命中 ctx 的规则，用完后调用 release
+func (x *ruleIndex) candidates(ctx *traffic.PacketContext, stage bitset) *candidates {
+	c := x.scratch.Get().(*candidates)
+	copy(c.set, stage)
+
+	dir := int(ctx.Direction)
+	if dir >= len(x.dir) {
+		dir = int(traffic.DirectionUnknown)
+	}
+	c.set.and(x.dir[dir])
+	if sel := x.selection(ctx.RuleSets); sel != nil {
+		c.set.and(sel)
+	}
+
+	if x.hasSrc {
+		x.narrow(c, &x.src, x.srcAny, ctx.SrcIP)
+	}
+	if x.hasDst {
+		x.narrow(c, &x.dst, x.dstAny, ctx.DstIP)
+	}
+	return c
+}
+
+// narrow 只保留没有该地址条件、或地址在其 CIDR 内的规则
+func (x *ruleIndex) narrow(c *candidates, t *cidrTrie, wild bitset, ip net.IP) {
+	copy(c.tmp, wild)
+	if ip != nil {
+		t.lookup(ip, func(vals []int32) bool {
+			for _, v := range vals {
+				c.tmp.set(int(v))
+			}
+			return true
+		})
+	}
+	c.set.and(c.tmp)
+}
+
+func (x *ruleIndex) release(c *candidates) {
+	x.scratch.Put(c)
+}
@@ -0,0 +1,189 @@
+package filter
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+)
+
+func TestCIDRSetMatchesIPNet(t *testing.T) {
+	var nets []*net.IPNet
+	for _, s := range []string{
+		"10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32", "192.168.0.0/24",
+		"0.0.0.0/1", "2001:db8::/32", "2001:db8:1::/48", "fe80::1/128",
+		"::ffff:172.16.0.0/108",
+	} {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nets = append(nets, n)
+	}
+	set := newCIDRSet(nets)
+
+	for _, s := range []string{
+		"10.1.2.3", "10.1.2.4", "10.200.0.1", "11.0.0.1", "127.0.0.1", "192.168.0.255", "192.168.1.0",
+		"172.16.5.5", "172.32.0.1", "200.0.0.1", "::ffff:10.1.2.3",
+		"2001:db8::1", "2001:db8:1:2::1", "2001:db9::1", "fe80::1", "fe80::2", "::1",
+	} {
+		ip := net.ParseIP(s)
+		if got, want := set.contains(ip), matchIPNet(ip, nets); got != want {
+			t.Errorf("%s: contains = %v, want %v", s, got, want)
+		}
+	}
+
+	// IPv6 网段不包含 IPv4 地址（与 net.IPNet.Contains 一致）
+	_, all6, _ := net.ParseCIDR("::/0")
+	if newCIDRSet([]*net.IPNet{all6}).contains(net.ParseIP("10.0.0.1")) {
+		t.Fatal("::/0 contains an IPv4 address")
+	}
+}
+
+func TestPortSet(t *testing.T) {
+	ranges := []PortRange{{Min: 22, Max: 22}, {Min: 1000, Max: 2047}, {Min: 8000, Max: 8100}, {Min: 65535, Max: 65535}}
+	s := newPortSet(ranges)
+	for p := 0; p <= 65535; p++ {
+		if got, want := s.contains(p), matchPort(p, ranges); got != want {
+			t.Fatalf("port %d: contains = %v, want %v", p, got, want)
+		}
+	}
+	if s.contains(-1) || s.contains(65536) {
+		t.Fatal("out of range port matched")
+	}
+	if newPortSet(nil) != nil {
+		t.Fatal("empty port set is not nil")
+	}
+}
+
+// 索引后的结果与逐条线性匹配一致
+func TestIndexMatchesLinearScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	rules := randomRules(rnd, 500)
+	e := load(t, ActionAllow, rules...)
+	sorted := e.index.Load().rules
+
+	for i := 0; i < 2000; i++ {
+		ctx := randomCtx(rnd)
+
+		var want *CompiledRule
+		for _, r := range sorted {
+			if linearMatch(r, ctx) {
+				want = r
+				break
+			}
+		}
+		if got := e.MatchRule(ctx); got != want {
+			t.Fatalf("%s %s:%d -> %s:%d: got %v, want %v", ctx.Direction, ctx.SrcIP, ctx.SrcPort, ctx.DstIP, ctx.DstPort, ruleID(got), ruleID(want))
+		}
+	}
+}
+
+func ruleID(r *CompiledRule) any {
+	if r == nil {
+		return nil
+	}
+	return r.ID
+}
+
+// linearMatch 不使用编译形式的参考实现
+func linearMatch(r *CompiledRule, ctx *traffic.PacketContext) bool {
+	if r.Direction != traffic.DirectionUnknown && r.Direction != ctx.Direction {
+		return false
+	}
+	if len(r.SrcIPNets) > 0 && (ctx.SrcIP == nil || !matchIPNet(ctx.SrcIP, r.SrcIPNets)) {
+		return false
+	}
+	if len(r.DstIPNets) > 0 && (ctx.DstIP == nil || !matchIPNet(ctx.DstIP, r.DstIPNets)) {
+		return false
+	}
+	if len(r.SrcPorts) > 0 && (ctx.SrcPort == 0 || !matchPort(ctx.SrcPort, r.SrcPorts)) {
+		return false
+	}
+	if len(r.DstPorts) > 0 && (ctx.DstPort == 0 || !matchPort(ctx.DstPort, r.DstPorts)) {
+		return false
+	}
+	return true
+}
+
+func randomRules(rnd *rand.Rand, n int) []Rule {
+	rules := make([]Rule, n)
+	for i := range rules {
+		r := Rule{ID: int64(i + 1), Priority: rnd.Intn(50), Action: ActionDeny, Enabled: true}
+		if rnd.Intn(4) == 0 {
+			r.Action = ActionAllow
+		}
+		if rnd.Intn(5) == 0 {
+			r.Direction = traffic.Direction(1 + rnd.Intn(2))
+		}
+		for j := rnd.Intn(3); j > 0; j-- {
+			r.DstCIDR = append(r.DstCIDR, randomCIDR(rnd))
+		}
+		if rnd.Intn(3) == 0 {
+			r.SrcCIDR = append(r.SrcCIDR, randomCIDR(rnd))
+		}
+		if rnd.Intn(3) == 0 {
+			lo := rnd.Intn(1024)
+			r.DstPort = []PortRange{{Min: lo, Max: lo + rnd.Intn(300)}}
+		}
+		rules[i] = r
+	}
+	return rules
+}
+
+func randomCIDR(rnd *rand.Rand) string {
+	if rnd.Intn(4) == 0 {
+		return fmt.Sprintf("2001:db8:%x::/%d", rnd.Intn(4), 32+rnd.Intn(33))
+	}
+	return fmt.Sprintf("10.%d.%d.0/%d", rnd.Intn(4), rnd.Intn(4), 8+rnd.Intn(17))
+}
+
+func randomCtx(rnd *rand.Rand) *traffic.PacketContext {
+	ip := func() net.IP {
+		if rnd.Intn(4) == 0 {
+			return net.ParseIP(fmt.Sprintf("2001:db8:%x::%x", rnd.Intn(4), rnd.Intn(16)))
+		}
+		return net.IPv4(10, byte(rnd.Intn(4)), byte(rnd.Intn(4)), byte(rnd.Intn(4)))
+	}
+	src := &net.TCPAddr{IP: ip(), Port: 40000 + rnd.Intn(100)}
+	dst := &net.TCPAddr{IP: ip(), Port: rnd.Intn(1400)}
+	return traffic.NewCtx("c1", traffic.Direction(1+rnd.Intn(2)), traffic.ProtocolTCP, src, dst)
+}
+
+func benchmarkEvaluate(b *testing.B, n int) {
+	rnd := rand.New(rand.NewSource(1))
+	rules := make([]Rule, n)
+	for i := range rules {
+		// 大部分是单个 IP 的阻断名单，少量网段 / 端口规则
+		r := Rule{ID: int64(i + 1), Priority: rnd.Intn(100), Action: ActionDeny, Enabled: true}
+		switch {
+		case i%100 == 0:
+			r.DstCIDR = []string{fmt.Sprintf("%d.%d.0.0/16", 1+rnd.Intn(200), rnd.Intn(256))}
+			r.DstPort = []PortRange{{Min: 8000, Max: 9000}}
+		default:
+			r.DstCIDR = []string{fmt.Sprintf("%d.%d.%d.%d/32", 1+rnd.Intn(200), rnd.Intn(256), rnd.Intn(256), rnd.Intn(256))}
+		}
+		rules[i] = r
+	}
+	e := NewEngine()
+	if err := e.Load(Config{Enabled: true, DefaultAction: ActionAllow}, rules); err != nil {
+		b.Fatal(err)
+	}
+
+	ctxs := make([]*traffic.PacketContext, 1024)
+	for i := range ctxs {
+		ctxs[i] = randomCtx(rnd)
+		ctxs[i].DstIP = net.IPv4(byte(1+rnd.Intn(200)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Evaluate(ctxs[i&1023])
+	}
+}
+
+func BenchmarkEvaluate10(b *testing.B)   { benchmarkEvaluate(b, 10) }
+func BenchmarkEvaluate1k(b *testing.B)   { benchmarkEvaluate(b, 1000) }
+func BenchmarkEvaluate100k(b *testing.B) { benchmarkEvaluate(b, 100000) }
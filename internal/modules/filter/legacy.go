@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"proxy-system-backend/internal/types"
+	"slices"
+	"strconv"
+)
+
+// LegacyTagPrefix 迁移后的规则带 "legacy:<旧规则 ID>" 标签，重复迁移时跳过
+const LegacyTagPrefix = "legacy:"
+
+// LegacyRule 旧版 packet.PacketFilter 的规则（filter_rules 表或 filter_config.rules）
+type LegacyRule struct {
+	ID          int                   `json:"id"`
+	Name        string                `json:"name"`
+	Action      types.FilterAction    `json:"action"`
+	Direction   types.FilterDirection `json:"direction"`
+	SourceIPs   []types.IPAddress     `json:"source_ips"`
+	DestIPs     []types.IPAddress     `json:"dest_ips"`
+	SourcePorts []PortRange           `json:"source_ports"`
+	DestPorts   []PortRange           `json:"dest_ports"`
+	Enabled     bool                  `json:"enabled"`
+	Priority    int                   `json:"priority"`
+	Description string                `json:"description"`
+}
+
+// ConvertLegacyRule 按旧版的实际匹配语义转换规则，notes 说明行为有变化的地方：
+//   - 旧版方向 in 为客户端 → 服务器（traffic.DirectionOut），out 为服务器 → 客户端（DirectionIn），
+//     both 转换为不限方向（按连接匹配，结果与逐包匹配相同）
+//   - 旧版的源地址 / 端口总是与客户端比较，方向为 out 时对应新规则的 dst_ip / dst_port
+//   - 旧版没有实现目标地址 / 端口检查，转换后会生效
+//   - 旧版掩码为 32 时总是精确匹配（包括 IPv6 地址）
+func ConvertLegacyRule(l LegacyRule) (r Rule, notes []string, err error) {
+	r = Rule{
+		Name:        l.Name,
+		Description: l.Description,
+		Priority:    l.Priority,
+		Enabled:     l.Enabled,
+		Tags:        []string{LegacyTagPrefix + strconv.Itoa(l.ID)},
+	}
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("legacy-%d", l.ID)
+	}
+
+	switch l.Action {
+	case types.FilterActionAllow:
+		r.Action = ActionAllow
+	case types.FilterActionDeny:
+		r.Action = ActionDeny
+	default:
+		return Rule{}, nil, fmt.Errorf("unsupported action %q", l.Action)
+	}
+
+	switch l.Direction {
+	case types.FilterDirectionBoth:
+		r.Direction = traffic.DirectionUnknown
+	case types.FilterDirectionIn:
+		r.Direction = traffic.DirectionOut
+	case types.FilterDirectionOut:
+		r.Direction = traffic.DirectionIn
+	default:
+		return Rule{}, nil, fmt.Errorf("unsupported direction %q", l.Direction)
+	}
+
+	client, err := legacyCIDRs(l.SourceIPs)
+	if err != nil {
+		return Rule{}, nil, fmt.Errorf("source_ips: %w", err)
+	}
+	server, err := legacyCIDRs(l.DestIPs)
+	if err != nil {
+		return Rule{}, nil, fmt.Errorf("dest_ips: %w", err)
+	}
+	clientPorts, err := legacyPorts(l.SourcePorts)
+	if err != nil {
+		return Rule{}, nil, fmt.Errorf("source_ports: %w", err)
+	}
+	serverPorts, err := legacyPorts(l.DestPorts)
+	if err != nil {
+		return Rule{}, nil, fmt.Errorf("dest_ports: %w", err)
+	}
+
+	if r.Direction == traffic.DirectionIn {
+		r.SrcCIDR, r.DstCIDR = server, client
+		r.SrcPort, r.DstPort = serverPorts, clientPorts
+	} else {
+		r.SrcCIDR, r.DstCIDR = client, server
+		r.SrcPort, r.DstPort = clientPorts, serverPorts
+	}
+
+	if len(server) > 0 || len(serverPorts) > 0 {
+		notes = append(notes, "dest_ips / dest_ports were ignored by the legacy filter and are now enforced")
+	}
+	for _, a := range slices.Concat(l.SourceIPs, l.DestIPs) {
+		if ip := net.ParseIP(a.IP); ip.To4() == nil && a.Mask == 32 {
+			notes = append(notes, fmt.Sprintf("mask 32 on IPv6 address %s converted to an exact match (/128)", a.IP))
+		}
+	}
+	for _, p := range slices.Concat(l.SourcePorts, l.DestPorts) {
+		if p.Min == 0 {
+			notes = append(notes, fmt.Sprintf("port range %d-%d starts at 0; port 0 never matches and was dropped from the range", p.Min, p.Max))
+		}
+	}
+	return r, notes, nil
+}
+
+// legacyCIDRs 旧版 ip + mask 转换为 CIDR，掩码 32 为精确匹配
+func legacyCIDRs(addrs []types.IPAddress) ([]string, error) {
+	var out []string
+	for _, a := range addrs {
+		ip := net.ParseIP(a.IP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %q", a.IP)
+		}
+		if a.Mask == 32 && ip.To4() == nil {
+			a.Mask = 128
+		}
+		cidr, err := a.CIDR()
+		if err != nil {
+			return nil, err
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n.String())
+	}
+	return out, nil
+}
+
+func legacyPorts(ranges []PortRange) ([]PortRange, error) {
+	var out []PortRange
+	for _, p := range ranges {
+		if p.Min < 0 || p.Max > 65535 || p.Min > p.Max {
+			return nil, fmt.Errorf("invalid port range %d-%d", p.Min, p.Max)
+		}
+		if p.Max == 0 {
+			return nil, fmt.Errorf("port range %d-%d only contains port 0", p.Min, p.Max)
+		}
+		out = append(out, PortRange{Min: max(p.Min, 1), Max: p.Max})
+	}
+	return out, nil
+}
@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"proxy-system-backend/internal/types"
+	"slices"
+	"testing"
+)
+
+func TestConvertLegacyRule(t *testing.T) {
+	client := []types.IPAddress{{IP: "192.168.1.10", Mask: 32}}
+	server := []types.IPAddress{{IP: "10.0.0.0", Mask: 8}}
+
+	r, notes, err := ConvertLegacyRule(LegacyRule{ID: 7, Action: "deny", Direction: "both", SourceIPs: client, Enabled: true, Priority: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "legacy-7" || r.Direction != traffic.DirectionUnknown || !slices.Equal(r.SrcCIDR, []string{"192.168.1.10/32"}) || len(notes) != 0 {
+		t.Fatalf("both: %+v notes %v", r, notes)
+	}
+	if !slices.Equal(r.Tags, []string{"legacy:7"}) {
+		t.Fatalf("tags = %v", r.Tags)
+	}
+
+	// 旧版 out 为服务器 → 客户端，源地址仍是客户端
+	r, notes, err = ConvertLegacyRule(LegacyRule{ID: 8, Name: "down", Action: "allow", Direction: "out", SourceIPs: client, DestIPs: server,
+		SourcePorts: []PortRange{{Min: 0, Max: 1024}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Direction != traffic.DirectionIn || !slices.Equal(r.DstCIDR, []string{"192.168.1.10/32"}) || !slices.Equal(r.SrcCIDR, []string{"10.0.0.0/8"}) {
+		t.Fatalf("out: %+v", r)
+	}
+	if !slices.Equal(r.DstPort, []PortRange{{Min: 1, Max: 1024}}) || len(notes) != 2 {
+		t.Fatalf("out: ports %v notes %v", r.DstPort, notes)
+	}
+
+	cr, err := CompileRule(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := traffic.NewCtx("c1", traffic.DirectionIn, traffic.ProtocolTCP,
+		&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 443}, &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 1000})
+	if !cr.Match(pkt) {
+		t.Fatal("converted rule does not match a server -> client packet")
+	}
+
+	r, _, err = ConvertLegacyRule(LegacyRule{ID: 9, Action: "allow", Direction: "in", SourceIPs: []types.IPAddress{{IP: "2001:db8::1", Mask: 32}}})
+	if err != nil || r.Direction != traffic.DirectionOut || !slices.Equal(r.SrcCIDR, []string{"2001:db8::1/128"}) {
+		t.Fatalf("ipv6: %+v %v", r, err)
+	}
+
+	for _, bad := range []LegacyRule{
+		{Action: "drop", Direction: "in"},
+		{Action: "deny", Direction: "up"},
+		{Action: "deny", Direction: "in", SourceIPs: []types.IPAddress{{IP: "nope"}}},
+		{Action: "deny", Direction: "in", DestIPs: []types.IPAddress{{IP: "10.0.0.1", Mask: 40}}},
+		{Action: "deny", Direction: "in", DestPorts: []PortRange{{Min: 0, Max: 0}}},
+	} {
+		if _, _, err := ConvertLegacyRule(bad); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
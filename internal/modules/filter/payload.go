@@ -0,0 +1,308 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type PayloadType string
+
+const (
+	PayloadBytes  PayloadType = "bytes"  // 字节模式，固定偏移或任意位置
+	PayloadPrefix PayloadType = "prefix" // 字节模式，从偏移 0 开始
+	PayloadRegex  PayloadType = "regex"  // 正则
+	PayloadLength PayloadType = "length" // 负载长度范围
+)
+
+// PayloadMatch 负载匹配条件，同一规则的多个条件需全部满足
+type PayloadMatch struct {
+	Type PayloadType `json:"type"`
+
+	// bytes / prefix：十六进制，?? 表示任意字节，可含空格，如 "16 03 ?? 01"
+	// regex：按字节匹配，每个字节视为 U+0000-U+00FF，非 ASCII 字节用 \xNN 表示
+	Pattern string `json:"pattern,omitempty"`
+	// bytes / prefix：命中任意一个即可，任意位置的模式统一用 Aho-Corasick 扫描
+	Patterns []string `json:"patterns,omitempty"`
+	// bytes：固定偏移，为空时可出现在任意位置
+	Offset *int `json:"offset,omitempty"`
+
+	// length：Max=0 表示不限
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+}
+
+// Validate 检查匹配条件
+func (m PayloadMatch) Validate() error {
+	_, err := m.compile()
+	return err
+}
+
+func (m PayloadMatch) compile() (payloadMatcher, error) {
+	switch m.Type {
+	case PayloadBytes, PayloadPrefix:
+		if m.Min != 0 || m.Max != 0 {
+			return nil, fmt.Errorf("min / max only apply to type length")
+		}
+		offset := -1
+		if m.Type == PayloadPrefix {
+			if m.Offset != nil {
+				return nil, fmt.Errorf("offset does not apply to type prefix")
+			}
+			offset = 0
+		} else if m.Offset != nil {
+			if *m.Offset < 0 {
+				return nil, fmt.Errorf("offset must be >= 0")
+			}
+			offset = *m.Offset
+		}
+
+		patterns := m.Patterns
+		if m.Pattern != "" {
+			patterns = append([]string{m.Pattern}, patterns...)
+		}
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("pattern is required")
+		}
+		bm := &bytesMatcher{offset: offset}
+		for _, s := range patterns {
+			p, err := parseBytePattern(s)
+			if err != nil {
+				return nil, err
+			}
+			bm.patterns = append(bm.patterns, p)
+		}
+		return bm, nil
+
+	case PayloadRegex:
+		if len(m.Patterns) > 0 || m.Offset != nil || m.Min != 0 || m.Max != 0 {
+			return nil, fmt.Errorf("type regex only takes pattern")
+		}
+		if m.Pattern == "" {
+			return nil, fmt.Errorf("pattern is required")
+		}
+		re, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return regexMatcher{re: re}, nil
+
+	case PayloadLength:
+		if m.Pattern != "" || len(m.Patterns) > 0 || m.Offset != nil {
+			return nil, fmt.Errorf("type length only takes min / max")
+		}
+		if m.Min < 0 || m.Max < 0 || (m.Max != 0 && m.Max < m.Min) {
+			return nil, fmt.Errorf("invalid length range %d-%d", m.Min, m.Max)
+		}
+		return lengthMatcher{min: m.Min, max: m.Max}, nil
+
+	case "":
+		return nil, fmt.Errorf("type is required")
+	default:
+		return nil, fmt.Errorf("unknown payload type %q", m.Type)
+	}
+}
+
+// bytePattern 带通配符的字节模式
+type bytePattern struct {
+	data []byte
+	mask []bool // true 表示该字节需要相等
+
+	// 最长的不含通配符的片段，用于 Aho-Corasick 预筛
+	anchor    []byte
+	anchorOff int
+}
+
+// parseBytePattern 解析 "16 03 ?? 01" 形式的十六进制模式
+func parseBytePattern(s string) (*bytePattern, error) {
+	compact := strings.Join(strings.Fields(s), "")
+	if compact == "" || len(compact)%2 != 0 {
+		return nil, fmt.Errorf("invalid hex pattern %q", s)
+	}
+
+	p := &bytePattern{}
+	for i := 0; i < len(compact); i += 2 {
+		pair := compact[i : i+2]
+		if pair == "??" {
+			p.data = append(p.data, 0)
+			p.mask = append(p.mask, false)
+			continue
+		}
+		b, err := hex.DecodeString(pair)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex pattern %q", s)
+		}
+		p.data = append(p.data, b[0])
+		p.mask = append(p.mask, true)
+	}
+
+	for i := 0; i < len(p.data); {
+		if !p.mask[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(p.data) && p.mask[j] {
+			j++
+		}
+		if j-i > len(p.anchor) {
+			p.anchor, p.anchorOff = p.data[i:j], i
+		}
+		i = j
+	}
+	return p, nil
+}
+
+func (p *bytePattern) matchAt(b []byte, off int) bool {
+	if off < 0 || off+len(p.data) > len(b) {
+		return false
+	}
+	for i, c := range p.data {
+		if p.mask[i] && b[off+i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// payloadScan 单个数据包的匹配状态，Aho-Corasick 扫描与正则文本按需计算一次
+type payloadScan struct {
+	data    []byte
+	scanner *payloadScanner
+
+	scanned bool
+	hits    map[int32][]int
+
+	text    string
+	hasText bool
+}
+
+func newPayloadScan(data []byte, sc *payloadScanner) *payloadScan {
+	return &payloadScan{data: data, scanner: sc}
+}
+
+// find 模式是否出现在任意位置
+func (s *payloadScan) find(p *bytePattern) bool {
+	if s.scanner != nil {
+		if id, ok := s.scanner.ids[p]; ok {
+			if !s.scanned {
+				s.hits = s.scanner.ac.scan(s.data)
+				s.scanned = true
+			}
+			for _, start := range s.hits[id] {
+				if p.matchAt(s.data, start-p.anchorOff) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	// 不在扫描器中（如规则刚被替换），逐个查找
+	if len(p.anchor) == 0 {
+		return len(s.data) >= len(p.data)
+	}
+	for from := 0; ; {
+		i := bytes.Index(s.data[from:], p.anchor)
+		if i < 0 {
+			return false
+		}
+		if p.matchAt(s.data, from+i-p.anchorOff) {
+			return true
+		}
+		from += i + 1
+	}
+}
+
+// latin1 每个字节转换为同值的 rune，使正则可以按字节匹配
+func (s *payloadScan) latin1() string {
+	if !s.hasText {
+		var sb strings.Builder
+		sb.Grow(len(s.data))
+		for _, b := range s.data {
+			if b < utf8.RuneSelf {
+				sb.WriteByte(b)
+			} else {
+				sb.WriteRune(rune(b))
+			}
+		}
+		s.text, s.hasText = sb.String(), true
+	}
+	return s.text
+}
+
+type payloadMatcher interface {
+	match(s *payloadScan) bool
+}
+
+type bytesMatcher struct {
+	patterns []*bytePattern
+	offset   int // -1 表示任意位置
+}
+
+func (m *bytesMatcher) match(s *payloadScan) bool {
+	for _, p := range m.patterns {
+		if m.offset >= 0 {
+			if p.matchAt(s.data, m.offset) {
+				return true
+			}
+		} else if s.find(p) {
+			return true
+		}
+	}
+	return false
+}
+
+type regexMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexMatcher) match(s *payloadScan) bool {
+	return m.re.MatchString(s.latin1())
+}
+
+type lengthMatcher struct {
+	min, max int
+}
+
+func (m lengthMatcher) match(s *payloadScan) bool {
+	n := len(s.data)
+	return n >= m.min && (m.max == 0 || n <= m.max)
+}
+
+// payloadScanner 引擎内全部任意位置字节模式共用的 Aho-Corasick 自动机
+type payloadScanner struct {
+	ac  *acMatcher
+	ids map[*bytePattern]int32
+}
+
+// newPayloadScanner 没有可预筛的模式时返回 nil
+func newPayloadScanner(rules []*CompiledRule) *payloadScanner {
+	sc := &payloadScanner{ids: make(map[*bytePattern]int32)}
+	var anchors [][]byte
+	for _, r := range rules {
+		for _, m := range r.payload {
+			bm, ok := m.(*bytesMatcher)
+			if !ok || bm.offset >= 0 {
+				continue
+			}
+			for _, p := range bm.patterns {
+				if len(p.anchor) == 0 {
+					continue
+				}
+				if _, ok := sc.ids[p]; !ok {
+					sc.ids[p] = int32(len(anchors))
+					anchors = append(anchors, p.anchor)
+				}
+			}
+		}
+	}
+	if len(anchors) == 0 {
+		return nil
+	}
+	sc.ac = newACMatcher(anchors)
+	return sc
+}
@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"proxy-system-backend/internal/traffic"
+	"slices"
+	"testing"
+)
+
+func packet(payload []byte) *traffic.PacketContext {
+	ctx := ctxFor(traffic.DirectionOut)
+	ctx.Payload = payload
+	return ctx
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestPayloadMatchers(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 50, Action: ActionDeny, Payload: []PayloadMatch{{Type: PayloadPrefix, Pattern: "16 03 ?? 01"}}},
+		Rule{ID: 2, Priority: 40, Action: "tag:magic", Payload: []PayloadMatch{{Type: PayloadBytes, Pattern: "ca fe", Offset: intPtr(2)}}},
+		Rule{ID: 3, Priority: 30, Action: "decode:proto-a", Payload: []PayloadMatch{
+			{Type: PayloadBytes, Patterns: []string{"de ad ?? ef", "6c 6f 67 69 6e"}},
+			{Type: PayloadLength, Min: 6, Max: 64},
+		}},
+		Rule{ID: 4, Priority: 20, Action: "tag:http", Payload: []PayloadMatch{{Type: PayloadRegex, Pattern: `^(GET|POST) /\S*\xff`}}},
+	)
+
+	cases := []struct {
+		payload []byte
+		rules   []int64
+	}{
+		{[]byte{0x16, 0x03, 0x03, 0x01, 0x00}, []int64{1}},
+		{[]byte{0x16, 0x03, 0x03, 0x02}, nil},
+		{[]byte{0x00, 0x00, 0xca, 0xfe}, []int64{2}},
+		{[]byte{0xca, 0xfe, 0x00, 0x00}, nil},
+		{[]byte("xx\xde\xad\x00\xefyy"), []int64{3}},
+		{[]byte("user login"), []int64{3}},
+		{[]byte("login"), nil}, // 长度不足
+		{[]byte("GET /a\xff"), []int64{4}},
+		{[]byte("GET /a\xfe"), nil},
+	}
+	for _, c := range cases {
+		d := e.EvaluatePacket(packet(c.payload))
+		if !slices.Equal(d.Rules, c.rules) {
+			t.Errorf("%q: rules = %v, want %v", c.payload, d.Rules, c.rules)
+		}
+		// 不经过扫描器的匹配结果一致
+		for _, r := range e.index.Load().rules {
+			if r.Match(packet(c.payload)) != slices.Contains(c.rules, r.ID) {
+				t.Errorf("%q: rule %d direct match differs", c.payload, r.ID)
+			}
+		}
+	}
+
+	// 负载规则不参与连接级匹配
+	if d := e.Evaluate(packet([]byte{0x16, 0x03, 0x03, 0x01})); d.Verdict != ActionAllow || len(d.Rules) != 0 {
+		t.Fatalf("connection decision = %+v", d)
+	}
+}
+
+func TestACMatcherOverlapping(t *testing.T) {
+	ac := newACMatcher([][]byte{[]byte("he"), []byte("she"), []byte("hers"), []byte("e")})
+	hits := ac.scan([]byte("ushers"))
+	want := map[int32][]int{0: {2}, 1: {1}, 2: {2}, 3: {3}}
+	for id, starts := range want {
+		if !slices.Equal(hits[id], starts) {
+			t.Fatalf("pattern %d: %v, want %v", id, hits[id], starts)
+		}
+	}
+}
+
+func TestPayloadMatchValidation(t *testing.T) {
+	bad := []PayloadMatch{
+		{Type: PayloadBytes},
+		{Type: PayloadBytes, Pattern: "abc"},
+		{Type: PayloadBytes, Pattern: "zz"},
+		{Type: PayloadBytes, Pattern: "00", Offset: intPtr(-1)},
+		{Type: PayloadPrefix, Pattern: "00", Offset: intPtr(1)},
+		{Type: PayloadRegex, Pattern: "("},
+		{Type: PayloadLength, Min: 10, Max: 5},
+		{Type: "glob"},
+	}
+	for _, m := range bad {
+		if m.Validate() == nil {
+			t.Errorf("%+v: expected error", m)
+		}
+	}
+
+	_, err := RuleDTO{Name: "x", Action: "deny", Payload: bad[:1]}.ToRule()
+	verr, ok := err.(*ValidationError)
+	if !ok || verr.Fields[0].Field != "payload[0]" {
+		t.Fatalf("err = %v", err)
+	}
+}
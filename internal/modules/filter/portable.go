@@ -0,0 +1,325 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/modules/redirect"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 规则文件格式
+const (
+	FormatJSON      = "json"
+	FormatYAML      = "yaml"
+	FormatBlocklist = "blocklist" // 每行一个 IP / CIDR / 域名
+	FormatIPTables  = "iptables"  // iptables-save 的 -A ... -j DROP 子集
+)
+
+// RuleFileVersion 当前的规则文件版本
+const RuleFileVersion = 1
+
+// RuleFile 可移植的规则文件，一个文件对应一个规则集，便于放在 git 中跨团队共享。
+// 不含 ID、创建时间、过期状态等实例相关的字段，规则在规则集内以名称区分
+type RuleFile struct {
+	Version int            `json:"version"`
+	RuleSet string         `json:"rule_set,omitempty"` // 空为默认规则集
+	Rules   []PortableRule `json:"rules"`
+}
+
+// PortableRule 规则文件中的规则，字段含义同 RuleDTO；省略 enabled，停用的规则写 disabled
+type PortableRule struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Priority    int    `json:"priority,omitempty"`
+	Action      string `json:"action"`
+	Direction   string `json:"direction,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+
+	SrcIP   string `json:"src_ip,omitempty"`
+	DstIP   string `json:"dst_ip,omitempty"`
+	SrcPort string `json:"src_port,omitempty"`
+	DstPort string `json:"dst_port,omitempty"`
+	Geo     string `json:"geo,omitempty"`
+	ASN     string `json:"asn,omitempty"`
+
+	Payload []PayloadMatch `json:"payload,omitempty"`
+	Decoded []string       `json:"decoded,omitempty"`
+	Expr    string         `json:"expr,omitempty"`
+
+	Tags     []string         `json:"tags,omitempty"`
+	Mirror   *mirror.Config   `json:"mirror,omitempty"`
+	Redirect *redirect.Target `json:"redirect,omitempty"`
+
+	StartAt int64    `json:"start_at,omitempty"`
+	EndAt   int64    `json:"end_at,omitempty"`
+	Windows []string `json:"windows,omitempty"`
+	TTL     string   `json:"ttl,omitempty"`
+}
+
+// NewRuleFile 导出规则集 set 中的规则（调用方按优先级排好序）
+func NewRuleFile(set string, rules []Rule) RuleFile {
+	f := RuleFile{Version: RuleFileVersion, RuleSet: set, Rules: make([]PortableRule, 0, len(rules))}
+	for _, r := range rules {
+		f.Rules = append(f.Rules, ToPortable(r))
+	}
+	return f
+}
+
+// ToPortable 规则转换为文件格式，与 PortableRule.ToRule 互逆（ID、过期状态除外）
+func ToPortable(r Rule) PortableRule {
+	d := RuleToDTO(r, time.Time{}, time.Time{})
+	return PortableRule{
+		Name:        d.Name,
+		Description: d.Description,
+		Priority:    d.Priority,
+		Action:      d.Action,
+		Direction:   d.Direction,
+		Disabled:    !d.Enabled,
+		SrcIP:       d.SrcIP,
+		DstIP:       d.DstIP,
+		SrcPort:     d.SrcPort,
+		DstPort:     d.DstPort,
+		Geo:         d.Geo,
+		ASN:         d.ASN,
+		Payload:     d.Payload,
+		Decoded:     d.Decoded,
+		Expr:        d.Expr,
+		Tags:        d.Tags,
+		Mirror:      d.Mirror,
+		Redirect:    d.Redirect,
+		StartAt:     d.StartAt,
+		EndAt:       d.EndAt,
+		Windows:     d.Windows,
+		TTL:         d.TTL,
+	}
+}
+
+// ToRule 校验并转换为规则集 set 中的规则，失败时返回 *ValidationError
+func (p PortableRule) ToRule(set string) (Rule, error) {
+	return RuleDTO{
+		Name:        p.Name,
+		Description: p.Description,
+		Priority:    p.Priority,
+		Action:      p.Action,
+		Direction:   p.Direction,
+		Enabled:     !p.Disabled,
+		SrcIP:       p.SrcIP,
+		DstIP:       p.DstIP,
+		SrcPort:     p.SrcPort,
+		DstPort:     p.DstPort,
+		Geo:         p.Geo,
+		ASN:         p.ASN,
+		Payload:     p.Payload,
+		Decoded:     p.Decoded,
+		Expr:        p.Expr,
+		Tags:        p.Tags,
+		RuleSet:     set,
+		Mirror:      p.Mirror,
+		Redirect:    p.Redirect,
+		StartAt:     p.StartAt,
+		EndAt:       p.EndAt,
+		Windows:     p.Windows,
+		TTL:         p.TTL,
+	}.ToRule()
+}
+
+// Diff 与 q 不同的字段（JSON 字段名），空列表与未设置视为相同
+func (p PortableRule) Diff(q PortableRule) []string {
+	var fields []string
+	a, b := reflect.ValueOf(p), reflect.ValueOf(q)
+	for i := 0; i < a.NumField(); i++ {
+		fa, fb := a.Field(i), b.Field(i)
+		if fa.Kind() == reflect.Slice && fa.Len() == 0 && fb.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// ToRules 校验全部规则，规则名在文件内必须唯一；错误字段带 rules[i] 前缀
+func (f RuleFile) ToRules() ([]Rule, error) {
+	verr := &ValidationError{}
+	if f.Version > RuleFileVersion {
+		verr.add("version", fmt.Errorf("unsupported version %d", f.Version))
+	}
+	if f.RuleSet != "" {
+		if err := ValidateSetName(f.RuleSet); err != nil {
+			verr.add("rule_set", err)
+		}
+	}
+
+	rules := make([]Rule, 0, len(f.Rules))
+	names := map[string]int{}
+	for i, p := range f.Rules {
+		prefix := fmt.Sprintf("rules[%d]", i)
+		r, err := p.ToRule(f.RuleSet)
+		if err != nil {
+			ve, ok := err.(*ValidationError)
+			if !ok {
+				return nil, err
+			}
+			verr.Fields = append(verr.Fields, ve.Prefix(prefix).Fields...)
+			continue
+		}
+		if j, ok := names[r.Name]; ok {
+			verr.add(prefix+".name", fmt.Errorf("duplicate name %q (rules[%d])", r.Name, j))
+			continue
+		}
+		names[r.Name] = i
+		rules = append(rules, r)
+	}
+	if len(verr.Fields) > 0 {
+		return nil, verr
+	}
+	return rules, nil
+}
+
+// ImportPlan 规则文件导入规则集的计划，规则在规则集内按名称对应
+type ImportPlan struct {
+	Added     []Rule
+	Changed   []RuleUpdate
+	Removed   []Rule // 只在 sync 时删除文件中没有的规则
+	Unchanged int
+}
+
+// RuleUpdate 同名规则有差异：Before 为规则集中的规则，After 为文件中的规则，Fields 为不同的字段
+type RuleUpdate struct {
+	Before Rule
+	After  Rule
+	Fields []string
+}
+
+// PlanImport 比较文件中的规则 rules 和规则集中的规则 existing（按优先级排序）；
+// 规则集中有同名规则时按顺序依次对应，多出的同名规则在 sync 时删除
+func PlanImport(existing, rules []Rule, sync bool) ImportPlan {
+	byName := map[string][]Rule{}
+	for _, r := range existing {
+		byName[r.Name] = append(byName[r.Name], r)
+	}
+
+	var p ImportPlan
+	for _, r := range rules {
+		olds := byName[r.Name]
+		if len(olds) == 0 {
+			p.Added = append(p.Added, r)
+			continue
+		}
+		old := olds[0]
+		byName[r.Name] = olds[1:]
+		if fields := ToPortable(old).Diff(ToPortable(r)); len(fields) > 0 {
+			p.Changed = append(p.Changed, RuleUpdate{Before: old, After: r, Fields: fields})
+		} else {
+			p.Unchanged++
+		}
+	}
+	if sync {
+		for _, r := range existing {
+			if slices.ContainsFunc(byName[r.Name], func(o Rule) bool { return o.ID == r.ID }) {
+				p.Removed = append(p.Removed, r)
+			}
+		}
+	}
+	return p
+}
+
+// MarshalRuleFile 按 json / yaml 输出规则文件
+func MarshalRuleFile(f RuleFile, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatJSON:
+		return append(data, '\n'), nil
+	case FormatYAML:
+		return jsonToYAML(data)
+	}
+	return nil, fmt.Errorf("unsupported export format %q, expected json or yaml", format)
+}
+
+// ParseRuleFile 解析 json / yaml 规则文件，未知字段视为错误
+func ParseRuleFile(data []byte, format string) (RuleFile, error) {
+	var err error
+	switch format {
+	case FormatJSON:
+	case FormatYAML:
+		if data, err = yamlToJSON(data); err != nil {
+			return RuleFile{}, err
+		}
+	default:
+		return RuleFile{}, fmt.Errorf("unsupported rule file format %q", format)
+	}
+
+	var f RuleFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return RuleFile{}, fmt.Errorf("parse %s rule file: %w", format, err)
+	}
+	return f, nil
+}
+
+// jsonToYAML JSON 是 YAML 的子集：解析成节点后去掉流式风格重新输出，保留字段顺序
+func jsonToYAML(data []byte) ([]byte, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	var clear func(n *yaml.Node)
+	clear = func(n *yaml.Node) {
+		n.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle
+		for _, c := range n.Content {
+			clear(c)
+		}
+	}
+	clear(&n)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&n); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlToJSON YAML 转换为 JSON 后按 json 标签解析，字段名与 JSON 格式相同
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("parse yaml rule file: %w", err)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("parse yaml rule file: empty document")
+	}
+	return json.Marshal(v)
+}
+
+// splitLines 去掉 # 注释和空行，返回 (行号, 内容)；
+// inline=false 时只有以 # 开头的行是注释（iptables 规则中的 # 可能在引号内）
+func splitLines(data []byte, inline bool) (lines []string, numbers []int) {
+	for i, line := range strings.Split(string(data), "\n") {
+		if j := strings.IndexByte(line, '#'); j >= 0 && (inline || strings.TrimSpace(line[:j]) == "") {
+			line = line[:j]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+			numbers = append(numbers, i+1)
+		}
+	}
+	return lines, numbers
+}
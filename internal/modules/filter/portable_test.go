@@ -0,0 +1,250 @@
+package filter
+
+import (
+	"errors"
+	"net"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/traffic"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleFileRoundTrip(t *testing.T) {
+	offset := 0
+	rules := []Rule{
+		{
+			Name: "block-update", Description: "no: 1", Action: ActionDeny, Direction: traffic.DirectionOut, Priority: 100, Enabled: true,
+			SrcCIDR: []string{"192.168.0.0/16"}, DstCIDR: []string{"10.0.0.1/32", "2001:db8::/32"},
+			SrcPort: []PortRange{{Min: 1024, Max: 65535}}, DstPort: []PortRange{{Min: 80, Max: 80}, {Min: 8000, Max: 9000}},
+			Geo: []string{"CN", "JP"}, ASN: []uint32{13335},
+			Payload: []PayloadMatch{{Type: PayloadBytes, Pattern: "16 03", Offset: &offset}},
+			Expr:    `domain ~ "*.example.com"`,
+			Tags:    []string{"team:qa", "true", "443"},
+			Set:     "team-qa",
+			StartAt: time.Unix(1700000000, 0), EndAt: time.Unix(1800000000, 0),
+			Windows: []string{"0 9 * * 1-5 8h"},
+			TTL:     90 * time.Minute,
+		},
+		{Name: "decoded", Action: ActionAlert, Decoded: []string{`msg.type == "Login"`}, Set: "team-qa"},
+		{Name: "mirror", Action: ActionMirror, Priority: -5, Enabled: true, Set: "team-qa", Mirror: &mirror.Config{Target: "tcp://127.0.0.1:9000"}},
+	}
+
+	for _, format := range []string{FormatJSON, FormatYAML} {
+		data, err := MarshalRuleFile(NewRuleFile("team-qa", rules), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := ParseRuleFile(data, format)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, data)
+		}
+		got, err := f.ToRules()
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, data)
+		}
+		if !reflect.DeepEqual(got, rules) {
+			t.Fatalf("%s round trip:\n got %+v\nwant %+v\n%s", format, got, rules, data)
+		}
+	}
+}
+
+func TestParseRuleFileYAML(t *testing.T) {
+	f, err := ParseRuleFile([]byte(`
+version: 1
+rules:
+  - name: ssh
+    action: deny
+    dst_port: "22"
+    tags: [ops]
+  - name: ssh
+    action: allow
+`), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.ToRules()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != "rules[1].name" {
+		t.Fatalf("err = %v", err)
+	}
+
+	if _, err := ParseRuleFile([]byte("rules:\n  - name: x\n    acton: deny\n"), FormatYAML); err == nil || !strings.Contains(err.Error(), "acton") {
+		t.Fatalf("unknown field: err = %v", err)
+	}
+}
+
+func TestParseBlocklist(t *testing.T) {
+	f, err := ParseBlocklist([]byte(`
+# comment
+10.0.0.1
+192.168.0.0/16   # lan
+Ads.Example.com.
+*.tracker.net
+0.0.0.0 localhost bad.example.org
+10.0.0.1
+`), BlocklistOptions{Tags: []string{"blocklist"}, Priority: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range f.Rules {
+		names = append(names, p.Name)
+	}
+	if want := []string{"10.0.0.1/32", "192.168.0.0/16", "ads.example.com", "*.tracker.net", "bad.example.org"}; !slices.Equal(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+
+	rules, err := f.ToRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine()
+	if err := e.Load(Config{DefaultAction: ActionAllow}, rules); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		dst    string
+		domain string
+		want   Action
+	}{
+		{"10.0.0.1", "", ActionDeny},
+		{"10.0.0.2", "", ActionAllow},
+		{"8.8.8.8", "ads.example.com", ActionDeny},
+		{"8.8.8.8", "x.ads.example.com", ActionDeny},
+		{"8.8.8.8", "tracker.net", ActionAllow},
+		{"8.8.8.8", "a.tracker.net", ActionDeny},
+	} {
+		ctx := traffic.NewCtx("c1", traffic.DirectionOut, traffic.ProtocolTCP,
+			&net.TCPAddr{IP: net.ParseIP("172.16.0.1"), Port: 50000}, &net.TCPAddr{IP: net.ParseIP(c.dst), Port: 443})
+		ctx.Domain = c.domain
+		if d := e.Evaluate(ctx); d.Verdict != c.want {
+			t.Errorf("%s %s: verdict %s, want %s", c.dst, c.domain, d.Verdict, c.want)
+		}
+	}
+
+	_, err = ParseBlocklist([]byte("ok.com\nnot a domain\nbad_.com/\n"), BlocklistOptions{})
+	if !errors.As(err, new(*ValidationError)) || !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestParseIPTables(t *testing.T) {
+	f, notes, err := ParseIPTables([]byte(`
+*filter
+:INPUT ACCEPT [0:0]
+-A INPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+-A INPUT -s 10.0.0.5 -p tcp -m tcp --dport 22 -j ACCEPT
+-A INPUT -p tcp -m multiport --dports 22,1000:2000 -m comment --comment "block ssh" -j REJECT --reject-with tcp-reset
+-A FORWARD -d 192.168.1.0/24 -j DROP
+-A INPUT -i eth0 -j DROP
+-A INPUT -j MYCHAIN
+  # -A INPUT -j DROP
+-A INPUT -s 1.2.3.4 -m comment --comment "ticket #42" -j DROP
+COMMIT
+`), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 3 || !strings.HasPrefix(notes[0], "line 4:") {
+		t.Fatalf("notes = %v", notes)
+	}
+	want := []PortableRule{
+		{Name: "-s 10.0.0.5/32 -p tcp --dport 22 -j ACCEPT", Action: "allow", Priority: 130, SrcIP: "10.0.0.5/32", DstPort: "22", Expr: "proto == tcp"},
+		{Name: "block ssh", Description: "-p tcp --dport 22,1000-2000 -j REJECT", Action: "reset", Priority: 120, DstPort: "22,1000-2000", Expr: "proto == tcp"},
+		{Name: "-d 192.168.1.0/24 -j DROP", Action: "deny", Priority: 110, DstIP: "192.168.1.0/24"},
+		{Name: "ticket #42", Description: "-s 1.2.3.4/32 -j DROP", Action: "deny", Priority: 100, SrcIP: "1.2.3.4/32"},
+	}
+	if !reflect.DeepEqual(f.Rules, want) {
+		t.Fatalf("rules:\n got %+v\nwant %+v", f.Rules, want)
+	}
+	if _, err := f.ToRules(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ParseIPTables([]byte("-A INPUT -s 10.0.0.300 -j DROP\n"), 0); err == nil {
+		t.Fatal("expected error for bad address")
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	existing := []Rule{
+		{ID: 1, Name: "ssh", Action: ActionDeny, DstPort: []PortRange{{Min: 22, Max: 22}}, Enabled: true},
+		{ID: 2, Name: "old-name", Action: ActionLog, Enabled: true},
+		{ID: 3, Name: "dup", Action: ActionAlert, Priority: 10, Enabled: true},
+		{ID: 4, Name: "dup", Action: ActionAlert, Priority: 5, Enabled: true},
+		{ID: 5, Name: "tags", Action: ActionLog, Tags: nil, Windows: []string{}, Enabled: true},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		rules     []Rule
+		sync      bool
+		added     []string
+		changed   map[int64][]string // 规则 ID → 不同的字段
+		removed   []int64
+		unchanged int
+	}{
+		{
+			name: "renamed",
+			rules: []Rule{
+				{Name: "new-name", Action: ActionLog, Enabled: true},
+			},
+			sync:    true,
+			added:   []string{"new-name"},
+			removed: []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name: "duplicate names in store",
+			rules: []Rule{
+				{Name: "dup", Action: ActionAlert, Priority: 10, Enabled: true},
+			},
+			unchanged: 1,
+		},
+		{
+			name: "duplicate names in store with sync",
+			rules: []Rule{
+				{Name: "dup", Action: ActionAlert, Priority: 7, Enabled: true},
+			},
+			sync:    true,
+			changed: map[int64][]string{3: {"priority"}},
+			removed: []int64{1, 2, 4, 5},
+		},
+		{
+			name: "nil and empty slices are equal",
+			rules: []Rule{
+				{Name: "tags", Action: ActionLog, Tags: []string{}, Windows: nil, Enabled: true},
+				{Name: "ssh", Action: ActionDeny, DstPort: []PortRange{{Min: 22, Max: 22}}, Enabled: false},
+			},
+			changed:   map[int64][]string{1: {"disabled"}},
+			unchanged: 1,
+		},
+	} {
+		p := PlanImport(existing, tc.rules, tc.sync)
+
+		var added []string
+		for _, r := range p.Added {
+			added = append(added, r.Name)
+		}
+		changed := map[int64][]string{}
+		for _, c := range p.Changed {
+			if c.After.Name != c.Before.Name {
+				t.Errorf("%s: %q matched %q", tc.name, c.After.Name, c.Before.Name)
+			}
+			changed[c.Before.ID] = c.Fields
+		}
+		var removed []int64
+		for _, r := range p.Removed {
+			removed = append(removed, r.ID)
+		}
+		if tc.changed == nil {
+			tc.changed = map[int64][]string{}
+		}
+		if !slices.Equal(added, tc.added) || !reflect.DeepEqual(changed, tc.changed) ||
+			!slices.Equal(removed, tc.removed) || p.Unchanged != tc.unchanged {
+			t.Errorf("%s: added=%v changed=%v removed=%v unchanged=%d", tc.name, added, changed, removed, p.Unchanged)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package filter
+
+// portSet 端口位图，两级存放：65536 个端口分为 256 块，
+// 整块命中只记一位，部分命中的块才分配 256 位，单个端口 O(1) 判断
+type portSet struct {
+	full  [4]uint64   // 整块命中的块
+	index [256]uint16 // 块 → parts 下标 + 1，0 表示该块没有端口
+	parts [][4]uint64 // 部分命中的块
+}
+
+func newPortSet(ranges []PortRange) *portSet {
+	if len(ranges) == 0 {
+		return nil
+	}
+	s := &portSet{}
+	for _, r := range ranges {
+		lo, hi := max(r.Min, 0), min(r.Max, 65535)
+		for p := lo; p <= hi; {
+			block := p >> 8
+			end := min(hi, block<<8|0xff)
+			if p&0xff == 0 && end&0xff == 0xff {
+				s.full[block>>6] |= 1 << (block & 63)
+			} else {
+				part := s.part(block)
+				for q := p; q <= end; q++ {
+					part[(q&0xff)>>6] |= 1 << (q & 63)
+				}
+			}
+			p = end + 1
+		}
+	}
+	return s
+}
+
+func (s *portSet) part(block int) *[4]uint64 {
+	if i := s.index[block]; i != 0 {
+		return &s.parts[i-1]
+	}
+	s.parts = append(s.parts, [4]uint64{})
+	s.index[block] = uint16(len(s.parts))
+	return &s.parts[len(s.parts)-1]
+}
+
+func (s *portSet) contains(port int) bool {
+	if port < 0 || port > 65535 {
+		return false
+	}
+	block := port >> 8
+	if s.full[block>>6]&(1<<(block&63)) != 0 {
+		return true
+	}
+	i := s.index[block]
+	return i != 0 && s.parts[i-1][(port&0xff)>>6]&(1<<(port&63)) != 0
+}
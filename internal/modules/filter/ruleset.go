@@ -0,0 +1,51 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 规则集：规则按 Rule.Set 分组。默认规则集（空名）对所有连接生效，
+// 命名规则集只对 PacketContext.RuleSets 中列出的连接生效（由应用层按
+// 全局 / 用户 / 代理分配解析）。适用的规则集合并为一个规则列表，仍按优先级求值
+
+const maxSetNameLen = 64
+
+// ValidateSetName 规则集名称：1–64 个字母、数字、'-'、'_'、'.'
+func ValidateSetName(name string) error {
+	if name == "" || len(name) > maxSetNameLen {
+		return fmt.Errorf("name must be 1-%d characters", maxSetNameLen)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("name %q may only contain letters, digits, '-', '_' and '.'", name)
+		}
+	}
+	return nil
+}
+
+// AppliesTo 规则所在规则集是否适用于 sets（默认规则集总是适用）
+func (r *CompiledRule) AppliesTo(sets []string) bool {
+	return r.Set == "" || slices.Contains(sets, r.Set)
+}
+
+// selection 默认规则集加上 sets 中各规则集的规则位图，按规则集组合缓存；
+// 没有命名规则集时返回 nil（不需要过滤）
+func (x *ruleIndex) selection(sets []string) bitset {
+	if len(x.sets) == 0 {
+		return nil
+	}
+	key := strings.Join(sets, "\x00")
+	if b, ok := x.selections.Load(key); ok {
+		return b.(bitset)
+	}
+
+	b := make(bitset, len(x.every))
+	copy(b, x.unnamed)
+	for _, name := range sets {
+		b.or(x.sets[name])
+	}
+	x.selections.Store(key, b)
+	return b
+}
@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleSetsSelectRules(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 30, Action: ActionDeny, Set: "strict"},
+		Rule{ID: 2, Priority: 20, Action: ActionLog, Set: "audit"},
+		Rule{ID: 3, Priority: 10, Action: ActionReset},
+	)
+
+	cases := []struct {
+		sets []string
+		want int64
+		log  bool
+	}{
+		{nil, 3, false},
+		{[]string{"audit"}, 3, true},
+		{[]string{"strict", "audit"}, 1, false},
+		{[]string{"unknown"}, 3, false},
+	}
+	for _, c := range cases {
+		ctx := ctxFor(0)
+		ctx.RuleSets = c.sets
+		d := e.Evaluate(ctx)
+		if d.RuleID != c.want || d.Log != c.log {
+			t.Errorf("sets %v: decision %+v, want rule %d log %v", c.sets, d, c.want, c.log)
+		}
+	}
+
+	ctx := ctxFor(0)
+	ctx.RuleSets = []string{"audit"}
+	tr := e.Trace(ctx, nil)
+	if tr.Steps[0].Evaluated || !strings.Contains(tr.Steps[0].Note, `"strict"`) {
+		t.Fatalf("trace step = %+v", tr.Steps[0])
+	}
+	if tr.Decision.RuleID != 3 {
+		t.Fatalf("trace decision = %+v", tr.Decision)
+	}
+}
+
+func TestValidateSetName(t *testing.T) {
+	for _, ok := range []string{"strict", "team-a_1.v2"} {
+		if err := ValidateSetName(ok); err != nil {
+			t.Errorf("%q: %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "a b", "中文", strings.Repeat("x", 65)} {
+		if ValidateSetName(bad) == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+	if _, err := (RuleDTO{Name: "x", Action: "deny", RuleSet: "a/b"}).ToRule(); err == nil {
+		t.Fatal("invalid rule_set accepted")
+	}
+}
@@ -0,0 +1,203 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 周期窗口的最长持续时间
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// Window 周期生效窗口："<分> <时> <日> <月> <周> <持续时间>"，
+// 前五段与 cron 相同（支持 *、a-b、a,b、*/n、a-b/n，周日为 0 或 7），
+// 每次触发后持续一段时间，如 "0 9 * * 1-5 8h" 表示工作日 9:00–17:00（本地时间）
+type Window struct {
+	minute, hour, dom, month, dow uint64
+	// 日、周都有限制时满足其一即可（与 cron 相同）
+	domStar, dowStar bool
+	dur              time.Duration
+	src              string
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func ParseWindow(s string) (*Window, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("window %q: expected \"<minute> <hour> <day> <month> <weekday> <duration>\"", s)
+	}
+
+	w := &Window{src: strings.Join(parts, " ")}
+	sets := []*uint64{&w.minute, &w.hour, &w.dom, &w.month, &w.dow}
+	for i, f := range cronFields {
+		set, err := parseCronField(parts[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %s: %w", s, f.name, err)
+		}
+		*sets[i] = set
+	}
+	if w.dow&(1<<7) != 0 {
+		w.dow |= 1
+	}
+	w.domStar = strings.HasPrefix(parts[2], "*")
+	w.dowStar = strings.HasPrefix(parts[4], "*")
+
+	dur, err := time.ParseDuration(parts[5])
+	if err != nil {
+		return nil, fmt.Errorf("window %q: %w", s, err)
+	}
+	if dur < time.Minute || dur > maxWindowDuration || dur%time.Minute != 0 {
+		return nil, fmt.Errorf("window %q: duration must be whole minutes between 1m and %s", s, maxWindowDuration)
+	}
+	w.dur = dur
+	return w, nil
+}
+
+func parseCronField(s string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", item)
+			}
+			step = n
+		}
+
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", item)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q out of range %d-%d", item, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (w *Window) String() string {
+	return w.src
+}
+
+// fires t（整分钟）是否为一次触发
+func (w *Window) fires(t time.Time) bool {
+	if w.minute&(1<<t.Minute()) == 0 || w.hour&(1<<t.Hour()) == 0 || w.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := w.dom&(1<<t.Day()) != 0
+	dow := w.dow&(1<<int(t.Weekday())) != 0
+	if w.domStar || w.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Active t 是否落在某次触发后的持续时间内
+func (w *Window) Active(t time.Time) bool {
+	start := t.Truncate(time.Minute)
+	for s := start; t.Sub(s) < w.dur; s = s.Add(-time.Minute) {
+		if w.fires(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule 规则的生效时间，零值表示不限
+type schedule struct {
+	start, end time.Time
+	expires    time.Time
+	windows    []*Window
+}
+
+func compileSchedule(r Rule) (*schedule, error) {
+	s := &schedule{start: r.StartAt, end: r.EndAt, expires: r.ExpiresAt}
+	for i, expr := range r.Windows {
+		w, err := ParseWindow(expr)
+		if err != nil {
+			return nil, fmt.Errorf("windows[%d]: %w", i, err)
+		}
+		s.windows = append(s.windows, w)
+	}
+	if s.start.IsZero() && s.end.IsZero() && s.expires.IsZero() && len(s.windows) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (s *schedule) active(t time.Time) bool {
+	if !s.start.IsZero() && t.Before(s.start) {
+		return false
+	}
+	if s.expired(t) {
+		return false
+	}
+	if len(s.windows) == 0 {
+		return true
+	}
+	for _, w := range s.windows {
+		if w.Active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// expired 结束时间或 TTL 已过，之后不会再生效
+func (s *schedule) expired(t time.Time) bool {
+	return (!s.end.IsZero() && !t.Before(s.end)) || (!s.expires.IsZero() && !t.Before(s.expires))
+}
+
+// next t 之后生效状态可能变化的最早时间；有周期窗口时为下一个整分钟
+func (s *schedule) next(t time.Time) time.Time {
+	var next time.Time
+	for _, at := range []time.Time{s.start, s.end, s.expires} {
+		if !at.IsZero() && at.After(t) && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	if len(s.windows) > 0 {
+		if m := t.Truncate(time.Minute).Add(time.Minute); next.IsZero() || m.Before(next) {
+			next = m
+		}
+	}
+	return next
+}
+
+// Active 规则在 t 时是否生效（没有生效时间的规则总是生效）
+func (r *CompiledRule) Active(t time.Time) bool {
+	return r.schedule == nil || r.schedule.active(t)
+}
+
+// Expired 规则的结束时间或 TTL 已过
+func (r *CompiledRule) Expired(t time.Time) bool {
+	return r.schedule != nil && r.schedule.expired(t)
+}
@@ -0,0 +1,116 @@
+package filter
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWindowActive(t *testing.T) {
+	loc := time.UTC
+	at := func(day, hour, min int) time.Time {
+		// 2025-01-06 是星期一
+		return time.Date(2025, 1, day, hour, min, 30, 0, loc)
+	}
+	cases := []struct {
+		window string
+		t      time.Time
+		want   bool
+	}{
+		{"0 9 * * 1-5 8h", at(6, 9, 0), true},
+		{"0 9 * * 1-5 8h", at(6, 16, 59), true},
+		{"0 9 * * 1-5 8h", at(6, 17, 0), false},
+		{"0 9 * * 1-5 8h", at(6, 8, 59), false},
+		{"0 9 * * 1-5 8h", at(11, 10, 0), false}, // 星期六
+		{"0 22 * * 5 4h", at(11, 1, 0), true},    // 跨过午夜
+		{"*/15 * * * * 5m", at(6, 12, 37), false},
+		{"*/15 * * * * 5m", at(6, 12, 49), true},
+		{"0 0 1 * 0 1h", at(12, 0, 10), true}, // 日、周都有限制时满足其一
+		{"0 0 1 * 7 1h", at(12, 0, 10), true}, // 周日可写 7
+	}
+	for _, c := range cases {
+		w, err := ParseWindow(c.window)
+		if err != nil {
+			t.Fatalf("%s: %v", c.window, err)
+		}
+		if got := w.Active(c.t); got != c.want {
+			t.Errorf("%s at %s: active = %v, want %v", c.window, c.t.Format("Mon 15:04"), got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"0 9 * * 1-5", "60 9 * * * 1h", "0 9 * * 1-5 30s", "0 9 * * * 90s", "0 9 5-1 * * 1h", "0 9 * * * 8d"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestEngineTickActivatesAndExpires(t *testing.T) {
+	now := time.Now()
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 10, Action: ActionDeny, StartAt: now.Add(time.Minute)},
+		Rule{ID: 2, Priority: 5, Action: ActionReset, EndAt: now.Add(2 * time.Minute)},
+		Rule{ID: 3, Priority: 1, Action: ActionLog, ExpiresAt: now.Add(-time.Second)},
+	)
+	ctx := ctxFor(0)
+
+	if d := e.Evaluate(ctx); d.RuleID != 2 {
+		t.Fatalf("before start: %+v", d)
+	}
+	if e.ActiveCount() != 1 {
+		t.Fatalf("active = %d", e.ActiveCount())
+	}
+
+	// 加载时已过期的规则在第一次 Tick 报告
+	changed, expired := e.Tick(time.Now())
+	if len(changed) != 0 || !slices.Equal(expired, []int64{3}) {
+		t.Fatalf("first tick: changed %v expired %v", changed, expired)
+	}
+	if changed, expired := e.Tick(now.Add(time.Second)); changed != nil || expired != nil {
+		t.Fatalf("nothing due: changed %v expired %v", changed, expired)
+	}
+
+	changed, _ = e.Tick(now.Add(time.Minute))
+	if !slices.Equal(changed, []int64{1}) {
+		t.Fatalf("start: changed %v", changed)
+	}
+	if d := e.Evaluate(ctx); d.RuleID != 1 {
+		t.Fatalf("after start: %+v", d)
+	}
+
+	changed, expired = e.Tick(now.Add(3 * time.Minute))
+	if !slices.Equal(changed, []int64{2}) || !slices.Equal(expired, []int64{2}) {
+		t.Fatalf("end: changed %v expired %v", changed, expired)
+	}
+	if s := e.Stats(); len(s.Rules) != 3 {
+		t.Fatalf("stats should list inactive rules: %+v", s.Rules)
+	}
+}
+
+func TestRuleDTOSchedule(t *testing.T) {
+	in := RuleDTO{Name: "window", Action: "capture", StartAt: 1700000000, EndAt: 1700003600, Windows: []string{"0 9 * * 1-5 8h"}, TTL: "30m0s"}
+	r, err := in.ToRule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TTL != 30*time.Minute || r.StartAt.Unix() != in.StartAt || r.EndAt.Unix() != in.EndAt {
+		t.Fatalf("rule = %+v", r)
+	}
+	out := RuleToDTO(r, time.Time{}, time.Time{})
+	if out.StartAt != in.StartAt || out.EndAt != in.EndAt || out.TTL != in.TTL || !slices.Equal(out.Windows, in.Windows) {
+		t.Fatalf("round trip = %+v", out)
+	}
+
+	_, err = RuleDTO{Name: "bad", Action: "log", StartAt: 10, EndAt: 5, Windows: []string{"x"}, TTL: "-1m"}.ToRule()
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Fields) != 3 {
+		t.Fatalf("err = %v", err)
+	}
+
+	// TTL 按秒保存
+	for _, ttl := range []string{"500ms", "1.5s"} {
+		if _, err := (RuleDTO{Name: "ttl", Action: "log", TTL: ttl}).ToRule(); err == nil {
+			t.Errorf("ttl %q: expected error", ttl)
+		}
+	}
+}
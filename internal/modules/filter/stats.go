@@ -0,0 +1,153 @@
+package filter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RuleStats 单条规则（或默认动作）的命中统计
+type RuleStats struct {
+	RuleID int64  `json:"rule_id"`
+	Name   string `json:"name,omitempty"`
+	Action Action `json:"action"`
+	// 所属规则集，空为默认规则集
+	RuleSet string `json:"rule_set,omitempty"`
+
+	// 连接级规则按连接计数，逐包规则按数据包计数
+	Hits uint64 `json:"hits"`
+	// 规则生效的数据包负载字节数
+	Bytes uint64 `json:"bytes"`
+	// 最后一次命中（Unix 毫秒），0 表示从未命中
+	LastHit int64 `json:"last_hit,omitempty"`
+}
+
+// Stats 过滤引擎统计，Rules 按优先级排序
+type Stats struct {
+	Rules   []RuleStats `json:"rules"`
+	Default RuleStats   `json:"default"`
+
+	Connections uint64 `json:"connections"` // 连接级匹配次数
+	Packets     uint64 `json:"packets"`     // 逐包匹配次数
+
+	// 统计起点（Unix 秒）
+	Since int64 `json:"since"`
+}
+
+// Total 各项计数之和，只用于判断统计是否有变化
+func (s Stats) Total() uint64 {
+	n := s.Connections + s.Packets + s.Default.Hits + s.Default.Bytes
+	for _, r := range s.Rules {
+		n += r.Hits + r.Bytes
+	}
+	return n
+}
+
+type ruleCounter struct {
+	hits    atomic.Uint64
+	bytes   atomic.Uint64
+	lastHit atomic.Int64
+}
+
+func (c *ruleCounter) hit() {
+	c.hits.Add(1)
+	c.lastHit.Store(time.Now().UnixMilli())
+}
+
+func (c *ruleCounter) reset() {
+	c.hits.Store(0)
+	c.bytes.Store(0)
+	c.lastHit.Store(0)
+}
+
+func (c *ruleCounter) snapshot(s *RuleStats) {
+	s.Hits = c.hits.Load()
+	s.Bytes = c.bytes.Load()
+	s.LastHit = c.lastHit.Load()
+}
+
+// counterSet 规则 ID → 计数器，Replace 时重建（同 ID 的计数器沿用），之后只读
+type counterSet map[int64]*ruleCounter
+
+func (e *Engine) count(r *CompiledRule) {
+	if c := (*e.counters.Load())[r.ID]; c != nil {
+		c.hit()
+	}
+}
+
+// Count 把 Decide 的连接级结果计入统计：连接数、命中的规则、默认动作
+func (e *Engine) Count(d Decision) {
+	e.connEvals.Add(1)
+	cs := *e.counters.Load()
+	for _, id := range d.Rules {
+		if c := cs[id]; c != nil {
+			c.hit()
+		}
+	}
+	if d.Default {
+		e.defaultHits.hit()
+	}
+}
+
+// Account 为决定当前数据包的规则累计负载字节数（每个数据包调用一次）
+func (e *Engine) Account(d Decision, n int) {
+	if n <= 0 {
+		return
+	}
+	cs := *e.counters.Load()
+	for _, id := range d.Rules {
+		if c := cs[id]; c != nil {
+			c.bytes.Add(uint64(n))
+		}
+	}
+	if d.Default {
+		e.defaultHits.bytes.Add(uint64(n))
+	}
+}
+
+// Stats 当前规则的统计快照
+func (e *Engine) Stats() Stats {
+	cs := *e.counters.Load()
+	rules := e.index.Load().all
+
+	s := Stats{
+		Rules:       make([]RuleStats, 0, len(rules)),
+		Default:     RuleStats{Action: e.defaultAction.Load().(Action)},
+		Connections: e.connEvals.Load(),
+		Packets:     e.packetEvals.Load(),
+		Since:       e.statsSince.Load(),
+	}
+	for _, r := range rules {
+		rs := RuleStats{RuleID: r.ID, Name: r.Name, Action: r.Action, RuleSet: r.Set}
+		if c := cs[r.ID]; c != nil {
+			c.snapshot(&rs)
+		}
+		s.Rules = append(s.Rules, rs)
+	}
+	e.defaultHits.snapshot(&s.Default)
+	return s
+}
+
+// ResetStats 清零全部计数
+func (e *Engine) ResetStats() {
+	for _, c := range *e.counters.Load() {
+		c.reset()
+	}
+	e.defaultHits.reset()
+	e.connEvals.Store(0)
+	e.packetEvals.Store(0)
+	e.statsSince.Store(time.Now().Unix())
+}
+
+// replaceCounters 为新规则集准备计数器，沿用同 ID 规则的计数
+func (e *Engine) replaceCounters(rules []*CompiledRule) {
+	old := *e.counters.Load()
+	next := make(counterSet, len(rules))
+	for _, r := range rules {
+		if c := old[r.ID]; c != nil {
+			next[r.ID] = c
+		} else {
+			next[r.ID] = &ruleCounter{}
+		}
+	}
+	e.counters.Store(&next)
+}
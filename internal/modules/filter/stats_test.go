@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"proxy-system-backend/internal/traffic"
+	"testing"
+)
+
+func TestStatsCountHitsAndBytes(t *testing.T) {
+	e := load(t, ActionAllow,
+		Rule{ID: 1, Priority: 20, Action: ActionLog, DstPort: []PortRange{{Min: 443, Max: 443}}},
+		Rule{ID: 2, Priority: 10, Action: ActionDeny, Direction: traffic.DirectionIn},
+		Rule{ID: 3, Priority: 5, Action: ActionCapture, DstPort: []PortRange{{Min: 80, Max: 80}}},
+	)
+
+	out := ctxFor(traffic.DirectionOut)
+	out.Payload = make([]byte, 100)
+	// Decide 只匹配不计数
+	if d := e.Decide(out); !d.Default || e.Stats().Total() != 0 {
+		t.Fatalf("Decide counted: %+v", e.Stats())
+	}
+	conn := e.Evaluate(out)
+	if !conn.Default {
+		t.Fatalf("expected default verdict: %+v", conn)
+	}
+	for i := 0; i < 3; i++ {
+		e.Account(conn.Override(e.EvaluatePacket(out)), len(out.Payload))
+	}
+	in := ctxFor(traffic.DirectionIn)
+	in.Payload = make([]byte, 10)
+	d := conn.Override(e.EvaluatePacket(in))
+	if d.Default || d.RuleID != 2 {
+		t.Fatalf("packet decision = %+v", d)
+	}
+	e.Account(d, len(in.Payload))
+
+	s := e.Stats()
+	byID := map[int64]RuleStats{}
+	for _, r := range s.Rules {
+		byID[r.RuleID] = r
+	}
+	if r := byID[1]; r.Hits != 1 || r.Bytes != 310 || r.LastHit == 0 {
+		t.Fatalf("rule 1 = %+v", r)
+	}
+	if r := byID[2]; r.Hits != 1 || r.Bytes != 10 {
+		t.Fatalf("rule 2 = %+v", r)
+	}
+	if r := byID[3]; r.Hits != 0 || r.LastHit != 0 {
+		t.Fatalf("dead rule 3 = %+v", r)
+	}
+	if s.Default.Hits != 1 || s.Default.Bytes != 300 || s.Connections != 1 || s.Packets != 4 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	// 热更新后同 ID 规则继续累计
+	cr, _ := CompileRule(Rule{ID: 1, Action: ActionLog, Enabled: true})
+	e.Replace([]*CompiledRule{cr})
+	if s := e.Stats(); len(s.Rules) != 1 || s.Rules[0].Hits != 1 {
+		t.Fatalf("after replace = %+v", s.Rules)
+	}
+
+	e.ResetStats()
+	if s := e.Stats(); s.Total() != 0 || s.Rules[0].LastHit != 0 {
+		t.Fatalf("after reset = %+v", s)
+	}
+}
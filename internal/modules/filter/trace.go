@@ -0,0 +1,203 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"strconv"
+)
+
+// 规则的求值阶段
+const (
+	StageConnection = "connection" // 不限方向的规则，每个连接一次（客户端视角）
+	StagePacket     = "packet"     // 限定方向 / 负载 / 逐包表达式的规则
+	StageDecoded    = "decoded"    // 带解码结果条件的规则
+)
+
+// Condition 单个匹配条件的结果
+type Condition struct {
+	Name    string `json:"name"` // direction / src_ip / dst_ip / src_port / dst_port / payload[i] / decoded[i] / expr
+	Matched bool   `json:"matched"`
+	Actual  string `json:"actual,omitempty"` // 数据包中对应的值
+}
+
+// TraceStep 一条规则的求值过程
+type TraceStep struct {
+	RuleID   int64  `json:"rule_id"`
+	Name     string `json:"name,omitempty"`
+	Action   Action `json:"action"`
+	Priority int    `json:"priority"`
+	Stage    string `json:"stage"`
+	RuleSet  string `json:"rule_set,omitempty"`
+
+	// 未求值时 Note 说明原因（如同阶段已命中终结动作）
+	Evaluated  bool        `json:"evaluated"`
+	Matched    bool        `json:"matched"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	// 该规则决定了所在阶段的 Verdict
+	Decided bool   `json:"decided,omitempty"`
+	Note    string `json:"note,omitempty"`
+}
+
+// Trace 规则求值的完整过程，Steps 按优先级排序
+type Trace struct {
+	Steps []TraceStep `json:"steps"`
+
+	Connection Decision  `json:"connection"`
+	Packet     Decision  `json:"packet"`
+	Decoded    *Decision `json:"decoded,omitempty"`
+	// 连接级结果叠加逐包结果（与实时流量一致）
+	Decision Decision `json:"decision"`
+}
+
+// Trace 与 Evaluate / EvaluatePacket / EvaluateDecoded 相同的求值过程，
+// 逐条记录每个条件的结果；decoded 为空时跳过解码结果阶段。不计入统计
+func (e *Engine) Trace(ctx *traffic.PacketContext, decoded json.RawMessage) Trace {
+	x := e.index.Load()
+	rules := x.all
+	t := Trace{Steps: make([]TraceStep, len(rules))}
+
+	view := ctx.ClientView()
+	scan := newPayloadScan(ctx.Payload, e.scanner.Load())
+
+	var (
+		doc    any
+		docErr error
+	)
+	if decoded != nil {
+		docErr = json.Unmarshal(decoded, &doc)
+		t.Decoded = &Decision{}
+	}
+
+	for i, r := range rules {
+		t.Steps[i] = TraceStep{RuleID: r.ID, Name: r.Name, Action: r.Action, Priority: r.Priority, Stage: r.stage(), RuleSet: r.Set}
+	}
+
+	run := func(stage string, d *Decision) {
+		decidedBy := int64(-1)
+		for i, r := range rules {
+			step := &t.Steps[i]
+			if step.Stage != stage {
+				continue
+			}
+			switch {
+			case x.inactive[r.ID]:
+				step.Note = "not evaluated: outside the rule's schedule"
+				continue
+			case !r.AppliesTo(ctx.RuleSets):
+				step.Note = fmt.Sprintf("not evaluated: rule set %q does not apply to this connection", r.Set)
+				continue
+			case decidedBy >= 0:
+				step.Note = fmt.Sprintf("not evaluated: rule %d already decided the %s stage", decidedBy, stage)
+				continue
+			case stage == StageDecoded && decoded == nil:
+				step.Note = "not evaluated: no decoded data"
+				continue
+			case stage == StageDecoded && docErr != nil:
+				step.Note = "not evaluated: decoded data is not JSON"
+				continue
+			}
+
+			c := ctx
+			if stage == StageConnection {
+				c = view
+			}
+			step.Evaluated = true
+			step.Conditions = r.explain(c, scan, doc)
+			step.Matched = true
+			for _, cond := range step.Conditions {
+				step.Matched = step.Matched && cond.Matched
+			}
+			if step.Matched && d.apply(r) {
+				step.Decided = true
+				decidedBy = r.ID
+			}
+		}
+	}
+
+	run(StageConnection, &t.Connection)
+	if t.Connection.Verdict == "" {
+		t.Connection.Verdict = e.defaultAction.Load().(Action)
+		if !t.Connection.Verdict.Terminal() {
+			t.Connection.Verdict = ActionAllow
+		}
+		t.Connection.Default = true
+	}
+	run(StagePacket, &t.Packet)
+	if t.Decoded != nil {
+		run(StageDecoded, t.Decoded)
+	} else {
+		run(StageDecoded, &Decision{})
+	}
+
+	t.Decision = t.Connection.Override(t.Packet)
+	return t
+}
+
+func (r *CompiledRule) stage() string {
+	switch {
+	case len(r.decoded) > 0:
+		return StageDecoded
+	case r.perPacket:
+		return StagePacket
+	}
+	return StageConnection
+}
+
+// explain 逐个求值全部条件（不短路），没有条件的规则总是命中
+func (r *CompiledRule) explain(ctx *traffic.PacketContext, scan *payloadScan, doc any) []Condition {
+	var out []Condition
+
+	if r.Direction != traffic.DirectionUnknown {
+		out = append(out, Condition{Name: "direction", Matched: r.Direction == ctx.Direction, Actual: ctx.Direction.String()})
+	}
+	if len(r.SrcIPNets) > 0 {
+		out = append(out, Condition{Name: "src_ip", Matched: r.matchSrcIP(ctx.SrcIP), Actual: ipString(ctx.SrcIP)})
+	}
+	if len(r.DstIPNets) > 0 {
+		out = append(out, Condition{Name: "dst_ip", Matched: r.matchDstIP(ctx.DstIP), Actual: ipString(ctx.DstIP)})
+	}
+	if len(r.SrcPorts) > 0 {
+		out = append(out, Condition{Name: "src_port", Matched: r.matchSrcPort(ctx.SrcPort), Actual: strconv.Itoa(ctx.SrcPort)})
+	}
+	if len(r.DstPorts) > 0 {
+		out = append(out, Condition{Name: "dst_port", Matched: r.matchDstPort(ctx.DstPort), Actual: strconv.Itoa(ctx.DstPort)})
+	}
+	if len(r.Geo) > 0 {
+		cond := Condition{Name: "geo", Matched: r.matchGeo(ctx.DstGeo)}
+		if ctx.DstGeo != nil {
+			cond.Actual = ctx.DstGeo.Country
+		}
+		out = append(out, cond)
+	}
+	if len(r.ASN) > 0 {
+		cond := Condition{Name: "asn", Matched: r.matchASN(ctx.DstGeo)}
+		if ctx.DstGeo != nil && ctx.DstGeo.ASN != 0 {
+			cond.Actual = strconv.FormatUint(uint64(ctx.DstGeo.ASN), 10)
+		}
+		out = append(out, cond)
+	}
+	for i, m := range r.payload {
+		out = append(out, Condition{Name: fmt.Sprintf("payload[%d]", i), Matched: m.match(scan), Actual: fmt.Sprintf("%d bytes", len(ctx.Payload))})
+	}
+	if r.expr != nil {
+		out = append(out, Condition{Name: "expr", Matched: r.expr.fn(ctx, scan), Actual: r.expr.String()})
+	}
+	for i, p := range r.decoded {
+		cond := Condition{Name: fmt.Sprintf("decoded[%d]", i), Matched: p.Eval(doc)}
+		if v, ok := p.Path.Get(doc); ok {
+			b, _ := json.Marshal(v)
+			cond.Actual = string(b)
+		}
+		out = append(out, cond)
+	}
+	return out
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"encoding/json"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+)
+
+func TestTraceMatchesEvaluate(t *testing.T) {
+	e := load(t, ActionDeny,
+		Rule{ID: 1, Priority: 30, Action: "tag:web", DstPort: []PortRange{{Min: 443, Max: 443}}},
+		Rule{ID: 2, Priority: 20, Action: ActionAllow, DstCIDR: []string{"10.0.0.0/8"}, SrcCIDR: []string{"172.16.0.0/12"}},
+		Rule{ID: 3, Priority: 15, Action: ActionAllow, DstCIDR: []string{"10.0.0.0/8"}},
+		Rule{ID: 4, Priority: 10, Action: ActionReset},
+		Rule{ID: 5, Priority: 9, Action: ActionDeny, Direction: traffic.DirectionOut, Payload: []PayloadMatch{{Type: PayloadPrefix, Pattern: "de ad"}}},
+		Rule{ID: 6, Priority: 8, Action: ActionDeny, Decoded: []string{`cmd == "login"`}},
+	)
+
+	ctx := ctxFor(traffic.DirectionOut)
+	ctx.Payload = []byte{0xbe, 0xef}
+	tr := e.Trace(ctx, nil)
+
+	want := e.Evaluate(ctx).Override(e.EvaluatePacket(ctx))
+	if tr.Decision.Verdict != want.Verdict || tr.Decision.RuleID != want.RuleID || tr.Decision.RuleID != 3 {
+		t.Fatalf("trace decision = %+v, want %+v", tr.Decision, want)
+	}
+
+	byID := map[int64]TraceStep{}
+	for _, s := range tr.Steps {
+		byID[s.RuleID] = s
+	}
+	if s := byID[2]; !s.Evaluated || s.Matched || len(s.Conditions) != 2 || s.Conditions[0].Name != "src_ip" || s.Conditions[0].Matched || !s.Conditions[1].Matched {
+		t.Fatalf("rule 2 step = %+v", s)
+	}
+	if s := byID[3]; !s.Matched || !s.Decided || s.Stage != StageConnection {
+		t.Fatalf("rule 3 step = %+v", s)
+	}
+	if s := byID[4]; s.Evaluated || s.Note == "" {
+		t.Fatalf("rule 4 evaluated after terminal: %+v", s)
+	}
+	if s := byID[5]; s.Stage != StagePacket || !s.Evaluated || s.Matched || !s.Conditions[0].Matched || s.Conditions[1].Matched {
+		t.Fatalf("rule 5 step = %+v", s)
+	}
+	if s := byID[6]; s.Stage != StageDecoded || s.Evaluated || tr.Decoded != nil {
+		t.Fatalf("rule 6 evaluated without decoded data: %+v", s)
+	}
+
+	tr = e.Trace(ctx, json.RawMessage(`{"cmd":"login"}`))
+	if s := tr.Steps[len(tr.Steps)-1]; !s.Decided || s.Conditions[0].Actual != `"login"` {
+		t.Fatalf("rule 6 step = %+v", s)
+	}
+	if tr.Decoded == nil || tr.Decoded.Verdict != ActionDeny {
+		t.Fatalf("decoded decision = %+v", tr.Decoded)
+	}
+
+	// 追踪不计入统计
+	if s := e.Stats(); s.Rules[2].Hits != 1 || s.Rules[5].Hits != 0 {
+		t.Fatalf("stats = %+v", s.Rules)
+	}
+}
@@ -1,17 +1,81 @@
 package filter
 
 import (
+	"fmt"
 	"net"
+	"proxy-system-backend/internal/modules/mirror"
+	"proxy-system-backend/internal/modules/redirect"
 	"proxy-system-backend/internal/traffic"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Action string
 
 const (
+	// 终结动作：命中后不再继续匹配
 	ActionAllow Action = "allow"
-	ActionDeny  Action = "deny"
+	ActionDeny  Action = "deny"  // 关闭连接
+	ActionReset Action = "reset" // 以 TCP RST 断开连接
+
+	// 附加动作：记录后继续匹配后面的规则
+	ActionMITM       Action = "mitm"        // 对命中的连接做 TLS 解密
+	ActionCapture    Action = "capture"     // 录制命中的连接
+	ActionMirror     Action = "mirror"      // 将命中的连接复制到 Rule.Mirror
+	ActionLog        Action = "log"         // 输出命中日志
+	ActionSkipDecode Action = "skip_decode" // 不经过解码插件
+	ActionAlert      Action = "alert"       // 推送告警事件
+	ActionRedirect   Action = "redirect"    // 将命中的连接转发到 Rule.Redirect（连接目标之前决定）
+
+	// 带参数的附加动作：decode:<plugin>、tag:<label>、rewrite:<改包规则 ID>
+	ActionDecode  Action = "decode"
+	ActionTag     Action = "tag"
+	ActionRewrite Action = "rewrite"
 )
 
+// Kind 去掉参数后的动作类型
+func (a Action) Kind() Action {
+	kind, _, _ := strings.Cut(string(a), ":")
+	return Action(kind)
+}
+
+// Arg decode:<plugin> / tag:<label> 的参数
+func (a Action) Arg() string {
+	_, arg, _ := strings.Cut(string(a), ":")
+	return arg
+}
+
+// Terminal 是否为终结动作
+func (a Action) Terminal() bool {
+	switch a {
+	case ActionAllow, ActionDeny, ActionReset:
+		return true
+	}
+	return false
+}
+
+// Validate 检查动作名称及参数
+func (a Action) Validate() error {
+	switch a.Kind() {
+	case ActionAllow, ActionDeny, ActionReset, ActionMITM, ActionCapture, ActionMirror, ActionLog, ActionSkipDecode, ActionAlert, ActionRedirect:
+		if a.Arg() != "" || strings.Contains(string(a), ":") {
+			return fmt.Errorf("action %q takes no argument", a.Kind())
+		}
+	case ActionDecode, ActionTag:
+		if a.Arg() == "" {
+			return fmt.Errorf("action %q requires an argument, e.g. %s:<name>", a.Kind(), a.Kind())
+		}
+	case ActionRewrite:
+		if id, err := strconv.ParseInt(a.Arg(), 10, 64); err != nil || id <= 0 {
+			return fmt.Errorf("action %q requires a rewrite rule id, e.g. rewrite:1", a.Kind())
+		}
+	default:
+		return fmt.Errorf("unknown action %q", a)
+	}
+	return nil
+}
+
 type Config struct {
 	Enabled       bool
 	DefaultAction Action
@@ -34,7 +98,42 @@ type Rule struct {
 	SrcPort []PortRange
 	DstPort []PortRange
 
+	// 目标地址的国家代码（ISO 3166-1，大写）/ ASN，命中任意一个即可；
+	// 需要加载 GeoIP 数据库（见 PacketContext.DstGeo），地址未收录时不匹配
+	Geo []string
+	ASN []uint32
+
+	// 负载匹配条件，需全部满足；设置后逐包匹配
+	Payload []PayloadMatch
+
+	// 解码结果条件（见 Predicate），需全部满足；设置后在插件解码成功后逐包匹配
+	Decoded []string
+
+	// 过滤表达式（见 Expr），与上面的条件同时满足；引用逐包字段时逐包匹配
+	Expr string
+
 	Tags []string
+
+	// 所属规则集，空为默认规则集（对所有连接生效）；
+	// 命名规则集只对分配到的代理 / 用户生效（见 PacketContext.RuleSets）
+	Set string
+
+	// action=mirror 时的镜像目标
+	Mirror *mirror.Config
+
+	// action=redirect 时的新目标
+	Redirect *redirect.Target
+
+	// ===== 生效时间（零值表示不限）=====
+	StartAt time.Time
+	EndAt   time.Time
+	// 周期生效窗口（见 Window），设置后只在窗口内生效
+	Windows []string
+	// 创建后的有效期，ExpiresAt = 创建时间 + TTL（由存储层计算）
+	TTL       time.Duration
+	ExpiresAt time.Time
+	// 已过期（EndAt / ExpiresAt 已过），不再加载
+	Expired bool
 }
 
 func matchIPNet(ip net.IP, nets []*net.IPNet) bool {
@@ -0,0 +1,227 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"proxy-system-backend/internal/traffic"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DB 目录下全部 .mmdb 库（国家 / 城市 / ASN 库可同时放置，查询结果合并）。
+// Reload 整体替换已加载的库，查询不加锁，可在运行时重新加载
+type DB struct {
+	dir string
+
+	mu         sync.Mutex // 串行化 Reload
+	cur        atomic.Pointer[dbSet]
+	generation atomic.Uint64
+}
+
+type dbSet struct {
+	files    []*dbFile
+	stamps   map[string]fileStamp // 加载时目录中的 .mmdb 文件（包括解析失败的）
+	loadedAt time.Time
+	errors   []string
+}
+
+type dbFile struct {
+	name   string
+	stamp  fileStamp
+	reader *Reader
+}
+
+type fileStamp struct {
+	size    int64
+	modTime int64
+}
+
+// FileStatus 已加载的数据库文件
+type FileStatus struct {
+	File    string    `json:"file"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Metadata
+}
+
+// Status 当前加载的数据库
+type Status struct {
+	Dir        string       `json:"dir"`
+	Generation uint64       `json:"generation"` // 每次加载加一
+	LoadedAt   time.Time    `json:"loaded_at,omitzero"`
+	Files      []FileStatus `json:"files"`
+	Errors     []string     `json:"errors,omitempty"` // 无法读取的文件
+}
+
+func NewDB(dir string) *DB {
+	db := &DB{dir: dir}
+	db.cur.Store(&dbSet{})
+	return db
+}
+
+func (db *DB) Dir() string {
+	return db.dir
+}
+
+// Generation 加载次数，用于判断缓存的查询结果是否过期
+func (db *DB) Generation() uint64 {
+	return db.generation.Load()
+}
+
+// Reload 重新读取目录中的 .mmdb 文件，目录不存在时清空（GeoIP 为可选功能）。
+// 个别文件无法解析时跳过并记录在 Status.Errors 中，其余文件照常生效
+func (db *DB) Reload() Status {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	set := &dbSet{loadedAt: time.Now()}
+	entries, stamps, err := db.scan()
+	if err != nil {
+		set.errors = append(set.errors, err.Error())
+	}
+	set.stamps = stamps
+	for _, e := range entries {
+		r, err := Open(filepath.Join(db.dir, e))
+		if err != nil {
+			set.errors = append(set.errors, fmt.Sprintf("%s: %v", e, err))
+			continue
+		}
+		set.files = append(set.files, &dbFile{name: e, stamp: stamps[e], reader: r})
+	}
+
+	db.cur.Store(set)
+	db.generation.Add(1)
+	return db.status(set)
+}
+
+// Changed 目录中的 .mmdb 文件自上次加载后是否有增删或修改（按大小和修改时间判断）
+func (db *DB) Changed() bool {
+	_, stamps, err := db.scan()
+	if err != nil {
+		return false
+	}
+	return !maps.Equal(stamps, db.cur.Load().stamps)
+}
+
+// scan 目录中的 .mmdb 文件名（按名称排序）及其大小、修改时间
+func (db *DB) scan() ([]string, map[string]fileStamp, error) {
+	entries, err := os.ReadDir(db.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, map[string]fileStamp{}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	stamps := map[string]fileStamp{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".mmdb") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		names = append(names, e.Name())
+		stamps[e.Name()] = fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	}
+	return names, stamps, nil
+}
+
+func (db *DB) Status() Status {
+	return db.status(db.cur.Load())
+}
+
+func (db *DB) status(set *dbSet) Status {
+	s := Status{
+		Dir:        db.dir,
+		Generation: db.generation.Load(),
+		LoadedAt:   set.loadedAt,
+		Files:      make([]FileStatus, 0, len(set.files)),
+		Errors:     set.errors,
+	}
+	for _, f := range set.files {
+		s.Files = append(s.Files, FileStatus{
+			File:     f.name,
+			Size:     f.stamp.size,
+			ModTime:  time.Unix(0, f.stamp.modTime),
+			Metadata: f.reader.Metadata,
+		})
+	}
+	return s
+}
+
+// Lookup 查询 ip 的国家 / ASN，各库的结果合并（按文件名顺序，先得到的字段优先）；
+// 没有加载数据库或都未收录时返回 nil
+func (db *DB) Lookup(ip net.IP) *traffic.GeoInfo {
+	set := db.cur.Load()
+	if ip == nil || len(set.files) == 0 {
+		return nil
+	}
+	var info traffic.GeoInfo
+	for _, f := range set.files {
+		v, err := f.reader.Lookup(ip)
+		if err != nil || v == nil {
+			continue
+		}
+		mergeRecord(&info, v)
+	}
+	if info == (traffic.GeoInfo{}) {
+		return nil
+	}
+	return &info
+}
+
+// mergeRecord 从记录中提取国家代码、ASN 和组织，已有的字段不覆盖。
+// 支持 GeoIP2 / GeoLite2 的 Country、City、ASN 库，以及 DB-IP、IPinfo 的扁平格式（country_code / asn / as_name）
+func mergeRecord(info *traffic.GeoInfo, v any) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	if info.Country == "" {
+		// 没有 country 时（如卫星、匿名网络）退回到注册国家
+		for _, key := range []string{"country", "registered_country"} {
+			if c, ok := m[key].(map[string]any); ok {
+				if code, _ := c["iso_code"].(string); code != "" {
+					info.Country = strings.ToUpper(code)
+					break
+				}
+			}
+		}
+		if code, _ := m["country_code"].(string); info.Country == "" && code != "" {
+			info.Country = strings.ToUpper(code)
+		}
+	}
+	if info.ASN == 0 {
+		if n, ok := m["autonomous_system_number"].(uint64); ok {
+			info.ASN = uint32(n)
+		} else if s, _ := m["asn"].(string); s != "" {
+			info.ASN = parseASN(s)
+		}
+	}
+	if info.Org == "" {
+		if org, _ := m["autonomous_system_organization"].(string); org != "" {
+			info.Org = org
+		} else if org, _ := m["as_name"].(string); org != "" {
+			info.Org = org
+		}
+	}
+}
+
+// parseASN 解析 "AS13335"，无法解析时为 0
+func parseASN(s string) uint32 {
+	if len(s) > 2 && strings.EqualFold(s[:2], "as") {
+		s = s[2:]
+	}
+	n, _ := strconv.ParseUint(s, 10, 32)
+	return uint32(n)
+}
@@ -0,0 +1,208 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// 数据段的字段类型（控制字节高 3 位，0 表示扩展类型，实际类型为 7 + 下一字节）
+const (
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// 无符号整数类型的最大字节数
+var uintSizes = map[uint]uint{typeUint16: 2, typeUint32: 4, typeUint64: 8}
+
+// 嵌套层数上限，防止损坏文件中的循环指针
+const maxDepth = 64
+
+// decoder 解码数据段（或元数据段）中的值：map 解码为 map[string]any，array 为 []any，
+// 无符号整数为 uint64，int32 为 int64，uint128 为 *big.Int
+type decoder struct {
+	buf []byte
+}
+
+// decode 解码 off 处的值，返回值之后的偏移
+func (d *decoder) decode(off uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("data nested too deeply")
+	}
+	b, err := d.bytes(off, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctrl := b[0]
+	off++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr, depth+1)
+		return v, next, err
+	}
+	if typ == 0 {
+		ext, err := d.bytes(off, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		typ = 7 + uint(ext[0])
+		off++
+	}
+	size, off, err := d.size(ctrl, off)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, min(size, uint(len(d.buf))-off))
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key at %d is %T, expected string", off, k)
+			}
+			v, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key], off = v, next
+		}
+		return m, off, nil
+	case typeArray:
+		a := make([]any, 0, min(size, uint(len(d.buf))-off))
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(off, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a, off = append(a, v), next
+		}
+		return a, off, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid bool size %d", size)
+		}
+		return size == 1, off, nil
+	}
+
+	b, err = d.bytes(off, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	off += size
+	switch typ {
+	case typeString:
+		return string(b), off, nil
+	case typeBytes:
+		return append([]byte(nil), b...), off, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), off, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), off, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > uintSizes[typ] {
+			return nil, 0, fmt.Errorf("invalid integer size %d for type %d", size, typ)
+		}
+		return beUint(b), off, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid int32 size %d", size)
+		}
+		return int64(int32(uint32(beUint(b)))), off, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid uint128 size %d", size)
+		}
+		return new(big.Int).SetBytes(b), off, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d at %d", typ, off-size)
+}
+
+// size 控制字节低 5 位：< 29 为长度本身，29 / 30 / 31 表示后面 1 / 2 / 3 字节的扩展长度
+func (d *decoder) size(ctrl byte, off uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1F)
+	if size < 29 {
+		return size, off, nil
+	}
+	n := size - 28
+	b, err := d.bytes(off, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	v := uint(beUint(b))
+	switch size {
+	case 29:
+		v += 29
+	case 30:
+		v += 285
+	default:
+		v += 65821
+	}
+	return v, off + n, nil
+}
+
+// pointer 指针的目标偏移（相对数据段起始）；控制字节的第 4、5 位决定长度
+func (d *decoder) pointer(ctrl byte, off uint) (uint, uint, error) {
+	n := uint(ctrl>>3&0x3) + 1
+	b, err := d.bytes(off, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	v := uint(ctrl & 0x7)
+	var ptr uint
+	switch n {
+	case 1:
+		ptr = v<<8 | uint(b[0])
+	case 2:
+		ptr = (v<<16 | uint(beUint(b))) + 2048
+	case 3:
+		ptr = (v<<24 | uint(beUint(b))) + 526336
+	default:
+		ptr = uint(beUint(b))
+	}
+	return ptr, off + n, nil
+}
+
+func (d *decoder) bytes(off, n uint) ([]byte, error) {
+	if off+n > uint(len(d.buf)) || off+n < off {
+		return nil, fmt.Errorf("data offset %d+%d out of range", off, n)
+	}
+	return d.buf[off : off+n], nil
+}
+
+func beUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
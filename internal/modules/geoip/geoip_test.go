@@ -0,0 +1,373 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"proxy-system-backend/internal/traffic"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===== 测试用的 mmdb 写入 =====
+
+// dataPointer 写入指向数据段偏移的指针
+type dataPointer uint
+
+type mmdbWriter struct {
+	ipVersion  int
+	recordSize int
+	nodes      [][2]int64 // >= 0 为节点；-1 为空；<= -2 为数据偏移 -(off+2)
+	data       bytes.Buffer
+}
+
+func newWriter(ipVersion, recordSize int) *mmdbWriter {
+	return &mmdbWriter{ipVersion: ipVersion, recordSize: recordSize, nodes: [][2]int64{{-1, -1}}}
+}
+
+// put 写入数据段，返回偏移（可用 dataPointer 引用）
+func (w *mmdbWriter) put(v any) uint {
+	off := uint(w.data.Len())
+	encode(&w.data, v)
+	return off
+}
+
+// insert 网段之间不能重叠
+func (w *mmdbWriter) insert(t *testing.T, cidr string, data any) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones, _ := n.Mask.Size()
+	ip := []byte(n.IP)
+	if ip4 := n.IP.To4(); ip4 != nil {
+		ip = ip4
+		if w.ipVersion == 6 {
+			ip, ones = append(make([]byte, 12), ip4...), ones+96
+		}
+	}
+	ref := -int64(w.put(data)) - 2
+
+	node := 0
+	for i := 0; i < ones; i++ {
+		bit := ip[i>>3] >> (7 - uint(i&7)) & 1
+		if i == ones-1 {
+			w.nodes[node][bit] = ref
+			return
+		}
+		next := w.nodes[node][bit]
+		if next == -1 {
+			w.nodes = append(w.nodes, [2]int64{-1, -1})
+			next = int64(len(w.nodes) - 1)
+			w.nodes[node][bit] = next
+		}
+		node = int(next)
+	}
+}
+
+func (w *mmdbWriter) bytes() []byte {
+	var out bytes.Buffer
+	n := int64(len(w.nodes))
+	value := func(r int64) uint32 {
+		switch {
+		case r >= 0:
+			return uint32(r)
+		case r == -1:
+			return uint32(n)
+		}
+		return uint32(n + 16 + (-r - 2))
+	}
+	for _, node := range w.nodes {
+		l, r := value(node[0]), value(node[1])
+		switch w.recordSize {
+		case 24:
+			out.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(r >> 16), byte(r >> 8), byte(r)})
+		case 28:
+			out.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(l>>20&0xF0 | r>>24&0x0F), byte(r >> 16), byte(r >> 8), byte(r)})
+		case 32:
+			out.Write([]byte{byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l), byte(r >> 24), byte(r >> 16), byte(r >> 8), byte(r)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(w.data.Bytes())
+	out.Write(metadataMarker)
+	encode(&out, map[string]any{
+		"node_count":                  uint32(n),
+		"record_size":                 uint16(w.recordSize),
+		"ip_version":                  uint16(w.ipVersion),
+		"database_type":               "Test-DB",
+		"languages":                   []any{"en"},
+		"description":                 map[string]any{"en": "test database"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+	})
+	return out.Bytes()
+}
+
+func encode(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case dataPointer:
+		if v < 2048 {
+			buf.Write([]byte{typePointer<<5 | byte(v>>8), byte(v)})
+		} else {
+			p := v - 2048
+			buf.Write([]byte{typePointer<<5 | 1<<3 | byte(p>>16), byte(p >> 8), byte(p)})
+		}
+	case string:
+		writeCtrl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case []byte:
+		writeCtrl(buf, typeBytes, len(v))
+		buf.Write(v)
+	case float64:
+		writeCtrl(buf, typeDouble, 8)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		writeCtrl(buf, typeBool, n)
+	case uint16:
+		writeUint(buf, typeUint16, uint64(v))
+	case uint32:
+		writeUint(buf, typeUint32, uint64(v))
+	case uint64:
+		writeUint(buf, typeUint64, v)
+	case int32:
+		writeCtrl(buf, typeInt32, 4)
+		u := uint32(v)
+		buf.Write([]byte{byte(u >> 24), byte(u >> 16), byte(u >> 8), byte(u)})
+	case *big.Int:
+		b := v.Bytes()
+		writeCtrl(buf, typeUint128, len(b))
+		buf.Write(b)
+	case []any:
+		writeCtrl(buf, typeArray, len(v))
+		for _, e := range v {
+			encode(buf, e)
+		}
+	case map[string]any:
+		writeCtrl(buf, typeMap, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			encode(buf, k)
+			encode(buf, v[k])
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+func writeUint(buf *bytes.Buffer, typ int, v uint64) {
+	b := big.NewInt(0).SetUint64(v).Bytes()
+	writeCtrl(buf, typ, len(b))
+	buf.Write(b)
+}
+
+func writeCtrl(buf *bytes.Buffer, typ, size int) {
+	ctrl := byte(typ) << 5
+	if typ > 7 {
+		ctrl = 0
+	}
+	var ext []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		ext = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		ext = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		ctrl |= 31
+		s := size - 65821
+		ext = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+	buf.WriteByte(ctrl)
+	if typ > 7 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	buf.Write(ext)
+}
+
+// ===== 测试 =====
+
+func countryRecord(code string) map[string]any {
+	return map[string]any{"country": map[string]any{"iso_code": code, "names": map[string]any{"en": code}}}
+}
+
+func TestReaderLookup(t *testing.T) {
+	for _, c := range []struct {
+		ipVersion, recordSize int
+	}{{6, 24}, {6, 28}, {6, 32}, {4, 24}, {4, 28}} {
+		w := newWriter(c.ipVersion, c.recordSize)
+		us := w.put(map[string]any{"iso_code": "US"})
+		w.insert(t, "1.1.1.0/24", countryRecord("AU"))
+		w.insert(t, "8.8.8.0/24", map[string]any{"country": dataPointer(us)})
+		w.insert(t, "9.9.9.9/32", map[string]any{"registered_country": dataPointer(us)})
+		if c.ipVersion == 6 {
+			w.insert(t, "2001:db8::/32", countryRecord("JP"))
+		}
+		r, err := NewReader(w.bytes())
+		if err != nil {
+			t.Fatalf("v%d/%d: %v", c.ipVersion, c.recordSize, err)
+		}
+		if r.Metadata.DatabaseType != "Test-DB" || r.Metadata.RecordSize != uint(c.recordSize) ||
+			!r.Metadata.BuildTime.Equal(time.Unix(1700000000, 0)) || r.Metadata.Description["en"] != "test database" {
+			t.Fatalf("metadata = %+v", r.Metadata)
+		}
+
+		db := &DB{}
+		db.cur.Store(&dbSet{files: []*dbFile{{reader: r}}})
+		want := map[string]string{
+			"1.1.1.1":     "AU",
+			"1.1.1.255":   "AU",
+			"1.1.2.1":     "",
+			"8.8.8.8":     "US",
+			"9.9.9.9":     "US",
+			"9.9.9.10":    "",
+			"2001:db8::1": "JP",
+			"2001:db9::1": "",
+		}
+		if c.ipVersion == 4 {
+			want["2001:db8::1"] = ""
+		}
+		for ip, country := range want {
+			got := db.Lookup(net.ParseIP(ip))
+			if country == "" && got != nil || country != "" && (got == nil || got.Country != country) {
+				t.Errorf("v%d/%d: %s = %+v, want %q", c.ipVersion, c.recordSize, ip, got, country)
+			}
+		}
+	}
+}
+
+func TestDecoderTypes(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	huge := strings.Repeat("y", 70000)
+	values := []any{
+		"", "中文", long, huge,
+		uint16(0), uint16(443), uint32(13335), uint64(1) << 40,
+		int32(-5), int32(7),
+		true, false,
+		3.25,
+		[]byte{1, 2, 3},
+		new(big.Int).Lsh(big.NewInt(1), 100),
+		[]any{"a", uint32(1), []any{}},
+		map[string]any{"k": map[string]any{"n": uint16(1)}},
+	}
+	var buf bytes.Buffer
+	for _, v := range values {
+		encode(&buf, v)
+	}
+	d := decoder{buf: buf.Bytes()}
+	off := uint(0)
+	for _, want := range values {
+		got, next, err := d.decode(off, 0)
+		if err != nil {
+			t.Fatalf("%T: %v", want, err)
+		}
+		switch w := want.(type) {
+		case uint16:
+			want = uint64(w)
+		case uint32:
+			want = uint64(w)
+		case int32:
+			want = int64(w)
+		case *big.Int:
+			if got.(*big.Int).Cmp(w) != 0 {
+				t.Fatalf("uint128 = %v, want %v", got, w)
+			}
+			off = next
+			continue
+		case []any:
+			want = []any{"a", uint64(1), []any{}}
+		case map[string]any:
+			want = map[string]any{"k": map[string]any{"n": uint64(1)}}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("decoded %#v, want %#v", got, want)
+		}
+		off = next
+	}
+	if off != uint(buf.Len()) {
+		t.Fatalf("offset %d, want %d", off, buf.Len())
+	}
+
+	// 截断和循环指针
+	if _, _, err := (&decoder{buf: buf.Bytes()[:5]}).decode(4, 0); err == nil {
+		t.Fatal("expected error for truncated data")
+	}
+	loop := decoder{buf: []byte{typePointer << 5, 0}}
+	if _, _, err := loop.decode(0, 0); err == nil {
+		t.Fatal("expected error for pointer loop")
+	}
+}
+
+func TestDBReload(t *testing.T) {
+	dir := t.TempDir()
+	db := NewDB(filepath.Join(dir, "missing"))
+	if s := db.Reload(); len(s.Files) != 0 || len(s.Errors) != 0 || db.Lookup(net.ParseIP("1.1.1.1")) != nil {
+		t.Fatalf("missing dir: %+v", s)
+	}
+
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	country := newWriter(6, 24)
+	country.insert(t, "1.1.1.0/24", countryRecord("au"))
+	asn := newWriter(6, 28)
+	asn.insert(t, "1.1.1.0/24", map[string]any{"autonomous_system_number": uint32(13335), "autonomous_system_organization": "CLOUDFLARENET"})
+	asn.insert(t, "8.8.8.0/24", map[string]any{"asn": "AS15169", "as_name": "Google LLC", "country_code": "us"})
+	write("a-country.mmdb", country.bytes())
+	write("b-asn.mmdb", asn.bytes())
+	write("c-broken.mmdb", []byte("not a database"))
+	write("readme.txt", nil)
+
+	db = NewDB(dir)
+	gen := db.Generation()
+	s := db.Reload()
+	if len(s.Files) != 2 || len(s.Errors) != 1 || !strings.HasPrefix(s.Errors[0], "c-broken.mmdb:") || db.Generation() != gen+1 {
+		t.Fatalf("status = %+v", s)
+	}
+	if got := db.Lookup(net.ParseIP("1.1.1.1")); !reflect.DeepEqual(got, &traffic.GeoInfo{Country: "AU", ASN: 13335, Org: "CLOUDFLARENET"}) {
+		t.Fatalf("1.1.1.1 = %+v", got)
+	}
+	if got := db.Lookup(net.ParseIP("8.8.8.8")); !reflect.DeepEqual(got, &traffic.GeoInfo{Country: "US", ASN: 15169, Org: "Google LLC"}) {
+		t.Fatalf("8.8.8.8 = %+v", got)
+	}
+	if db.Changed() {
+		t.Fatal("unchanged directory reported as changed")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "c-broken.mmdb")); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Changed() {
+		t.Fatal("removed file not detected")
+	}
+	country = newWriter(6, 24)
+	country.insert(t, "1.1.1.0/24", countryRecord("NZ"))
+	write("a-country.mmdb", country.bytes())
+	if s := db.Reload(); len(s.Errors) != 0 || len(s.Files) != 2 {
+		t.Fatalf("status = %+v", s)
+	}
+	if got := db.Lookup(net.ParseIP("1.1.1.1")); got == nil || got.Country != "NZ" {
+		t.Fatalf("after reload 1.1.1.1 = %+v", got)
+	}
+}
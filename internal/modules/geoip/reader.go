@@ -0,0 +1,194 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// 元数据段的起始标记，位于文件末尾 128KB 内
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const metadataMaxSize = 128 << 10
+
+// ErrFormat 不是 MaxMind DB 文件
+var ErrFormat = errors.New("not a maxmind db file")
+
+// Metadata mmdb 文件的元数据
+type Metadata struct {
+	DatabaseType string            `json:"database_type"`
+	Description  map[string]string `json:"description,omitempty"`
+	Languages    []string          `json:"languages,omitempty"`
+	IPVersion    int               `json:"ip_version"`
+	NodeCount    uint              `json:"node_count"`
+	RecordSize   uint              `json:"record_size"`
+	BuildTime    time.Time         `json:"build_time"`
+}
+
+// Reader MaxMind DB（.mmdb）格式的只读解析，文件整体读入内存
+type Reader struct {
+	Metadata Metadata
+
+	tree      []byte
+	data      decoder
+	nodeBytes uint
+	// IPv6 库中 IPv4 地址（::/96）对应的起始节点
+	ipv4Start uint
+}
+
+// Open 读取 mmdb 文件
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(buf)
+}
+
+// NewReader 解析内存中的 mmdb 文件，buf 之后不能再修改
+func NewReader(buf []byte) (*Reader, error) {
+	tail := buf
+	if len(tail) > metadataMaxSize {
+		tail = tail[len(tail)-metadataMaxSize:]
+	}
+	i := bytes.LastIndex(tail, metadataMarker)
+	if i < 0 {
+		return nil, ErrFormat
+	}
+	metaStart := len(buf) - len(tail) + i + len(metadataMarker)
+
+	meta := decoder{buf: buf[metaStart:]}
+	v, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("metadata: expected map, got %T", v)
+	}
+
+	r := &Reader{}
+	md := &r.Metadata
+	md.DatabaseType, _ = m["database_type"].(string)
+	md.IPVersion = int(toUint(m["ip_version"]))
+	md.NodeCount = uint(toUint(m["node_count"]))
+	md.RecordSize = uint(toUint(m["record_size"]))
+	if epoch := toUint(m["build_epoch"]); epoch > 0 {
+		md.BuildTime = time.Unix(int64(epoch), 0)
+	}
+	if d, ok := m["description"].(map[string]any); ok {
+		md.Description = make(map[string]string, len(d))
+		for k, v := range d {
+			md.Description[k], _ = v.(string)
+		}
+	}
+	if langs, ok := m["languages"].([]any); ok {
+		for _, l := range langs {
+			if s, ok := l.(string); ok {
+				md.Languages = append(md.Languages, s)
+			}
+		}
+	}
+	if major := toUint(m["binary_format_major_version"]); major != 2 {
+		return nil, fmt.Errorf("unsupported binary format version %d", major)
+	}
+
+	switch md.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", md.RecordSize)
+	}
+	if md.IPVersion != 4 && md.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported ip version %d", md.IPVersion)
+	}
+	if md.NodeCount == 0 {
+		return nil, fmt.Errorf("empty search tree")
+	}
+
+	// 搜索树之后是 16 字节的 0，然后是数据段
+	r.nodeBytes = md.RecordSize / 4
+	treeSize := md.NodeCount * r.nodeBytes
+	dataEnd := uint(metaStart - len(metadataMarker))
+	if treeSize+16 > dataEnd {
+		return nil, fmt.Errorf("search tree exceeds file size")
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[treeSize+16 : dataEnd]}
+
+	if md.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < md.NodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// Lookup 查找 ip 所在网段的数据记录，库中没有时返回 nil
+func (r *Reader) Lookup(ip net.IP) (any, error) {
+	node, bits := uint(0), 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		if r.Metadata.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.Metadata.IPVersion == 4 || len(ip) != net.IPv6len {
+		return nil, nil
+	}
+
+	n := r.Metadata.NodeCount
+	for i := 0; i < bits && node < n; i++ {
+		bit := ip[i>>3] >> (7 - uint(i&7)) & 1
+		node = r.record(node, bit)
+	}
+	switch {
+	case node == n:
+		return nil, nil
+	case node < n:
+		return nil, fmt.Errorf("invalid search tree: node %d has no record", node)
+	}
+
+	off := node - n - 16
+	if off >= uint(len(r.data.buf)) {
+		return nil, fmt.Errorf("invalid search tree: data offset %d out of range", off)
+	}
+	v, _, err := r.data.decode(off, 0)
+	return v, err
+}
+
+// record 节点的左（bit=0）/ 右（bit=1）记录
+func (r *Reader) record(node uint, bit byte) uint {
+	b := r.tree[node*r.nodeBytes:]
+	switch r.Metadata.RecordSize {
+	case 24:
+		if bit == 0 {
+			return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3])<<16 | uint(b[4])<<8 | uint(b[5])
+	case 28:
+		// 中间字节的高 4 位属于左记录，低 4 位属于右记录
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	}
+	return uint(binary.BigEndian.Uint32(b[uint(bit)*4:]))
+}
+
+// toUint 元数据中的无符号整数（解码为 uint64）
+func toUint(v any) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		if n > 0 {
+			return uint64(n)
+		}
+	}
+	return 0
+}
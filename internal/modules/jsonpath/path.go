@@ -0,0 +1,171 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Segment 路径中的一段：对象字段或数组下标
+type Segment struct {
+	Key   string
+	Index int
+	IsIdx bool
+}
+
+// Path 已解析的路径，例如 "player.items[0].id"
+type Path []Segment
+
+func (p Path) String() string {
+	var b strings.Builder
+	for i, s := range p {
+		if s.IsIdx {
+			fmt.Fprintf(&b, "[%d]", s.Index)
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(s.Key)
+	}
+	return b.String()
+}
+
+// Parse 解析点号路径，支持可选的 "$." 前缀和 [n] 数组下标
+func Parse(s string) (Path, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "$")
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var p Path
+	i := 0
+	for i < len(s) {
+		switch s[i] {
+		case '.':
+			i++
+			if i >= len(s) || s[i] == '.' || s[i] == '[' {
+				return nil, fmt.Errorf("invalid path %q: empty field at %d", s, i)
+			}
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed '[' at %d", s, i)
+			}
+			raw := s[i+1 : i+end]
+			if q, err := strconv.Unquote(raw); err == nil {
+				// ["field.with.dots"]
+				p = append(p, Segment{Key: q})
+			} else {
+				n, err := strconv.Atoi(raw)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid path %q: bad index %q at %d", s, raw, i)
+				}
+				p = append(p, Segment{Index: n, IsIdx: true})
+			}
+			i += end + 1
+		default:
+			j := i
+			for j < len(s) && s[j] != '.' && s[j] != '[' {
+				j++
+			}
+			p = append(p, Segment{Key: s[i:j]})
+			i = j
+		}
+	}
+
+	return p, nil
+}
+
+// MustParse 解析失败时 panic，仅用于常量路径
+func MustParse(s string) Path {
+	p, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Get 在 json.Unmarshal 得到的 any 上取值
+func (p Path) Get(doc any) (any, bool) {
+	cur := doc
+	for _, s := range p {
+		if s.IsIdx {
+			arr, ok := cur.([]any)
+			if !ok || s.Index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[s.Index]
+			continue
+		}
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = obj[s.Key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// Set 设置值，中间缺失的对象会自动创建；返回（可能被替换的）根节点
+func (p Path) Set(doc any, value any) (any, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	return setAt(doc, p, value)
+}
+
+func setAt(cur any, p Path, value any) (any, error) {
+	s := p[0]
+	rest := p[1:]
+
+	if s.IsIdx {
+		arr, ok := cur.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an array", s.String())
+		}
+		if s.Index >= len(arr) {
+			return nil, fmt.Errorf("index %d out of range (len %d)", s.Index, len(arr))
+		}
+		if len(rest) == 0 {
+			arr[s.Index] = value
+			return arr, nil
+		}
+		child, err := setAt(arr[s.Index], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		arr[s.Index] = child
+		return arr, nil
+	}
+
+	obj, ok := cur.(map[string]any)
+	if !ok {
+		if cur != nil {
+			return nil, fmt.Errorf("%s: not an object", s.Key)
+		}
+		obj = map[string]any{}
+	}
+	if len(rest) == 0 {
+		obj[s.Key] = value
+		return obj, nil
+	}
+	child, err := setAt(obj[s.Key], rest, value)
+	if err != nil {
+		return nil, err
+	}
+	obj[s.Key] = child
+	return obj, nil
+}
+
+func (s Segment) String() string {
+	if s.IsIdx {
+		return fmt.Sprintf("[%d]", s.Index)
+	}
+	return s.Key
+}
@@ -0,0 +1,163 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Manager 管理镜像目标的统计和共享文件
+type Manager struct {
+	mu    sync.Mutex
+	stats map[string]*counter
+	files map[string]*fileSink
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		stats: make(map[string]*counter),
+		files: make(map[string]*fileSink),
+	}
+}
+
+// Open 为一个连接开始镜像；实际的连接/写入在后台进行，不阻塞调用方
+func (m *Manager) Open(connID string, cfg Config) (*Tee, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c := m.counter(cfg.Target)
+	c.connections.Add(1)
+	c.active.Add(1)
+
+	t := &Tee{
+		m:      m,
+		cfg:    cfg,
+		connID: connID,
+		stats:  c,
+		ch:     make(chan packet, cfg.Buffer),
+		done:   make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+// Stats 各镜像目标的统计，按目标排序
+func (m *Manager) Stats() []Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Stats, 0, len(m.stats))
+	for target, c := range m.stats {
+		out = append(out, c.snapshot(target))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Target < out[j].Target })
+	return out
+}
+
+// Close 关闭共享的镜像文件
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var first error
+	for path, f := range m.files {
+		if err := f.close(); err != nil && first == nil {
+			first = err
+		}
+		delete(m.files, path)
+	}
+	return first
+}
+
+func (m *Manager) counter(target string) *counter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.stats[target]
+	if !ok {
+		c = &counter{}
+		m.stats[target] = c
+	}
+	return c
+}
+
+// sharedFile 多个连接追加写入同一个文件
+func (m *Manager) sharedFile(path string) (*fileSink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.files[path]; ok {
+		return f, nil
+	}
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m.files[path] = f
+	return f, nil
+}
+
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func openFile(path string) (*fileSink, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{f: f}, nil
+}
+
+func (s *fileSink) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Write(b)
+}
+
+func (s *fileSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+type counter struct {
+	active      atomic.Int64
+	connections atomic.Int64
+	packets     atomic.Int64
+	bytes       atomic.Int64
+	dropped     atomic.Int64
+	errors      atomic.Int64
+
+	mu      sync.Mutex
+	lastErr string
+}
+
+func (c *counter) fail(err error) {
+	c.errors.Add(1)
+	c.mu.Lock()
+	c.lastErr = err.Error()
+	c.mu.Unlock()
+}
+
+func (c *counter) snapshot(target string) Stats {
+	c.mu.Lock()
+	lastErr := c.lastErr
+	c.mu.Unlock()
+	return Stats{
+		Target:      target,
+		Active:      int(c.active.Load()),
+		Connections: c.connections.Load(),
+		Packets:     c.packets.Load(),
+		Bytes:       c.bytes.Load(),
+		Dropped:     c.dropped.Load(),
+		Errors:      c.errors.Load(),
+		LastError:   lastErr,
+	}
+}
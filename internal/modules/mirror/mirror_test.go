@@ -0,0 +1,161 @@
+package mirror
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+	"time"
+)
+
+func TestShadowSendsClientStreamOnly(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	got := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// 影子服务器的响应应被丢弃
+		_, _ = conn.Write([]byte("ignored response"))
+		b, _ := io.ReadAll(conn)
+		got <- b
+	}()
+
+	m := NewManager()
+	tee, err := m.Open("c1", Config{Target: "tcp://" + ln.Addr().String(), Shadow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tee.Write(traffic.DirectionOut, []byte("hello "), now)
+	tee.Write(traffic.DirectionIn, []byte("server data"), now)
+	tee.Write(traffic.DirectionOut, []byte("world"), now)
+	tee.Close()
+	<-tee.Done()
+
+	select {
+	case b := <-got:
+		if string(b) != "hello world" {
+			t.Fatalf("shadow received %q", b)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shadow server received nothing")
+	}
+
+	st := m.Stats()[0]
+	if st.Packets != 2 || st.Active != 0 || st.Errors != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestFileSinkJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mirror", "traffic.jsonl")
+
+	m := NewManager()
+	defer m.Close()
+	for _, id := range []string{"a", "b"} {
+		tee, err := m.Open(id, Config{Target: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tee.Write(traffic.DirectionOut, []byte("req-"+id), time.Now())
+		tee.Write(traffic.DirectionIn, []byte("resp-"+id), time.Now())
+		tee.Close()
+		<-tee.Done()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines []record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, r)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("lines = %d", len(lines))
+	}
+	if lines[1].ConnID != "a" || lines[1].Direction != "in" || string(lines[1].Payload) != "resp-a" {
+		t.Fatalf("line = %+v", lines[1])
+	}
+}
+
+func TestJSONLZeroTimeUsesNow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zero.jsonl")
+
+	m := NewManager()
+	defer m.Close()
+	tee, err := m.Open("c1", Config{Target: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 实时流量的 PacketContext.Time 为零值
+	before := time.Now().UnixMilli()
+	tee.Write(traffic.DirectionOut, []byte("hello"), time.Time{})
+	tee.Close()
+	<-tee.Done()
+	after := time.Now().UnixMilli()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Time < before || r.Time > after {
+		t.Fatalf("time = %d, want between %d and %d", r.Time, before, after)
+	}
+}
+
+func TestUnreachableTargetNeverBlocks(t *testing.T) {
+	// 先占用再释放端口，得到一个无人监听的地址
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	m := NewManager()
+	tee, err := m.Open("c1", Config{Target: "tcp://" + addr, Buffer: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		tee.Write(traffic.DirectionOut, []byte("x"), time.Now())
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("writes blocked on an unreachable mirror")
+	}
+	tee.Close()
+	tee.Write(traffic.DirectionOut, []byte("after close"), time.Now())
+	<-tee.Done()
+
+	st := m.Stats()[0]
+	if st.Errors != 1 || st.Dropped != 1000 || st.Packets != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+
+	if _, err := m.Open("c2", Config{Target: "/tmp/x", Shadow: true}); err == nil {
+		t.Fatal("shadow to a file should be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package mirror
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"strings"
+	"sync"
+	"time"
+)
+
+type packet struct {
+	at      time.Time
+	dir     traffic.Direction
+	payload []byte
+}
+
+// Tee 单个连接的镜像：数据包先进入有界队列，由后台协程写出。
+// 队列满、目标不可达或写入失败时只丢弃镜像数据，不影响主转发。
+type Tee struct {
+	m      *Manager
+	cfg    Config
+	connID string
+	stats  *counter
+
+	mu     sync.RWMutex
+	closed bool
+	ch     chan packet
+	done   chan struct{}
+}
+
+// Write 复制一份数据包放入队列，不会阻塞；at 为零值时使用当前时间
+func (t *Tee) Write(dir traffic.Direction, payload []byte, at time.Time) {
+	if len(payload) == 0 || !t.cfg.wants(dir) {
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.ch <- packet{at: at, dir: dir, payload: append([]byte(nil), payload...)}:
+	default:
+		t.stats.dropped.Add(1)
+	}
+}
+
+// Close 连接结束；队列中剩余的数据仍会在后台写完
+func (t *Tee) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	close(t.ch)
+}
+
+// Done 后台写出结束
+func (t *Tee) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *Tee) run() {
+	defer close(t.done)
+	defer t.stats.active.Add(-1)
+
+	w, closeFn, err := t.open()
+	if err != nil {
+		t.stats.fail(err)
+		t.discard()
+		return
+	}
+	defer closeFn()
+
+	for p := range t.ch {
+		b := p.payload
+		if t.cfg.Format == FormatJSONL {
+			b, _ = json.Marshal(record{
+				Time:      p.at.UnixMilli(),
+				ConnID:    t.connID,
+				Direction: p.dir.String(),
+				Payload:   p.payload,
+			})
+			b = append(b, '\n')
+		}
+		if c, ok := w.(net.Conn); ok {
+			_ = c.SetWriteDeadline(time.Now().Add(writeTimeout))
+		}
+		if _, err := w.Write(b); err != nil {
+			t.stats.fail(err)
+			t.stats.dropped.Add(1)
+			t.discard()
+			return
+		}
+		t.stats.packets.Add(1)
+		t.stats.bytes.Add(int64(len(p.payload)))
+	}
+}
+
+// open 建立镜像目标：TCP 每个连接一条，文件按路径共享或每连接一个
+func (t *Tee) open() (io.Writer, func(), error) {
+	if addr, ok := t.cfg.tcpAddr(); ok {
+		conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 对端（分析工具或影子服务器）的响应直接丢弃
+		go func() { _, _ = io.Copy(io.Discard, conn) }()
+		return conn, func() { _ = conn.Close() }, nil
+	}
+
+	if strings.Contains(t.cfg.Target, "{conn_id}") {
+		f, err := openFile(strings.ReplaceAll(t.cfg.Target, "{conn_id}", t.connID))
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, func() { _ = f.close() }, nil
+	}
+
+	f, err := t.m.sharedFile(t.cfg.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() {}, nil
+}
+
+// discard 目标失败后把剩余数据计为丢弃，直到连接结束
+func (t *Tee) discard() {
+	for range t.ch {
+		t.stats.dropped.Add(1)
+	}
+}
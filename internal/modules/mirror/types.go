@@ -0,0 +1,100 @@
+package mirror
+
+import (
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"strings"
+	"time"
+)
+
+// 输出格式
+const (
+	FormatRaw   = "raw"   // 原始字节，不区分方向
+	FormatJSONL = "jsonl" // 每个数据包一行 JSON（含方向和时间）
+)
+
+const (
+	defaultBuffer = 256
+	dialTimeout   = 5 * time.Second
+	writeTimeout  = 5 * time.Second
+)
+
+// Config 镜像目标
+type Config struct {
+	// tcp://host:port，或本地文件路径（可含 {conn_id}，每个连接一个文件）
+	Target string `json:"target"`
+
+	// 镜像方向（unknown 表示双向）
+	Direction traffic.Direction `json:"direction"`
+
+	// raw / jsonl，默认 TCP 为 raw、文件为 jsonl
+	Format string `json:"format,omitempty"`
+
+	// 影子服务器：只发送客户端数据，丢弃其响应
+	Shadow bool `json:"shadow,omitempty"`
+
+	// 每个连接排队的数据包上限，满了之后丢弃
+	Buffer int `json:"buffer,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.Target == "" {
+		return fmt.Errorf("mirror target is required")
+	}
+	if addr, ok := c.tcpAddr(); ok {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("mirror target: %w", err)
+		}
+	} else if c.Shadow {
+		return fmt.Errorf("shadow mirror requires a tcp:// target")
+	}
+
+	if c.Shadow {
+		c.Direction = traffic.DirectionOut
+		c.Format = FormatRaw
+	}
+	switch c.Format {
+	case "":
+		c.Format = FormatJSONL
+		if _, ok := c.tcpAddr(); ok {
+			c.Format = FormatRaw
+		}
+	case FormatRaw, FormatJSONL:
+	default:
+		return fmt.Errorf("unknown mirror format %q", c.Format)
+	}
+	if c.Buffer <= 0 {
+		c.Buffer = defaultBuffer
+	}
+	return nil
+}
+
+func (c *Config) tcpAddr() (string, bool) {
+	return strings.CutPrefix(c.Target, "tcp://")
+}
+
+// wants 该方向是否需要镜像
+func (c *Config) wants(dir traffic.Direction) bool {
+	return c.Direction == traffic.DirectionUnknown || c.Direction == dir
+}
+
+// Stats 单个镜像目标的累计计数
+type Stats struct {
+	Target      string `json:"target"`
+	Active      int    `json:"active"`
+	Connections int64  `json:"connections"`
+	Packets     int64  `json:"packets"`
+	Bytes       int64  `json:"bytes"`
+	Dropped     int64  `json:"dropped"`
+	Errors      int64  `json:"errors"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// record jsonl 格式的一行
+type record struct {
+	Time      int64  `json:"time"` // unix ms
+	ConnID    string `json:"conn_id"`
+	Direction string `json:"direction"`
+	Payload   []byte `json:"payload"` // base64
+}
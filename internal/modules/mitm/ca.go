@@ -0,0 +1,245 @@
+package mitm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 365 * 24 * time.Hour
+)
+
+// Authority 本地根证书，负责按 SNI 签发叶子证书
+type Authority struct {
+	cert    *x509.Certificate
+	key     crypto.Signer
+	certPEM []byte
+
+	mu    sync.Mutex
+	cache map[string]*tls.Certificate // host -> leaf
+}
+
+// LoadOrCreateAuthority 从 dir 读取 ca.crt / ca.key，两个文件都不存在时才生成并持久化；
+// 只有其中一个时返回错误，避免覆盖客户端已安装的根证书
+func LoadOrCreateAuthority(dir string) (*Authority, error) {
+	certPath := filepath.Join(dir, caCertFile)
+	keyPath := filepath.Join(dir, caKeyFile)
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		return parseAuthority(certPEM, keyPEM)
+	}
+	if !errors.Is(certErr, os.ErrNotExist) && certErr != nil {
+		return nil, fmt.Errorf("read ca cert: %w", certErr)
+	}
+	if !errors.Is(keyErr, os.ErrNotExist) && keyErr != nil {
+		return nil, fmt.Errorf("read ca key: %w", keyErr)
+	}
+	if certErr == nil {
+		return nil, fmt.Errorf("ca key %s is missing but %s exists", keyPath, certPath)
+	}
+	if keyErr == nil {
+		return nil, fmt.Errorf("ca cert %s is missing but %s exists", certPath, keyPath)
+	}
+
+	certPEM, keyPEM, err := generateCA()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create ca dir: %w", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("write ca key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return nil, fmt.Errorf("write ca cert: %w", err)
+	}
+
+	return parseAuthority(certPEM, keyPEM)
+}
+
+func generateCA() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate ca key: %w", err)
+	}
+
+	serial, err := randSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "Proxy System Interception CA",
+			Organization: []string{"proxy-system"},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create ca cert: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal ca key: %w", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+func parseAuthority(certPEM, keyPEM []byte) (*Authority, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse ca key pair: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse ca cert: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate %q is not a CA", cert.Subject.CommonName)
+	}
+
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("ca key is not a signer")
+	}
+
+	return &Authority{
+		cert:    cert,
+		key:     signer,
+		certPEM: certPEM,
+		cache:   make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// CertPEM 返回根证书（PEM），用于安装到设备
+func (a *Authority) CertPEM() []byte {
+	return a.certPEM
+}
+
+// Certificate 返回根证书
+func (a *Authority) Certificate() *x509.Certificate {
+	return a.cert
+}
+
+// Fingerprint 返回根证书 SHA-256 指纹（hex）
+func (a *Authority) Fingerprint() string {
+	sum := sha256.Sum256(a.cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue 为 host 签发叶子证书，按 host 缓存，过期前自动重签
+func (a *Authority) Issue(host string) (*tls.Certificate, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return nil, fmt.Errorf("empty host")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if c, ok := a.cache[host]; ok && time.Now().Add(time.Hour).Before(c.Leaf.NotAfter) {
+		return c, nil
+	}
+
+	c, err := a.issue(host)
+	if err != nil {
+		return nil, err
+	}
+	a.cache[host] = c
+	return c, nil
+}
+
+func (a *Authority) issue(host string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate leaf key: %w", err)
+	}
+
+	serial, err := randSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(leafValidity)
+	if notAfter.After(a.cert.NotAfter) {
+		notAfter = a.cert.NotAfter
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		return nil, fmt.Errorf("sign leaf cert for %s: %w", host, err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der, a.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func randSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial: %w", err)
+	}
+	return serial, nil
+}
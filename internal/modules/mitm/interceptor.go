@@ -0,0 +1,236 @@
+package mitm
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"proxy-system-backend/internal/modules/shared"
+	"proxy-system-backend/internal/traffic"
+	"sync"
+	"time"
+)
+
+// Config 单个代理的 TLS 中间人配置
+type Config struct {
+	// 是否对 Domains 中的域名开启中间人
+	Enabled bool `json:"enabled"`
+
+	// 需要解密的域名（支持 "*.example.com"），为空表示所有 TLS 连接
+	Domains []string `json:"domains,omitempty"`
+
+	// 证书固定（pinning）等原因不能解密的域名，直接透传
+	Bypass []string `json:"bypass,omitempty"`
+
+	// 连接真实服务器时跳过证书校验
+	InsecureUpstream bool `json:"insecure_upstream,omitempty"`
+}
+
+// 等待客户端首个字节的时间：TLS 客户端连接后立即发送 ClientHello，
+// 服务器先发送数据的协议（SMTP、FTP、MySQL 等）客户端在此期间不会发送，超时后直接透传
+const firstByteTimeout = 200 * time.Millisecond
+
+// 首个字节是 TLS 握手记录（0x16）后等待完整 ClientHello 的最长时间
+const helloTimeout = 3 * time.Second
+
+// recordTypeHandshake TLS 握手记录的类型字节
+const recordTypeHandshake = 0x16
+
+var errHelloCaptured = errors.New("client hello captured")
+
+// Interceptor 在 pipe 之前终结客户端 TLS，并重新与真实服务器建立 TLS
+type Interceptor struct {
+	ca  *Authority
+	cfg Config
+
+	// 额外的选择条件（例如 filter 规则 action=mitm）
+	match func(ctx *traffic.PacketContext) bool
+
+	// 客户端拒绝伪造证书的域名，自动加入透传名单
+	mu     sync.RWMutex
+	pinned map[string]struct{}
+}
+
+func NewInterceptor(ca *Authority, cfg Config, match func(ctx *traffic.PacketContext) bool) *Interceptor {
+	return &Interceptor{
+		ca:     ca,
+		cfg:    cfg,
+		match:  match,
+		pinned: make(map[string]struct{}),
+	}
+}
+
+// Intercept 返回（可能被替换的）客户端和远端连接
+// 未命中或非 TLS 流量时原样返回，不影响后续转发
+func (i *Interceptor) Intercept(
+	ctx *traffic.PacketContext,
+	client, remote net.Conn,
+) (net.Conn, net.Conn, error) {
+	if !i.selected(ctx) {
+		return client, remote, nil
+	}
+
+	// 1️⃣ 先看首个字节，不是 TLS 握手记录时立即透传
+	var first [1]byte
+	_ = client.SetReadDeadline(time.Now().Add(firstByteTimeout))
+	if n, _ := client.Read(first[:]); n == 0 {
+		// 客户端没有先发送数据（或已关闭），交给 pipe 处理
+		_ = client.SetReadDeadline(time.Time{})
+		return client, remote, nil
+	}
+	if first[0] != recordTypeHandshake {
+		_ = client.SetReadDeadline(time.Time{})
+		return &prefixConn{Conn: client, r: io.MultiReader(bytes.NewReader(first[:]), client)}, remote, nil
+	}
+
+	// 2️⃣ 读取 ClientHello（同时缓存已读字节，透传时原样回放）
+	var buf bytes.Buffer
+	_ = client.SetReadDeadline(time.Now().Add(helloTimeout))
+	hello, err := peekClientHello(io.TeeReader(io.MultiReader(bytes.NewReader(first[:]), client), &buf))
+	_ = client.SetReadDeadline(time.Time{})
+
+	replay := &prefixConn{Conn: client, r: io.MultiReader(&buf, client)}
+	if err != nil {
+		// 非 TLS，按明文继续
+		return replay, remote, nil
+	}
+
+	serverName := hello.ServerName
+	if serverName == "" {
+		serverName = ctx.Domain
+	}
+	if serverName == "" && ctx.DstIP != nil {
+		serverName = ctx.DstIP.String()
+	}
+
+	if i.bypassed(serverName) {
+		return replay, remote, nil
+	}
+
+	// 3️⃣ 与真实服务器握手，沿用客户端的 ALPN；重定向并改写主机名时使用新目标的名称
+	upstreamName := serverName
+	if ctx.UpstreamHost != "" {
+		upstreamName = ctx.UpstreamHost
+		if host, _, err := net.SplitHostPort(upstreamName); err == nil {
+			upstreamName = host
+		}
+	}
+	upstream := tls.Client(remote, &tls.Config{
+		ServerName:         upstreamName,
+		NextProtos:         hello.SupportedProtos,
+		InsecureSkipVerify: i.cfg.InsecureUpstream,
+	})
+	if err := upstream.Handshake(); err != nil {
+		return nil, nil, fmt.Errorf("upstream tls handshake %s: %w", upstreamName, err)
+	}
+
+	// 4️⃣ 用本地 CA 签发的证书终结客户端 TLS
+	var nextProtos []string
+	if p := upstream.ConnectionState().NegotiatedProtocol; p != "" {
+		nextProtos = []string{p}
+	}
+	downstream := tls.Server(replay, &tls.Config{
+		NextProtos: nextProtos,
+		GetCertificate: func(h *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return i.ca.Issue(serverName)
+		},
+	})
+	if err := downstream.Handshake(); err != nil {
+		i.markPinned(serverName)
+		_ = upstream.Close()
+		return nil, nil, fmt.Errorf("client tls handshake %s: %w", serverName, err)
+	}
+
+	ctx.TLSIntercepted = true
+	return downstream, upstream, nil
+}
+
+func (i *Interceptor) selected(ctx *traffic.PacketContext) bool {
+	if i.match != nil && i.match(ctx) {
+		return true
+	}
+	if !i.cfg.Enabled {
+		return false
+	}
+	if len(i.cfg.Domains) == 0 {
+		return true
+	}
+	return ctx.Domain != "" && shared.MatchAnyDomain(i.cfg.Domains, ctx.Domain)
+}
+
+func (i *Interceptor) bypassed(host string) bool {
+	if shared.MatchAnyDomain(i.cfg.Bypass, host) {
+		return true
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	_, ok := i.pinned[host]
+	return ok
+}
+
+func (i *Interceptor) markPinned(host string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.pinned[host]; !ok {
+		log.Printf("[MITM] client rejected certificate for %s, bypassing from now on", host)
+	}
+	i.pinned[host] = struct{}{}
+}
+
+// PinnedHosts 返回自动加入透传名单的域名
+func (i *Interceptor) PinnedHosts() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	hosts := make([]string, 0, len(i.pinned))
+	for h := range i.pinned {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// peekClientHello 借助 crypto/tls 解析 ClientHello，拿到后立即中止握手
+func peekClientHello(r io.Reader) (*tls.ClientHelloInfo, error) {
+	var hello *tls.ClientHelloInfo
+
+	err := tls.Server(readOnlyConn{r: r}, &tls.Config{
+		GetConfigForClient: func(h *tls.ClientHelloInfo) (*tls.Config, error) {
+			cp := *h
+			hello = &cp
+			return nil, errHelloCaptured
+		},
+	}).Handshake()
+
+	if hello == nil {
+		return nil, err
+	}
+	return hello, nil
+}
+
+// readOnlyConn 只允许读的 net.Conn，写入直接丢弃
+type readOnlyConn struct {
+	r io.Reader
+}
+
+func (c readOnlyConn) Read(p []byte) (int, error)         { return c.r.Read(p) }
+func (c readOnlyConn) Write(p []byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (c readOnlyConn) Close() error                       { return nil }
+func (c readOnlyConn) LocalAddr() net.Addr                { return nil }
+func (c readOnlyConn) RemoteAddr() net.Addr               { return nil }
+func (c readOnlyConn) SetDeadline(t time.Time) error      { return nil }
+func (c readOnlyConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c readOnlyConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// prefixConn 先回放已读取的字节，再继续读底层连接
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,296 @@
+package mitm
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+	"time"
+)
+
+func TestAuthorityPersisted(t *testing.T) {
+	dir := t.TempDir()
+
+	a1, err := LoadOrCreateAuthority(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := LoadOrCreateAuthority(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a1.Fingerprint() != a2.Fingerprint() {
+		t.Fatalf("CA was regenerated instead of loaded")
+	}
+
+	leaf, err := a1.Issue("game.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := a1.Issue("GAME.example.com.")
+	if leaf != again {
+		t.Fatalf("leaf certificate not cached by host")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(a2.Certificate())
+	if _, err := leaf.Leaf.Verify(x509.VerifyOptions{
+		DNSName: "game.example.com",
+		Roots:   pool,
+	}); err != nil {
+		t.Fatalf("leaf does not chain to CA: %v", err)
+	}
+}
+
+func TestAuthorityMissingOneFile(t *testing.T) {
+	for _, missing := range []string{caKeyFile, caCertFile} {
+		dir := t.TempDir()
+		a, err := LoadOrCreateAuthority(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept := caCertFile
+		if missing == caCertFile {
+			kept = caKeyFile
+		}
+		before, _ := os.ReadFile(filepath.Join(dir, kept))
+		if err := os.Remove(filepath.Join(dir, missing)); err != nil {
+			t.Fatal(err)
+		}
+
+		// 只剩一个文件时报错，不重新生成覆盖已安装的证书
+		if _, err := LoadOrCreateAuthority(dir); err == nil {
+			t.Fatalf("%s missing: expected error", missing)
+		}
+		if after, _ := os.ReadFile(filepath.Join(dir, kept)); !bytes.Equal(before, after) {
+			t.Fatalf("%s missing: %s was overwritten", missing, kept)
+		}
+		if _, err := os.Stat(filepath.Join(dir, missing)); !os.IsNotExist(err) {
+			t.Fatalf("%s missing: file was recreated (CA %s)", missing, a.Fingerprint())
+		}
+	}
+}
+
+func TestInterceptDecryptsTLS(t *testing.T) {
+	ca, err := LoadOrCreateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 真实服务器：用另一个 CA 的证书，验证 InsecureUpstream
+	serverCA, err := LoadOrCreateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(h *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return serverCA.Issue(h.ServerName)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_, _ = io.Copy(c, c)
+	}()
+
+	remote, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	ic := NewInterceptor(ca, Config{
+		Enabled:          true,
+		Domains:          []string{"*.example.com"},
+		InsecureUpstream: true,
+	}, nil)
+	ctx := &traffic.PacketContext{Domain: "game.example.com"}
+
+	plain := make(chan []byte, 1)
+	go func() {
+		src, dst, err := ic.Intercept(ctx, proxySide, remote)
+		if err != nil {
+			t.Error(err)
+			close(plain)
+			return
+		}
+		buf := make([]byte, 4)
+		n, _ := io.ReadFull(src, buf)
+		plain <- bytes.Clone(buf[:n])
+		_, _ = dst.Write(buf[:n])
+		n, _ = io.ReadFull(dst, buf)
+		_, _ = src.Write(buf[:n])
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate())
+	client := tls.Client(clientSide, &tls.Config{
+		ServerName: "game.example.com",
+		RootCAs:    pool,
+	})
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-plain; string(got) != "ping" {
+		t.Fatalf("proxy saw %q, want plaintext ping", got)
+	}
+
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(client, echo); err != nil {
+		t.Fatal(err)
+	}
+	if string(echo) != "ping" {
+		t.Fatalf("echo = %q", echo)
+	}
+	if !ctx.TLSIntercepted {
+		t.Fatalf("context not marked as intercepted")
+	}
+}
+
+func TestInterceptSkipsUnselectedDomain(t *testing.T) {
+	ca, err := LoadOrCreateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic := NewInterceptor(ca, Config{Enabled: true, Domains: []string{"*.example.com"}}, nil)
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	src, dst, err := ic.Intercept(&traffic.PacketContext{Domain: "other.org"}, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != a || dst != b {
+		t.Fatalf("unselected connection was wrapped")
+	}
+}
+
+func TestInterceptUpstreamHost(t *testing.T) {
+	ca, err := LoadOrCreateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重定向目标按改写后的名称收到 SNI，客户端仍看到原域名的证书
+	sni := make(chan string, 1)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(h *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			sni <- h.ServerName
+			return ca.Issue(h.ServerName)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			defer c.Close()
+			_, _ = io.Copy(c, c)
+		}
+	}()
+
+	remote, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	ic := NewInterceptor(ca, Config{Enabled: true, InsecureUpstream: true}, nil)
+	ctx := &traffic.PacketContext{Domain: "game.example.com", UpstreamHost: "dev.local:8443"}
+	go func() {
+		if _, _, err := ic.Intercept(ctx, proxySide, remote); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate())
+	client := tls.Client(clientSide, &tls.Config{ServerName: "game.example.com", RootCAs: pool})
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-sni; got != "dev.local" {
+		t.Fatalf("upstream sni = %q, want dev.local", got)
+	}
+}
+
+func TestInterceptServerSpeaksFirst(t *testing.T) {
+	ca, err := LoadOrCreateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Domains 为空时选中所有连接
+	ic := NewInterceptor(ca, Config{Enabled: true}, nil)
+
+	// 客户端等待服务器问候（SMTP 等），不会先发送数据
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	remote, server := net.Pipe()
+	defer remote.Close()
+	defer server.Close()
+
+	start := time.Now()
+	src, dst, err := ic.Intercept(&traffic.PacketContext{Domain: "mail.example.com"}, proxySide, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= helloTimeout {
+		t.Fatalf("intercept blocked for %v", d)
+	}
+	if src != proxySide || dst != remote {
+		t.Fatalf("server-first connection was wrapped")
+	}
+
+	// 之后客户端发送的明文原样到达
+	go func() { _, _ = clientSide.Write([]byte("EHLO client\r\n")) }()
+	buf := make([]byte, len("EHLO client\r\n"))
+	if _, err := io.ReadFull(src, buf); err != nil || string(buf) != "EHLO client\r\n" {
+		t.Fatalf("read %q, %v", buf, err)
+	}
+}
+
+func TestInterceptPlaintextClientFirst(t *testing.T) {
+	ca, err := LoadOrCreateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic := NewInterceptor(ca, Config{Enabled: true}, nil)
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	remote, server := net.Pipe()
+	defer remote.Close()
+	defer server.Close()
+
+	req := "GET / HTTP/1.1\r\n\r\n"
+	go func() { _, _ = clientSide.Write([]byte(req)) }()
+	src, _, err := ic.Intercept(&traffic.PacketContext{}, proxySide, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(req))
+	if _, err := io.ReadFull(src, buf); err != nil || string(buf) != req {
+		t.Fatalf("read %q, %v", buf, err)
+	}
+}
@@ -0,0 +1,212 @@
+package mock
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const readBufferSize = 64 * 1024
+
+// DialFunc 真实出站（fallback=passthrough 时使用）
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Miss 未匹配请求的通知
+type Miss struct {
+	Target   string `json:"target"`
+	Key      string `json:"key"`
+	Fallback string `json:"fallback"`
+	Size     int    `json:"size"`
+}
+
+// Dialer 不连接目标，而是用录制的服务端消息响应客户端
+type Dialer struct {
+	lib      *Library
+	upstream DialFunc
+	onMiss   func(Miss)
+}
+
+func NewDialer(lib *Library, upstream DialFunc) *Dialer {
+	return &Dialer{lib: lib, upstream: upstream}
+}
+
+// OnMiss 设置未匹配请求的回调
+func (d *Dialer) OnMiss(fn func(Miss)) {
+	d.onMiss = fn
+}
+
+// DialContext 返回内存管道的一端，另一端由 mock 服务端处理
+func (d *Dialer) DialContext(_ context.Context, network, addr string) (net.Conn, error) {
+	client, server := net.Pipe()
+	s := &session{
+		dialer: d,
+		target: addr,
+		conn:   server,
+		used:   make(map[string]int),
+	}
+	go s.serve()
+	return &conn{Conn: client, remote: targetAddr(network, addr)}, nil
+}
+
+// conn 让上层看到的对端地址是原目标，而不是 pipe
+type conn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+type hostAddr struct {
+	network string
+	addr    string
+}
+
+func (a hostAddr) Network() string { return a.network }
+func (a hostAddr) String() string  { return a.addr }
+
+func targetAddr(network, addr string) net.Addr {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return hostAddr{network: network, addr: addr}
+	}
+	return net.TCPAddrFromAddrPort(ap)
+}
+
+// session 单个连接的 mock 服务端
+type session struct {
+	dialer *Dialer
+	target string
+	conn   net.Conn
+
+	wmu  sync.Mutex
+	used map[string]int // 每个键已响应的次数，重复请求按录制顺序轮换
+	next int            // fallback=next 时下一组响应的位置
+
+	upstream net.Conn
+}
+
+func (s *session) serve() {
+	defer s.close()
+
+	lib := s.dialer.lib
+	if !s.send(lib.banner) {
+		return
+	}
+
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		req := append([]byte(nil), buf[:n]...)
+
+		key := lib.Key(req)
+		if idx, ok := s.lookup(key); ok {
+			s.next = idx + 1
+			if !s.send(lib.list[idx].resp) {
+				return
+			}
+			continue
+		}
+
+		if s.dialer.onMiss != nil {
+			s.dialer.onMiss(Miss{Target: s.target, Key: key, Fallback: lib.cfg.Fallback, Size: n})
+		}
+		switch lib.cfg.Fallback {
+		case FallbackClose:
+			return
+		case FallbackNext:
+			if s.next < len(lib.list) {
+				idx := s.next
+				s.next++
+				if !s.send(lib.list[idx].resp) {
+					return
+				}
+			}
+		case FallbackPassthrough:
+			if !s.forward(req) {
+				return
+			}
+		}
+	}
+}
+
+// lookup 同一个键多次出现时依次使用录制中的各组响应，用完后重复最后一组
+func (s *session) lookup(key string) (int, bool) {
+	if key == "" {
+		return 0, false
+	}
+	list := s.dialer.lib.byKey[key]
+	if len(list) == 0 {
+		return 0, false
+	}
+	n := s.used[key]
+	s.used[key] = n + 1
+	if n >= len(list) {
+		n = len(list) - 1
+	}
+	return list[n], true
+}
+
+func (s *session) send(resp []response) bool {
+	var elapsed time.Duration
+	for _, r := range resp {
+		if s.dialer.lib.cfg.KeepTiming && r.delay > elapsed {
+			time.Sleep(r.delay - elapsed)
+			elapsed = r.delay
+		}
+		if !s.write(r.payload) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *session) write(b []byte) bool {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err := s.conn.Write(b)
+	return err == nil
+}
+
+// forward 未匹配的请求转发给真实服务器，首次使用时建立连接
+func (s *session) forward(req []byte) bool {
+	if s.upstream == nil {
+		if s.dialer.upstream == nil {
+			return false
+		}
+		up, err := s.dialer.upstream(context.Background(), "tcp", s.target)
+		if err != nil {
+			return false
+		}
+		s.upstream = up
+		go func() {
+			buf := make([]byte, readBufferSize)
+			for {
+				n, err := up.Read(buf)
+				if n > 0 && !s.write(buf[:n]) {
+					return
+				}
+				if err != nil {
+					// 真实服务器断开后结束整个连接
+					_ = s.conn.Close()
+					return
+				}
+			}
+		}()
+	}
+	_, err := s.upstream.Write(req)
+	return err == nil
+}
+
+func (s *session) close() {
+	_ = s.conn.Close()
+	if s.upstream != nil {
+		_ = s.upstream.Close()
+	}
+}
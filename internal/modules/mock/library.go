@@ -0,0 +1,187 @@
+package mock
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/jsonpath"
+	"proxy-system-backend/internal/modules/replay"
+	"proxy-system-backend/internal/traffic"
+	"time"
+)
+
+// 请求匹配方式
+const (
+	MatchType    = "type"    // 解码结果中的消息类型字段
+	MatchKey     = "key"     // 插件在解码结果中给出的请求键
+	MatchPayload = "payload" // 原始字节完全一致（不需要插件）
+)
+
+// 未匹配请求的处理策略
+const (
+	FallbackIgnore      = "ignore"      // 不响应
+	FallbackClose       = "close"       // 关闭连接
+	FallbackNext        = "next"        // 按录制顺序返回下一组响应
+	FallbackPassthrough = "passthrough" // 转发给真实服务器
+)
+
+// Config mock 出站配置
+type Config struct {
+	// 录制会话的 conn_id
+	Session string `json:"session"`
+	// 解码插件，为空时使用录制时的插件
+	Plugin string `json:"plugin,omitempty"`
+
+	MatchBy string `json:"match_by"`
+	// 匹配字段路径，默认 type 为 "type"，key 为 "key"
+	Path string `json:"path,omitempty"`
+
+	Fallback string `json:"fallback"`
+
+	// 按录制时请求到响应的间隔延迟发送
+	KeepTiming bool `json:"keep_timing,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.Session == "" {
+		return fmt.Errorf("mock session is required")
+	}
+	switch c.MatchBy {
+	case "":
+		c.MatchBy = MatchType
+	case MatchType, MatchKey, MatchPayload:
+	default:
+		return fmt.Errorf("unknown match_by %q", c.MatchBy)
+	}
+	if c.Path == "" {
+		switch c.MatchBy {
+		case MatchType:
+			c.Path = "type"
+		case MatchKey:
+			c.Path = "key"
+		}
+	}
+	if c.MatchBy != MatchPayload {
+		if _, err := jsonpath.Parse(c.Path); err != nil {
+			return fmt.Errorf("path: %w", err)
+		}
+	}
+	switch c.Fallback {
+	case "":
+		c.Fallback = FallbackIgnore
+	case FallbackIgnore, FallbackClose, FallbackNext, FallbackPassthrough:
+	default:
+		return fmt.Errorf("unknown fallback %q", c.Fallback)
+	}
+	return nil
+}
+
+// response 一条录制的服务端消息及其相对请求的延迟
+type response struct {
+	payload []byte
+	delay   time.Duration
+}
+
+type entry struct {
+	key  string
+	resp []response
+}
+
+// Library 从录制会话构建的请求 → 响应表（只读，多个连接共享）
+type Library struct {
+	cfg    Config
+	codec  replay.Codec
+	banner []response // 首个请求之前服务端主动下发的数据
+	list   []entry
+	byKey  map[string][]int
+}
+
+// NewLibrary 按录制顺序把服务端消息归到前一条客户端请求下
+func NewLibrary(cfg Config, msgs []replay.Message, codec replay.Codec) (*Library, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.MatchBy != MatchPayload && codec == nil {
+		return nil, fmt.Errorf("match_by %s requires a decoder plugin", cfg.MatchBy)
+	}
+
+	lib := &Library{cfg: cfg, codec: codec, byKey: make(map[string][]int)}
+	var last time.Time
+	for _, m := range msgs {
+		switch m.Direction {
+		case traffic.DirectionOut:
+			key, err := lib.recordedKey(m)
+			if err != nil {
+				return nil, err
+			}
+			lib.byKey[key] = append(lib.byKey[key], len(lib.list))
+			lib.list = append(lib.list, entry{key: key})
+			last = m.Time
+
+		case traffic.DirectionIn:
+			r := response{payload: m.Payload}
+			if !last.IsZero() {
+				r.delay = m.Time.Sub(last)
+			}
+			if len(lib.list) == 0 {
+				lib.banner = append(lib.banner, r)
+				continue
+			}
+			e := &lib.list[len(lib.list)-1]
+			e.resp = append(e.resp, r)
+		}
+	}
+	if len(lib.list) == 0 && len(lib.banner) == 0 {
+		return nil, fmt.Errorf("session %s has no recorded messages", cfg.Session)
+	}
+	return lib, nil
+}
+
+// Requests 录制中的请求数
+func (l *Library) Requests() int {
+	return len(l.list)
+}
+
+func (l *Library) recordedKey(m replay.Message) (string, error) {
+	if l.cfg.MatchBy == MatchPayload {
+		return hex.EncodeToString(m.Payload), nil
+	}
+	decoded := m.Decoded
+	if len(decoded) == 0 {
+		d, err := l.codec.Decode(m.Payload, true)
+		if err != nil {
+			// 录制中无法解码的请求不参与匹配
+			return "", nil
+		}
+		decoded = d
+	}
+	return l.keyOf(decoded), nil
+}
+
+// Key 计算实时请求的匹配键，空字符串表示无法匹配
+func (l *Library) Key(payload []byte) string {
+	if l.cfg.MatchBy == MatchPayload {
+		return hex.EncodeToString(payload)
+	}
+	decoded, err := l.codec.Decode(payload, true)
+	if err != nil {
+		return ""
+	}
+	return l.keyOf(decoded)
+}
+
+func (l *Library) keyOf(decoded json.RawMessage) string {
+	var doc any
+	if err := json.Unmarshal(decoded, &doc); err != nil {
+		return ""
+	}
+	v, ok := jsonpath.MustParse(l.cfg.Path).Get(doc)
+	if !ok {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
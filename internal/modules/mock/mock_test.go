@@ -0,0 +1,159 @@
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"proxy-system-backend/internal/modules/replay"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+	"time"
+)
+
+type lineCodec struct{}
+
+func (lineCodec) Decode(payload []byte, _ bool) (json.RawMessage, error) {
+	b := bytes.TrimSpace(payload)
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (lineCodec) Encode(data json.RawMessage) ([]byte, error) {
+	return append(append([]byte(nil), data...), '\n'), nil
+}
+
+func recording() []replay.Message {
+	now := time.Now()
+	msg := func(dir traffic.Direction, s string) replay.Message {
+		return replay.Message{Time: now, Direction: dir, Payload: []byte(s + "\n")}
+	}
+	return []replay.Message{
+		msg(traffic.DirectionIn, `{"type":"hello"}`),
+		msg(traffic.DirectionOut, `{"type":"login","user":"a"}`),
+		msg(traffic.DirectionIn, `{"type":"login_ok"}`),
+		msg(traffic.DirectionOut, `{"type":"list","page":1}`),
+		msg(traffic.DirectionIn, `{"type":"items","page":1}`),
+		msg(traffic.DirectionOut, `{"type":"list","page":2}`),
+		msg(traffic.DirectionIn, `{"type":"items","page":2}`),
+		msg(traffic.DirectionOut, `{"type":"bye"}`),
+		msg(traffic.DirectionIn, `{"type":"bye_ok"}`),
+	}
+}
+
+func dial(t *testing.T, cfg Config) (net.Conn, *bufio.Reader, *[]Miss) {
+	t.Helper()
+	lib, err := NewLibrary(cfg, recording(), lineCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var misses []Miss
+	d := NewDialer(lib, nil)
+	d.OnMiss(func(m Miss) { misses = append(misses, m) })
+
+	c, err := d.DialContext(context.Background(), "tcp", "10.0.0.1:9000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	_ = c.SetDeadline(time.Now().Add(2 * time.Second))
+	return c, bufio.NewReader(c), &misses
+}
+
+func roundTrip(t *testing.T, c net.Conn, r *bufio.Reader, req string) string {
+	t.Helper()
+	if _, err := c.Write([]byte(req + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes.TrimSpace([]byte(line)))
+}
+
+func TestMockMatchByType(t *testing.T) {
+	c, r, misses := dial(t, Config{Session: "s1", MatchBy: MatchType})
+
+	if c.RemoteAddr().String() != "10.0.0.1:9000" {
+		t.Fatalf("remote addr = %s", c.RemoteAddr())
+	}
+	if banner, _ := r.ReadString('\n'); banner != `{"type":"hello"}`+"\n" {
+		t.Fatalf("banner = %q", banner)
+	}
+
+	steps := [][2]string{
+		{`{"type":"login","user":"b"}`, `{"type":"login_ok"}`},
+		// 同类型请求依次使用录制中的响应，用完后重复最后一组
+		{`{"type":"list","page":9}`, `{"type":"items","page":1}`},
+		{`{"type":"list","page":9}`, `{"type":"items","page":2}`},
+		{`{"type":"list","page":9}`, `{"type":"items","page":2}`},
+	}
+	for _, s := range steps {
+		if got := roundTrip(t, c, r, s[0]); got != s[1] {
+			t.Fatalf("%s -> %s, want %s", s[0], got, s[1])
+		}
+	}
+	if len(*misses) != 0 {
+		t.Fatalf("misses = %+v", *misses)
+	}
+}
+
+func TestMockFallback(t *testing.T) {
+	// next：未匹配的请求拿到录制顺序中的下一组响应
+	c, r, misses := dial(t, Config{Session: "s1", Path: "type", Fallback: FallbackNext})
+	_, _ = r.ReadString('\n')
+	if got := roundTrip(t, c, r, `{"type":"login"}`); got != `{"type":"login_ok"}` {
+		t.Fatal(got)
+	}
+	if got := roundTrip(t, c, r, `{"type":"unknown"}`); got != `{"type":"items","page":1}` {
+		t.Fatalf("next fallback = %s", got)
+	}
+	if len(*misses) != 1 || (*misses)[0].Key != `"unknown"` {
+		t.Fatalf("misses = %+v", *misses)
+	}
+
+	// close：未匹配时断开连接
+	c, r, _ = dial(t, Config{Session: "s1", Fallback: FallbackClose})
+	_, _ = r.ReadString('\n')
+	if _, err := c.Write([]byte("not json\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadString('\n'); err != io.EOF {
+		t.Fatalf("read after close = %v", err)
+	}
+}
+
+func TestMockMatchByPayload(t *testing.T) {
+	lib, err := NewLibrary(Config{Session: "s1", MatchBy: MatchPayload}, recording(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lib.Requests() != 4 {
+		t.Fatalf("requests = %d", lib.Requests())
+	}
+	c, err := NewDialer(lib, nil).DialContext(context.Background(), "tcp", "example.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(2 * time.Second))
+	r := bufio.NewReader(c)
+	_, _ = r.ReadString('\n')
+
+	if got := roundTrip(t, c, r, `{"type":"bye"}`); got != `{"type":"bye_ok"}` {
+		t.Fatal(got)
+	}
+	if c.RemoteAddr().String() != "example.com:443" {
+		t.Fatalf("remote addr = %s", c.RemoteAddr())
+	}
+
+	if _, err := NewLibrary(Config{Session: "s1"}, recording(), nil); err == nil {
+		t.Fatal("match by type without codec should fail")
+	}
+}
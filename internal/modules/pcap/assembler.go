@@ -0,0 +1,321 @@
+package pcap
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// 单方向最多缓存的乱序段数，超过后跳过缺口继续交付
+const maxPending = 1024
+
+// 连接结束原因
+const (
+	CloseFIN   = "fin"
+	CloseRST   = "rst"
+	CloseFlush = "eof" // 文件结束时仍未关闭
+)
+
+// Stream 一条重组后的连接（TCP）或会话（UDP）
+type Stream struct {
+	ID     int
+	Client netip.AddrPort
+	Server netip.AddrPort
+	UDP    bool
+
+	Start time.Time
+	End   time.Time
+
+	Packets     int   // 交付的数据块数
+	Bytes       int64 // 交付的字节数
+	Retransmits int   // 完全重复的段
+	GapBytes    int64 // 因丢包跳过的字节数
+	Closed      string
+}
+
+// Assembler 按连接重组 TCP 字节流：处理乱序、重传与 FIN / RST
+// 同一方向的数据按序列号交付，UDP 数据报原样交付
+type Assembler struct {
+	// OnData 按序交付的数据（data 在回调返回后不再被引用）
+	OnData func(s *Stream, fromClient bool, ts time.Time, data []byte)
+	// OnClose 连接结束（FIN 双向完成、RST，或 Flush）
+	OnClose func(s *Stream)
+
+	streams map[flowKey]*tcpStream
+	nextID  int
+}
+
+type flowKey struct {
+	a, b netip.AddrPort
+	udp  bool
+}
+
+func newFlowKey(src, dst netip.AddrPort, udp bool) flowKey {
+	if src.Addr().Less(dst.Addr()) || (src.Addr() == dst.Addr() && src.Port() < dst.Port()) {
+		return flowKey{src, dst, udp}
+	}
+	return flowKey{dst, src, udp}
+}
+
+type pendingSeg struct {
+	seq  uint32
+	ts   time.Time
+	data []byte
+	fin  bool
+}
+
+type halfStream struct {
+	init    bool
+	next    uint32
+	finDone bool
+	pending []pendingSeg
+}
+
+type tcpStream struct {
+	Stream
+	client halfStream
+	server halfStream
+}
+
+func NewAssembler(onData func(s *Stream, fromClient bool, ts time.Time, data []byte), onClose func(s *Stream)) *Assembler {
+	return &Assembler{
+		OnData:  onData,
+		OnClose: onClose,
+		streams: make(map[flowKey]*tcpStream),
+	}
+}
+
+// Add 处理一个解析后的段
+func (a *Assembler) Add(ts time.Time, seg *Segment) {
+	key := newFlowKey(seg.Src, seg.Dst, seg.UDP)
+	st := a.streams[key]
+
+	// 端口复用：旧连接已结束又收到新的 SYN
+	if st != nil && st.Closed != "" && seg.SYN() && !seg.ACK() {
+		delete(a.streams, key)
+		st = nil
+	}
+	if st == nil {
+		if !seg.UDP && (seg.RST() || (seg.FIN() && len(seg.Payload) == 0)) {
+			// 只看到连接尾部，没有可交付的数据
+			return
+		}
+		st = a.newStream(ts, seg)
+		a.streams[key] = st
+	}
+	if st.Closed != "" {
+		return
+	}
+
+	fromClient := seg.Src == st.Client
+	if ts.After(st.End) {
+		st.End = ts
+	}
+
+	if seg.UDP {
+		if len(seg.Payload) > 0 {
+			a.deliver(&st.Stream, fromClient, ts, seg.Payload)
+		}
+		return
+	}
+
+	h := &st.server
+	if fromClient {
+		h = &st.client
+	}
+
+	if seg.RST() {
+		a.finish(st, CloseRST)
+		return
+	}
+	if seg.SYN() {
+		// 重传的 SYN 不回退序列号
+		if !h.init {
+			h.init = true
+			h.next = seg.Seq + 1
+		}
+		return
+	}
+	if !h.init {
+		h.init = true
+		h.next = seg.Seq
+	}
+	if len(seg.Payload) == 0 && !seg.FIN() {
+		return // 纯 ACK
+	}
+
+	a.accept(st, h, fromClient, pendingSeg{seq: seg.Seq, ts: ts, data: seg.Payload, fin: seg.FIN()})
+	if st.client.finDone && st.server.finDone {
+		a.close(st, CloseFIN)
+	}
+}
+
+func (a *Assembler) newStream(ts time.Time, seg *Segment) *tcpStream {
+	client, server := seg.Src, seg.Dst
+	switch {
+	case seg.UDP:
+		// UDP：首个发送方视为客户端
+	case seg.SYN() && seg.ACK():
+		client, server = seg.Dst, seg.Src
+	case seg.SYN():
+	case seg.Src.Port() < seg.Dst.Port():
+		// 没有看到握手：端口较小的一端视为服务端
+		client, server = seg.Dst, seg.Src
+	}
+
+	a.nextID++
+	return &tcpStream{Stream: Stream{
+		ID:     a.nextID,
+		Client: client,
+		Server: server,
+		UDP:    seg.UDP,
+		Start:  ts,
+		End:    ts,
+	}}
+}
+
+// accept 按序交付数据，乱序段缓存等待缺口补齐
+func (a *Assembler) accept(st *tcpStream, h *halfStream, fromClient bool, p pendingSeg) {
+	if h.finDone {
+		return
+	}
+
+	diff := int32(p.seq - h.next)
+	end := int32(p.seq + uint32(len(p.data)) - h.next)
+
+	switch {
+	case diff > 0:
+		// 乱序：缓存（同一序列号只保留最长的一份）
+		for i, q := range h.pending {
+			if q.seq == p.seq {
+				if len(p.data) > len(q.data) || p.fin {
+					h.pending[i] = copySeg(p)
+				} else {
+					st.Retransmits++
+				}
+				return
+			}
+		}
+		h.pending = append(h.pending, copySeg(p))
+		if len(h.pending) > maxPending {
+			a.skipGap(st, h, fromClient)
+		}
+		return
+
+	case diff < 0:
+		if end <= 0 && !(p.fin && end == 0) {
+			st.Retransmits++
+			return
+		}
+		// 部分重叠：去掉已交付的部分
+		p.data = p.data[-diff:]
+	}
+
+	a.consume(st, h, fromClient, p)
+	a.drain(st, h, fromClient)
+}
+
+func (a *Assembler) consume(st *tcpStream, h *halfStream, fromClient bool, p pendingSeg) {
+	if len(p.data) > 0 {
+		a.deliver(&st.Stream, fromClient, p.ts, p.data)
+		h.next += uint32(len(p.data))
+	}
+	if p.fin {
+		h.next++
+		h.finDone = true
+		h.pending = nil
+	}
+}
+
+// drain 交付缓存中已经连续的段
+func (a *Assembler) drain(st *tcpStream, h *halfStream, fromClient bool) {
+	for len(h.pending) > 0 && !h.finDone {
+		progressed := false
+		for i := 0; i < len(h.pending); i++ {
+			p := h.pending[i]
+			diff := int32(p.seq - h.next)
+			if diff > 0 {
+				continue
+			}
+			h.pending = append(h.pending[:i], h.pending[i+1:]...)
+			i--
+
+			end := int32(p.seq + uint32(len(p.data)) - h.next)
+			if end <= 0 && !(p.fin && end == 0) {
+				st.Retransmits++
+				continue
+			}
+			p.data = p.data[-diff:]
+			a.consume(st, h, fromClient, p)
+			progressed = true
+			break
+		}
+		if !progressed {
+			return
+		}
+	}
+}
+
+// skipGap 丢包无法补齐时跳到最小的缓存序列号
+func (a *Assembler) skipGap(st *tcpStream, h *halfStream, fromClient bool) {
+	if len(h.pending) == 0 {
+		return
+	}
+	sort.Slice(h.pending, func(i, j int) bool {
+		return int32(h.pending[i].seq-h.pending[j].seq) < 0
+	})
+	st.GapBytes += int64(h.pending[0].seq - h.next)
+	h.next = h.pending[0].seq
+	a.drain(st, h, fromClient)
+}
+
+func (a *Assembler) deliver(st *Stream, fromClient bool, ts time.Time, data []byte) {
+	st.Packets++
+	st.Bytes += int64(len(data))
+	if a.OnData != nil {
+		a.OnData(st, fromClient, ts, data)
+	}
+}
+
+func (a *Assembler) close(st *tcpStream, reason string) {
+	if st.Closed != "" {
+		return
+	}
+	st.Closed = reason
+	if a.OnClose != nil {
+		a.OnClose(&st.Stream)
+	}
+}
+
+// Flush 文件读完：跳过缺口交付剩余缓存，并关闭所有未结束的连接（按创建顺序）
+func (a *Assembler) Flush() {
+	list := make([]*tcpStream, 0, len(a.streams))
+	for _, st := range a.streams {
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	for _, st := range list {
+		a.finish(st, CloseFlush)
+	}
+	a.streams = make(map[flowKey]*tcpStream)
+}
+
+// finish 跳过缺口交付剩余缓存后关闭连接
+func (a *Assembler) finish(st *tcpStream, reason string) {
+	if st.Closed != "" {
+		return
+	}
+	for len(st.client.pending) > 0 && !st.client.finDone {
+		a.skipGap(st, &st.client, true)
+	}
+	for len(st.server.pending) > 0 && !st.server.finDone {
+		a.skipGap(st, &st.server, false)
+	}
+	a.close(st, reason)
+}
+
+func copySeg(p pendingSeg) pendingSeg {
+	p.data = append([]byte(nil), p.data...)
+	return p
+}
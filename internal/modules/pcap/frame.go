@@ -0,0 +1,224 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86DD
+
+	protoTCP = 6
+	protoUDP = 17
+
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpRST = 0x04
+	tcpPSH = 0x08
+	tcpACK = 0x10
+
+	// 单帧最大负载，保证 IPv4 总长度不溢出
+	maxSegment = 65000
+)
+
+// 合成帧使用的本地管理 MAC（客户端 / 服务端）
+var (
+	macClient = []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	macServer = []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+)
+
+// Flow 为一个连接合成以太网帧，维护两个方向的 TCP 序列号
+type Flow struct {
+	Client netip.AddrPort
+	Server netip.AddrPort
+	UDP    bool
+
+	clientSeq uint32
+	serverSeq uint32
+}
+
+// NewFlow 创建连接；两端地址族不一致时统一映射为 IPv6
+func NewFlow(client, server netip.AddrPort, udp bool) *Flow {
+	c, s := client.Addr().Unmap(), server.Addr().Unmap()
+	if c.Is4() != s.Is4() {
+		c, s = netip.AddrFrom16(c.As16()), netip.AddrFrom16(s.As16())
+	}
+	return &Flow{
+		Client:    netip.AddrPortFrom(c, client.Port()),
+		Server:    netip.AddrPortFrom(s, server.Port()),
+		UDP:       udp,
+		clientSeq: 1000,
+		serverSeq: 5000,
+	}
+}
+
+// Handshake 合成三次握手（UDP 无握手）
+func (f *Flow) Handshake() [][]byte {
+	if f.UDP {
+		return nil
+	}
+	frames := [][]byte{
+		f.tcp(true, tcpSYN, f.clientSeq, 0, nil),
+		f.tcp(false, tcpSYN|tcpACK, f.serverSeq, f.clientSeq+1, nil),
+	}
+	f.clientSeq++
+	f.serverSeq++
+	frames = append(frames, f.tcp(true, tcpACK, f.clientSeq, f.serverSeq, nil))
+	return frames
+}
+
+// Data 合成一个方向的数据帧，超过 maxSegment 时拆分为多帧
+func (f *Flow) Data(fromClient bool, payload []byte) [][]byte {
+	var frames [][]byte
+	for len(payload) > 0 {
+		n := min(len(payload), maxSegment)
+		chunk := payload[:n]
+		payload = payload[n:]
+
+		if f.UDP {
+			frames = append(frames, f.udp(fromClient, chunk))
+			continue
+		}
+		if fromClient {
+			frames = append(frames, f.tcp(true, tcpPSH|tcpACK, f.clientSeq, f.serverSeq, chunk))
+			f.clientSeq += uint32(n)
+		} else {
+			frames = append(frames, f.tcp(false, tcpPSH|tcpACK, f.serverSeq, f.clientSeq, chunk))
+			f.serverSeq += uint32(n)
+		}
+	}
+	return frames
+}
+
+// Close 合成四次挥手（UDP 无挥手）
+func (f *Flow) Close() [][]byte {
+	if f.UDP {
+		return nil
+	}
+	frames := [][]byte{
+		f.tcp(true, tcpFIN|tcpACK, f.clientSeq, f.serverSeq, nil),
+		f.tcp(false, tcpFIN|tcpACK, f.serverSeq, f.clientSeq+1, nil),
+	}
+	f.clientSeq++
+	f.serverSeq++
+	frames = append(frames, f.tcp(true, tcpACK, f.clientSeq, f.serverSeq, nil))
+	return frames
+}
+
+func (f *Flow) endpoints(fromClient bool) (src, dst netip.AddrPort, srcMAC, dstMAC []byte) {
+	if fromClient {
+		return f.Client, f.Server, macClient, macServer
+	}
+	return f.Server, f.Client, macServer, macClient
+}
+
+func (f *Flow) tcp(fromClient bool, flags byte, seq, ack uint32, payload []byte) []byte {
+	src, dst, srcMAC, dstMAC := f.endpoints(fromClient)
+
+	seg := make([]byte, 20+len(payload))
+	binary.BigEndian.PutUint16(seg[0:], src.Port())
+	binary.BigEndian.PutUint16(seg[2:], dst.Port())
+	binary.BigEndian.PutUint32(seg[4:], seq)
+	if flags&tcpACK != 0 {
+		binary.BigEndian.PutUint32(seg[8:], ack)
+	}
+	seg[12] = 5 << 4 // data offset
+	seg[13] = flags
+	binary.BigEndian.PutUint16(seg[14:], 65535) // window
+	copy(seg[20:], payload)
+	binary.BigEndian.PutUint16(seg[16:], transportChecksum(src.Addr(), dst.Addr(), protoTCP, seg))
+
+	return ethernet(srcMAC, dstMAC, ipPacket(src.Addr(), dst.Addr(), protoTCP, seg))
+}
+
+func (f *Flow) udp(fromClient bool, payload []byte) []byte {
+	src, dst, srcMAC, dstMAC := f.endpoints(fromClient)
+
+	dg := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint16(dg[0:], src.Port())
+	binary.BigEndian.PutUint16(dg[2:], dst.Port())
+	binary.BigEndian.PutUint16(dg[4:], uint16(len(dg)))
+	copy(dg[8:], payload)
+	sum := transportChecksum(src.Addr(), dst.Addr(), protoUDP, dg)
+	if sum == 0 {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(dg[6:], sum)
+
+	return ethernet(srcMAC, dstMAC, ipPacket(src.Addr(), dst.Addr(), protoUDP, dg))
+}
+
+func ethernet(src, dst []byte, ip []byte) []byte {
+	frame := make([]byte, 14, 14+len(ip))
+	copy(frame[0:], dst)
+	copy(frame[6:], src)
+	if len(ip) > 0 && ip[0]>>4 == 6 {
+		binary.BigEndian.PutUint16(frame[12:], etherTypeIPv6)
+	} else {
+		binary.BigEndian.PutUint16(frame[12:], etherTypeIPv4)
+	}
+	return append(frame, ip...)
+}
+
+func ipPacket(src, dst netip.Addr, proto byte, payload []byte) []byte {
+	if src.Is4() {
+		h := make([]byte, 20, 20+len(payload))
+		h[0] = 0x45
+		binary.BigEndian.PutUint16(h[2:], uint16(20+len(payload)))
+		binary.BigEndian.PutUint16(h[6:], 0x4000) // DF
+		h[8] = 64
+		h[9] = proto
+		s, d := src.As4(), dst.As4()
+		copy(h[12:], s[:])
+		copy(h[16:], d[:])
+		binary.BigEndian.PutUint16(h[10:], checksum(h, 0))
+		return append(h, payload...)
+	}
+
+	h := make([]byte, 40, 40+len(payload))
+	h[0] = 6 << 4
+	binary.BigEndian.PutUint16(h[4:], uint16(len(payload)))
+	h[6] = proto
+	h[7] = 64
+	s, d := src.As16(), dst.As16()
+	copy(h[8:], s[:])
+	copy(h[24:], d[:])
+	return append(h, payload...)
+}
+
+// transportChecksum 计算带伪首部的 TCP / UDP 校验和
+func transportChecksum(src, dst netip.Addr, proto byte, seg []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(b[i:]))
+		}
+	}
+	if src.Is4() {
+		s, d := src.As4(), dst.As4()
+		add(s[:])
+		add(d[:])
+	} else {
+		s, d := src.As16(), dst.As16()
+		add(s[:])
+		add(d[:])
+	}
+	sum += uint32(proto)
+	sum += uint32(len(seg))
+	return checksum(seg, sum)
+}
+
+func checksum(b []byte, initial uint32) uint16 {
+	sum := initial
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + sum>>16
+	}
+	return ^uint16(sum)
+}
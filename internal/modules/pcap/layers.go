@@ -0,0 +1,223 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+const (
+	// LinkTypeIPv4 / LinkTypeIPv6 只含对应版本 IP 包
+	LinkTypeIPv4 = 228
+	LinkTypeIPv6 = 229
+	// LinkTypeLinuxSLL2 DLT_LINUX_SLL2
+	LinkTypeLinuxSLL2 = 276
+
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88A8
+	etherTypeQinQ2 = 0x9100
+)
+
+var (
+	// ErrNotTransport 不是 TCP / UDP 数据包（ARP、ICMP 等），调用方直接跳过
+	ErrNotTransport = errors.New("not a tcp or udp packet")
+	// ErrFragment IP 分片（暂不重组）
+	ErrFragment = errors.New("ip fragment")
+)
+
+// Segment 解析出的传输层数据
+type Segment struct {
+	Src netip.AddrPort
+	Dst netip.AddrPort
+	UDP bool
+
+	// TCP
+	Seq   uint32
+	Ack   uint32
+	Flags byte
+
+	Payload []byte
+}
+
+func (s *Segment) SYN() bool { return s.Flags&tcpSYN != 0 }
+func (s *Segment) ACK() bool { return s.Flags&tcpACK != 0 }
+func (s *Segment) FIN() bool { return s.Flags&tcpFIN != 0 }
+func (s *Segment) RST() bool { return s.Flags&tcpRST != 0 }
+
+// Decode 从链路层帧中解析出 TCP / UDP 段
+func Decode(linkType uint16, data []byte) (*Segment, error) {
+	ip, err := stripLink(linkType, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(ip) < 1 {
+		return nil, fmt.Errorf("empty ip packet")
+	}
+	switch ip[0] >> 4 {
+	case 4:
+		return decodeIPv4(ip)
+	case 6:
+		return decodeIPv6(ip)
+	default:
+		return nil, ErrNotTransport
+	}
+}
+
+// stripLink 去掉链路层头，返回 IP 包；非 IP 帧返回 ErrNotTransport
+func stripLink(linkType uint16, data []byte) ([]byte, error) {
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return nil, fmt.Errorf("short ethernet frame")
+		}
+		et := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for et == etherTypeVLAN || et == etherTypeQinQ || et == etherTypeQinQ2 {
+			if len(data) < 4 {
+				return nil, fmt.Errorf("short vlan tag")
+			}
+			et = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		if et != etherTypeIPv4 && et != etherTypeIPv6 {
+			return nil, ErrNotTransport
+		}
+		return data, nil
+
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		return data, nil
+
+	case LinkTypeNull:
+		// 4 字节地址族，字节序取决于抓包主机
+		if len(data) < 4 {
+			return nil, fmt.Errorf("short loopback header")
+		}
+		family := binary.LittleEndian.Uint32(data)
+		if family > 0xFFFF {
+			family = binary.BigEndian.Uint32(data)
+		}
+		switch family {
+		case 2, 24, 28, 30: // AF_INET，各平台的 AF_INET6
+			return data[4:], nil
+		}
+		return nil, ErrNotTransport
+
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, fmt.Errorf("short sll header")
+		}
+		et := binary.BigEndian.Uint16(data[14:])
+		if et != etherTypeIPv4 && et != etherTypeIPv6 {
+			return nil, ErrNotTransport
+		}
+		return data[16:], nil
+
+	case LinkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return nil, fmt.Errorf("short sll2 header")
+		}
+		et := binary.BigEndian.Uint16(data[0:])
+		if et != etherTypeIPv4 && et != etherTypeIPv6 {
+			return nil, ErrNotTransport
+		}
+		return data[20:], nil
+	}
+	return nil, fmt.Errorf("unsupported link type %d", linkType)
+}
+
+func decodeIPv4(b []byte) (*Segment, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("short ipv4 header")
+	}
+	ihl := int(b[0]&0x0F) * 4
+	total := int(binary.BigEndian.Uint16(b[2:]))
+	if ihl < 20 || total < ihl || len(b) < ihl {
+		return nil, fmt.Errorf("invalid ipv4 header")
+	}
+	// 截掉以太网填充
+	if total < len(b) {
+		b = b[:total]
+	}
+
+	frag := binary.BigEndian.Uint16(b[6:])
+	if frag&0x1FFF != 0 || frag&0x2000 != 0 {
+		return nil, ErrFragment
+	}
+
+	src := netip.AddrFrom4([4]byte(b[12:16]))
+	dst := netip.AddrFrom4([4]byte(b[16:20]))
+	return decodeTransport(b[9], src, dst, b[ihl:])
+}
+
+func decodeIPv6(b []byte) (*Segment, error) {
+	if len(b) < 40 {
+		return nil, fmt.Errorf("short ipv6 header")
+	}
+	plen := int(binary.BigEndian.Uint16(b[4:]))
+	next := b[6]
+	src := netip.AddrFrom16([16]byte(b[8:24]))
+	dst := netip.AddrFrom16([16]byte(b[24:40]))
+
+	payload := b[40:]
+	if plen < len(payload) {
+		payload = payload[:plen]
+	}
+
+	// 跳过扩展头
+	for {
+		switch next {
+		case 0, 43, 60: // hop-by-hop / routing / destination options
+			if len(payload) < 8 {
+				return nil, fmt.Errorf("short ipv6 extension header")
+			}
+			n := (int(payload[1]) + 1) * 8
+			if len(payload) < n {
+				return nil, fmt.Errorf("short ipv6 extension header")
+			}
+			next = payload[0]
+			payload = payload[n:]
+		case 44:
+			return nil, ErrFragment
+		default:
+			return decodeTransport(next, src, dst, payload)
+		}
+	}
+}
+
+func decodeTransport(proto byte, src, dst netip.Addr, b []byte) (*Segment, error) {
+	switch proto {
+	case protoTCP:
+		if len(b) < 20 {
+			return nil, fmt.Errorf("short tcp header")
+		}
+		off := int(b[12]>>4) * 4
+		if off < 20 || off > len(b) {
+			return nil, fmt.Errorf("invalid tcp data offset")
+		}
+		return &Segment{
+			Src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(b[0:])),
+			Dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(b[2:])),
+			Seq:     binary.BigEndian.Uint32(b[4:]),
+			Ack:     binary.BigEndian.Uint32(b[8:]),
+			Flags:   b[13],
+			Payload: b[off:],
+		}, nil
+
+	case protoUDP:
+		if len(b) < 8 {
+			return nil, fmt.Errorf("short udp header")
+		}
+		n := int(binary.BigEndian.Uint16(b[4:]))
+		if n >= 8 && n < len(b) {
+			b = b[:n]
+		}
+		return &Segment{
+			Src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(b[0:])),
+			Dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(b[2:])),
+			UDP:     true,
+			Payload: b[8:],
+		}, nil
+	}
+	return nil, ErrNotTransport
+}
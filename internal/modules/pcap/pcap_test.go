@@ -0,0 +1,203 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+var (
+	testClient = netip.MustParseAddrPort("192.168.1.10:51000")
+	testServer = netip.MustParseAddrPort("10.0.0.1:7000")
+)
+
+type chunk struct {
+	fromClient bool
+	data       string
+}
+
+func collect(a *Assembler) (*[]chunk, *[]*Stream) {
+	var chunks []chunk
+	var closed []*Stream
+	a.OnData = func(s *Stream, fromClient bool, ts time.Time, data []byte) {
+		chunks = append(chunks, chunk{fromClient, string(data)})
+	}
+	a.OnClose = func(s *Stream) {
+		closed = append(closed, s)
+	}
+	return &chunks, &closed
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewNGWriter(&buf, LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFlow(testClient, testServer, false)
+	var frames [][]byte
+	frames = append(frames, f.Handshake()...)
+	frames = append(frames, f.Data(true, []byte("ping"))...)
+	frames = append(frames, f.Data(false, []byte("pong"))...)
+	frames = append(frames, f.Close()...)
+
+	start := time.Unix(1700000000, 123456000)
+	for i, fr := range frames {
+		if err := w.WritePacket(start.Add(time.Duration(i)*time.Millisecond), fr, "c"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAssembler(nil, nil)
+	chunks, closed := collect(a)
+
+	n := 0
+	for {
+		fr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 && !fr.Time.Equal(start) {
+			t.Fatalf("time = %v, want %v", fr.Time, start)
+		}
+		seg, err := Decode(fr.LinkType, fr.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Add(fr.Time, seg)
+		n++
+	}
+
+	if n != len(frames) {
+		t.Fatalf("read %d frames, want %d", n, len(frames))
+	}
+	want := []chunk{{true, "ping"}, {false, "pong"}}
+	if len(*chunks) != 2 || (*chunks)[0] != want[0] || (*chunks)[1] != want[1] {
+		t.Fatalf("chunks = %v", *chunks)
+	}
+	if len(*closed) != 1 || (*closed)[0].Closed != CloseFIN || (*closed)[0].Client != testClient {
+		t.Fatalf("closed = %+v", *closed)
+	}
+}
+
+func TestReadClassicPcapBigEndianNano(t *testing.T) {
+	f := NewFlow(testClient, testServer, true)
+	frame := f.Data(true, []byte("dns?"))[0]
+
+	var buf bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.BigEndian.PutUint32(hdr[0:], 0xA1B23C4D)
+	binary.BigEndian.PutUint16(hdr[4:], 2)
+	binary.BigEndian.PutUint16(hdr[6:], 4)
+	binary.BigEndian.PutUint32(hdr[16:], 65535)
+	binary.BigEndian.PutUint32(hdr[20:], LinkTypeEthernet)
+	buf.Write(hdr)
+
+	rec := make([]byte, 16)
+	binary.BigEndian.PutUint32(rec[0:], 1700000000)
+	binary.BigEndian.PutUint32(rec[4:], 42)
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(frame)))
+	binary.BigEndian.PutUint32(rec[12:], uint32(len(frame)))
+	buf.Write(rec)
+	buf.Write(frame)
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.Time.Nanosecond() != 42 {
+		t.Fatalf("ns = %d", fr.Time.Nanosecond())
+	}
+	seg, err := Decode(fr.LinkType, fr.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !seg.UDP || seg.Src != testClient || string(seg.Payload) != "dns?" {
+		t.Fatalf("seg = %+v", seg)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("err = %v, want EOF", err)
+	}
+}
+
+func tcpSeg(fromClient bool, flags byte, seq uint32, data string) *Segment {
+	s := &Segment{Src: testClient, Dst: testServer, Seq: seq, Flags: flags | tcpACK, Payload: []byte(data)}
+	if !fromClient {
+		s.Src, s.Dst = testServer, testClient
+	}
+	return s
+}
+
+func TestAssemblerOutOfOrderAndRetransmit(t *testing.T) {
+	a := NewAssembler(nil, nil)
+	chunks, closed := collect(a)
+	now := time.Now()
+
+	a.Add(now, &Segment{Src: testClient, Dst: testServer, Seq: 99, Flags: tcpSYN})
+	a.Add(now, &Segment{Src: testServer, Dst: testClient, Seq: 499, Flags: tcpSYN | tcpACK})
+	a.Add(now, tcpSeg(true, 0, 100, "hel"))
+	a.Add(now, tcpSeg(true, 0, 106, "wor"))  // 乱序
+	a.Add(now, tcpSeg(true, 0, 100, "hel"))  // 重传
+	a.Add(now, tcpSeg(true, 0, 102, "llo ")) // 与已交付部分重叠
+	a.Add(now, tcpSeg(true, tcpFIN, 109, "ld"))
+	a.Add(now, tcpSeg(false, tcpFIN, 500, "ok"))
+
+	var got string
+	for _, c := range *chunks {
+		if c.fromClient {
+			got += c.data
+		}
+	}
+	if got != "hello world" {
+		t.Fatalf("client stream = %q", got)
+	}
+	if len(*closed) != 1 {
+		t.Fatalf("closed = %d", len(*closed))
+	}
+	s := (*closed)[0]
+	if s.Closed != CloseFIN || s.Retransmits != 1 {
+		t.Fatalf("stream = %+v", s)
+	}
+}
+
+func TestAssemblerRSTAndGap(t *testing.T) {
+	a := NewAssembler(nil, nil)
+	chunks, closed := collect(a)
+	now := time.Now()
+
+	// 没有握手：端口较小的一端是服务端
+	a.Add(now, tcpSeg(false, 0, 1000, "banner"))
+	a.Add(now, tcpSeg(true, 0, 10, "a"))
+	a.Add(now, tcpSeg(true, 0, 15, "b")) // 11..14 丢失
+	a.Add(now, tcpSeg(true, tcpRST, 16, ""))
+
+	if len(*chunks) != 3 || (*chunks)[2].data != "b" {
+		t.Fatalf("chunks = %v", *chunks)
+	}
+	s := (*closed)[0]
+	if s.Closed != CloseRST || s.GapBytes != 4 || s.Client != testClient {
+		t.Fatalf("stream = %+v", s)
+	}
+
+	// 连接已结束，后续数据被忽略
+	a.Add(now, tcpSeg(true, 0, 16, "late"))
+	a.Flush()
+	if len(*chunks) != 3 || len(*closed) != 1 {
+		t.Fatalf("late data delivered: %v", *chunks)
+	}
+}
@@ -0,0 +1,121 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// pcapng 块类型
+const (
+	blockSHB = 0x0A0D0D0A
+	blockIDB = 0x00000001
+	blockEPB = 0x00000006
+	blockSPB = 0x00000003
+
+	byteOrderMagic = 0x1A2B3C4D
+
+	optEndOfOpt = 0
+	optComment  = 1
+	optTSResol  = 9
+
+	// LinkTypeEthernet DLT_EN10MB
+	LinkTypeEthernet = 1
+	// LinkTypeRaw DLT_RAW（IPv4 / IPv6，无链路层）
+	LinkTypeRaw = 101
+	// LinkTypeNull DLT_NULL（BSD loopback）
+	LinkTypeNull = 0
+	// LinkTypeLinuxSLL DLT_LINUX_SLL
+	LinkTypeLinuxSLL = 113
+
+	defaultSnapLen = 262144
+
+	// 选项长度字段为 u16
+	maxOptionLen = 0xFFFF - 3
+)
+
+// NGWriter 写 pcapng 文件（单接口，微秒时间戳，小端）
+type NGWriter struct {
+	w        io.Writer
+	linkType uint16
+}
+
+// NewNGWriter 写入 Section Header 和 Interface Description
+func NewNGWriter(w io.Writer, linkType uint16) (*NGWriter, error) {
+	ng := &NGWriter{w: w, linkType: linkType}
+
+	// Section Header Block
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1) // major
+	binary.LittleEndian.PutUint16(shb[6:], 0) // minor
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+	shb = appendOption(shb, optComment, []byte("exported by proxy-system"))
+	shb = appendOption(shb, optEndOfOpt, nil)
+	if err := ng.writeBlock(blockSHB, shb); err != nil {
+		return nil, err
+	}
+
+	// Interface Description Block
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:], linkType)
+	binary.LittleEndian.PutUint32(idb[4:], defaultSnapLen)
+	idb = appendOption(idb, optTSResol, []byte{6})
+	idb = appendOption(idb, optEndOfOpt, nil)
+	if err := ng.writeBlock(blockIDB, idb); err != nil {
+		return nil, err
+	}
+
+	return ng, nil
+}
+
+// WritePacket 写入 Enhanced Packet Block，comment 非空时附加 opt_comment
+func (ng *NGWriter) WritePacket(ts time.Time, frame []byte, comment string) error {
+	us := uint64(ts.UnixMicro())
+
+	body := make([]byte, 20, 20+len(frame)+8+len(comment))
+	binary.LittleEndian.PutUint32(body[0:], 0) // interface id
+	binary.LittleEndian.PutUint32(body[4:], uint32(us>>32))
+	binary.LittleEndian.PutUint32(body[8:], uint32(us))
+	binary.LittleEndian.PutUint32(body[12:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(body[16:], uint32(len(frame)))
+	body = append(body, frame...)
+	body = pad4(body)
+
+	if comment != "" {
+		if len(comment) > maxOptionLen {
+			comment = comment[:maxOptionLen]
+		}
+		body = appendOption(body, optComment, []byte(comment))
+		body = appendOption(body, optEndOfOpt, nil)
+	}
+
+	return ng.writeBlock(blockEPB, body)
+}
+
+func (ng *NGWriter) writeBlock(typ uint32, body []byte) error {
+	total := uint32(12 + len(body))
+
+	buf := make([]byte, 0, total)
+	buf = binary.LittleEndian.AppendUint32(buf, typ)
+	buf = binary.LittleEndian.AppendUint32(buf, total)
+	buf = append(buf, body...)
+	buf = binary.LittleEndian.AppendUint32(buf, total)
+
+	_, err := ng.w.Write(buf)
+	return err
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return pad4(b)
+}
+
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
@@ -0,0 +1,267 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// pcap 文件头魔数（按小端读取）
+const (
+	magicMicroLE = 0xA1B2C3D4
+	magicNanoLE  = 0xA1B23C4D
+	magicMicroBE = 0xD4C3B2A1
+	magicNanoBE  = 0x4D3CB2A1
+
+	blockOPB = 0x00000002 // 已废弃的 Packet Block
+
+	optIfTSResol = 9
+
+	// 单个块 / 记录的上限，防止损坏文件导致超大分配
+	maxBlockSize = 64 << 20
+)
+
+// ErrFormat 无法识别的文件格式
+var ErrFormat = errors.New("not a pcap or pcapng file")
+
+// Frame 从文件读出的一帧
+type Frame struct {
+	Time     time.Time
+	LinkType uint16
+	Data     []byte
+}
+
+type ngInterface struct {
+	linkType uint16
+	tsUnits  uint64 // 每秒的时间戳单位数
+}
+
+// Reader 读取 pcap / pcapng 文件（自动识别格式和字节序）
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	ng    bool
+
+	// pcap
+	linkType uint16
+	nano     bool
+
+	// pcapng
+	ifaces []ngInterface
+	last   time.Time
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	head, err := br.Peek(4)
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	rd := &Reader{r: br}
+	if binary.LittleEndian.Uint32(head) == blockSHB {
+		rd.ng = true
+		return rd, nil
+	}
+
+	hdr := make([]byte, 24)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, ErrFormat
+	}
+	switch binary.LittleEndian.Uint32(hdr) {
+	case magicMicroLE:
+		rd.order = binary.LittleEndian
+	case magicNanoLE:
+		rd.order, rd.nano = binary.LittleEndian, true
+	case magicMicroBE:
+		rd.order = binary.BigEndian
+	case magicNanoBE:
+		rd.order, rd.nano = binary.BigEndian, true
+	default:
+		return nil, ErrFormat
+	}
+	rd.linkType = uint16(rd.order.Uint32(hdr[20:]))
+	return rd, nil
+}
+
+// Next 返回下一帧，读完时返回 io.EOF
+func (rd *Reader) Next() (*Frame, error) {
+	if rd.ng {
+		return rd.nextNG()
+	}
+	return rd.nextPcap()
+}
+
+func (rd *Reader) nextPcap() (*Frame, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(rd.r, hdr); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated record header: %w", err)
+		}
+		return nil, err
+	}
+	sec := rd.order.Uint32(hdr[0:])
+	frac := rd.order.Uint32(hdr[4:])
+	capLen := rd.order.Uint32(hdr[8:])
+	if capLen > maxBlockSize {
+		return nil, fmt.Errorf("record too large: %d", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(rd.r, data); err != nil {
+		return nil, fmt.Errorf("truncated record: %w", err)
+	}
+
+	ns := int64(frac) * 1000
+	if rd.nano {
+		ns = int64(frac)
+	}
+	return &Frame{Time: time.Unix(int64(sec), ns), LinkType: rd.linkType, Data: data}, nil
+}
+
+func (rd *Reader) nextNG() (*Frame, error) {
+	for {
+		typ, body, err := rd.readBlock()
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case blockSHB:
+			// 新 section：接口编号重新开始
+			rd.ifaces = rd.ifaces[:0]
+
+		case blockIDB:
+			if len(body) < 8 {
+				return nil, fmt.Errorf("short interface block")
+			}
+			iface := ngInterface{linkType: rd.order.Uint16(body[0:]), tsUnits: 1_000_000}
+			rd.walkOptions(body[8:], func(code uint16, v []byte) {
+				if code == optIfTSResol && len(v) >= 1 {
+					iface.tsUnits = tsUnits(v[0])
+				}
+			})
+			rd.ifaces = append(rd.ifaces, iface)
+
+		case blockEPB:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("short packet block")
+			}
+			id := rd.order.Uint32(body[0:])
+			ts := uint64(rd.order.Uint32(body[4:]))<<32 | uint64(rd.order.Uint32(body[8:]))
+			return rd.ngFrame(id, ts, body[20:], rd.order.Uint32(body[12:]))
+
+		case blockOPB:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("short packet block")
+			}
+			id := uint32(rd.order.Uint16(body[0:]))
+			ts := uint64(rd.order.Uint32(body[4:]))<<32 | uint64(rd.order.Uint32(body[8:]))
+			return rd.ngFrame(id, ts, body[20:], rd.order.Uint32(body[12:]))
+
+		case blockSPB:
+			if len(body) < 4 || len(rd.ifaces) == 0 {
+				return nil, fmt.Errorf("invalid simple packet block")
+			}
+			// SPB 没有时间戳，沿用上一帧的时间
+			n := min(rd.order.Uint32(body[0:]), uint32(len(body)-4))
+			return &Frame{Time: rd.last, LinkType: rd.ifaces[0].linkType, Data: body[4 : 4+n]}, nil
+		}
+		// 其它块（统计、名称解析等）忽略
+	}
+}
+
+func (rd *Reader) ngFrame(id uint32, ts uint64, rest []byte, capLen uint32) (*Frame, error) {
+	if int(id) >= len(rd.ifaces) {
+		return nil, fmt.Errorf("packet references unknown interface %d", id)
+	}
+	if uint64(capLen) > uint64(len(rest)) {
+		return nil, fmt.Errorf("captured length %d exceeds block", capLen)
+	}
+	iface := rd.ifaces[id]
+
+	sec, frac := ts/iface.tsUnits, ts%iface.tsUnits
+	hi, lo := bits.Mul64(frac, 1_000_000_000)
+	ns, _ := bits.Div64(hi, lo, iface.tsUnits)
+
+	rd.last = time.Unix(int64(sec), int64(ns))
+	return &Frame{Time: rd.last, LinkType: iface.linkType, Data: rest[:capLen]}, nil
+}
+
+// readBlock 读取一个完整块，返回块类型和块体（不含首尾长度）
+func (rd *Reader) readBlock() (uint32, []byte, error) {
+	hdr, err := rd.r.Peek(8)
+	if err != nil {
+		if len(hdr) == 0 {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, fmt.Errorf("truncated block header: %w", io.ErrUnexpectedEOF)
+	}
+
+	typ := binary.LittleEndian.Uint32(hdr)
+	if typ == blockSHB {
+		// section 头决定后续字节序
+		b, err := rd.r.Peek(12)
+		if err != nil {
+			return 0, nil, fmt.Errorf("truncated section header: %w", io.ErrUnexpectedEOF)
+		}
+		switch binary.LittleEndian.Uint32(b[8:]) {
+		case byteOrderMagic:
+			rd.order = binary.LittleEndian
+		case 0x4D3C2B1A:
+			rd.order = binary.BigEndian
+		default:
+			return 0, nil, ErrFormat
+		}
+	} else if rd.order == nil {
+		return 0, nil, ErrFormat
+	} else {
+		typ = rd.order.Uint32(hdr)
+	}
+
+	total := rd.order.Uint32(hdr[4:])
+	if total < 12 || total%4 != 0 || total > maxBlockSize {
+		return 0, nil, fmt.Errorf("invalid block length %d", total)
+	}
+
+	buf := make([]byte, total)
+	if _, err := io.ReadFull(rd.r, buf); err != nil {
+		return 0, nil, fmt.Errorf("truncated block: %w", io.ErrUnexpectedEOF)
+	}
+	if rd.order.Uint32(buf[total-4:]) != total {
+		return 0, nil, fmt.Errorf("block length mismatch")
+	}
+	return typ, buf[8 : total-4], nil
+}
+
+func (rd *Reader) walkOptions(b []byte, fn func(code uint16, v []byte)) {
+	for len(b) >= 4 {
+		code := rd.order.Uint16(b[0:])
+		n := int(rd.order.Uint16(b[2:]))
+		if code == optEndOfOpt || 4+n > len(b) {
+			return
+		}
+		fn(code, b[4:4+n])
+		b = b[4+(n+3)/4*4:]
+	}
+}
+
+// tsUnits 解析 if_tsresol：最高位为 0 表示 10^-n 秒，为 1 表示 2^-n 秒
+func tsUnits(v byte) uint64 {
+	n := uint64(v & 0x7F)
+	if v&0x80 != 0 {
+		if n >= 64 {
+			n = 63
+		}
+		return 1 << n
+	}
+	u := uint64(1)
+	for i := uint64(0); i < n && i < 19; i++ {
+		u *= 10
+	}
+	return u
+}
@@ -108,15 +108,21 @@ func (inv *PluginInvoker) doEncode(req *EncodeCallContext) ([]byte, error) {
 
 	// 执行编码
 	startTime := time.Now()
-	// 注意：Manager 目前没有直接暴露 Encode 方法，这里需要扩展
-	// 暂时返回错误
+	result, err := inv.manager.Encode(pluginName, req.Data)
 	duration := time.Since(startTime)
 
+	if err != nil {
+		if req.Context.Verbose {
+			fmt.Printf("[Plugin] Encode call failed after %v: %v\n", duration, err)
+		}
+		return nil, err
+	}
+
 	if req.Context.Verbose {
 		fmt.Printf("[Plugin] Encode call completed in %v\n", duration)
 	}
 
-	return nil, fmt.Errorf("encode method not implemented in manager")
+	return result, nil
 }
 
 // invokeWithRetry 带重试的解码调用
@@ -163,6 +163,17 @@ func (m *Manager) Decode(
 	return p.Decode(payload, isClient)
 }
 
+// Encode 将解码后的数据重新编码为原始 payload
+func (m *Manager) Encode(name string, data []byte) ([]byte, error) {
+	m.mu.RLock()
+	p, ok := m.plugins[name]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("plugin %s not loaded", name)
+	}
+	return p.Encode(data)
+}
+
 func (m *Manager) Unload(name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -3,12 +3,24 @@ package proxy
 import (
 	"fmt"
 	"github.com/shadowsocks/go-shadowsocks2/core"
+	"proxy-system-backend/internal/modules/dns"
+	"proxy-system-backend/internal/modules/mitm"
+	"proxy-system-backend/internal/modules/mock"
+	"proxy-system-backend/internal/modules/redirect"
+)
+
+// 出站方式
+const (
+	OutboundDirect = "direct" // 直连目标（默认）
+	OutboundMock   = "mock"   // 用录制会话响应，不访问网络
 )
 
 type Config struct {
 	// ===== 身份 =====
 	ID   string `json:"id"`   // proxy_id（稳定标识）
 	Name string `json:"name"` // 显示用
+	// Shadowsocks 用户（每个代理一个密码），用于按用户分配过滤规则集
+	User string `json:"user,omitempty"`
 
 	// ===== 网络 =====
 	ListenAddr string `json:"listen_addr"` // ":8388"
@@ -19,6 +31,8 @@ type Config struct {
 
 	// ===== 行为配置 =====
 	EnableFilter bool `json:"enable_filter"`
+	Capture      bool `json:"capture"`      // 录制该代理的所有连接
+	TraceFilter  bool `json:"trace_filter"` // 流量事件附带规则求值过程（调试用）
 
 	// ===== 生命周期 =====
 	Enabled   bool  `json:"enabled"`
@@ -27,6 +41,19 @@ type Config struct {
 
 	BlockIPs   []string `json:"block_ips,omitempty"`
 	BlockPorts []string `json:"block_ports,omitempty"`
+
+	// ===== TLS 中间人 =====
+	MITM mitm.Config `json:"mitm"`
+
+	// ===== 出站 =====
+	Outbound string      `json:"outbound,omitempty"`
+	Mock     mock.Config `json:"mock"`
+
+	// 出站连接的域名解析（上游 DNS、静态解析、缓存），默认使用系统解析器
+	DNS dns.Config `json:"dns"`
+
+	// 目标重定向，按顺序匹配客户端请求的目标；过滤规则 action=redirect 优先
+	Redirect []redirect.Rule `json:"redirect,omitempty"`
 }
 
 func (c *Config) BuildCipher() (core.Cipher, error) {
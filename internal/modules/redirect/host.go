@@ -0,0 +1,74 @@
+package redirect
+
+import (
+	"bytes"
+	"net"
+)
+
+var crlf = []byte("\r\n")
+
+// hostConn 改写写入数据中 HTTP/1.x 请求头的 Host
+type hostConn struct {
+	net.Conn
+	host string
+}
+
+// RewriteHost 包装发往上游的连接：每次写入以 HTTP/1.x 请求开头时把 Host 头改为 host；
+// 其它数据（TLS、HTTP/2、请求体）原样写入
+func RewriteHost(c net.Conn, host string) net.Conn {
+	return &hostConn{Conn: c, host: host}
+}
+
+func (c *hostConn) Write(p []byte) (int, error) {
+	out, ok := rewriteHostHeader(p, c.host)
+	if !ok {
+		return c.Conn.Write(p)
+	}
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func rewriteHostHeader(p []byte, host string) ([]byte, bool) {
+	lineEnd := bytes.Index(p, crlf)
+	if lineEnd < 0 || !isRequestLine(p[:lineEnd]) {
+		return nil, false
+	}
+	headerEnd := bytes.Index(p, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		headerEnd = len(p)
+	}
+
+	for start := lineEnd + 2; start < headerEnd; {
+		end := len(p)
+		if i := bytes.Index(p[start:], crlf); i >= 0 {
+			end = start + i
+		}
+		name, _, ok := bytes.Cut(p[start:end], []byte(":"))
+		if ok && bytes.EqualFold(bytes.TrimSpace(name), []byte("Host")) {
+			out := make([]byte, 0, len(p)+len(host))
+			out = append(out, p[:start]...)
+			out = append(out, "Host: "...)
+			out = append(out, host...)
+			out = append(out, p[end:]...)
+			return out, true
+		}
+		start = end + 2
+	}
+	return nil, false
+}
+
+// isRequestLine "GET /path HTTP/1.1"
+func isRequestLine(line []byte) bool {
+	method, _, ok := bytes.Cut(line, []byte(" "))
+	if !ok || len(method) == 0 {
+		return false
+	}
+	for _, c := range method {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return bytes.HasSuffix(line, []byte(" HTTP/1.1")) || bytes.HasSuffix(line, []byte(" HTTP/1.0"))
+}
@@ -0,0 +1,174 @@
+package redirect
+
+import (
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/modules/shared"
+	"strconv"
+	"strings"
+)
+
+// Target 重定向目标
+type Target struct {
+	// 实际连接的 host:port；":7001" 保持原主机，省略端口（"127.0.0.1"）保持原端口
+	To string `json:"to"`
+
+	// 改写上游看到的主机名：中间人解密时的 TLS SNI 和 HTTP/1.x 请求的 Host 头；
+	// 默认保留客户端请求的原始值（目标服务器按原域名提供服务时无需设置）
+	RewriteHost bool `json:"rewrite_host,omitempty"`
+}
+
+func (t *Target) Validate() error {
+	host, port := splitAddr(t.To)
+	if host == "" && port == "" {
+		return fmt.Errorf("redirect target is required")
+	}
+	if port != "" {
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			return fmt.Errorf("redirect target %q: invalid port", t.To)
+		}
+	}
+	if strings.ContainsAny(host, "/*") {
+		return fmt.Errorf("redirect target %q: invalid host", t.To)
+	}
+	return nil
+}
+
+// Apply 返回原始目标 orig（host:port）重定向后的地址
+func (t Target) Apply(orig string) string {
+	origHost, origPort, _ := net.SplitHostPort(orig)
+	host, port := splitAddr(t.To)
+	if host == "" {
+		host = origHost
+	}
+	if port == "" {
+		port = origPort
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Rule 代理级重定向规则
+type Rule struct {
+	// 匹配客户端请求的目标："host:port"、"host"（任意端口）、":port"（任意主机）；
+	// host 为域名（支持 "*.example.com"，"*" 匹配所有目标）、IP 或 CIDR
+	Match string `json:"match"`
+	Target
+}
+
+// Table 编译后的代理级重定向规则，按顺序匹配第一条
+type Table struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	domain string     // 域名模式，与 ipNet 二选一
+	ipNet  *net.IPNet // IP / CIDR
+	port   int        // 0 表示任意端口
+	target Target
+}
+
+// NewTable 校验规则，返回的 Table 并发安全（只读）
+func NewTable(rules []Rule) (*Table, error) {
+	t := &Table{}
+	for i, r := range rules {
+		cr, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("redirect[%d]: %w", i, err)
+		}
+		t.rules = append(t.rules, cr)
+	}
+	return t, nil
+}
+
+func compileRule(r Rule) (compiledRule, error) {
+	if err := r.Target.Validate(); err != nil {
+		return compiledRule{}, err
+	}
+	host, port := splitAddr(r.Match)
+	if host == "" && port == "" {
+		return compiledRule{}, fmt.Errorf("match is required")
+	}
+
+	cr := compiledRule{target: r.Target}
+	if port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return compiledRule{}, fmt.Errorf("match %q: invalid port", r.Match)
+		}
+		cr.port = p
+	}
+	switch {
+	case host == "":
+		cr.domain = "*"
+	case strings.Contains(host, "/"):
+		_, n, err := net.ParseCIDR(host)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("match %q: %w", r.Match, err)
+		}
+		cr.ipNet = n
+	case net.ParseIP(host) != nil:
+		ip := net.ParseIP(host)
+		bits := 128
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 32
+		}
+		cr.ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	default:
+		cr.domain = host
+	}
+	return cr, nil
+}
+
+// Lookup 按客户端请求的目标 host（域名或 IP）和端口查找第一条命中的规则
+func (t *Table) Lookup(host string, port int) (Target, bool) {
+	if t == nil {
+		return Target{}, false
+	}
+	ip := net.ParseIP(host)
+	for _, r := range t.rules {
+		if r.port != 0 && r.port != port {
+			continue
+		}
+		if r.ipNet != nil {
+			if ip == nil || !r.ipNet.Contains(ip) {
+				continue
+			}
+		} else if !shared.MatchDomain(r.domain, host) {
+			continue
+		}
+		return r.target, true
+	}
+	return Target{}, false
+}
+
+// Len 规则条数
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.rules)
+}
+
+// splitAddr 拆分 "host:port" / "host" / ":port"，支持 "[::1]:80" 和不带端口的 IPv6
+func splitAddr(s string) (host, port string) {
+	s = strings.TrimSpace(s)
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		return h, p
+	}
+	return strings.Trim(s, "[]"), ""
+}
+
+// HostHeader 改写 Host 头时使用的值，默认端口（80 / 443）省略
+func HostHeader(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if port == "80" || port == "443" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return addr
+}
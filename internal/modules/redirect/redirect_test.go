@@ -0,0 +1,112 @@
+package redirect
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestTableLookup(t *testing.T) {
+	table, err := NewTable([]Rule{
+		{Match: "gameserver:7000", Target: Target{To: "127.0.0.1:7001"}},
+		{Match: "*.cdn.test", Target: Target{To: "127.0.0.2", RewriteHost: true}},
+		{Match: "10.0.0.0/8:443", Target: Target{To: ":8443"}},
+		{Match: "2001:db8::1", Target: Target{To: "[::1]:9000"}},
+		{Match: ":25", Target: Target{To: "127.0.0.1:2525"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		host string
+		port int
+		want string // 空表示不重定向
+	}{
+		{"gameserver", 7000, "127.0.0.1:7001"},
+		{"GameServer", 7000, "127.0.0.1:7001"},
+		{"gameserver", 7001, ""},
+		{"img.cdn.test", 443, "127.0.0.2:443"},
+		{"10.1.2.3", 443, "10.1.2.3:8443"},
+		{"10.1.2.3", 80, ""},
+		{"2001:db8::1", 80, "[::1]:9000"},
+		{"mail.test", 25, "127.0.0.1:2525"},
+		{"other.test", 80, ""},
+	} {
+		target, ok := table.Lookup(tc.host, tc.port)
+		got := ""
+		if ok {
+			got = target.Apply(net.JoinHostPort(tc.host, strconv.Itoa(tc.port)))
+		}
+		if got != tc.want {
+			t.Errorf("Lookup(%s, %d) = %q, want %q", tc.host, tc.port, got, tc.want)
+		}
+	}
+
+	var nilTable *Table
+	if _, ok := nilTable.Lookup("gameserver", 7000); ok || nilTable.Len() != 0 {
+		t.Fatal("nil table matched")
+	}
+}
+
+func TestTableValidation(t *testing.T) {
+	for _, r := range []Rule{
+		{Match: "gameserver:7000"},
+		{Match: "", Target: Target{To: "127.0.0.1"}},
+		{Match: "gameserver:70000", Target: Target{To: "127.0.0.1"}},
+		{Match: "10.0.0.0/33", Target: Target{To: "127.0.0.1"}},
+		{Match: "gameserver", Target: Target{To: "127.0.0.1:x"}},
+		{Match: "gameserver", Target: Target{To: "*.test:80"}},
+	} {
+		if _, err := NewTable([]Rule{r}); err == nil {
+			t.Errorf("%+v: expected error", r)
+		}
+	}
+}
+
+func TestHostHeader(t *testing.T) {
+	for in, want := range map[string]string{
+		"dev.local:80":   "dev.local",
+		"dev.local:8080": "dev.local:8080",
+		"[::1]:443":      "[::1]",
+	} {
+		if got := HostHeader(in); got != want {
+			t.Errorf("HostHeader(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRewriteHost(t *testing.T) {
+	client, server := net.Pipe()
+	conn := RewriteHost(client, "dev.local:8080")
+
+	writes := []string{
+		"GET /a HTTP/1.1\r\nUser-Agent: x\r\nhost: game.test\r\n\r\n",
+		"body GET / HTTP/1.1\r\nHost: game.test\r\n\r\n",
+		"\x16\x03\x01\x00\x05hello",
+	}
+	want := []string{
+		"GET /a HTTP/1.1\r\nUser-Agent: x\r\nHost: dev.local:8080\r\n\r\n",
+		writes[1],
+		writes[2],
+	}
+	go func() {
+		for _, w := range writes {
+			if n, err := conn.Write([]byte(w)); err != nil || n != len(w) {
+				t.Errorf("Write = %d, %v", n, err)
+			}
+		}
+		conn.Close()
+	}()
+
+	for _, w := range want {
+		buf := make([]byte, len(w))
+		if _, err := io.ReadFull(server, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != w {
+			t.Fatalf("got %q, want %q", buf, w)
+		}
+	}
+}
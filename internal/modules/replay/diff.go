@@ -0,0 +1,129 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/jsonpath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiffJSON 比较两个解码结果，返回字段级差异（忽略 ignore 前缀下的字段）
+func DiffJSON(recorded, replayed json.RawMessage, ignore []string) ([]Difference, error) {
+	var a, b any
+	if err := json.Unmarshal(recorded, &a); err != nil {
+		return nil, fmt.Errorf("recorded: %w", err)
+	}
+	if err := json.Unmarshal(replayed, &b); err != nil {
+		return nil, fmt.Errorf("replayed: %w", err)
+	}
+
+	norm := make([]string, 0, len(ignore))
+	for _, s := range ignore {
+		if p, err := jsonpath.Parse(s); err == nil {
+			norm = append(norm, p.String())
+		}
+	}
+
+	var out []Difference
+	diffValue(nil, a, b, norm, &out)
+	return out, nil
+}
+
+func diffValue(path jsonpath.Path, a, b any, ignore []string, out *[]Difference) {
+	if ignored(path, ignore) {
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			*out = append(*out, Difference{Path: path.String(), Kind: "type", Recorded: a, Replayed: b})
+			return
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := append(path[:len(path):len(path)], jsonpath.Segment{Key: k})
+			x, okA := av[k]
+			y, okB := bv[k]
+			switch {
+			case !okB:
+				if !ignored(p, ignore) {
+					*out = append(*out, Difference{Path: p.String(), Kind: "missing", Recorded: x})
+				}
+			case !okA:
+				if !ignored(p, ignore) {
+					*out = append(*out, Difference{Path: p.String(), Kind: "extra", Replayed: y})
+				}
+			default:
+				diffValue(p, x, y, ignore, out)
+			}
+		}
+
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			*out = append(*out, Difference{Path: path.String(), Kind: "type", Recorded: a, Replayed: b})
+			return
+		}
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			p := append(path[:len(path):len(path)], jsonpath.Segment{Index: i, IsIdx: true})
+			switch {
+			case i >= len(bv):
+				*out = append(*out, Difference{Path: p.String(), Kind: "missing", Recorded: av[i]})
+			case i >= len(av):
+				*out = append(*out, Difference{Path: p.String(), Kind: "extra", Replayed: bv[i]})
+			default:
+				diffValue(p, av[i], bv[i], ignore, out)
+			}
+		}
+
+	default:
+		if !reflect.DeepEqual(a, b) {
+			kind := "changed"
+			if reflect.TypeOf(a) != reflect.TypeOf(b) {
+				kind = "type"
+			}
+			*out = append(*out, Difference{Path: path.String(), Kind: kind, Recorded: a, Replayed: b})
+		}
+	}
+}
+
+func ignored(path jsonpath.Path, ignore []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	s := path.String()
+	for _, p := range ignore {
+		if s == p || strings.HasPrefix(s, p+".") || strings.HasPrefix(s, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffRaw 无法解码时按字节比较
+func diffRaw(index int, recorded, replayed []byte) []Difference {
+	if bytes.Equal(recorded, replayed) {
+		return nil
+	}
+	return []Difference{{
+		Index:    index,
+		Kind:     "changed",
+		Recorded: hex.EncodeToString(recorded),
+		Replayed: hex.EncodeToString(replayed),
+	}}
+}
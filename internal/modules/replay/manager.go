@@ -0,0 +1,104 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"proxy-system-backend/internal/modules/shared"
+	"sort"
+	"sync"
+)
+
+// 保留的已结束回放数
+const maxFinished = 100
+
+// Manager 管理进行中和已结束的回放
+type Manager struct {
+	mu       sync.Mutex
+	runs     map[string]*Run
+	onUpdate func(r Report, ex *Exchange)
+}
+
+func NewManager() *Manager {
+	return &Manager{runs: make(map[string]*Run)}
+}
+
+// OnUpdate 每轮结束和状态变化时回调（ex 为 nil 表示状态变化）
+func (m *Manager) OnUpdate(fn func(r Report, ex *Exchange)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onUpdate = fn
+}
+
+// Start 异步开始回放
+func (m *Manager) Start(cfg Config, target, plugin string, msgs []Message, codec Codec, dial Dialer) (*Run, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if target == "" {
+		return nil, fmt.Errorf("target is required")
+	}
+	if dial == nil {
+		return nil, fmt.Errorf("dialer is required")
+	}
+
+	r := newRun(shared.GenerateConnID(), cfg, target, plugin, msgs, codec, dial)
+	if r.report.Total == 0 {
+		return nil, fmt.Errorf("session %s has nothing to replay", cfg.ConnID)
+	}
+
+	m.mu.Lock()
+	r.onUpdate = m.onUpdate
+	m.runs[r.report.ID] = r
+	m.prune()
+	m.mu.Unlock()
+
+	r.start(context.Background())
+	return r, nil
+}
+
+func (m *Manager) Get(id string) (*Run, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.runs[id]
+	return r, ok
+}
+
+// List 按开始时间倒序返回所有回放（不含逐轮明细）
+func (m *Manager) List() []Report {
+	m.mu.Lock()
+	runs := make([]*Run, 0, len(m.runs))
+	for _, r := range m.runs {
+		runs = append(runs, r)
+	}
+	m.mu.Unlock()
+
+	out := make([]Report, 0, len(runs))
+	for _, r := range runs {
+		rep := r.Report()
+		rep.Exchanges = nil
+		out = append(out, rep)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out
+}
+
+// prune 已结束的回放超过上限时删除最早的（调用方持有锁）
+func (m *Manager) prune() {
+	var finished []*Run
+	for _, r := range m.runs {
+		select {
+		case <-r.done:
+			finished = append(finished, r)
+		default:
+		}
+	}
+	if len(finished) <= maxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].report.StartedAt.Before(finished[j].report.StartedAt)
+	})
+	for _, r := range finished[:len(finished)-maxFinished] {
+		delete(m.runs, r.report.ID)
+	}
+}
@@ -0,0 +1,509 @@
+package replay
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"proxy-system-backend/internal/modules/jsonpath"
+	"proxy-system-backend/internal/traffic"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Codec 插件编解码（由 app 层基于插件实现）
+type Codec interface {
+	Decode(payload []byte, isClient bool) (json.RawMessage, error)
+	Encode(data json.RawMessage) ([]byte, error)
+}
+
+// Dialer 连接回放目标（真实服务器或 mock）
+type Dialer func(ctx context.Context, addr string) (net.Conn, error)
+
+// ErrNotStepping 非 step 模式或当前不在等待放行
+var ErrNotStepping = errors.New("replay is not waiting for a step")
+
+// plan 一轮：一条客户端请求及其后的服务端响应
+type plan struct {
+	req  *Message
+	resp []Message
+}
+
+type chunk struct {
+	t    time.Time
+	data []byte
+}
+
+// Run 一次回放
+type Run struct {
+	mu     sync.Mutex
+	report Report
+
+	cfg   Config
+	plans []plan
+	codec Codec
+	dial  Dialer
+
+	step     chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+	onUpdate func(r Report, ex *Exchange)
+
+	// 从响应中提取的变量与序列号计数
+	vars map[string]any
+	seqs []int64
+}
+
+func newRun(id string, cfg Config, target, plugin string, msgs []Message, codec Codec, dial Dialer) *Run {
+	r := &Run{
+		cfg:   cfg,
+		plans: buildPlans(msgs),
+		codec: codec,
+		dial:  dial,
+		step:  make(chan struct{}),
+		done:  make(chan struct{}),
+		vars:  make(map[string]any),
+		seqs:  make([]int64, len(cfg.Rewrite.Sequence)),
+	}
+	r.report = Report{
+		ID:        id,
+		Config:    cfg,
+		Target:    target,
+		Plugin:    plugin,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+		Total:     len(r.plans),
+	}
+	return r
+}
+
+// buildPlans 按客户端请求切分轮次；首条请求之前的服务端数据单独成一轮
+func buildPlans(msgs []Message) []plan {
+	var out []plan
+	for i := range msgs {
+		m := msgs[i]
+		switch m.Direction {
+		case traffic.DirectionOut:
+			out = append(out, plan{req: &m})
+		case traffic.DirectionIn:
+			if len(out) == 0 {
+				out = append(out, plan{})
+			}
+			out[len(out)-1].resp = append(out[len(out)-1].resp, m)
+		}
+	}
+	return out
+}
+
+// Report 当前报告的快照
+func (r *Run) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.report
+	rep.Exchanges = append([]Exchange(nil), r.report.Exchanges...)
+	return rep
+}
+
+// Step step 模式下放行下一条请求
+func (r *Run) Step() error {
+	select {
+	case r.step <- struct{}{}:
+		return nil
+	default:
+		return ErrNotStepping
+	}
+}
+
+func (r *Run) Cancel() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// Wait 等待回放结束并返回最终报告
+func (r *Run) Wait() Report {
+	<-r.done
+	return r.Report()
+}
+
+func (r *Run) setStatus(s Status) {
+	r.mu.Lock()
+	r.report.Status = s
+	rep := r.report
+	r.mu.Unlock()
+	if r.onUpdate != nil {
+		r.onUpdate(rep, nil)
+	}
+}
+
+func (r *Run) finish(status Status, err error) {
+	now := time.Now()
+	r.mu.Lock()
+	r.report.Status = status
+	r.report.FinishedAt = &now
+	if err != nil {
+		r.report.Error = err.Error()
+	}
+	rep := r.report
+	r.mu.Unlock()
+
+	if r.onUpdate != nil {
+		r.onUpdate(rep, nil)
+	}
+	close(r.done)
+}
+
+func (r *Run) start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	r.cancel = cancel
+	go func() {
+		defer cancel()
+		r.run(ctx)
+	}()
+}
+
+func (r *Run) run(ctx context.Context) {
+	conn, err := r.dial(ctx, r.report.Target)
+	if err != nil {
+		r.finish(StatusFailed, fmt.Errorf("dial %s: %w", r.report.Target, err))
+		return
+	}
+	defer conn.Close()
+
+	// run 返回（取消、写入失败等）后停止转发响应，避免读取协程阻塞在发送上
+	readCtx, stopRead := context.WithCancel(ctx)
+	defer stopRead()
+	chunks := make(chan chunk, 64)
+	go readChunks(readCtx, conn, chunks)
+
+	start := time.Now()
+	var first time.Time
+	for _, p := range r.plans {
+		if p.req != nil {
+			first = p.req.Time
+			break
+		}
+	}
+
+	for i, p := range r.plans {
+		if err := r.pace(ctx, p, start, first); err != nil {
+			r.finish(StatusCanceled, err)
+			return
+		}
+
+		began := time.Now()
+		ex := Exchange{Index: i, Expected: r.decodeAll(p.resp, false)}
+
+		if p.req != nil {
+			payload, sent, changes := r.prepare(*p.req)
+			ex.Request, ex.Sent, ex.Rewrites = payload, sent, changes
+
+			_ = conn.SetWriteDeadline(time.Now().Add(r.cfg.timeout()))
+			if _, err := conn.Write(payload); err != nil {
+				r.finish(StatusFailed, fmt.Errorf("write request %d: %w", i, err))
+				return
+			}
+		}
+
+		expected := 0
+		for _, m := range p.resp {
+			expected += len(m.Payload)
+		}
+		ex.Received = r.decodeAll(r.collect(ctx, chunks, expected), false)
+		r.correlate(ex.Received)
+
+		ex.Differences = r.compare(ex.Expected, ex.Received)
+		ex.Matched = len(ex.Differences) == 0
+		ex.Elapsed = time.Since(began).Milliseconds()
+
+		r.mu.Lock()
+		r.report.Exchanges = append(r.report.Exchanges, ex)
+		if ex.Matched {
+			r.report.Matched++
+		} else {
+			r.report.Mismatched++
+		}
+		rep := r.report
+		r.mu.Unlock()
+
+		if r.onUpdate != nil {
+			r.onUpdate(rep, &ex)
+		}
+		if ctx.Err() != nil {
+			r.finish(StatusCanceled, ctx.Err())
+			return
+		}
+	}
+
+	r.finish(StatusDone, nil)
+}
+
+// pace 按模式等待发送时机
+func (r *Run) pace(ctx context.Context, p plan, start, first time.Time) error {
+	if p.req == nil {
+		return ctx.Err()
+	}
+
+	switch r.cfg.Mode {
+	case ModeOriginal:
+		offset := time.Duration(float64(p.req.Time.Sub(first)) / r.cfg.Speed)
+		if wait := time.Until(start.Add(offset)); wait > 0 {
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+	case ModeStep:
+		r.setStatus(StatusWaiting)
+		select {
+		case <-r.step:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		r.setStatus(StatusRunning)
+	}
+	return ctx.Err()
+}
+
+func readChunks(ctx context.Context, conn net.Conn, out chan<- chunk) {
+	defer close(out)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			select {
+			case out <- chunk{t: time.Now(), data: append([]byte(nil), buf[:n]...)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// collect 收集本轮响应：收齐录制的字节数、空闲超时或总超时为止
+// 没有预期响应时只等待一个空闲周期
+func (r *Run) collect(ctx context.Context, chunks <-chan chunk, expected int) []Message {
+	idle := time.NewTimer(r.cfg.idle())
+	defer idle.Stop()
+	deadline := time.NewTimer(r.cfg.timeout())
+	defer deadline.Stop()
+
+	var (
+		out   []Message
+		total int
+	)
+	for expected == 0 || total < expected {
+		select {
+		case c, ok := <-chunks:
+			if !ok {
+				return out
+			}
+			out = append(out, Message{Time: c.t, Direction: traffic.DirectionIn, Payload: c.data})
+			total += len(c.data)
+			idle.Reset(r.cfg.idle())
+		case <-idle.C:
+			if expected == 0 || len(out) > 0 {
+				return out
+			}
+		case <-deadline.C:
+			return out
+		case <-ctx.Done():
+			return out
+		}
+	}
+	return out
+}
+
+// decodeAll 补全缺少解码结果的消息
+func (r *Run) decodeAll(msgs []Message, isClient bool) []Message {
+	if r.codec == nil {
+		return msgs
+	}
+	out := make([]Message, len(msgs))
+	for i, m := range msgs {
+		if len(m.Decoded) == 0 {
+			if d, err := r.codec.Decode(m.Payload, isClient); err == nil {
+				m.Decoded = d
+			}
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// prepare 在请求的解码结果上应用改写，经插件 Encode 得到发送的字节
+// 没有改动或无法编码时发送录制的原始字节
+func (r *Run) prepare(req Message) ([]byte, json.RawMessage, []Change) {
+	if r.cfg.Rewrite.empty() || r.codec == nil {
+		return req.Payload, nil, nil
+	}
+	decoded := req.Decoded
+	if len(decoded) == 0 {
+		d, err := r.codec.Decode(req.Payload, true)
+		if err != nil {
+			return req.Payload, nil, nil
+		}
+		decoded = d
+	}
+
+	var doc any
+	if err := json.Unmarshal(decoded, &doc); err != nil {
+		return req.Payload, nil, nil
+	}
+
+	var changes []Change
+	set := func(path string, v any, onlyExisting bool) {
+		p, err := jsonpath.Parse(path)
+		if err != nil {
+			return
+		}
+		before, ok := p.Get(doc)
+		if onlyExisting && !ok {
+			return
+		}
+		if ok && reflect.DeepEqual(before, v) {
+			return
+		}
+		if d, err := p.Set(doc, v); err == nil {
+			doc = d
+			changes = append(changes, Change{Path: p.String(), Before: before, After: v})
+		}
+	}
+
+	rw := r.cfg.Rewrite
+	for _, a := range rw.Set {
+		var v any
+		if err := json.Unmarshal(a.Value, &v); err == nil {
+			set(a.Path, v, false)
+		}
+	}
+	for i, s := range rw.Sequence {
+		p, _ := jsonpath.Parse(s.Path)
+		if _, ok := p.Get(doc); !ok {
+			continue
+		}
+		step := s.Step
+		if step == 0 {
+			step = 1
+		}
+		set(s.Path, float64(s.Start+r.seqs[i]*step), true)
+		r.seqs[i]++
+	}
+	for _, c := range rw.Correlate {
+		if v, ok := r.vars[c.Name]; ok {
+			set(c.To, v, true)
+		}
+	}
+
+	if len(changes) == 0 {
+		return req.Payload, decoded, nil
+	}
+
+	sent, err := json.Marshal(doc)
+	if err != nil {
+		return req.Payload, decoded, nil
+	}
+	payload, err := r.codec.Encode(sent)
+	if err == nil && rw.Length != nil {
+		payload, err = rw.Length.Apply(payload)
+	}
+	if err != nil || len(payload) == 0 {
+		return req.Payload, decoded, nil
+	}
+	return payload, sent, changes
+}
+
+// correlate 从实际响应中提取变量，供后续请求使用
+func (r *Run) correlate(received []Message) {
+	for _, c := range r.cfg.Rewrite.Correlate {
+		p, err := jsonpath.Parse(c.From)
+		if err != nil {
+			continue
+		}
+		for _, m := range received {
+			if len(m.Decoded) == 0 {
+				continue
+			}
+			var doc any
+			if json.Unmarshal(m.Decoded, &doc) != nil {
+				continue
+			}
+			if v, ok := p.Get(doc); ok {
+				r.vars[c.Name] = v
+			}
+		}
+	}
+}
+
+// compare 按顺序配对录制与实际响应
+func (r *Run) compare(expected, received []Message) []Difference {
+	received = r.resplit(expected, received)
+
+	var out []Difference
+	for i := 0; i < max(len(expected), len(received)); i++ {
+		switch {
+		case i >= len(received):
+			out = append(out, Difference{Index: i, Kind: "missing", Recorded: display(expected[i])})
+		case i >= len(expected):
+			out = append(out, Difference{Index: i, Kind: "extra", Replayed: display(received[i])})
+		default:
+			e, g := expected[i], received[i]
+			if len(e.Decoded) > 0 && len(g.Decoded) > 0 {
+				diffs, err := DiffJSON(e.Decoded, g.Decoded, r.cfg.Ignore)
+				if err == nil {
+					for _, d := range diffs {
+						d.Index = i
+						out = append(out, d)
+					}
+					continue
+				}
+			}
+			out = append(out, diffRaw(i, e.Payload, g.Payload)...)
+		}
+	}
+	return out
+}
+
+// resplit TCP 合并了服务端的多次写入时，按录制的边界重新切分
+func (r *Run) resplit(expected, received []Message) []Message {
+	if len(expected) == len(received) || len(expected) == 0 {
+		return received
+	}
+	var want, got int
+	for _, m := range expected {
+		want += len(m.Payload)
+	}
+	var all []byte
+	for _, m := range received {
+		got += len(m.Payload)
+		all = append(all, m.Payload...)
+	}
+	if want != got {
+		return received
+	}
+
+	out := make([]Message, 0, len(expected))
+	for _, e := range expected {
+		m := Message{Time: received[0].Time, Direction: traffic.DirectionIn, Payload: all[:len(e.Payload)]}
+		all = all[len(e.Payload):]
+		out = append(out, m)
+	}
+	return r.decodeAll(out, false)
+}
+
+func display(m Message) any {
+	if len(m.Decoded) > 0 {
+		return m.Decoded
+	}
+	return hex.EncodeToString(m.Payload)
+}
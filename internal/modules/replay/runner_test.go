@@ -0,0 +1,164 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+	"time"
+)
+
+// lineCodec 以换行分隔的 JSON 作为测试协议
+type lineCodec struct{}
+
+func (lineCodec) Decode(payload []byte, _ bool) (json.RawMessage, error) {
+	b := bytes.TrimSpace(payload)
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (lineCodec) Encode(data json.RawMessage) ([]byte, error) {
+	return append(append([]byte(nil), data...), '\n'), nil
+}
+
+// mockServer 登录返回新 token，后续请求校验 token；记录收到的 seq
+func mockServer(t *testing.T) (string, chan float64) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	seqs := make(chan float64, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			var req map[string]any
+			_ = json.Unmarshal(sc.Bytes(), &req)
+			seqs <- req["seq"].(float64)
+
+			var resp map[string]any
+			switch req["cmd"] {
+			case "login":
+				resp = map[string]any{"ok": true, "token": "live-token"}
+			default:
+				resp = map[string]any{"value": 0, "ts": time.Now().UnixNano()}
+				if req["token"] == "live-token" {
+					resp["value"] = 1
+				}
+			}
+			b, _ := json.Marshal(resp)
+			_, _ = conn.Write(append(b, '\n'))
+		}
+	}()
+	return ln.Addr().String(), seqs
+}
+
+func line(dir traffic.Direction, at time.Time, s string) Message {
+	return Message{Time: at, Direction: dir, Payload: []byte(s + "\n")}
+}
+
+func TestReplayCorrelateSequenceAndDiff(t *testing.T) {
+	addr, seqs := mockServer(t)
+
+	now := time.Now()
+	msgs := []Message{
+		line(traffic.DirectionOut, now, `{"cmd":"login","seq":1}`),
+		line(traffic.DirectionIn, now, `{"ok":true,"token":"rec-token"}`),
+		line(traffic.DirectionOut, now, `{"cmd":"get","seq":2,"token":"rec-token"}`),
+		line(traffic.DirectionIn, now, `{"ts":1,"value":1}`),
+	}
+
+	cfg := Config{
+		ConnID: "c1",
+		Mode:   ModeFast,
+		Ignore: []string{"ts"},
+		Rewrite: Rewrite{
+			Correlate: []Correlation{{Name: "token", From: "token", To: "token"}},
+			Sequence:  []Sequence{{Path: "seq", Start: 10}},
+		},
+	}
+
+	m := NewManager()
+	run, err := m.Start(cfg, addr, "line", msgs, lineCodec{}, func(ctx context.Context, a string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", a)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := run.Wait()
+
+	if rep.Status != StatusDone {
+		t.Fatalf("status = %s (%s)", rep.Status, rep.Error)
+	}
+	if got := []float64{<-seqs, <-seqs}; got[0] != 10 || got[1] != 11 {
+		t.Fatalf("server saw seq %v, want [10 11]", got)
+	}
+
+	// 第一轮：token 不同；第二轮：带上实际 token，ts 被忽略
+	first := rep.Exchanges[0]
+	if first.Matched || len(first.Differences) != 1 || first.Differences[0].Path != "token" {
+		t.Fatalf("first exchange diff = %+v", first.Differences)
+	}
+	second := rep.Exchanges[1]
+	if !second.Matched {
+		t.Fatalf("second exchange diff = %+v", second.Differences)
+	}
+	if len(second.Rewrites) != 2 {
+		t.Fatalf("rewrites = %+v", second.Rewrites)
+	}
+	if rep.Matched != 1 || rep.Mismatched != 1 {
+		t.Fatalf("matched = %d mismatched = %d", rep.Matched, rep.Mismatched)
+	}
+}
+
+func TestReplayStepMode(t *testing.T) {
+	addr, _ := mockServer(t)
+
+	now := time.Now()
+	msgs := []Message{
+		line(traffic.DirectionOut, now, `{"cmd":"login","seq":1}`),
+		line(traffic.DirectionIn, now, `{"ok":true,"token":"live-token"}`),
+	}
+
+	m := NewManager()
+	run, err := m.Start(Config{ConnID: "c1", Mode: ModeStep}, addr, "", msgs, lineCodec{}, func(ctx context.Context, a string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", a)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for run.Report().Status != StatusWaiting {
+		if time.Now().After(deadline) {
+			t.Fatal("replay never waited for a step")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(run.Report().Exchanges) != 0 {
+		t.Fatal("request sent before step")
+	}
+	if err := run.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := run.Wait()
+	if rep.Status != StatusDone || rep.Matched != 1 {
+		t.Fatalf("report = %+v", rep)
+	}
+}
@@ -0,0 +1,216 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/jsonpath"
+	"proxy-system-backend/internal/modules/rewrite"
+	"proxy-system-backend/internal/traffic"
+	"time"
+)
+
+// Mode 发送节奏
+type Mode string
+
+const (
+	ModeOriginal Mode = "original" // 按录制时的间隔发送（可用 Speed 加速）
+	ModeFast     Mode = "fast"     // 收到响应后立即发送下一条
+	ModeStep     Mode = "step"     // 每条请求等待操作员单步放行
+)
+
+// Status 回放状态
+type Status string
+
+const (
+	StatusRunning  Status = "running"
+	StatusWaiting  Status = "waiting" // step 模式等待放行
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// Config 回放配置
+type Config struct {
+	ConnID string `json:"conn_id"`
+	// 目标地址 host:port，为空时使用录制会话的目标
+	Target string `json:"target,omitempty"`
+
+	Mode  Mode    `json:"mode"`
+	Speed float64 `json:"speed,omitempty"` // original 模式的倍速，默认 1
+
+	// 编解码插件，为空时使用录制时的插件
+	Plugin string `json:"plugin,omitempty"`
+
+	Rewrite Rewrite `json:"rewrite"`
+
+	// 比较时忽略的字段（时间戳等），按路径前缀匹配
+	Ignore []string `json:"ignore,omitempty"`
+
+	// 等待响应：收齐录制的字节数，或空闲超过 IdleMs，最长 TimeoutMs
+	IdleMs    int `json:"idle_ms,omitempty"`
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
+
+const (
+	defaultIdle    = 300 * time.Millisecond
+	defaultTimeout = 5 * time.Second
+)
+
+func (c *Config) Validate() error {
+	switch c.Mode {
+	case "":
+		c.Mode = ModeFast
+	case ModeOriginal, ModeFast, ModeStep:
+	default:
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+	if c.Speed < 0 {
+		return fmt.Errorf("speed must be >= 0")
+	}
+	if c.Speed == 0 {
+		c.Speed = 1
+	}
+	for _, p := range c.Ignore {
+		if _, err := jsonpath.Parse(p); err != nil {
+			return fmt.Errorf("ignore: %w", err)
+		}
+	}
+	return c.Rewrite.validate()
+}
+
+func (c *Config) idle() time.Duration {
+	if c.IdleMs > 0 {
+		return time.Duration(c.IdleMs) * time.Millisecond
+	}
+	return defaultIdle
+}
+
+func (c *Config) timeout() time.Duration {
+	if c.TimeoutMs > 0 {
+		return time.Duration(c.TimeoutMs) * time.Millisecond
+	}
+	return defaultTimeout
+}
+
+// Rewrite 每次会话都不同的字段：在解码结果上修改后经插件 Encode 发送
+type Rewrite struct {
+	// 从服务端响应中取值，写入后续请求（token、会话 ID 等）
+	Correlate []Correlation `json:"correlate,omitempty"`
+	// 请求中的序列号按条重新编号
+	Sequence []Sequence `json:"sequence,omitempty"`
+	// 固定值
+	Set []rewrite.Assign `json:"set,omitempty"`
+	// 插件 Encode 未处理帧头时修正长度字段
+	Length *rewrite.LengthField `json:"length,omitempty"`
+}
+
+// Correlation 响应 From 字段的实际值写入之后请求的 To 字段
+type Correlation struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Sequence 请求中出现 Path 时依次设置为 Start, Start+Step, ...
+type Sequence struct {
+	Path  string `json:"path"`
+	Start int64  `json:"start"`
+	Step  int64  `json:"step,omitempty"` // 默认 1
+}
+
+func (r *Rewrite) validate() error {
+	for _, c := range r.Correlate {
+		if c.Name == "" {
+			return fmt.Errorf("correlate: name is required")
+		}
+		if _, err := jsonpath.Parse(c.From); err != nil {
+			return fmt.Errorf("correlate %s from: %w", c.Name, err)
+		}
+		if _, err := jsonpath.Parse(c.To); err != nil {
+			return fmt.Errorf("correlate %s to: %w", c.Name, err)
+		}
+	}
+	for _, s := range r.Sequence {
+		if _, err := jsonpath.Parse(s.Path); err != nil {
+			return fmt.Errorf("sequence: %w", err)
+		}
+	}
+	for _, a := range r.Set {
+		if _, err := jsonpath.Parse(a.Path); err != nil {
+			return fmt.Errorf("set: %w", err)
+		}
+		if !json.Valid(a.Value) {
+			return fmt.Errorf("set %s: invalid json value", a.Path)
+		}
+	}
+	if r.Length != nil {
+		if err := r.Length.Validate(); err != nil {
+			return fmt.Errorf("length: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *Rewrite) empty() bool {
+	return len(r.Correlate) == 0 && len(r.Sequence) == 0 && len(r.Set) == 0
+}
+
+// Message 录制的一条数据
+type Message struct {
+	Time      time.Time         `json:"time"`
+	Direction traffic.Direction `json:"direction"`
+	Payload   []byte            `json:"payload"`
+	Decoded   json.RawMessage   `json:"decoded,omitempty"`
+}
+
+// Change 请求改写的字段
+type Change struct {
+	Path   string `json:"path"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// Difference 录制与回放响应的差异；Path 为空表示整条消息（缺失 / 多出 / 无法解码）
+type Difference struct {
+	Index    int    `json:"index"` // 本轮第几条响应
+	Path     string `json:"path"`
+	Kind     string `json:"kind"` // changed / missing / extra / type
+	Recorded any    `json:"recorded,omitempty"`
+	Replayed any    `json:"replayed,omitempty"`
+}
+
+// Exchange 一轮请求与响应
+type Exchange struct {
+	Index int `json:"index"`
+
+	// 请求（首轮可能只有服务端主动下发的数据，没有请求）
+	Request  []byte          `json:"request,omitempty"`
+	Sent     json.RawMessage `json:"sent,omitempty"`
+	Rewrites []Change        `json:"rewrites,omitempty"`
+
+	Expected []Message `json:"expected"`
+	Received []Message `json:"received"`
+
+	Differences []Difference `json:"differences,omitempty"`
+	Matched     bool         `json:"matched"`
+	Elapsed     int64        `json:"elapsed_ms"`
+}
+
+// Report 回放报告
+type Report struct {
+	ID     string `json:"id"`
+	Config Config `json:"config"`
+	Target string `json:"target"`
+	Plugin string `json:"plugin,omitempty"`
+
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	Total      int        `json:"total"` // 总轮数
+	Matched    int        `json:"matched"`
+	Mismatched int        `json:"mismatched"`
+	Exchanges  []Exchange `json:"exchanges"`
+}
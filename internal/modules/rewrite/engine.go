@@ -0,0 +1,300 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"fmt"
+	"proxy-system-backend/internal/modules/jsonpath"
+	"proxy-system-backend/internal/traffic"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type compiledRule struct {
+	rule    Rule
+	match   []compiledMatch
+	set     []compiledAssign
+	replace []compiledReplace
+}
+
+type compiledMatch struct {
+	path   jsonpath.Path
+	equals any
+	exists bool
+}
+
+type compiledAssign struct {
+	path  jsonpath.Path
+	value any
+}
+
+type compiledReplace struct {
+	path     jsonpath.Path
+	old, new string
+}
+
+// Engine 改包规则集合（并发安全）
+type Engine struct {
+	mu     sync.RWMutex
+	rules  []*compiledRule
+	nextID int64
+}
+
+func NewEngine() *Engine {
+	return &Engine{nextID: 1}
+}
+
+// Compile 校验规则并编译路径
+func Compile(r Rule) (*compiledRule, error) {
+	cr := &compiledRule{rule: r}
+
+	for i, m := range r.Match {
+		p, err := jsonpath.Parse(m.Path)
+		if err != nil {
+			return nil, fmt.Errorf("match[%d]: %w", i, err)
+		}
+		cm := compiledMatch{path: p, exists: len(m.Equals) == 0}
+		if !cm.exists {
+			if err := json.Unmarshal(m.Equals, &cm.equals); err != nil {
+				return nil, fmt.Errorf("match[%d].equals: %w", i, err)
+			}
+		}
+		cr.match = append(cr.match, cm)
+	}
+
+	for i, a := range r.Set {
+		p, err := jsonpath.Parse(a.Path)
+		if err != nil {
+			return nil, fmt.Errorf("set[%d]: %w", i, err)
+		}
+		var v any
+		if err := json.Unmarshal(a.Value, &v); err != nil {
+			return nil, fmt.Errorf("set[%d].value: %w", i, err)
+		}
+		cr.set = append(cr.set, compiledAssign{path: p, value: v})
+	}
+
+	for i, rp := range r.Replace {
+		p, err := jsonpath.Parse(rp.Path)
+		if err != nil {
+			return nil, fmt.Errorf("replace[%d]: %w", i, err)
+		}
+		if rp.Old == "" {
+			return nil, fmt.Errorf("replace[%d].old is empty", i)
+		}
+		cr.replace = append(cr.replace, compiledReplace{path: p, old: rp.Old, new: rp.New})
+	}
+
+	if len(cr.set) == 0 && len(cr.replace) == 0 {
+		return nil, fmt.Errorf("rule has neither set nor replace")
+	}
+
+	if r.Length != nil {
+		if err := r.Length.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cr, nil
+}
+
+// Upsert 新增或更新规则（ID 为 0 时新增）
+func (e *Engine) Upsert(r Rule) (Rule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if r.ID == 0 {
+		r.ID = e.nextID
+	}
+	cr, err := Compile(r)
+	if err != nil {
+		return Rule{}, err
+	}
+	if r.ID >= e.nextID {
+		e.nextID = r.ID + 1
+	}
+
+	for i, old := range e.rules {
+		if old.rule.ID == r.ID {
+			e.rules[i] = cr
+			return r, nil
+		}
+	}
+	e.rules = append(e.rules, cr)
+	sort.Slice(e.rules, func(i, j int) bool { return e.rules[i].rule.ID < e.rules[j].rule.ID })
+	return r, nil
+}
+
+func (e *Engine) Delete(id int64) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, cr := range e.rules {
+		if cr.rule.ID == id {
+			e.rules = append(e.rules[:i], e.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) Get(id int64) (Rule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, cr := range e.rules {
+		if cr.rule.ID == id {
+			return cr.rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (e *Engine) List() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	out := make([]Rule, 0, len(e.rules))
+	for _, cr := range e.rules {
+		out = append(out, cr.rule)
+	}
+	return out
+}
+
+// Empty 没有启用的规则时可以跳过整个改写阶段
+func (e *Engine) Empty() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, cr := range e.rules {
+		if cr.rule.Enabled {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply 依次应用所有命中的规则，未改动时返回的 Result.Changed() 为 false
+func (e *Engine) Apply(dir traffic.Direction, plugin string, data json.RawMessage) (*Result, error) {
+	return e.ApplyWith(dir, plugin, data, nil)
+}
+
+// ApplyWith 同 Apply，另外应用 trigger 中的规则（过滤规则 rewrite:<id> 触发，
+// 不要求规则已启用，方向与插件仍需相符）
+func (e *Engine) ApplyWith(dir traffic.Direction, plugin string, data json.RawMessage, trigger []int64) (*Result, error) {
+	e.mu.RLock()
+	rules := make([]*compiledRule, 0, len(e.rules))
+	for _, cr := range e.rules {
+		if cr.applies(dir, plugin) || (slices.Contains(trigger, cr.rule.ID) && cr.targets(dir, plugin)) {
+			rules = append(rules, cr)
+		}
+	}
+	e.mu.RUnlock()
+
+	return apply(rules, data)
+}
+
+func apply(rules []*compiledRule, data json.RawMessage) (*Result, error) {
+	res := &Result{Before: data, After: data}
+	if len(rules) == 0 {
+		return res, nil
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decoded data is not JSON: %w", err)
+	}
+
+	for _, cr := range rules {
+		if !cr.matches(doc) {
+			continue
+		}
+
+		changes, next, err := cr.rewrite(doc)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", cr.rule.ID, err)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		doc = next
+		res.Rules = append(res.Rules, cr.rule.ID)
+		res.Changes = append(res.Changes, changes...)
+		if cr.rule.Length != nil {
+			res.Length = cr.rule.Length
+		}
+	}
+
+	if !res.Changed() {
+		return res, nil
+	}
+
+	after, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	res.After = after
+	return res, nil
+}
+
+func (cr *compiledRule) applies(dir traffic.Direction, plugin string) bool {
+	return cr.rule.Enabled && cr.targets(dir, plugin)
+}
+
+// targets 方向与插件是否相符
+func (cr *compiledRule) targets(dir traffic.Direction, plugin string) bool {
+	if cr.rule.Direction != traffic.DirectionUnknown && cr.rule.Direction != dir {
+		return false
+	}
+	return cr.rule.Plugin == "" || cr.rule.Plugin == plugin
+}
+
+func (cr *compiledRule) matches(doc any) bool {
+	for _, m := range cr.match {
+		v, ok := m.path.Get(doc)
+		if !ok {
+			return false
+		}
+		if !m.exists && !reflect.DeepEqual(v, m.equals) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cr *compiledRule) rewrite(doc any) ([]Change, any, error) {
+	var changes []Change
+
+	for _, a := range cr.set {
+		before, _ := a.path.Get(doc)
+		if reflect.DeepEqual(before, a.value) {
+			continue
+		}
+		next, err := a.path.Set(doc, a.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("set %s: %w", a.path, err)
+		}
+		doc = next
+		changes = append(changes, Change{RuleID: cr.rule.ID, Path: a.path.String(), Before: before, After: a.value})
+	}
+
+	for _, rp := range cr.replace {
+		v, ok := rp.path.Get(doc)
+		s, isStr := v.(string)
+		if !ok || !isStr || !strings.Contains(s, rp.old) {
+			continue
+		}
+		after := strings.ReplaceAll(s, rp.old, rp.new)
+		next, err := rp.path.Set(doc, after)
+		if err != nil {
+			return nil, nil, fmt.Errorf("replace %s: %w", rp.path, err)
+		}
+		doc = next
+		changes = append(changes, Change{RuleID: cr.rule.ID, Path: rp.path.String(), Before: s, After: after})
+	}
+
+	return changes, doc, nil
+}
@@ -0,0 +1,88 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"proxy-system-backend/internal/traffic"
+	"testing"
+)
+
+func TestApplySetAndReplace(t *testing.T) {
+	e := NewEngine()
+	_, err := e.Upsert(Rule{
+		Name:      "gold",
+		Enabled:   true,
+		Direction: traffic.DirectionIn,
+		Match:     []Match{{Path: "msg.type", Equals: json.RawMessage(`"Login"`)}},
+		Set:       []Assign{{Path: "player.gold", Value: json.RawMessage(`999`)}},
+		Replace:   []Replace{{Path: "player.name", Old: "bob", New: "alice"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := json.RawMessage(`{"msg":{"type":"Login"},"player":{"gold":1,"name":"bob_1"}}`)
+
+	res, err := e.Apply(traffic.DirectionOut, "p", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed() {
+		t.Fatalf("rule applied to wrong direction")
+	}
+
+	res, err = e.Apply(traffic.DirectionIn, "p", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changes) != 2 {
+		t.Fatalf("changes = %+v", res.Changes)
+	}
+
+	var out struct {
+		Player struct {
+			Gold int    `json:"gold"`
+			Name string `json:"name"`
+		} `json:"player"`
+	}
+	if err := json.Unmarshal(res.After, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Player.Gold != 999 || out.Player.Name != "alice_1" {
+		t.Fatalf("after = %s", res.After)
+	}
+}
+
+func TestLengthFieldApply(t *testing.T) {
+	l := &LengthField{Offset: 0, Size: 2, BigEndian: true}
+	frame, err := l.Apply([]byte{0, 0, 'a', 'b', 'c'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame[0] != 0 || frame[1] != 3 {
+		t.Fatalf("length field = %v", frame[:2])
+	}
+}
+
+func TestApplyWithTrigger(t *testing.T) {
+	e := NewEngine()
+	r, err := e.Upsert(Rule{
+		Name:      "on-demand",
+		Direction: traffic.DirectionIn,
+		Set:       []Assign{{Path: "player.gold", Value: json.RawMessage(`0`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := json.RawMessage(`{"player":{"gold":5}}`)
+	if res, _ := e.Apply(traffic.DirectionIn, "p", in); res.Changed() {
+		t.Fatalf("disabled rule applied without trigger")
+	}
+	if res, _ := e.ApplyWith(traffic.DirectionOut, "p", in, []int64{r.ID}); res.Changed() {
+		t.Fatalf("triggered rule applied to wrong direction")
+	}
+	res, err := e.ApplyWith(traffic.DirectionIn, "p", in, []int64{r.ID})
+	if err != nil || !res.Changed() || string(res.After) != `{"player":{"gold":0}}` {
+		t.Fatalf("res = %+v, err = %v", res, err)
+	}
+}
@@ -0,0 +1,56 @@
+package rewrite
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// LengthField 帧头中的长度字段
+// 写入值 = 长度字段之后的字节数 + Adjust
+type LengthField struct {
+	Offset    int  `json:"offset"`
+	Size      int  `json:"size"` // 1 / 2 / 4
+	BigEndian bool `json:"big_endian"`
+	Adjust    int  `json:"adjust,omitempty"`
+}
+
+func (l *LengthField) Validate() error {
+	if l.Offset < 0 {
+		return fmt.Errorf("length offset must be >= 0")
+	}
+	switch l.Size {
+	case 1, 2, 4:
+		return nil
+	default:
+		return fmt.Errorf("length size must be 1, 2 or 4, got %d", l.Size)
+	}
+}
+
+// Apply 按重新编码后的帧长度回写长度字段
+func (l *LengthField) Apply(frame []byte) ([]byte, error) {
+	end := l.Offset + l.Size
+	if len(frame) < end {
+		return nil, fmt.Errorf("frame too short for length field: %d < %d", len(frame), end)
+	}
+
+	v := len(frame) - end + l.Adjust
+	if v < 0 || (l.Size < 4 && v >= 1<<(8*l.Size)) {
+		return nil, fmt.Errorf("length %d does not fit in %d bytes", v, l.Size)
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if l.BigEndian {
+		order = binary.BigEndian
+	}
+
+	field := frame[l.Offset:end]
+	switch l.Size {
+	case 1:
+		field[0] = byte(v)
+	case 2:
+		order.PutUint16(field, uint16(v))
+	case 4:
+		order.PutUint32(field, uint32(v))
+	}
+	return frame, nil
+}
@@ -0,0 +1,73 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"proxy-system-backend/internal/traffic"
+)
+
+// Rule 改包规则：decode → 匹配字段 → 修改 → encode
+type Rule struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+
+	// 生效方向（unknown 表示双向）
+	Direction traffic.Direction `json:"direction"`
+
+	// 仅对该插件的解码结果生效，为空表示任意插件
+	Plugin string `json:"plugin,omitempty"`
+
+	// 全部命中才会改写
+	Match []Match `json:"match,omitempty"`
+
+	// 设置字段值
+	Set []Assign `json:"set,omitempty"`
+
+	// 字符串字段内替换
+	Replace []Replace `json:"replace,omitempty"`
+
+	// 重新编码后修正长度字段（插件 Encode 未处理帧头时使用）
+	Length *LengthField `json:"length,omitempty"`
+}
+
+// Match 字段匹配条件，Equals 为空表示字段存在即可
+type Match struct {
+	Path   string          `json:"path"`
+	Equals json.RawMessage `json:"equals,omitempty"`
+}
+
+// Assign 将 Path 设置为 Value（JSON 值）
+type Assign struct {
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Replace 将字符串字段中的 Old 替换为 New
+type Replace struct {
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Change 单个字段的改动（before/after diff）
+type Change struct {
+	RuleID int64  `json:"rule_id"`
+	Path   string `json:"path"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// Result 一次改写的结果
+type Result struct {
+	Rules   []int64         `json:"rules"`
+	Before  json.RawMessage `json:"before"`
+	After   json.RawMessage `json:"after"`
+	Changes []Change        `json:"changes"`
+
+	// 最后一个命中且配置了长度字段的规则
+	Length *LengthField `json:"-"`
+}
+
+func (r *Result) Changed() bool {
+	return r != nil && len(r.Changes) > 0
+}
@@ -2,14 +2,59 @@ package shadowsocks
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"proxy-system-backend/internal/traffic"
+	"sync"
+	"time"
 )
 
+var errReset = errors.New("reset by hook")
+
 type proxyConn struct {
 	id   string
 	hook traffic.TrafficHook
+
+	// 拦截之后实际读写的两端
+	client net.Conn
+	remote net.Conn
+
+	// 按写入方向串行化 pipe 写入与注入写入
+	toRemote sync.Mutex
+	toClient sync.Mutex
+
+	// 连接建立时的上下文快照（注入时复制，不与 pipe 共享）
+	outBase traffic.PacketContext
+	inBase  traffic.PacketContext
+}
+
+// ConnInfo 活跃连接信息
+type ConnInfo struct {
+	ConnID    string    `json:"conn_id"`
+	Client    string    `json:"client"`
+	Target    string    `json:"target"`
+	Domain    string    `json:"domain,omitempty"`
+	TLS       bool      `json:"tls_intercepted,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func newProxyConn(id string, hook traffic.TrafficHook, client, remote net.Conn, outCtx, inCtx *traffic.PacketContext) *proxyConn {
+	return &proxyConn{
+		id:      id,
+		hook:    hook,
+		client:  client,
+		remote:  remote,
+		outBase: *outCtx,
+		inBase:  *inCtx,
+	}
+}
+
+func (c *proxyConn) writeLock(dir traffic.Direction) *sync.Mutex {
+	if dir == traffic.DirectionOut {
+		return &c.toRemote
+	}
+	return &c.toClient
 }
 
 func (c *proxyConn) pipe(
@@ -19,6 +64,7 @@ func (c *proxyConn) pipe(
 ) error {
 
 	buf := make([]byte, 32*1024)
+	mu := c.writeLock(ctx.Direction)
 
 	for {
 		n, err := src.Read(buf)
@@ -26,11 +72,20 @@ func (c *proxyConn) pipe(
 			ctx.Payload = buf[:n]
 
 			if c.hook != nil && !c.hook.OnPacket(ctx) {
+				if ctx.Reset {
+					return errReset
+				}
 				return errors.New("blocked by hook") // 被过滤，直接断
 			}
 
-			if _, werr := dst.Write(ctx.Payload); werr != nil {
-				return werr
+			// hook 可以清空 payload 来丢弃单个数据包
+			if len(ctx.Payload) > 0 {
+				mu.Lock()
+				_, werr := dst.Write(ctx.Payload)
+				mu.Unlock()
+				if werr != nil {
+					return werr
+				}
 			}
 		}
 		if err != nil {
@@ -41,3 +96,61 @@ func (c *proxyConn) pipe(
 		}
 	}
 }
+
+// inject 在两次 pipe 写入之间写入构造的数据包，并以 Injected 标记交给 hook 记录
+func (c *proxyConn) inject(dir traffic.Direction, payload []byte) error {
+	var (
+		dst  net.Conn
+		base traffic.PacketContext
+	)
+	switch dir {
+	case traffic.DirectionOut:
+		dst, base = c.remote, c.outBase
+	case traffic.DirectionIn:
+		dst, base = c.client, c.inBase
+	default:
+		return fmt.Errorf("invalid direction %q", dir)
+	}
+
+	ctx := base
+	ctx.Payload = payload
+	ctx.Injected = true
+
+	mu := c.writeLock(dir)
+	mu.Lock()
+	_, err := dst.Write(payload)
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if c.hook != nil {
+		c.hook.OnPacket(&ctx)
+	}
+	return nil
+}
+
+func (c *proxyConn) info() ConnInfo {
+	return ConnInfo{
+		ConnID:    c.id,
+		Client:    addrString(c.outBase.SrcAddr),
+		Target:    addrString(c.outBase.DstAddr),
+		Domain:    c.outBase.Domain,
+		TLS:       c.outBase.TLSIntercepted,
+		StartedAt: c.outBase.StartAt,
+	}
+}
+
+// resetConn 关闭时发送 RST（仅对 TCP 连接有效）
+func resetConn(c net.Conn) {
+	if tc, ok := c.(*net.TCPConn); ok {
+		_ = tc.SetLinger(0)
+	}
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
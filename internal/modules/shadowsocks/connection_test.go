@@ -0,0 +1,158 @@
+package shadowsocks
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"proxy-system-backend/internal/traffic"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordHook 记录收到的数据包
+type recordHook struct {
+	mu      sync.Mutex
+	packets []traffic.PacketContext
+}
+
+func (h *recordHook) OnPacket(ctx *traffic.PacketContext) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := *ctx
+	p.Payload = bytes.Clone(ctx.Payload)
+	h.packets = append(h.packets, p)
+	return true
+}
+
+// newTestConn 两端都用 net.Pipe，返回客户端和服务器各自读写的一端
+func newTestConn(t *testing.T, hook traffic.TrafficHook) (pc *proxyConn, client, remote net.Conn) {
+	t.Helper()
+	clientSide, client := net.Pipe()
+	remoteSide, remote := net.Pipe()
+	t.Cleanup(func() {
+		for _, c := range []net.Conn{clientSide, client, remoteSide, remote} {
+			c.Close()
+		}
+	})
+	outCtx := traffic.NewOutCtx("c1", clientSide, remoteSide)
+	inCtx := traffic.NewInCtx("c1", remoteSide, clientSide)
+	return newProxyConn("c1", hook, clientSide, remoteSide, outCtx, inCtx), client, remote
+}
+
+func readAsync(c net.Conn, n int) <-chan []byte {
+	ch := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c, buf); err != nil {
+			buf = nil
+		}
+		ch <- buf
+	}()
+	return ch
+}
+
+func TestInjectRoutesByDirection(t *testing.T) {
+	hook := &recordHook{}
+	pc, client, remote := newTestConn(t, hook)
+	s := NewServer(nil, nil, nil, nil)
+	s.conns.Store("c1", pc)
+
+	// out 发往服务器，in 发往客户端
+	toRemote := readAsync(remote, len("to-remote"))
+	if err := s.Inject("c1", traffic.DirectionOut, []byte("to-remote")); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-toRemote; string(b) != "to-remote" {
+		t.Fatalf("remote got %q", b)
+	}
+	toClient := readAsync(client, len("to-client"))
+	if err := s.Inject("c1", traffic.DirectionIn, []byte("to-client")); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-toClient; string(b) != "to-client" {
+		t.Fatalf("client got %q", b)
+	}
+
+	// 注入的数据包以 Injected 标记交给 hook
+	if len(hook.packets) != 2 {
+		t.Fatalf("hook got %d packets", len(hook.packets))
+	}
+	for i, want := range []struct {
+		dir     traffic.Direction
+		payload string
+	}{{traffic.DirectionOut, "to-remote"}, {traffic.DirectionIn, "to-client"}} {
+		p := hook.packets[i]
+		if !p.Injected || p.Direction != want.dir || string(p.Payload) != want.payload || p.ConnID != "c1" {
+			t.Fatalf("packet %d = %+v", i, p)
+		}
+	}
+
+	if err := s.Inject("missing", traffic.DirectionOut, []byte("x")); !errors.Is(err, ErrConnNotFound) {
+		t.Fatalf("unknown conn: err = %v", err)
+	}
+	if err := s.Inject("c1", traffic.DirectionUnknown, []byte("x")); err == nil {
+		t.Fatal("expected error for unknown direction")
+	}
+}
+
+// serialConn 检查 Write 是否被并发调用
+type serialConn struct {
+	net.Conn
+	active  atomic.Int32
+	overlap atomic.Bool
+	writes  atomic.Int32
+}
+
+func (c *serialConn) Write(p []byte) (int, error) {
+	if c.active.Add(1) > 1 {
+		c.overlap.Store(true)
+	}
+	time.Sleep(50 * time.Microsecond)
+	c.active.Add(-1)
+	c.writes.Add(1)
+	return len(p), nil
+}
+
+func TestInjectSerializedWithPipe(t *testing.T) {
+	pc, client, _ := newTestConn(t, nil)
+	dst := &serialConn{}
+	pc.remote = dst
+
+	const n = 100
+	done := make(chan error, 1)
+	outCtx := pc.outBase
+	go func() { done <- pc.pipe(dst, pc.client, &outCtx) }()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			_, _ = client.Write([]byte("relay"))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := pc.inject(traffic.DirectionOut, []byte("inject")); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	client.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.overlap.Load() {
+		t.Fatal("injected write overlapped a relay write")
+	}
+	if w := dst.writes.Load(); w != 2*n {
+		t.Fatalf("writes = %d, want %d", w, 2*n)
+	}
+}
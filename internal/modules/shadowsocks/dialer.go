@@ -3,9 +3,23 @@ package shadowsocks
 import (
 	"context"
 	"net"
+	"proxy-system-backend/internal/traffic"
 )
 
 type Dialer interface {
 	DialContext(ctx context.Context,
 		network, addr string) (net.Conn, error)
 }
+
+// Interceptor 在双向 pipe 之前接管连接（例如 TLS 中间人解密）
+// 返回值替换原有的 client / remote，未处理时原样返回
+type Interceptor interface {
+	Intercept(ctx *traffic.PacketContext, client, remote net.Conn) (net.Conn, net.Conn, error)
+}
+
+// Redirector 在连接目标之前改写目标地址（例如转发到本地开发服务器）
+// ctx 只有客户端地址、Domain、OrigDst 和目标端口（目标为 IP 时还有 DstIP）；
+// 返回实际连接的 host:port，不改写时返回空字符串，需要改写 SNI / Host 时设置 ctx.UpstreamHost
+type Redirector interface {
+	Redirect(ctx *traffic.PacketContext) string
+}
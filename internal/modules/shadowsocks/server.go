@@ -2,15 +2,21 @@ package shadowsocks
 
 import (
 	"context"
+	"errors"
 
 	"github.com/shadowsocks/go-shadowsocks2/core"
 	"github.com/shadowsocks/go-shadowsocks2/socks"
+	"log"
 	"net"
+	"proxy-system-backend/internal/modules/redirect"
 	"proxy-system-backend/internal/modules/shared"
 	"proxy-system-backend/internal/traffic"
+	"strconv"
 	"sync"
 )
 
+var ErrConnNotFound = errors.New("connection not found")
+
 type Server struct {
 	listener net.Listener
 	hookFn   func(connID string) traffic.TrafficHook
@@ -18,6 +24,12 @@ type Server struct {
 	dialer   Dialer
 	cipher   core.Cipher
 
+	interceptor Interceptor
+	redirector  Redirector
+
+	// connID -> *proxyConn
+	conns sync.Map
+
 	closeOnce sync.Once
 	closed    chan struct{}
 }
@@ -65,29 +77,139 @@ func (s *Server) handleConn(client net.Conn) {
 		return
 	}
 
-	// 3️⃣ 连接目标
-	remote, err := s.dialer.DialContext(context.Background(), "tcp", target.String())
+	// 3️⃣ 目标重定向
+	connID := shared.GenerateConnID()
+	origDst, dialAddr := target.String(), target.String()
+	var upstreamHost string
+	if s.redirector != nil {
+		rctx := targetCtx(connID, client, target)
+		if addr := s.redirector.Redirect(rctx); addr != "" {
+			dialAddr, upstreamHost = addr, rctx.UpstreamHost
+		}
+	}
+
+	// 4️⃣ 连接目标
+	remote, err := s.dialer.DialContext(context.Background(), "tcp", dialAddr)
 	if err != nil {
+		if dialAddr != origDst {
+			log.Printf("[Proxy] redirect %s -> %s failed: %v", origDst, dialAddr, err)
+		}
 		return
 	}
 	defer remote.Close()
 
-	// 4️⃣ 双向 pipe（你现在已有的）
-	connID := shared.GenerateConnID()
+	outCtx := traffic.NewOutCtx(connID, client, remote)
+	inCtx := traffic.NewInCtx(connID, remote, client)
+	for _, ctx := range []*traffic.PacketContext{outCtx, inCtx} {
+		ctx.Domain = targetDomain(target)
+		ctx.OrigDst, ctx.EffectiveDst = origDst, dialAddr
+		ctx.UpstreamHost = upstreamHost
+	}
+
+	// 5️⃣ 连接拦截（TLS 中间人等）
+	var src, dst net.Conn = ssConn, remote
+	if s.interceptor != nil {
+		src, dst, err = s.interceptor.Intercept(outCtx, ssConn, remote)
+		if err != nil {
+			log.Printf("[Proxy] intercept %s failed: %v", target, err)
+			return
+		}
+		inCtx.TLSIntercepted = outCtx.TLSIntercepted
+		if src != ssConn {
+			defer src.Close()
+		}
+		if dst != remote {
+			defer dst.Close()
+		}
+	}
+	if upstreamHost != "" {
+		// 改写（解密后的）HTTP 请求的 Host
+		dst = redirect.RewriteHost(dst, upstreamHost)
+	}
+
+	// 6️⃣ 双向 pipe
 	hook := s.hookFn(connID)
-	pc := &proxyConn{id: connID, hook: hook}
+	if ch, ok := hook.(traffic.CloseHook); ok {
+		defer ch.OnClose()
+	}
+	pc := newProxyConn(connID, hook, src, dst, outCtx, inCtx)
+
+	s.conns.Store(connID, pc)
+	defer s.conns.Delete(connID)
 
 	errCh := make(chan error, 2)
 
 	go func() {
-		errCh <- pc.pipe(remote, ssConn, traffic.NewOutCtx(connID, client, remote))
+		errCh <- pc.pipe(dst, src, outCtx)
 	}()
 
 	go func() {
-		errCh <- pc.pipe(ssConn, remote, traffic.NewInCtx(connID, remote, client))
+		errCh <- pc.pipe(src, dst, inCtx)
 	}()
 
-	<-errCh
+	if err := <-errCh; errors.Is(err, errReset) {
+		resetConn(client)
+		resetConn(remote)
+	}
+}
+
+// Inject 向活跃连接写入数据包（out：发往服务器，in：发往客户端）
+func (s *Server) Inject(connID string, dir traffic.Direction, payload []byte) error {
+	v, ok := s.conns.Load(connID)
+	if !ok {
+		return ErrConnNotFound
+	}
+	return v.(*proxyConn).inject(dir, payload)
+}
+
+// Conns 返回当前活跃连接
+func (s *Server) Conns() []ConnInfo {
+	var out []ConnInfo
+	s.conns.Range(func(_, v any) bool {
+		out = append(out, v.(*proxyConn).info())
+		return true
+	})
+	return out
+}
+
+// HasConn 连接是否属于本代理
+func (s *Server) HasConn(connID string) bool {
+	_, ok := s.conns.Load(connID)
+	return ok
+}
+
+// SetInterceptor 设置连接拦截器，需在 Serve 之前调用
+func (s *Server) SetInterceptor(i Interceptor) {
+	s.interceptor = i
+}
+
+// SetRedirector 设置目标重定向，需在 Serve 之前调用
+func (s *Server) SetRedirector(r Redirector) {
+	s.redirector = r
+}
+
+// targetCtx 连接目标之前的上下文：目标为域名时没有 DstIP
+func targetCtx(connID string, client net.Conn, target socks.Addr) *traffic.PacketContext {
+	var dst net.Addr
+	host, port, _ := net.SplitHostPort(target.String())
+	p, _ := strconv.Atoi(port)
+	if ip := net.ParseIP(host); ip != nil {
+		dst = &net.TCPAddr{IP: ip, Port: p}
+	}
+	ctx := traffic.NewCtx(connID, traffic.DirectionOut, traffic.ProtocolTCP, client.RemoteAddr(), dst)
+	ctx.DstPort = p
+	ctx.Domain = targetDomain(target)
+	ctx.OrigDst = target.String()
+	return ctx
+}
+
+// targetDomain 返回 SOCKS 目标地址中的域名部分（IP 地址返回空）
+func targetDomain(addr socks.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil || net.ParseIP(host) != nil {
+		return ""
+	}
+	return host
 }
 
 func (s *Server) unwrap(conn net.Conn) net.Conn {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/shadowsocks/go-shadowsocks2/core"
 	"net"
+	"os"
 	"proxy-system-backend/internal/traffic"
 	"testing"
 )
@@ -33,14 +34,17 @@ func (t TestHook) OnPacket(ctx *traffic.PacketContext) bool {
 	return true
 }
 
+// TestNewServer 手动测试：在 :8388 上一直运行服务器，设置 SS_TEST_SERVER=1 时执行
 func TestNewServer(t *testing.T) {
+	if os.Getenv("SS_TEST_SERVER") == "" {
+		t.Skip("manual test, set SS_TEST_SERVER=1 to run a server on :8388")
+	}
 
 	cipher, _ := core.PickCipher("aes-256-gcm", nil, "test-password")
 	ln, _ := net.Listen("tcp", ":8388")
-	s := NewServer(ln, NewDirectDialer(), func(connID string) traffic.TrafficHook {
-
+	s := NewServer(ln, cipher, NewDirectDialer(), func(connID string) traffic.TrafficHook {
+		return TestHook{}
 	})
-	s.cipher = cipher
 
 	for {
 		c, _ := ln.Accept()
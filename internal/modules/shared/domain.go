@@ -0,0 +1,32 @@
+package shared
+
+import "strings"
+
+// MatchDomain 判断 host 是否命中域名模式
+// 支持精确匹配、"*.example.com"（任意子域名，含 example.com 本身）以及单独的 "*"
+func MatchDomain(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(pattern), "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if pattern == "" || host == "" {
+		return false
+	}
+	if pattern == "*" {
+		return true
+	}
+
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == suffix || strings.HasSuffix(host, "."+suffix)
+	}
+
+	return host == pattern
+}
+
+// MatchAnyDomain 命中任意一个模式即返回 true
+func MatchAnyDomain(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if MatchDomain(p, host) {
+			return true
+		}
+	}
+	return false
+}
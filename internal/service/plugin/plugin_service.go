@@ -77,82 +77,14 @@ func (ps *PluginService) createTables() error {
 	)
 	`
 
-	// 创建过滤规则表
-	filterRulesQuery := `
-	CREATE TABLE IF NOT EXISTS filter_rules (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		action TEXT NOT NULL,
-		direction TEXT NOT NULL,
-		source_ips TEXT, -- JSON格式存储
-		dest_ips TEXT,   -- JSON格式存储
-		source_ports TEXT, -- JSON格式存储
-		dest_ports TEXT,   -- JSON格式存储
-		enabled INTEGER NOT NULL DEFAULT 1,
-		priority INTEGER NOT NULL DEFAULT 0,
-		description TEXT,
-		created_at TEXT NOT NULL,
-		updated_at TEXT NOT NULL,
-		created_at_db TEXT DEFAULT CURRENT_TIMESTAMP
-	)
-	`
-
-	// 创建过滤配置表
-	filterConfigQuery := `
-	CREATE TABLE IF NOT EXISTS filter_config (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		enabled INTEGER NOT NULL DEFAULT 0,
-		default_action TEXT NOT NULL,
-		rules TEXT, -- JSON格式存储完整规则列表
-		update_time TEXT NOT NULL,
-		created_at_db TEXT DEFAULT CURRENT_TIMESTAMP
-	)
-	`
-
-	// 执行建表语句
+	// 旧版的 filter_rules / filter_config 不再创建，已有数据用 POST /api/filter/migrate 迁移
 	if _, err := ps.db.Exec(pluginsQuery); err != nil {
 		return fmt.Errorf("failed to create plugins table: %w", err)
 	}
 
-	if _, err := ps.db.Exec(filterRulesQuery); err != nil {
-		return fmt.Errorf("failed to create filter_rules table: %w", err)
-	}
-
-	if _, err := ps.db.Exec(filterConfigQuery); err != nil {
-		return fmt.Errorf("failed to create filter_config table: %w", err)
-	}
-
-	// 插入默认过滤配置
-	if err := ps.insertDefaultFilterConfig(); err != nil {
-		return fmt.Errorf("failed to insert default filter config: %w", err)
-	}
-
 	return nil
 }
 
-// insertDefaultFilterConfig 插入默认过滤配置
-func (ps *PluginService) insertDefaultFilterConfig() error {
-	// 检查是否已存在配置
-	var count int
-	err := ps.db.QueryRow("SELECT COUNT(*) FROM filter_config").Scan(&count)
-	if err != nil {
-		return err
-	}
-
-	if count > 0 {
-		return nil // 已存在配置，无需插入
-	}
-
-	// 插入默认配置
-	query := `
-		INSERT INTO filter_config (enabled, default_action, rules, update_time)
-		VALUES (?, ?, ?, ?)
-	`
-
-	_, err = ps.db.Exec(query, 0, "allow", "[]", time.Now().Format(time.RFC3339))
-	return err
-}
-
 // UploadPlugin 上传插件
 func (ps *PluginService) UploadPlugin(name string, file io.Reader, metadata PluginUploadRequest) (*PluginInfo, error) {
 	// 检查插件是否已存在
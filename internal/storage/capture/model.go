@@ -0,0 +1,39 @@
+package capturestore
+
+// SessionModel 一个连接的录制会话
+type SessionModel struct {
+	ConnID  string `gorm:"primaryKey;size:64"`
+	ProxyID string `gorm:"size:64;index"`
+	Source  string `gorm:"size:16"` // live / import
+
+	Protocol string `gorm:"size:8"` // tcp / udp
+
+	Client string
+	Dst    string `gorm:"index"` // ip:port
+	Domain string `gorm:"index"`
+	Tags   string // 逗号分隔
+
+	StartedAt int64 `gorm:"index"` // unix 毫秒
+	EndedAt   int64
+
+	Packets int64
+	Bytes   int64
+}
+
+// PacketModel 数据包索引，内容存放在 segment 文件中
+type PacketModel struct {
+	ID      int64  `gorm:"primaryKey"`
+	ProxyID string `gorm:"size:64;index:idx_capture_proxy_time"`
+	ConnID  string `gorm:"size:64;index:idx_capture_conn_time"`
+	Time    int64  `gorm:"index:idx_capture_conn_time;index:idx_capture_proxy_time"` // unix 微秒
+	Dst     string `gorm:"index"`
+
+	Direction uint8
+	Length    int
+	Plugin    string
+	Injected  bool
+
+	Segment string
+	Offset  int64
+	Size    int
+}
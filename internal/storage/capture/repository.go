@@ -0,0 +1,25 @@
+package capturestore
+
+import "context"
+
+// SessionQuery 会话查询条件，零值字段不参与过滤
+type SessionQuery struct {
+	ProxyID string
+	Dst     string
+	Domain  string
+	From    int64 // unix 毫秒
+	To      int64
+
+	Offset int
+	Limit  int
+}
+
+type Repository interface {
+	SaveSession(ctx context.Context, s *SessionModel) error
+	GetSession(ctx context.Context, connID string) (*SessionModel, error)
+	ListSessions(ctx context.Context, q SessionQuery) ([]SessionModel, int64, error)
+
+	AddPackets(ctx context.Context, packets []PacketModel) error
+	ListPackets(ctx context.Context, connID string, offset, limit int) ([]PacketModel, int64, error)
+	GetPacket(ctx context.Context, id int64) (*PacketModel, error)
+}
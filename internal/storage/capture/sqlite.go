@@ -0,0 +1,89 @@
+package capturestore
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type SQLiteRepo struct {
+	db *gorm.DB
+}
+
+func NewSQLiteRepo(db *gorm.DB) *SQLiteRepo {
+	return &SQLiteRepo{db: db}
+}
+
+func (r *SQLiteRepo) SaveSession(ctx context.Context, s *SessionModel) error {
+	return r.db.WithContext(ctx).Save(s).Error
+}
+
+func (r *SQLiteRepo) GetSession(ctx context.Context, connID string) (*SessionModel, error) {
+	var s SessionModel
+	if err := r.db.WithContext(ctx).First(&s, "conn_id = ?", connID).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SQLiteRepo) ListSessions(ctx context.Context, q SessionQuery) ([]SessionModel, int64, error) {
+	tx := r.db.WithContext(ctx).Model(&SessionModel{})
+	if q.ProxyID != "" {
+		tx = tx.Where("proxy_id = ?", q.ProxyID)
+	}
+	if q.Dst != "" {
+		tx = tx.Where("dst = ?", q.Dst)
+	}
+	if q.Domain != "" {
+		tx = tx.Where("domain = ?", q.Domain)
+	}
+	if q.From > 0 {
+		tx = tx.Where("started_at >= ?", q.From)
+	}
+	if q.To > 0 {
+		tx = tx.Where("started_at <= ?", q.To)
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var list []SessionModel
+	err := tx.Order("started_at desc").
+		Offset(q.Offset).
+		Limit(q.Limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+func (r *SQLiteRepo) AddPackets(ctx context.Context, packets []PacketModel) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(packets, 200).Error
+}
+
+func (r *SQLiteRepo) ListPackets(ctx context.Context, connID string, offset, limit int) ([]PacketModel, int64, error) {
+	tx := r.db.WithContext(ctx).Model(&PacketModel{}).Where("conn_id = ?", connID)
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var list []PacketModel
+	err := tx.Order("time asc, id asc").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+func (r *SQLiteRepo) GetPacket(ctx context.Context, id int64) (*PacketModel, error) {
+	var p PacketModel
+	if err := r.db.WithContext(ctx).First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
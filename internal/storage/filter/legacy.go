@@ -0,0 +1,96 @@
+package filterstore
+
+import (
+	"context"
+	"net/url"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// LegacyRuleModel 旧版 packet.PacketFilter 的 filter_rules 表（plugins.db），IP / 端口列为 JSON
+type LegacyRuleModel struct {
+	ID          int64
+	Name        string
+	Action      string
+	Direction   string
+	SourceIPs   string `gorm:"column:source_ips"`
+	DestIPs     string `gorm:"column:dest_ips"`
+	SourcePorts string
+	DestPorts   string
+	Enabled     bool
+	Priority    int
+	Description string
+}
+
+func (LegacyRuleModel) TableName() string {
+	return "filter_rules"
+}
+
+// LegacyConfigModel 旧版的 filter_config 表，Rules 为完整规则列表的 JSON
+type LegacyConfigModel struct {
+	ID            int64
+	Enabled       bool
+	DefaultAction string
+	Rules         string
+}
+
+func (LegacyConfigModel) TableName() string {
+	return "filter_config"
+}
+
+// LegacyRepo 只读访问旧版过滤数据，用于迁移
+type LegacyRepo struct {
+	db *gorm.DB
+}
+
+func NewLegacyRepo(db *gorm.DB) *LegacyRepo {
+	return &LegacyRepo{db: db}
+}
+
+// OpenLegacy 以只读方式打开旧版数据库文件
+func OpenLegacy(path string) (*LegacyRepo, func() error, error) {
+	// 路径转义后放入 URI，其中的 ? / # 不会被当作参数
+	db, err := gorm.Open(sqlite.Open("file:"+url.PathEscape(path)+"?mode=ro"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	// 打开时不会检查文件，先查询一次
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, nil, err
+	}
+	return NewLegacyRepo(db), sqlDB.Close, nil
+}
+
+// Rules 读取 filter_rules，表不存在时返回空
+func (r *LegacyRepo) Rules(ctx context.Context) ([]LegacyRuleModel, error) {
+	db := r.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&LegacyRuleModel{}) {
+		return nil, nil
+	}
+	var rules []LegacyRuleModel
+	err := db.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// Config 读取最新的 filter_config，表不存在或为空时返回 nil
+func (r *LegacyRepo) Config(ctx context.Context) (*LegacyConfigModel, error) {
+	db := r.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&LegacyConfigModel{}) {
+		return nil, nil
+	}
+	var configs []LegacyConfigModel
+	if err := db.Order("id desc").Limit(1).Find(&configs).Error; err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return &configs[0], nil
+}
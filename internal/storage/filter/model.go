@@ -18,8 +18,60 @@ type RuleModel struct {
 	DstCIDR string
 	SrcPort string
 	DstPort string
+	Geo     string // JSON，目标国家代码
+	ASN     string // JSON，目标 ASN
+
+	Payload string // JSON，负载匹配条件
+	Decoded string // JSON，解码结果条件
+	Expr    string // 过滤表达式
 
 	Tags string
 
+	RuleSet string `gorm:"index"` // 所属规则集，空为默认规则集
+
+	Mirror   string // JSON，action=mirror 时的镜像目标
+	Redirect string // JSON，action=redirect 时的新目标
+
+	// 生效时间（Unix 秒，0 表示不限）
+	StartAt int64
+	EndAt   int64
+	Windows string // JSON，周期生效窗口
+	TTL     int64  // 秒，从 CreatedAt 起算
+	Expired bool   `gorm:"index"` // EndAt / TTL 已过，不再加载
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RuleSetModel 命名规则集
+type RuleSetModel struct {
+	ID          int64  `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex"`
+	Description string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// 规则集的分配范围
+const (
+	ScopeGlobal = "global" // 所有代理
+	ScopeProxy  = "proxy"  // Target 为代理 ID 或名称
+	ScopeUser   = "user"   // Target 为代理的用户名
+)
+
+// RuleSetAssignmentModel 规则集分配
+type RuleSetAssignmentModel struct {
+	ID      int64  `gorm:"primaryKey"`
+	RuleSet string `gorm:"index"`
+	Scope   string
+	Target  string
+}
+
+// SettingsModel 过滤全局设置，只有一行（ID = 1）
+type SettingsModel struct {
+	ID            int64  `gorm:"primaryKey"`
+	DefaultAction string // 没有终结规则命中时的结果，空为 allow
+
 	UpdatedAt time.Time
 }
@@ -4,6 +4,26 @@ import "context"
 
 type RuleRepository interface {
 	List(ctx context.Context) ([]RuleModel, error)
+	Get(ctx context.Context, id int64) (*RuleModel, error)
 	Save(ctx context.Context, r *RuleModel) error
 	Delete(ctx context.Context, id int64) error
+	DeleteAll(ctx context.Context) error
+
+	// Settings 读取全局设置，尚未保存过时返回零值
+	Settings(ctx context.Context) (*SettingsModel, error)
+	SaveSettings(ctx context.Context, m *SettingsModel) error
+
+	// Transaction 在同一事务中执行 fn，fn 返回错误时全部回滚
+	Transaction(ctx context.Context, fn func(repo RuleRepository) error) error
+}
+
+type RuleSetRepository interface {
+	List(ctx context.Context) ([]RuleSetModel, error)
+	Get(ctx context.Context, name string) (*RuleSetModel, error)
+	// Save 保存规则集，并用 assignments 替换它原有的分配
+	Save(ctx context.Context, m *RuleSetModel, assignments []RuleSetAssignmentModel) error
+	// Delete 删除规则集及其分配
+	Delete(ctx context.Context, name string) error
+
+	Assignments(ctx context.Context) ([]RuleSetAssignmentModel, error)
 }
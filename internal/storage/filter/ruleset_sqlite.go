@@ -0,0 +1,67 @@
+package filterstore
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type SQLiteRuleSetRepo struct {
+	db *gorm.DB
+}
+
+func NewSQLiteRuleSetRepo(db *gorm.DB) *SQLiteRuleSetRepo {
+	return &SQLiteRuleSetRepo{db: db}
+}
+
+func (r *SQLiteRuleSetRepo) List(ctx context.Context) ([]RuleSetModel, error) {
+	var sets []RuleSetModel
+	err := r.db.WithContext(ctx).
+		Order("name").
+		Find(&sets).Error
+	return sets, err
+}
+
+func (r *SQLiteRuleSetRepo) Get(ctx context.Context, name string) (*RuleSetModel, error) {
+	var m RuleSetModel
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *SQLiteRuleSetRepo) Save(ctx context.Context, m *RuleSetModel, assignments []RuleSetAssignmentModel) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(m).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("rule_set = ?", m.Name).Delete(&RuleSetAssignmentModel{}).Error; err != nil {
+			return err
+		}
+		for i := range assignments {
+			assignments[i].ID = 0
+			assignments[i].RuleSet = m.Name
+		}
+		if len(assignments) == 0 {
+			return nil
+		}
+		return tx.Create(&assignments).Error
+	})
+}
+
+func (r *SQLiteRuleSetRepo) Delete(ctx context.Context, name string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rule_set = ?", name).Delete(&RuleSetAssignmentModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("name = ?", name).Delete(&RuleSetModel{}).Error
+	})
+}
+
+func (r *SQLiteRuleSetRepo) Assignments(ctx context.Context) ([]RuleSetAssignmentModel, error) {
+	var out []RuleSetAssignmentModel
+	err := r.db.WithContext(ctx).
+		Order("id").
+		Find(&out).Error
+	return out, err
+}
@@ -17,11 +17,19 @@ func NewSQLiteRepo(db *gorm.DB) *SQLiteRepo {
 func (r *SQLiteRepo) List(ctx context.Context) ([]RuleModel, error) {
 	var rules []RuleModel
 	err := r.db.WithContext(ctx).
-		Order("priority desc").
+		Order("priority desc, id").
 		Find(&rules).Error
 	return rules, err
 }
 
+func (r *SQLiteRepo) Get(ctx context.Context, id int64) (*RuleModel, error) {
+	var m RuleModel
+	if err := r.db.WithContext(ctx).First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 func (r *SQLiteRepo) Save(ctx context.Context, m *RuleModel) error {
 	return r.db.WithContext(ctx).Save(m).Error
 }
@@ -30,3 +38,26 @@ func (r *SQLiteRepo) Delete(ctx context.Context, id int64) error {
 	return r.db.WithContext(ctx).
 		Delete(&RuleModel{}, id).Error
 }
+
+func (r *SQLiteRepo) DeleteAll(ctx context.Context) error {
+	return r.db.WithContext(ctx).
+		Where("1 = 1").
+		Delete(&RuleModel{}).Error
+}
+
+func (r *SQLiteRepo) Settings(ctx context.Context) (*SettingsModel, error) {
+	var m SettingsModel
+	err := r.db.WithContext(ctx).Limit(1).Find(&m, 1).Error
+	return &m, err
+}
+
+func (r *SQLiteRepo) SaveSettings(ctx context.Context, m *SettingsModel) error {
+	m.ID = 1
+	return r.db.WithContext(ctx).Save(m).Error
+}
+
+func (r *SQLiteRepo) Transaction(ctx context.Context, fn func(repo RuleRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&SQLiteRepo{db: tx})
+	})
+}
@@ -1,6 +1,7 @@
 package traffic
 
 import (
+	"fmt"
 	"net"
 	"time"
 )
@@ -28,6 +29,20 @@ func (d Direction) String() string {
 	}
 }
 
+// ParseDirection 解析 "out" / "in"（以及 "unknown" / ""）
+func ParseDirection(s string) (Direction, error) {
+	switch s {
+	case "out":
+		return DirectionOut, nil
+	case "in":
+		return DirectionIn, nil
+	case "", "unknown":
+		return DirectionUnknown, nil
+	default:
+		return DirectionUnknown, fmt.Errorf("invalid direction %q", s)
+	}
+}
+
 //
 // ===== Protocol（为未来预留，先放好）=====
 //
@@ -72,13 +87,71 @@ type PacketContext struct {
 	DstIP   net.IP `json:"dst_ip"`
 	DstPort int    `json:"dst_port"`
 
+	// 客户端请求的目标域名（SOCKS 地址为 IP 时为空）
+	Domain string `json:"domain,omitempty"`
+
+	// 客户端请求的目标与实际连接的目标（host:port），重定向时两者不同；
+	// DstAddr / DstIP 为实际连接的地址
+	OrigDst      string `json:"orig_dst,omitempty"`
+	EffectiveDst string `json:"effective_dst,omitempty"`
+
+	// 重定向且改写主机名时上游看到的主机名（中间人的 TLS SNI、HTTP Host），空表示沿用客户端的
+	UpstreamHost string `json:"upstream_host,omitempty"`
+
+	// 是否经过 TLS 中间人解密（Payload 为明文）
+	TLSIntercepted bool `json:"tls_intercepted,omitempty"`
+
+	// 两端地址的国家 / ASN（GeoIP 数据库未加载或未收录时为空）
+	SrcGeo *GeoInfo `json:"src_geo,omitempty"`
+	DstGeo *GeoInfo `json:"dst_geo,omitempty"`
+
+	// 由接口注入的数据包（不是客户端 / 服务器发出的）
+	Injected bool `json:"injected,omitempty"`
+
+	// 过滤规则 tag:<label> 打上的标签
+	Tags []string `json:"tags,omitempty"`
+
+	// 适用于该连接的过滤规则集（默认规则集之外），由代理 / 用户 / 全局分配决定
+	RuleSets []string `json:"rule_sets,omitempty"`
+
+	// 代理开启 trace_filter 时的规则求值过程（*filter.Trace）
+	FilterTrace any `json:"filter_trace,omitempty"`
+
+	// hook 返回 false 时以 TCP RST 断开连接，而不是正常关闭
+	Reset bool `json:"-"`
+
 	// 生命周期
 	StartAt time.Time `json:"start_at"`
 
+	// 数据包时间：离线导入时为抓包时间，实时流量为零值（录制时取当前时间）
+	Time time.Time `json:"time,omitzero"`
+
 	// 可选：当前 packet payload（filter / plugin 用）
 	Payload []byte `json:"payload"`
 }
 
+// ClientView 以客户端 → 服务器视角返回上下文（DirectionIn 时交换两端地址），
+// 用于按连接匹配规则，结果与数据包方向无关
+func (c *PacketContext) ClientView() *PacketContext {
+	if c.Direction != DirectionIn {
+		return c
+	}
+	v := *c
+	v.Direction = DirectionOut
+	v.SrcAddr, v.DstAddr = c.DstAddr, c.SrcAddr
+	v.SrcIP, v.DstIP = c.DstIP, c.SrcIP
+	v.SrcPort, v.DstPort = c.DstPort, c.SrcPort
+	v.SrcGeo, v.DstGeo = c.DstGeo, c.SrcGeo
+	return &v
+}
+
+// GeoInfo IP 地址的地理位置和所属网络
+type GeoInfo struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 两位国家代码（大写）
+	ASN     uint32 `json:"asn,omitempty"`
+	Org     string `json:"org,omitempty"` // ASN 所属组织
+}
+
 //
 // ===== Hook =====
 //
@@ -88,6 +161,11 @@ type TrafficHook interface {
 	OnPacket(ctx *PacketContext) bool
 }
 
+// CloseHook 可选接口：连接结束时回调（录制会话收尾等）
+type CloseHook interface {
+	OnClose()
+}
+
 //
 // ===== Context Factory =====
 //
@@ -149,13 +227,16 @@ func safeRemoteAddr(c net.Conn) net.Addr {
 
 // 只在 Context 创建时解析一次
 func fillIPPort(ctx *PacketContext) {
-	if tcp, ok := ctx.SrcAddr.(*net.TCPAddr); ok {
-		ctx.SrcIP = tcp.IP
-		ctx.SrcPort = tcp.Port
-	}
+	ctx.SrcIP, ctx.SrcPort = addrIPPort(ctx.SrcAddr)
+	ctx.DstIP, ctx.DstPort = addrIPPort(ctx.DstAddr)
+}
 
-	if tcp, ok := ctx.DstAddr.(*net.TCPAddr); ok {
-		ctx.DstIP = tcp.IP
-		ctx.DstPort = tcp.Port
+func addrIPPort(a net.Addr) (net.IP, int) {
+	switch v := a.(type) {
+	case *net.TCPAddr:
+		return v.IP, v.Port
+	case *net.UDPAddr:
+		return v.IP, v.Port
 	}
+	return nil, 0
 }